@@ -4,43 +4,265 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
 	"sso/interanal/config"
+	"sso/migrations"
+	"strconv"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
-	_ "github.com/golang-migrate/migrate/v4/source/github"
-	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 )
 
+const usage = `Usage: migrator [flags] [command]
+
+Commands:
+  up [N]       apply all pending migrations or the next N (default)
+  down N       roll back the last N migrations
+  goto V       migrate up or down to version V
+  force V      set version V without running migrations, to recover
+               from a dirty state; -1 means no version
+  version      print the current version
+  status       print the current version and the pending migrations
+
+Flags:
+`
+
+// dsnEnv overrides the config when --dsn is not set.
+const dsnEnv = "MIGRATOR_DSN"
+
 func main() {
-	var migrationsPath, migrationsTable string
-	var cfg config.ConfigDataBase
-	err := cleanenv.ReadConfig("config/db.yaml", &cfg)
-	if err != nil {
-		panic(err)
-	}
-	flag.StringVar(&migrationsPath, "migrations-path", "", "path to migrations")
+	var configPath, dsn, migrationsPath, migrationsTable string
+	var dryRun bool
+	flag.StringVar(&configPath, "config", "", "path to config file, CONFIG_PATH by default")
+	flag.StringVar(&dsn, "dsn", "", "database URL instead of the config, "+dsnEnv+" by default")
+	flag.StringVar(&migrationsPath, "migrations-path", "", "path to migrations, the ones embedded in the binary by default")
 	flag.StringVar(&migrationsTable, "migrations-table", "migrations", "name of migrations table")
+	flag.BoolVar(&dryRun, "dry-run", false, "print the migrations that up, down or goto would run and exit")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
-	if migrationsPath == "" {
-		panic("storage-path is required")
+	if err := run(configPath, dsn, migrationsPath, migrationsTable, dryRun, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "migrator:", err)
+		os.Exit(1)
+	}
+}
+
+func run(configPath, dsn, migrationsPath, migrationsTable string, dryRun bool, args []string) error {
+	cmd, arg, err := parseCommand(args)
+	if err != nil {
+		return err
+	}
+	dbURL, err := databaseURL(configPath, dsn, migrationsTable)
+	if err != nil {
+		return err
+	}
+	// migrate closes its source, so plan reads another one.
+	migrateSrc, err := openSource(migrationsPath, dbURL)
+	if err != nil {
+		return err
+	}
+	m, err := migrate.NewWithSourceInstance("migrations", migrateSrc, dbURL)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	src, err := openSource(migrationsPath, dbURL)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	switch cmd {
+	case "version":
+		return printVersion(m)
+	case "status":
+		if err := printVersion(m); err != nil {
+			return err
+		}
+		steps, err := plan(m, src, cmd, arg)
+		if err != nil {
+			return err
+		}
+		printSteps("pending:", steps)
+		return nil
+	case "force":
+		if dryRun {
+			fmt.Printf("would set version %d without running migrations\n", arg)
+			return nil
+		}
+		if err := m.Force(arg); err != nil {
+			return err
+		}
+		return printVersion(m)
+	}
+
+	steps, err := plan(m, src, cmd, arg)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		printSteps("would run:", steps)
+		return nil
+	}
+	switch cmd {
+	case "up":
+		if arg > 0 {
+			err = m.Steps(arg)
+		} else {
+			err = m.Up()
+		}
+	case "down":
+		err = m.Steps(-arg)
+	case "goto":
+		err = m.Migrate(uint(arg))
+	}
+	if errors.Is(err, migrate.ErrNoChange) {
+		fmt.Println("no migrations to apply")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return printVersion(m)
+}
+
+// openSource opens the migrations at path or, without a path, the ones
+// embedded in the binary for the database of dbURL. The latter are
+// what storage.migrate_on_start applies, so both run the same files.
+func openSource(path string, dbURL string) (source.Driver, error) {
+	if path != "" {
+		return source.Open("file://" + path)
 	}
-	m, err := migrate.New(
-		"file://"+migrationsPath,
-		fmt.Sprintf("pgx5://%s:%s@%s:%s/%s?x-migrations-table=%s&sslmode=disable", cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.DBName, migrationsTable),
-	)
+	u, err := url.Parse(dbURL)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("invalid database URL: %w", err)
+	}
+	dir := migrations.PostgresDir
+	if u.Scheme == "sqlite3" {
+		dir = migrations.SQLiteDir
 	}
+	return iofs.New(migrations.FS, dir)
+}
+
+// parseCommand returns the command and its number argument. Without
+// a command the migrator applies every pending migration.
+func parseCommand(args []string) (string, int, error) {
+	if len(args) == 0 {
+		return "up", 0, nil
+	}
+	cmd, rest := args[0], args[1:]
+	var needsArg bool
+	switch cmd {
+	case "up":
+		needsArg = false
+	case "down", "goto", "force":
+		needsArg = true
+	case "version", "status":
+		if len(rest) > 0 {
+			return "", 0, fmt.Errorf("%s takes no arguments", cmd)
+		}
+		return cmd, 0, nil
+	default:
+		return "", 0, fmt.Errorf("unknown command %q, see --help", cmd)
+	}
+	if len(rest) == 0 {
+		if needsArg {
+			return "", 0, fmt.Errorf("%s needs a number", cmd)
+		}
+		return cmd, 0, nil
+	}
+	if len(rest) > 1 {
+		return "", 0, fmt.Errorf("%s takes one argument", cmd)
+	}
+	n, err := strconv.Atoi(rest[0])
+	if err != nil {
+		return "", 0, fmt.Errorf("%s: %q is not a number", cmd, rest[0])
+	}
+	switch {
+	case cmd == "force" && n < -1:
+		return "", 0, errors.New("force: version must be -1 or more")
+	case cmd == "goto" && n < 0:
+		return "", 0, errors.New("goto: version must not be negative")
+	case (cmd == "up" || cmd == "down") && n <= 0:
+		return "", 0, fmt.Errorf("%s: number of migrations must be positive", cmd)
+	}
+	return cmd, n, nil
+}
 
-	if err := m.Up(); err != nil {
-		if errors.Is(err, migrate.ErrNoChange) {
-			fmt.Println("no migrations to apply")
-			return
+// databaseURL returns the golang-migrate URL from --dsn, the
+// MIGRATOR_DSN variable or the config, in that order.
+func databaseURL(configPath, dsn, table string) (string, error) {
+	if dsn == "" {
+		dsn = os.Getenv(dsnEnv)
+	}
+	if dsn != "" {
+		return migrateURL(dsn, table)
+	}
+	if configPath == "" {
+		configPath = os.Getenv("CONFIG_PATH")
+	}
+	if configPath == "" {
+		return "", errors.New("one of --dsn, " + dsnEnv + ", --config or CONFIG_PATH is required")
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return "", err
+	}
+	if cfg.Storage.Driver == config.StorageSQLite {
+		// sqlite creates the database file, but not its directory.
+		if err := os.MkdirAll(filepath.Dir(cfg.Storage.SQLitePath), 0o755); err != nil {
+			return "", err
 		}
-		panic(err)
+		return migrateURL("sqlite3://"+cfg.Storage.SQLitePath, table)
+	}
+	return migrateURL(cfg.Database.URL(), table)
+}
+
+// migrateURL switches postgres URLs to the pgx5 driver of
+// golang-migrate and sets the migrations table.
+func migrateURL(dbURL string, table string) (string, error) {
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid database URL: %w", err)
+	}
+	switch u.Scheme {
+	case "postgres", "postgresql":
+		u.Scheme = "pgx5"
+	case "sqlite", "sqlite3":
+		u.Scheme = "sqlite3"
+	default:
+		return "", fmt.Errorf("unsupported database URL scheme %q", u.Scheme)
+	}
+	q := u.Query()
+	q.Set("x-migrations-table", table)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func printVersion(m *migrate.Migrate) error {
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		fmt.Println("no migrations applied")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if dirty {
+		fmt.Printf("version %d (dirty)\n", version)
+		fmt.Println("a migration failed halfway: fix the database by hand, then run force with the last version that is fully applied")
+		return nil
 	}
-	fmt.Println("migrations applied")
+	fmt.Printf("version %d\n", version)
+	return nil
 }
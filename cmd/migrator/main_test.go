@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseCommand проверяет разбор команд и их аргументов.
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		args    []string
+		cmd     string
+		arg     int
+		wantErr bool
+	}{
+		{args: nil, cmd: "up"},
+		{args: []string{"up", "2"}, cmd: "up", arg: 2},
+		{args: []string{"down", "1"}, cmd: "down", arg: 1},
+		{args: []string{"goto", "0"}, cmd: "goto", arg: 0},
+		{args: []string{"force", "-1"}, cmd: "force", arg: -1},
+		{args: []string{"status"}, cmd: "status"},
+		{args: []string{"down"}, wantErr: true},
+		{args: []string{"down", "0"}, wantErr: true},
+		{args: []string{"up", "two"}, wantErr: true},
+		{args: []string{"version", "1"}, wantErr: true},
+		{args: []string{"drop"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		cmd, arg, err := parseCommand(tt.args)
+		if tt.wantErr {
+			assert.Error(t, err, tt.args)
+			continue
+		}
+		require.NoError(t, err, tt.args)
+		assert.Equal(t, tt.cmd, cmd, tt.args)
+		assert.Equal(t, tt.arg, arg, tt.args)
+	}
+}
+
+// TestMigrateURL проверяет, что postgres переключается
+// на драйвер pgx5, а таблица миграций задается в URL.
+func TestMigrateURL(t *testing.T) {
+	got, err := migrateURL("postgres://sso:secret@db:5432/sso?sslmode=disable", "migrations")
+	require.NoError(t, err)
+	assert.Equal(t, "pgx5://sso:secret@db:5432/sso?sslmode=disable&x-migrations-table=migrations", got)
+
+	got, err = migrateURL("sqlite3:///var/lib/sso/sso.db", "migrations")
+	require.NoError(t, err)
+	assert.Equal(t, "sqlite3:///var/lib/sso/sso.db?x-migrations-table=migrations", got)
+
+	_, err = migrateURL("mysql://sso@db/sso", "migrations")
+	assert.Error(t, err)
+}
+
+// TestPlan проверяет, какие файлы миграций выполнят
+// up, down и goto.
+func TestPlan(t *testing.T) {
+	src, err := source.Open("file://../../migrations/sqlite")
+	require.NoError(t, err)
+	t.Cleanup(func() { src.Close() })
+
+	steps, err := planUp(src, 0, false, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1_init.up", "2_add_is_admin_to_users_tbl.up"}, names(steps))
+
+	steps, err = planUp(src, 8, true, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"9_add_audit_event.up", "10_drop_app_secret_unique.up", "11_remove_test_app.up", "12_add_sessions_revoked_at.up"}, names(steps))
+	_, err = planUp(src, 8, true, 5)
+	assert.Error(t, err)
+
+	steps, err = planDown(src, 3, true, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3_add_app_to_app_table.down", "2_add_is_admin_to_users_tbl.down"}, names(steps))
+	_, err = planDown(src, 3, true, 4)
+	assert.Error(t, err)
+
+	steps, err = planGoto(src, 2, true, 4)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"3_add_app_to_app_table.up", "4_add_organization.up"}, names(steps))
+	steps, err = planGoto(src, 4, true, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"4_add_organization.down"}, names(steps))
+	_, err = planGoto(src, 4, true, 42)
+	assert.EqualError(t, err, "no migration with version 42")
+}
+
+func names(steps []step) []string {
+	out := make([]string, len(steps))
+	for i, s := range steps {
+		out[i] = s.String()
+	}
+	return out
+}
+
+// TestOpenSourceDefaultsToEmbedded проверяет, что без
+// --migrations-path читаются встроенные миграции нужной базы.
+func TestOpenSourceDefaultsToEmbedded(t *testing.T) {
+	for _, dbURL := range []string{"pgx5://db/sso", "sqlite3:///var/lib/sso/sso.db"} {
+		src, err := openSource("", dbURL)
+		require.NoError(t, err, dbURL)
+		first, err := src.First()
+		require.NoError(t, err, dbURL)
+		assert.Equal(t, uint(1), first, dbURL)
+		require.NoError(t, src.Close())
+	}
+}
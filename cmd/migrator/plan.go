@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+// step is one migration file that a command would run.
+type step struct {
+	version    uint
+	identifier string
+	up         bool
+}
+
+func (s step) String() string {
+	direction := "down"
+	if s.up {
+		direction = "up"
+	}
+	return fmt.Sprintf("%d_%s.%s", s.version, s.identifier, direction)
+}
+
+// plan returns the migrations cmd would run. status plans a plain up.
+// It fails before anything runs when the migrations can't be reached,
+// for example when down is asked for more than are applied.
+func plan(m *migrate.Migrate, src source.Driver, cmd string, arg int) ([]step, error) {
+	current, dirty, err := m.Version()
+	applied := true
+	if errors.Is(err, migrate.ErrNilVersion) {
+		applied = false
+	} else if err != nil {
+		return nil, err
+	}
+	if dirty && cmd != "status" {
+		return nil, fmt.Errorf("database version %d is dirty, run force first", current)
+	}
+	switch cmd {
+	case "up", "status":
+		return planUp(src, current, applied, arg)
+	case "down":
+		return planDown(src, current, applied, arg)
+	case "goto":
+		return planGoto(src, current, applied, uint(arg))
+	}
+	return nil, nil
+}
+
+func printSteps(header string, steps []step) {
+	if len(steps) == 0 {
+		fmt.Println("no migrations to apply")
+		return
+	}
+	fmt.Println(header)
+	for _, s := range steps {
+		fmt.Println("  " + s.String())
+	}
+}
+
+// planUp returns the next n up migrations after current, or all of
+// them when n is 0.
+func planUp(src source.Driver, current uint, applied bool, n int) ([]step, error) {
+	var steps []step
+	version, err := src.First()
+	if applied {
+		version, err = src.Next(current)
+	}
+	for err == nil && (n == 0 || len(steps) < n) {
+		s, readErr := readStep(src, version, true)
+		if readErr != nil {
+			return nil, readErr
+		}
+		steps = append(steps, s)
+		version, err = src.Next(version)
+	}
+	if err != nil && !isEnd(err) {
+		return nil, err
+	}
+	if n > 0 && len(steps) < n {
+		return nil, fmt.Errorf("only %d migrations to apply, not %d", len(steps), n)
+	}
+	return steps, nil
+}
+
+// planDown returns the n down migrations from current backwards.
+func planDown(src source.Driver, current uint, applied bool, n int) ([]step, error) {
+	var steps []step
+	version := current
+	for applied && len(steps) < n {
+		s, err := readStep(src, version, false)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, s)
+		prev, err := src.Prev(version)
+		if isEnd(err) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		version = prev
+	}
+	if len(steps) < n {
+		return nil, fmt.Errorf("only %d migrations to roll back, not %d", len(steps), n)
+	}
+	return steps, nil
+}
+
+// planGoto returns the migrations between current and target.
+func planGoto(src source.Driver, current uint, applied bool, target uint) ([]step, error) {
+	r, _, err := src.ReadUp(target)
+	if isEnd(err) {
+		return nil, fmt.Errorf("no migration with version %d", target)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.Close()
+	if !applied || target > current {
+		var steps []step
+		all, err := planUp(src, current, applied, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range all {
+			if s.version > target {
+				break
+			}
+			steps = append(steps, s)
+		}
+		return steps, nil
+	}
+	var steps []step
+	version := current
+	for version > target {
+		s, err := readStep(src, version, false)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, s)
+		version, err = src.Prev(version)
+		if isEnd(err) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return steps, nil
+}
+
+func readStep(src source.Driver, version uint, up bool) (step, error) {
+	read := src.ReadDown
+	if up {
+		read = src.ReadUp
+	}
+	r, identifier, err := read(version)
+	if err != nil {
+		return step{}, fmt.Errorf("migration %d: %w", version, err)
+	}
+	r.Close()
+	return step{version: version, identifier: identifier, up: up}, nil
+}
+
+// isEnd reports whether err means there is no next or previous migration.
+func isEnd(err error) bool {
+	return errors.Is(err, os.ErrNotExist) || errors.Is(err, fs.ErrNotExist)
+}
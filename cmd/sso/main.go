@@ -2,49 +2,82 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
 	"sso/interanal/app"
 	"sso/interanal/config"
+	"sso/interanal/logging"
+	"strings"
 	"syscall"
 )
 
-const (
-	EnvLocal = "local"
-	EnvProd  = "prod"
-)
-
 func main() {
 	ctx := context.Background()
-	cfg := config.MustLoad()
-	dbCfg := config.MustLoadDBConfig("./config/db.yaml")
-	logger := setupLogger(cfg.Env)
+	path := config.MustFetchPath()
+	cfg := config.MustLoadByPath(path)
+	level := new(slog.LevelVar)
+	level.Set(cfg.Log.SlogLevel())
+	output, closeOutput, err := logging.Open(cfg.Log.Output)
+	if err != nil {
+		panic(err)
+	}
+	defer closeOutput()
+	logger := logging.New(logging.Options{Level: level, Format: cfg.Log.Format, Output: output})
 	logger.LogAttrs(
 		ctx,
 		slog.LevelInfo,
 		"starting application",
-		slog.String("with config", fmt.Sprintf("%+v", cfg)),
+		slog.Any("with config", config.Redacted(cfg)),
 	)
-	dbURL := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", dbCfg.User, dbCfg.Password, dbCfg.Host, dbCfg.Port, dbCfg.DBName)
-	grpcApp := app.New(ctx, logger, cfg.GRPC.Port, dbURL, cfg.TokenTTL)
-	go grpcApp.GrpcServer.MustRun()
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
-	<-stop
-	grpcApp.GrpcServer.Stop()
-	grpcApp.Conn.Stop(ctx)
+	application := app.New(ctx, logger, cfg)
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	go reloadOnSIGHUP(ctx, logger, path, cfg, level, application)
+	if err := application.Run(ctx); err != nil {
+		logger.Error("application stopped with error", slog.String("err", err.Error()))
+		os.Exit(1)
+	}
 	logger.Info("application stopped")
 }
 
-func setupLogger(env string) *slog.Logger {
-	var log *slog.Logger
-	switch env {
-	case EnvLocal:
-		log = slog.New(slog.NewTextHandler(os.Stdout, nil))
-	case EnvProd:
-		log = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+// reloadOnSIGHUP rereads the config on every SIGHUP and applies it
+// only when all changed fields can be applied live.
+func reloadOnSIGHUP(
+	ctx context.Context,
+	logger *slog.Logger,
+	path string,
+	cfg *config.Config,
+	level *slog.LevelVar,
+	application *app.App,
+) {
+	const op = "main.reloadOnSIGHUP"
+	logger = logger.With(slog.String("op", op))
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+		logger.Info("reloading config", slog.String("path", path))
+		next, err := config.Load(path)
+		if err != nil {
+			logger.Error("config reload failed, keeping the current config", slog.String("err", err.Error()))
+			continue
+		}
+		if fields := cfg.RestartRequired(next); len(fields) > 0 {
+			logger.Error(
+				"config reload rejected, these fields require a restart",
+				slog.String("fields", strings.Join(fields, ", ")),
+			)
+			continue
+		}
+		level.Set(next.Log.SlogLevel())
+		application.Reload(next)
+		cfg = next
+		logger.Info("config reloaded", slog.Any("with config", config.Redacted(cfg)))
 	}
-	return log
 }
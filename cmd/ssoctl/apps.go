@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	adminv1 "sso/gen/sso/admin/v1"
+	"strconv"
+)
+
+type appResult struct {
+	Id                 int32  `json:"id"`
+	OrgId              int64  `json:"org_id"`
+	Name               string `json:"name"`
+	AllowAutoProvision bool   `json:"allow_auto_provision"`
+	// Secret is set only when it was generated.
+	Secret string `json:"secret,omitempty"`
+}
+
+func toAppResult(app *adminv1.App) appResult {
+	return appResult{
+		Id:                 app.GetId(),
+		OrgId:              app.GetOrgId(),
+		Name:               app.GetName(),
+		AllowAutoProvision: app.GetAllowAutoProvision(),
+	}
+}
+
+func appTable(apps []appResult) table {
+	t := table{header: []string{"ID", "ORG", "NAME", "AUTO_PROVISION"}}
+	for _, app := range apps {
+		t.rows = append(t.rows, []string{
+			strconv.Itoa(int(app.Id)),
+			strconv.FormatInt(app.OrgId, 10),
+			app.Name,
+			strconv.FormatBool(app.AllowAutoProvision),
+		})
+	}
+	return t
+}
+
+func runAppsList(ctx context.Context, args []string, stdout io.Writer) error {
+	var client clientFlags
+	flags := flag.NewFlagSet("apps list", flag.ContinueOnError)
+	client.register(flags)
+	orgId := flags.Int64("org-id", 0, "organization of the apps")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *orgId == 0 {
+		return errors.New("--org-id is required")
+	}
+	conn, err := client.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := client.callContext(ctx, 0)
+	defer cancel()
+	resp, err := adminv1.NewAdminServiceClient(conn).ListApps(ctx, &adminv1.ListAppsRequest{OrgId: *orgId})
+	if err != nil {
+		return callError(err)
+	}
+	result := make([]appResult, 0, len(resp.GetApps()))
+	for _, app := range resp.GetApps() {
+		result = append(result, toAppResult(app))
+	}
+	return printResult(stdout, client.output, result, appTable(result))
+}
+
+func runAppsCreate(ctx context.Context, args []string, stdout io.Writer) error {
+	var client clientFlags
+	flags := flag.NewFlagSet("apps create", flag.ContinueOnError)
+	client.register(flags)
+	id := flags.Int("id", 0, "id of the app")
+	orgId := flags.Int64("org-id", 0, "organization of the app")
+	name := flags.String("name", "", "name of the app")
+	autoProvision := flags.Bool("auto-provision", false, "let passwordless login create unknown users")
+	secret := flags.String("secret", "", "secret of the app, "+appSecretEnv+" by default; generated when both are empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *id == 0 {
+		return errors.New("--id is required")
+	}
+	if *orgId == 0 {
+		return errors.New("--org-id is required")
+	}
+	if *name == "" {
+		return errors.New("--name is required")
+	}
+	if *secret == "" {
+		*secret = os.Getenv(appSecretEnv)
+	}
+	conn, err := client.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := client.callContext(ctx, 0)
+	defer cancel()
+	resp, err := adminv1.NewAdminServiceClient(conn).CreateApp(ctx, &adminv1.CreateAppRequest{
+		App: &adminv1.App{
+			Id:                 int32(*id),
+			OrgId:              *orgId,
+			Name:               *name,
+			AllowAutoProvision: *autoProvision,
+		},
+		Secret: *secret,
+	})
+	if err != nil {
+		return callError(err)
+	}
+	result := toAppResult(resp.GetApp())
+	result.Secret = resp.GetSecret()
+	t := appTable([]appResult{result})
+	if result.Secret != "" {
+		t.header = append(t.header, "SECRET")
+		t.rows[0] = append(t.rows[0], result.Secret)
+	}
+	return printResult(stdout, client.output, result, t)
+}
+
+func runAppsUpdate(ctx context.Context, args []string, stdout io.Writer) error {
+	var client clientFlags
+	flags := flag.NewFlagSet("apps update", flag.ContinueOnError)
+	client.register(flags)
+	id := flags.Int("id", 0, "id of the app")
+	name := flags.String("name", "", "new name of the app")
+	autoProvision := flags.Bool("auto-provision", false, "let passwordless login create unknown users; replaces the current value")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *id == 0 {
+		return errors.New("--id is required")
+	}
+	if *name == "" {
+		return errors.New("--name is required")
+	}
+	conn, err := client.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := client.callContext(ctx, 0)
+	defer cancel()
+	resp, err := adminv1.NewAdminServiceClient(conn).UpdateApp(ctx, &adminv1.UpdateAppRequest{
+		AppId:              int32(*id),
+		Name:               *name,
+		AllowAutoProvision: *autoProvision,
+	})
+	if err != nil {
+		return callError(err)
+	}
+	result := toAppResult(resp.GetApp())
+	return printResult(stdout, client.output, result, appTable([]appResult{result}))
+}
+
+type rotateSecretResult struct {
+	Id     int    `json:"id"`
+	Secret string `json:"secret"`
+}
+
+// runAppsRotateSecret prints only the new secret in the table form, so
+// it can be captured by a script.
+func runAppsRotateSecret(ctx context.Context, args []string, stdout io.Writer) error {
+	var client clientFlags
+	flags := flag.NewFlagSet("apps rotate-secret", flag.ContinueOnError)
+	client.register(flags)
+	id := flags.Int("id", 0, "id of the app")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *id == 0 {
+		return errors.New("--id is required")
+	}
+	conn, err := client.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := client.callContext(ctx, 0)
+	defer cancel()
+	resp, err := adminv1.NewAdminServiceClient(conn).RotateAppSecret(ctx, &adminv1.RotateAppSecretRequest{AppId: int32(*id)})
+	if err != nil {
+		return callError(err)
+	}
+	result := rotateSecretResult{Id: *id, Secret: resp.GetSecret()}
+	return printResult(stdout, client.output, result, table{rows: [][]string{{result.Secret}}})
+}
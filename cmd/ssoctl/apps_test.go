@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestApps проверяет создание, обновление, ротацию секрета
+// и список приложений через админский сервис.
+func TestApps(t *testing.T) {
+	certs := newTestCerts(t)
+	addr := startTestServer(t, certs)
+	call := certs.runner(addr)
+
+	out, err := call("apps", "create", "--id", "2", "--org-id", "1", "--name", "web", "--output", "json")
+	require.NoError(t, err)
+	var created appResult
+	require.NoError(t, json.Unmarshal([]byte(out), &created))
+	assert.Equal(t, "web", created.Name)
+	assert.NotEmpty(t, created.Secret)
+
+	out, err = call("apps", "create", "--id", "3", "--org-id", "1", "--name", "mobile", "--secret", "given")
+	require.NoError(t, err)
+	assert.Equal(t, "ID  ORG  NAME    AUTO_PROVISION\n3   1    mobile  false\n", out)
+
+	_, err = call("apps", "update", "--id", "2", "--name", "portal", "--auto-provision")
+	require.NoError(t, err)
+	secret, err := call("apps", "rotate-secret", "--id", "2")
+	require.NoError(t, err)
+	assert.NotEqual(t, created.Secret+"\n", secret)
+
+	out, err = call("apps", "list", "--org-id", "1")
+	require.NoError(t, err)
+	assert.Equal(t, "ID  ORG  NAME    AUTO_PROVISION\n2   1    portal  true\n3   1    mobile  false\n", out)
+
+	_, err = call("apps", "create", "--id", "2", "--org-id", "1", "--name", "other")
+	assert.EqualError(t, err, "AlreadyExists: app already exists")
+}
+
+// TestAdminNeedsClientCert проверяет, что без клиентского
+// сертификата админские команды не доходят до сервиса.
+func TestAdminNeedsClientCert(t *testing.T) {
+	certs := newTestCerts(t)
+	addr := startTestServer(t, certs)
+
+	err := run(context.Background(), []string{"apps", "list", "--org-id", "1", "--addr", addr, "--tls-ca", certs.ca, "--timeout", "1s"}, io.Discard)
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sso/interanal/app"
+	"sso/interanal/config"
+	"sso/interanal/service/bootstrap"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// runBootstrap applies a seed straight to the database of the config,
+// as there is no admin to call the service with on a fresh install.
+func runBootstrap(ctx context.Context, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("bootstrap", flag.ContinueOnError)
+	configFlag := flags.String("config", "", "path to config file, CONFIG_PATH by default")
+	seedPath := flags.String("seed", "", "path to the seed file")
+	var output string
+	registerOutput(flags, &output)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := checkOutput(output); err != nil {
+		return err
+	}
+	if *seedPath == "" {
+		return errors.New("--seed is required")
+	}
+	path, err := configPath(*configFlag)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	seed, err := loadSeed(*seedPath)
+	if err != nil {
+		return err
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	st := app.MustNewStorage(ctx, logger, cfg)
+	defer st.Stop(context.WithoutCancel(ctx))
+	service := bootstrap.New(logger, st, st, st, st, st, st)
+	result, err := service.Apply(ctx, seed)
+	if err != nil {
+		return err
+	}
+	return printBootstrap(stdout, output, result)
+}
+
+// loadSeed reads a seed and resolves file:// and env:// references in
+// it like in the config. Unknown keys are errors, so a typo can't
+// silently drop a setting.
+func loadSeed(path string) (bootstrap.Seed, error) {
+	var seed bootstrap.Seed
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return seed, fmt.Errorf("cannot read seed: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&seed); err != nil && !errors.Is(err, io.EOF) {
+		return seed, fmt.Errorf("cannot parse seed: %w", err)
+	}
+	if err := config.ResolveRefs(&seed); err != nil {
+		return seed, fmt.Errorf("cannot resolve seed references:\n%w", err)
+	}
+	return seed, nil
+}
+
+type bootstrapApp struct {
+	Id     int    `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Secret string `json:"secret,omitempty"`
+}
+
+type bootstrapUser struct {
+	Id       int64  `json:"id"`
+	OrgId    int64  `json:"org_id"`
+	Email    string `json:"email"`
+	Status   string `json:"status"`
+	Password string `json:"password,omitempty"`
+}
+
+type bootstrapResult struct {
+	Apps  []bootstrapApp  `json:"apps"`
+	Users []bootstrapUser `json:"users"`
+}
+
+// printBootstrap prints what was done and the generated secrets, which
+// are shown this one time only.
+func printBootstrap(w io.Writer, output string, result bootstrap.Result) error {
+	if output == outputJSON {
+		view := bootstrapResult{Apps: []bootstrapApp{}, Users: []bootstrapUser{}}
+		for _, app := range result.Apps {
+			view.Apps = append(view.Apps, bootstrapApp(app))
+		}
+		for _, user := range result.Users {
+			view.Users = append(view.Users, bootstrapUser(user))
+		}
+		return printResult(w, output, view, table{})
+	}
+
+	var rows [][]string
+	for _, app := range result.Apps {
+		rows = append(rows, []string{"app", strconv.Itoa(app.Id), app.Name, app.Status})
+	}
+	for _, user := range result.Users {
+		rows = append(rows, []string{"user", strconv.FormatInt(user.Id, 10), user.Email, user.Status})
+	}
+	err := printResult(w, output, nil, table{header: []string{"KIND", "ID", "NAME", "STATUS"}, rows: rows})
+	if err != nil {
+		return err
+	}
+
+	var generated []string
+	for _, app := range result.Apps {
+		if app.Secret != "" {
+			generated = append(generated, fmt.Sprintf("app %d (%s) secret: %s", app.Id, app.Name, app.Secret))
+		}
+	}
+	for _, user := range result.Users {
+		if user.Password != "" {
+			generated = append(generated, fmt.Sprintf("user %s (org %d) password: %s", user.Email, user.OrgId, user.Password))
+		}
+	}
+	if len(generated) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w, "\nGenerated credentials are shown only this once, store them now:")
+	for _, line := range generated {
+		fmt.Fprintln(w, "  "+line)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sso/interanal/service/bootstrap"
+	"sso/interanal/storage/sqlite"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLoadSeed проверяет, что в сиде раскрываются ссылки
+// на переменные окружения, а опечатки в ключах не проходят.
+func TestLoadSeed(t *testing.T) {
+	t.Setenv("SEED_ADMIN_PASSWORD", "from-env")
+	path := writeFile(t, "seed.yaml", `
+apps:
+  - id: 2
+    name: web
+users:
+  - email: admin@gmail.com
+    password: env://SEED_ADMIN_PASSWORD
+    role: owner
+    admin: true
+`)
+
+	seed, err := loadSeed(path)
+	require.NoError(t, err)
+	assert.Equal(t, bootstrap.Seed{
+		Apps:  []bootstrap.AppSeed{{Id: 2, Name: "web"}},
+		Users: []bootstrap.UserSeed{{Email: "admin@gmail.com", Password: "from-env", Role: "owner", Admin: true}},
+	}, seed)
+
+	_, err = loadSeed(writeFile(t, "typo.yaml", "users:\n  - email: admin@gmail.com\n    rol: owner\n"))
+	assert.ErrorContains(t, err, "field rol not found")
+}
+
+// TestBootstrapPrintsSecretsOnce проверяет, что сгенерированные
+// секреты печатаются только при первом запуске.
+func TestBootstrapPrintsSecretsOnce(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "sso.db")
+	_, _, err := sqlite.Migrate(dbPath, "migrations")
+	require.NoError(t, err)
+	t.Setenv("STORAGE_DRIVER", "sqlite")
+	t.Setenv("STORAGE_SQLITE_PATH", dbPath)
+	seedPath := writeFile(t, "seed.yaml", "apps:\n  - id: 2\n    name: web\nusers:\n  - email: admin@gmail.com\n    role: owner\n")
+	args := []string{"bootstrap", "--config", "../../config/local.yaml", "--seed", seedPath}
+
+	var first bytes.Buffer
+	require.NoError(t, run(context.Background(), args, &first))
+	assert.Contains(t, first.String(), "app 2 (web) secret: ")
+	assert.Contains(t, first.String(), "user admin@gmail.com (org 1) password: ")
+
+	var second bytes.Buffer
+	require.NoError(t, run(context.Background(), args, &second))
+	assert.Contains(t, second.String(), "unchanged")
+	assert.NotContains(t, second.String(), "Generated credentials")
+}
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
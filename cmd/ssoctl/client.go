@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// addrEnv is the default of --addr.
+const addrEnv = "SSOCTL_ADDR"
+
+// appIdMetadataKey names the app, and so the organization, of RPCs
+// whose requests have no app_id.
+const appIdMetadataKey = "x-app-id"
+
+// clientFlags are the flags of the commands that call the service.
+type clientFlags struct {
+	addr       string
+	useTLS     bool
+	caFile     string
+	certFile   string
+	keyFile    string
+	serverName string
+	timeout    time.Duration
+	output     string
+}
+
+func (c *clientFlags) register(flags *flag.FlagSet) {
+	addr := os.Getenv(addrEnv)
+	if addr == "" {
+		addr = "localhost:44044"
+	}
+	flags.StringVar(&c.addr, "addr", addr, "address of the service, "+addrEnv+" overrides the default")
+	flags.BoolVar(&c.useTLS, "tls", false, "connect over TLS, implied by the other --tls-* flags")
+	flags.StringVar(&c.caFile, "tls-ca", "", "CA of the server certificate, the system roots by default")
+	flags.StringVar(&c.certFile, "tls-cert", "", "client certificate for mTLS")
+	flags.StringVar(&c.keyFile, "tls-key", "", "key of the client certificate")
+	flags.StringVar(&c.serverName, "tls-server-name", "", "name in the server certificate, the host of --addr by default")
+	flags.DurationVar(&c.timeout, "timeout", 10*time.Second, "timeout of a call")
+	registerOutput(flags, &c.output)
+}
+
+// dial connects to the service. Connections are lazy, so errors of an
+// unreachable service come from the first call.
+func (c *clientFlags) dial() (*grpc.ClientConn, error) {
+	if err := checkOutput(c.output); err != nil {
+		return nil, err
+	}
+	useTLS := c.useTLS || c.caFile != "" || c.certFile != "" || c.keyFile != ""
+	var opts []grpc.DialOption
+	if useTLS {
+		cfg, err := c.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(cfg)))
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	return grpc.NewClient(c.addr, opts...)
+}
+
+func (c *clientFlags) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: c.serverName, MinVersion: tls.VersionTLS12}
+	if c.caFile != "" {
+		pem, err := os.ReadFile(c.caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", c.caFile)
+		}
+	}
+	if (c.certFile == "") != (c.keyFile == "") {
+		return nil, errors.New("--tls-cert and --tls-key must be set together")
+	}
+	if c.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// callContext bounds a call by --timeout and sends the app when
+// appId is set.
+func (c *clientFlags) callContext(ctx context.Context, appId int) (context.Context, context.CancelFunc) {
+	if appId != 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, appIdMetadataKey, strconv.Itoa(appId))
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+// callError turns a gRPC status into "Code: message".
+func callError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	return fmt.Errorf("%s: %s", st.Code(), st.Message())
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	grpcapp "sso/interanal/app/grpc"
+	"sso/interanal/service/admin"
+	"sso/interanal/service/auth"
+	"sso/interanal/storage/memory"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHealthOverMTLS проверяет, что ssoctl вызывает сервис
+// по mTLS, а без клиентского сертификата сервер его не пускает.
+func TestHealthOverMTLS(t *testing.T) {
+	certs := newTestCerts(t)
+	addr := startTestServer(t, certs)
+	args := append([]string{"health"}, certs.clientArgs(addr)...)
+
+	var out bytes.Buffer
+	require.Eventually(t, func() bool {
+		out.Reset()
+		return run(context.Background(), args, &out) == nil && strings.HasSuffix(out.String(), " SERVING\n")
+	}, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, "SERVICE   STATUS\n(server)  SERVING\n", out.String())
+
+	out.Reset()
+	require.NoError(t, run(context.Background(), append(args, "--output", "json", "--service", "auth.Auth"), &out))
+	var result healthResult
+	require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+	assert.Equal(t, healthResult{Service: "auth.Auth", Status: "SERVING"}, result)
+
+	err := run(context.Background(), []string{"health", "--addr", addr, "--tls-ca", certs.ca, "--timeout", "1s"}, io.Discard)
+	assert.Error(t, err)
+}
+
+// testCerts are PEM files of a CA and the server and client
+// certificates it signed.
+type testCerts struct {
+	ca, serverCert, serverKey, clientCert, clientKey string
+}
+
+// clientArgs are the flags that call addr over mTLS.
+func (c testCerts) clientArgs(addr string) []string {
+	return []string{"--addr", addr, "--tls-ca", c.ca, "--tls-cert", c.clientCert, "--tls-key", c.clientKey}
+}
+
+// runner runs ssoctl with the flags of clientArgs and returns what it
+// printed.
+func (c testCerts) runner(addr string) func(args ...string) (string, error) {
+	return func(args ...string) (string, error) {
+		var out bytes.Buffer
+		err := run(context.Background(), append(args, c.clientArgs(addr)...), &out)
+		return out.String(), err
+	}
+}
+
+func newTestCerts(t *testing.T) testCerts {
+	t.Helper()
+	dir := t.TempDir()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	issue := func(name string, serial int64, usage x509.ExtKeyUsage) (string, string) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: name},
+			DNSNames:     []string{"localhost"},
+			IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		require.NoError(t, err)
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+		return writePEM(t, dir, name+".crt", "CERTIFICATE", der), writePEM(t, dir, name+".key", "EC PRIVATE KEY", keyDER)
+	}
+	certs := testCerts{ca: writePEM(t, dir, "ca.crt", "CERTIFICATE", caDER)}
+	certs.serverCert, certs.serverKey = issue("server", 2, x509.ExtKeyUsageServerAuth)
+	certs.clientCert, certs.clientKey = issue("client", 3, x509.ExtKeyUsageClientAuth)
+	return certs
+}
+
+func writePEM(t *testing.T, dir string, name string, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+	return path
+}
+
+// startTestServer serves the auth and admin services over mTLS on a
+// free port.
+func startTestServer(t *testing.T, certs testCerts) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	serverTLS, err := grpcapp.LoadTLS(certs.serverCert, certs.serverKey, certs.ca)
+	require.NoError(t, err)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	st := memory.New()
+	authService := auth.New(logger, st, st, st, time.Hour, true)
+	adminService := admin.New(logger, st, st, st, st, st, st)
+	server := grpcapp.New(logger, grpcapp.Services{Auth: authService, Admin: adminService}, st, st, st, grpcapp.Options{
+		Port:                port,
+		HealthCheckInterval: time.Second,
+		TLS:                 serverTLS,
+	})
+	go server.Run()
+	t.Cleanup(func() { server.Stop(context.Background()) })
+
+	addr := fmt.Sprintf("localhost:%d", port)
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	return addr
+}
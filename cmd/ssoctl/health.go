@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type healthResult struct {
+	Service string `json:"service"`
+	Status  string `json:"status"`
+}
+
+func runHealth(ctx context.Context, args []string, stdout io.Writer) error {
+	var client clientFlags
+	flags := flag.NewFlagSet("health", flag.ContinueOnError)
+	client.register(flags)
+	service := flags.String("service", "", "service to check, the whole server by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	conn, err := client.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := client.callContext(ctx, 0)
+	defer cancel()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: *service})
+	if err != nil {
+		return callError(err)
+	}
+	result := healthResult{Service: *service, Status: resp.GetStatus().String()}
+	name := result.Service
+	if name == "" {
+		name = "(server)"
+	}
+	return printResult(stdout, client.output, result, table{
+		header: []string{"SERVICE", "STATUS"},
+		rows:   [][]string{{name, result.Status}},
+	})
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// jwk is a JSON Web Key, RFC 7517. Only the members of symmetric and
+// public keys are read, private members are ignored.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func loadJWKS(path string) ([]jwk, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(set.Keys) == 0 {
+		return nil, fmt.Errorf("%s: no keys", path)
+	}
+	return set.Keys, nil
+}
+
+// fits reports whether the key may verify a token signed with alg.
+func (k jwk) fits(alg string) bool {
+	if k.Use != "" && k.Use != "sig" || k.Alg != "" && k.Alg != alg {
+		return false
+	}
+	switch k.Kty {
+	case "oct":
+		return strings.HasPrefix(alg, "HS")
+	case "RSA":
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case "EC":
+		return strings.HasPrefix(alg, "ES")
+	case "OKP":
+		return alg == "EdDSA"
+	}
+	return false
+}
+
+// verificationKey returns the key in the form the signing methods of
+// golang-jwt expect.
+func (k jwk) verificationKey() (any, error) {
+	switch k.Kty {
+	case "oct":
+		return decodeMember("k", k.K)
+	case "RSA":
+		n, err := decodeMember("n", k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeMember("e", k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("e: is too large")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("crv: unsupported curve %q", k.Crv)
+		}
+		x, err := decodeMember("x", k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeMember("y", k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("crv: unsupported curve %q", k.Crv)
+		}
+		x, err := decodeMember("x", k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("x: must be %d bytes", ed25519.PublicKeySize)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("kty: unsupported key type %q", k.Kty)
+}
+
+// name identifies the key in the output.
+func (k jwk) name(i int) string {
+	if k.Kid != "" {
+		return fmt.Sprintf("jwks kid %q", k.Kid)
+	}
+	return fmt.Sprintf("jwks key #%d", i)
+}
+
+func decodeMember(name string, value string) ([]byte, error) {
+	if value == "" {
+		return nil, fmt.Errorf("%s: is required", name)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return b, nil
+}
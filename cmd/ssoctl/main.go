@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const usage = `Usage: ssoctl <command> [subcommand] [flags]
+
+Commands:
+  bootstrap              apply a seed of apps and users to the database
+  users is-admin         check whether a user is an admin
+  users register         create a user with a password
+  tokens issue           log in as a user and print the token
+  tokens inspect         decode a token and print its header and claims
+  tokens verify          check a token with an app secret or a JWKS file
+  health                 print the serving status of the service
+  apps list              list the apps of an organization
+  apps create            create an app, generating its secret by default
+  apps update            change the name and auto provisioning of an app
+  apps rotate-secret     replace the secret of an app with a generated one
+  roles list             list the members of an organization with their roles
+  roles add              create a user without a password with a role
+  roles set              change the role of a member
+  sessions revoke        reject the tokens issued to a user so far
+
+"token" is an alias of "tokens". All commands but bootstrap and
+tokens inspect|verify call the running service over gRPC. The apps,
+roles and sessions commands call the admin service, which needs a
+client certificate (--tls-cert and --tls-key).
+Run "ssoctl <command> [subcommand] -h" for the flags of a command.
+`
+
+// runFunc is the entry point of a command. It parses its own flags
+// from args.
+type runFunc func(ctx context.Context, args []string, stdout io.Writer) error
+
+// tokens is registered under "token" as well, since a single token is
+// what inspect and verify take.
+var tokens = group(map[string]runFunc{
+	"issue":   runTokensIssue,
+	"inspect": runTokensInspect,
+	"verify":  runTokensVerify,
+})
+
+// commands maps a command name to its entry point. Groups of
+// subcommands dispatch further with group.
+var commands = map[string]runFunc{
+	"bootstrap": runBootstrap,
+	"users": group(map[string]runFunc{
+		"is-admin": runUsersIsAdmin,
+		"register": runUsersRegister,
+	}),
+	"tokens": tokens,
+	"token":  tokens,
+	"health": runHealth,
+	"apps": group(map[string]runFunc{
+		"list":          runAppsList,
+		"create":        runAppsCreate,
+		"update":        runAppsUpdate,
+		"rotate-secret": runAppsRotateSecret,
+	}),
+	"roles": group(map[string]runFunc{
+		"list": runRolesList,
+		"add":  runRolesAdd,
+		"set":  runRolesSet,
+	}),
+	"sessions": group(map[string]runFunc{
+		"revoke": runSessionsRevoke,
+	}),
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	err := run(ctx, os.Args[1:], os.Stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ssoctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdout io.Writer) error {
+	return dispatch(ctx, commands, args, stdout)
+}
+
+func group(subcommands map[string]runFunc) runFunc {
+	return func(ctx context.Context, args []string, stdout io.Writer) error {
+		return dispatch(ctx, subcommands, args, stdout)
+	}
+}
+
+func dispatch(ctx context.Context, cmds map[string]runFunc, args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		fmt.Fprint(os.Stderr, usage)
+		if len(args) == 0 {
+			return errors.New("command is required")
+		}
+		return flag.ErrHelp
+	}
+	cmd, ok := cmds[args[0]]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return cmd(ctx, args[1:], stdout)
+}
+
+// configPath returns the --config flag or the CONFIG_PATH variable.
+func configPath(flagValue string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	if path := os.Getenv("CONFIG_PATH"); path != "" {
+		return path, nil
+	}
+	return "", errors.New("config path is empty, set --config or CONFIG_PATH")
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+func registerOutput(flags *flag.FlagSet, output *string) {
+	flags.StringVar(output, "output", outputTable, "output format, table or json")
+}
+
+func checkOutput(output string) error {
+	if output != outputTable && output != outputJSON {
+		return fmt.Errorf("--output must be %q or %q", outputTable, outputJSON)
+	}
+	return nil
+}
+
+// table is the table form of a result. Without a header only the rows
+// are printed, which suits single values used in scripts.
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+// printResult writes value as indented JSON or t as aligned columns.
+func printResult(w io.Writer, output string, value any, t table) error {
+	if output == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(value)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if t.header != nil {
+		fmt.Fprintln(tw, strings.Join(t.header, "\t"))
+	}
+	for _, row := range t.rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	adminv1 "sso/gen/sso/admin/v1"
+	"strconv"
+)
+
+type memberResult struct {
+	UserId int64  `json:"user_id"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+}
+
+func memberTable(members []memberResult) table {
+	t := table{header: []string{"USER", "EMAIL", "ROLE"}}
+	for _, member := range members {
+		t.rows = append(t.rows, []string{
+			strconv.FormatInt(member.UserId, 10),
+			member.Email,
+			member.Role,
+		})
+	}
+	return t
+}
+
+func runRolesList(ctx context.Context, args []string, stdout io.Writer) error {
+	var client clientFlags
+	flags := flag.NewFlagSet("roles list", flag.ContinueOnError)
+	client.register(flags)
+	orgId := flags.Int64("org-id", 0, "organization of the members")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *orgId == 0 {
+		return errors.New("--org-id is required")
+	}
+	conn, err := client.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := client.callContext(ctx, 0)
+	defer cancel()
+	resp, err := adminv1.NewAdminServiceClient(conn).ListMembers(ctx, &adminv1.ListMembersRequest{OrgId: *orgId})
+	if err != nil {
+		return callError(err)
+	}
+	result := make([]memberResult, 0, len(resp.GetMembers()))
+	for _, member := range resp.GetMembers() {
+		result = append(result, memberResult{UserId: member.GetUserId(), Email: member.GetEmail(), Role: member.GetRole()})
+	}
+	return printResult(stdout, client.output, result, memberTable(result))
+}
+
+// runRolesAdd creates a user without a password, who then signs in
+// without a password or after a password reset.
+func runRolesAdd(ctx context.Context, args []string, stdout io.Writer) error {
+	var client clientFlags
+	flags := flag.NewFlagSet("roles add", flag.ContinueOnError)
+	client.register(flags)
+	orgId := flags.Int64("org-id", 0, "organization the user joins")
+	email := flags.String("email", "", "email of the user")
+	role := flags.String("role", "member", "role of the user: owner, admin or member")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *orgId == 0 {
+		return errors.New("--org-id is required")
+	}
+	if *email == "" {
+		return errors.New("--email is required")
+	}
+	conn, err := client.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := client.callContext(ctx, 0)
+	defer cancel()
+	resp, err := adminv1.NewAdminServiceClient(conn).AddMember(ctx, &adminv1.AddMemberRequest{
+		OrgId: *orgId,
+		Email: *email,
+		Role:  *role,
+	})
+	if err != nil {
+		return callError(err)
+	}
+	member := resp.GetMember()
+	result := memberResult{UserId: member.GetUserId(), Email: member.GetEmail(), Role: member.GetRole()}
+	return printResult(stdout, client.output, result, memberTable([]memberResult{result}))
+}
+
+type setRoleResult struct {
+	OrgId  int64  `json:"org_id"`
+	UserId int64  `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+func runRolesSet(ctx context.Context, args []string, stdout io.Writer) error {
+	var client clientFlags
+	flags := flag.NewFlagSet("roles set", flag.ContinueOnError)
+	client.register(flags)
+	orgId := flags.Int64("org-id", 0, "organization of the user")
+	userId := flags.Int64("user-id", 0, "id of the user")
+	role := flags.String("role", "", "new role of the user: owner, admin or member")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *orgId == 0 {
+		return errors.New("--org-id is required")
+	}
+	if *userId == 0 {
+		return errors.New("--user-id is required")
+	}
+	if *role == "" {
+		return errors.New("--role is required")
+	}
+	conn, err := client.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := client.callContext(ctx, 0)
+	defer cancel()
+	_, err = adminv1.NewAdminServiceClient(conn).SetMemberRole(ctx, &adminv1.SetMemberRoleRequest{
+		OrgId:  *orgId,
+		UserId: *userId,
+		Role:   *role,
+	})
+	if err != nil {
+		return callError(err)
+	}
+	result := setRoleResult{OrgId: *orgId, UserId: *userId, Role: *role}
+	return printResult(stdout, client.output, result, table{
+		header: []string{"ORG", "USER", "ROLE"},
+		rows: [][]string{{
+			strconv.FormatInt(result.OrgId, 10),
+			strconv.FormatInt(result.UserId, 10),
+			result.Role,
+		}},
+	})
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRoles проверяет добавление участника, смену его роли
+// и список участников организации.
+func TestRoles(t *testing.T) {
+	certs := newTestCerts(t)
+	addr := startTestServer(t, certs)
+	call := certs.runner(addr)
+
+	out, err := call("roles", "add", "--org-id", "1", "--email", "user@gmail.com", "--output", "json")
+	require.NoError(t, err)
+	var added memberResult
+	require.NoError(t, json.Unmarshal([]byte(out), &added))
+	assert.Equal(t, memberResult{UserId: 1, Email: "user@gmail.com", Role: "member"}, added)
+
+	_, err = call("roles", "set", "--org-id", "1", "--user-id", "1", "--role", "admin")
+	require.NoError(t, err)
+	out, err = call("roles", "list", "--org-id", "1")
+	require.NoError(t, err)
+	assert.Equal(t, "USER  EMAIL           ROLE\n1     user@gmail.com  admin\n", out)
+
+	_, err = call("roles", "set", "--org-id", "1", "--user-id", "1", "--role", "root")
+	assert.EqualError(t, err, "InvalidArgument: invalid role")
+	_, err = call("roles", "set", "--org-id", "1", "--user-id", "42", "--role", "admin")
+	assert.EqualError(t, err, "NotFound: user not found")
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	adminv1 "sso/gen/sso/admin/v1"
+	"strconv"
+	"time"
+)
+
+type revokeResult struct {
+	OrgId     int64     `json:"org_id"`
+	UserId    int64     `json:"user_id"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+func runSessionsRevoke(ctx context.Context, args []string, stdout io.Writer) error {
+	var client clientFlags
+	flags := flag.NewFlagSet("sessions revoke", flag.ContinueOnError)
+	client.register(flags)
+	orgId := flags.Int64("org-id", 0, "organization of the user")
+	userId := flags.Int64("user-id", 0, "id of the user")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *orgId == 0 {
+		return errors.New("--org-id is required")
+	}
+	if *userId == 0 {
+		return errors.New("--user-id is required")
+	}
+	conn, err := client.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := client.callContext(ctx, 0)
+	defer cancel()
+	resp, err := adminv1.NewAdminServiceClient(conn).RevokeSessions(ctx, &adminv1.RevokeSessionsRequest{
+		OrgId:  *orgId,
+		UserId: *userId,
+	})
+	if err != nil {
+		return callError(err)
+	}
+	result := revokeResult{OrgId: *orgId, UserId: *userId, RevokedAt: resp.GetRevokedAt().AsTime().UTC()}
+	return printResult(stdout, client.output, result, table{
+		header: []string{"ORG", "USER", "REVOKED_AT"},
+		rows: [][]string{{
+			strconv.FormatInt(result.OrgId, 10),
+			strconv.FormatInt(result.UserId, 10),
+			result.RevokedAt.Format(time.RFC3339),
+		}},
+	})
+}
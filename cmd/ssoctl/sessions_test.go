@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSessionsRevoke проверяет, что отзыв сессий возвращает
+// время отзыва, а для неизвестного пользователя — ошибку.
+func TestSessionsRevoke(t *testing.T) {
+	certs := newTestCerts(t)
+	addr := startTestServer(t, certs)
+	call := certs.runner(addr)
+	_, err := call("roles", "add", "--org-id", "1", "--email", "user@gmail.com")
+	require.NoError(t, err)
+
+	out, err := call("sessions", "revoke", "--org-id", "1", "--user-id", "1", "--output", "json")
+	require.NoError(t, err)
+	var result revokeResult
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	assert.Equal(t, int64(1), result.UserId)
+	assert.WithinDuration(t, time.Now(), result.RevokedAt, time.Minute)
+
+	_, err = call("sessions", "revoke", "--org-id", "1", "--user-id", "42")
+	assert.EqualError(t, err, "NotFound: user not found")
+}
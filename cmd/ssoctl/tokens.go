@@ -0,0 +1,395 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sso/interanal/config"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	ssov1 "github.com/sariya23/sso_proto/gen/sso"
+)
+
+type issueResult struct {
+	Token string `json:"token"`
+}
+
+// runTokensIssue logs in as a user with a password. The table output is
+// the bare token, so scripts can capture it.
+func runTokensIssue(ctx context.Context, args []string, stdout io.Writer) error {
+	var client clientFlags
+	flags := flag.NewFlagSet("tokens issue", flag.ContinueOnError)
+	client.register(flags)
+	email := flags.String("email", "", "email of the user")
+	password := flags.String("password", "", "password of the user, "+passwordEnv+" by default")
+	appId := flags.Int("app-id", 0, "app to log in to")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *email == "" || *appId == 0 {
+		return errors.New("--email and --app-id are required")
+	}
+	if *password == "" {
+		*password = os.Getenv(passwordEnv)
+	}
+	conn, err := client.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := client.callContext(ctx, 0)
+	defer cancel()
+	resp, err := ssov1.NewAuthClient(conn).Login(ctx, &ssov1.LoginRequest{
+		Email:    *email,
+		Password: *password,
+		AppId:    int32(*appId),
+	})
+	if err != nil {
+		return callError(err)
+	}
+	result := issueResult{Token: resp.GetToken()}
+	return printResult(stdout, client.output, result, table{rows: [][]string{{result.Token}}})
+}
+
+// appSecretEnv is the default of --secret of tokens verify and apps create.
+const appSecretEnv = "SSOCTL_APP_SECRET"
+
+type inspectResult struct {
+	Header map[string]any `json:"header"`
+	Claims jwt.MapClaims  `json:"claims"`
+	// Times are the time claims in a readable form relative to now.
+	Times    map[string]string `json:"times,omitempty"`
+	Problems []string          `json:"problems,omitempty"`
+}
+
+// runTokensInspect decodes a token without checking its signature, so
+// it works for tokens of any app.
+func runTokensInspect(ctx context.Context, args []string, stdout io.Writer) error {
+	var output string
+	flags := flag.NewFlagSet("tokens inspect", flag.ContinueOnError)
+	registerOutput(flags, &output)
+	leeway := registerLeeway(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := checkOutput(output); err != nil {
+		return err
+	}
+	raw, err := readToken(flags.Args())
+	if err != nil {
+		return err
+	}
+	token, claims, err := decodeToken(raw)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	result := inspectResult{
+		Header:   token.Header,
+		Claims:   claims,
+		Times:    describeTimes(claims, now),
+		Problems: checkTimes(claims, now, *leeway),
+	}
+	t := table{header: []string{"PART", "NAME", "VALUE"}}
+	for _, name := range sortedKeys(result.Header) {
+		t.rows = append(t.rows, []string{"header", name, formatClaim(result.Header[name])})
+	}
+	for _, name := range sortedKeys(result.Claims) {
+		value, ok := result.Times[name]
+		if !ok {
+			value = formatClaim(result.Claims[name])
+		}
+		t.rows = append(t.rows, []string{"claims", name, value})
+	}
+	if err := printResult(stdout, output, result, t); err != nil {
+		return err
+	}
+	return printProblems(stdout, output, result.Problems)
+}
+
+type verifyResult struct {
+	Valid bool   `json:"valid"`
+	Alg   string `json:"alg"`
+	// Key names the key that verified the signature.
+	Key      string   `json:"key,omitempty"`
+	Error    string   `json:"error,omitempty"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+// runTokensVerify checks the signature and the time claims of a token
+// with the secret of its app or with the keys of a JWKS file.
+func runTokensVerify(ctx context.Context, args []string, stdout io.Writer) error {
+	var output string
+	flags := flag.NewFlagSet("tokens verify", flag.ContinueOnError)
+	registerOutput(flags, &output)
+	leeway := registerLeeway(flags)
+	secret := flags.String("secret", "", "secret of the app, "+appSecretEnv+" by default; file:// and env:// references are resolved")
+	jwksPath := flags.String("jwks", "", "JWKS file with the keys to verify with")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := checkOutput(output); err != nil {
+		return err
+	}
+	if *secret == "" && *jwksPath == "" {
+		*secret = os.Getenv(appSecretEnv)
+	}
+	if (*secret == "") == (*jwksPath == "") {
+		return errors.New("exactly one of --secret and --jwks is required")
+	}
+	raw, err := readToken(flags.Args())
+	if err != nil {
+		return err
+	}
+	token, claims, err := decodeToken(raw)
+	if err != nil {
+		return err
+	}
+	keys, err := verificationKeys(token, *secret, *jwksPath)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	result := verifyResult{Alg: token.Method.Alg(), Problems: checkTimes(claims, now, *leeway)}
+	key, err := verifyToken(raw, keys, now, *leeway)
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Valid = true
+		result.Key = key.name
+	}
+	printErr := printResult(stdout, output, result, table{
+		header: []string{"VALID", "ALG", "KEY"},
+		rows:   [][]string{{strconv.FormatBool(result.Valid), result.Alg, result.Key}},
+	})
+	if printErr == nil {
+		printErr = printProblems(stdout, output, result.Problems)
+	}
+	if err != nil {
+		return fmt.Errorf("token is invalid: %w", err)
+	}
+	return printErr
+}
+
+func registerLeeway(flags *flag.FlagSet) *time.Duration {
+	return flags.Duration("leeway", time.Minute, "clock difference between the issuer and this host to tolerate")
+}
+
+// readToken returns the token argument. Without one, or with "-", the
+// token is read from stdin, which keeps it out of the shell history.
+// A pasted "Bearer " prefix is dropped.
+func readToken(args []string) (string, error) {
+	if len(args) > 1 {
+		return "", errors.New("expected a single token, put flags before it")
+	}
+	raw := "-"
+	if len(args) == 1 {
+		raw = args[0]
+	}
+	if raw == "-" {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("read token: %w", err)
+		}
+		raw = string(b)
+	}
+	raw = strings.TrimPrefix(strings.TrimSpace(raw), "Bearer ")
+	if raw == "" {
+		return "", errors.New("token is empty")
+	}
+	return raw, nil
+}
+
+func decodeToken(raw string) (*jwt.Token, jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	token, _, err := jwt.NewParser(jwt.WithJSONNumber()).ParseUnverified(raw, claims)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode token: %w", err)
+	}
+	return token, claims, nil
+}
+
+type namedKey struct {
+	name string
+	key  any
+}
+
+// verificationKeys returns the keys that may have signed the token:
+// the secret, or the JWKS keys that fit its alg and kid.
+func verificationKeys(token *jwt.Token, secret string, jwksPath string) ([]namedKey, error) {
+	if secret != "" {
+		secret, err := config.ResolveRef(secret)
+		if err != nil {
+			return nil, fmt.Errorf("--secret: %w", err)
+		}
+		if !strings.HasPrefix(token.Method.Alg(), "HS") {
+			return nil, fmt.Errorf("token is signed with %s, an app secret verifies only HS algorithms", token.Method.Alg())
+		}
+		return []namedKey{{name: "secret", key: []byte(secret)}}, nil
+	}
+	set, err := loadJWKS(jwksPath)
+	if err != nil {
+		return nil, err
+	}
+	kid, _ := token.Header["kid"].(string)
+	var keys []namedKey
+	for i, k := range set {
+		if kid != "" && k.Kid != kid || !k.fits(token.Method.Alg()) {
+			continue
+		}
+		key, err := k.verificationKey()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k.name(i), err)
+		}
+		keys = append(keys, namedKey{name: k.name(i), key: key})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s has no %s key with kid %q", jwksPath, token.Method.Alg(), kid)
+	}
+	return keys, nil
+}
+
+// verifyToken tries the keys in turn and returns the one that verified
+// the signature. Once a signature matches, the claims decide.
+func verifyToken(raw string, keys []namedKey, now time.Time, leeway time.Duration) (namedKey, error) {
+	parser := jwt.NewParser(
+		jwt.WithJSONNumber(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(leeway),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+	)
+	var err error
+	for _, key := range keys {
+		_, err = parser.Parse(raw, func(*jwt.Token) (any, error) { return key.key, nil })
+		if !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+			return key, err
+		}
+	}
+	return namedKey{}, err
+}
+
+// checkTimes reports the problems with the time claims at now. The
+// issuer and this host may disagree about the time, so misses within
+// leeway are reported as likely clock skew rather than as bad tokens.
+func checkTimes(claims jwt.MapClaims, now time.Time, leeway time.Duration) []string {
+	var problems []string
+	exp, err := claims.GetExpirationTime()
+	switch {
+	case err != nil:
+		problems = append(problems, err.Error())
+	case exp == nil:
+		problems = append(problems, "exp is missing, the token never expires")
+	case !now.Before(exp.Time):
+		ago := now.Sub(exp.Time).Round(time.Second)
+		if ago <= leeway {
+			problems = append(problems, fmt.Sprintf("expired %s ago, within the leeway of %s: the clock of the issuer or of this host is likely off", ago, leeway))
+		} else {
+			problems = append(problems, fmt.Sprintf("expired %s ago", ago))
+		}
+	}
+
+	iat, err := claims.GetIssuedAt()
+	switch {
+	case err != nil:
+		problems = append(problems, err.Error())
+	case iat != nil && iat.After(now):
+		ahead := iat.Sub(now).Round(time.Second)
+		problems = append(problems, fmt.Sprintf("issued %s in the future: the clock of the issuer is ahead of this host", ahead))
+	}
+	if exp != nil && iat != nil && exp.Before(iat.Time) {
+		problems = append(problems, "exp is before iat")
+	}
+
+	nbf, err := claims.GetNotBefore()
+	switch {
+	case err != nil:
+		problems = append(problems, err.Error())
+	case nbf != nil && nbf.After(now):
+		ahead := nbf.Sub(now).Round(time.Second)
+		if ahead <= leeway {
+			problems = append(problems, fmt.Sprintf("not valid for another %s, within the leeway of %s: the clock of the issuer is likely ahead of this host", ahead, leeway))
+		} else {
+			problems = append(problems, fmt.Sprintf("not valid for another %s", ahead))
+		}
+	}
+	return problems
+}
+
+// describeTimes formats the valid time claims as UTC dates with the
+// distance from now, e.g. "2024-05-01T10:00:00Z (in 59m0s)".
+func describeTimes(claims jwt.MapClaims, now time.Time) map[string]string {
+	dates := map[string]func() (*jwt.NumericDate, error){
+		"exp": claims.GetExpirationTime,
+		"iat": claims.GetIssuedAt,
+		"nbf": claims.GetNotBefore,
+	}
+	times := make(map[string]string)
+	for name, get := range dates {
+		date, err := get()
+		if err != nil || date == nil {
+			continue
+		}
+		d := date.Sub(now).Round(time.Second)
+		switch {
+		case d > 0:
+			times[name] = fmt.Sprintf("%s (in %s)", date.UTC().Format(time.RFC3339), d)
+		case d < 0:
+			times[name] = fmt.Sprintf("%s (%s ago)", date.UTC().Format(time.RFC3339), -d)
+		default:
+			times[name] = fmt.Sprintf("%s (now)", date.UTC().Format(time.RFC3339))
+		}
+	}
+	if len(times) == 0 {
+		return nil
+	}
+	return times
+}
+
+// formatClaim prints strings and numbers as is and other values as JSON.
+func formatClaim(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
+
+// printProblems lists problems under a table. JSON results carry them
+// in a field instead.
+func printProblems(w io.Writer, output string, problems []string) error {
+	if output == outputJSON {
+		return nil
+	}
+	for _, problem := range problems {
+		if _, err := fmt.Fprintln(w, "warning:", problem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys[M ~map[string]V, V any](m M) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
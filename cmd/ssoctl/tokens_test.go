@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sso/interanal/domain/models"
+	ssojwt "sso/lib/jwt"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTokensInspect проверяет, что inspect печатает заголовок
+// и claims токена, а истекший токен помечается предупреждением.
+func TestTokensInspect(t *testing.T) {
+	token := newTestToken(t, -2*time.Minute)
+
+	var out bytes.Buffer
+	require.NoError(t, run(context.Background(), []string{"token", "inspect", token}, &out))
+	assert.Regexp(t, `header  alg     HS256\n`, out.String())
+	assert.Regexp(t, `claims  email   user@gmail\.com\n`, out.String())
+	assert.Regexp(t, `claims  exp     \S+Z \(2m\ds ago\)\n`, out.String())
+	assert.Regexp(t, `warning: expired 2m\ds ago\n`, out.String())
+
+	out.Reset()
+	require.NoError(t, run(context.Background(), []string{"tokens", "inspect", "--output", "json", "Bearer " + token}, &out))
+	var result struct {
+		Header   map[string]string `json:"header"`
+		Claims   map[string]any    `json:"claims"`
+		Times    map[string]string `json:"times"`
+		Problems []string          `json:"problems"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+	assert.Equal(t, map[string]string{"alg": "HS256", "typ": "JWT"}, result.Header)
+	assert.Equal(t, float64(42), result.Claims["uid"])
+	assert.Regexp(t, `\(2m\ds ago\)$`, result.Times["exp"])
+	require.Len(t, result.Problems, 1)
+	assert.Regexp(t, `^expired 2m\ds ago$`, result.Problems[0])
+
+	err := run(context.Background(), []string{"tokens", "inspect", "not-a-token"}, io.Discard)
+	assert.ErrorContains(t, err, "decode token")
+}
+
+// TestTokensVerifySecret проверяет проверку подписи секретом
+// приложения и то, что расхождение часов в пределах leeway
+// не делает токен невалидным, но сообщается.
+func TestTokensVerifySecret(t *testing.T) {
+	token := newTestToken(t, time.Hour)
+
+	var out bytes.Buffer
+	require.NoError(t, run(context.Background(), []string{"tokens", "verify", "--secret", "test-secret", token}, &out))
+	assert.Equal(t, "VALID  ALG    KEY\ntrue   HS256  secret\n", out.String())
+
+	t.Setenv(appSecretEnv, "wrong-secret")
+	out.Reset()
+	err := run(context.Background(), []string{"tokens", "verify", token}, &out)
+	assert.ErrorIs(t, err, jwt.ErrTokenSignatureInvalid)
+	assert.Contains(t, out.String(), "false  HS256")
+
+	skewed := newTestToken(t, -20*time.Second)
+	out.Reset()
+	require.NoError(t, run(context.Background(), []string{"tokens", "verify", "--secret", "test-secret", skewed}, &out))
+	assert.Regexp(t, `warning: expired 2\ds ago, within the leeway of 1m0s`, out.String())
+
+	err = run(context.Background(), []string{"tokens", "verify", "--secret", "test-secret", "--leeway", "10s", skewed}, io.Discard)
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+}
+
+// TestTokensVerifyJWKS проверяет, что ключ из JWKS выбирается
+// по kid и алгоритму токена.
+func TestTokensVerifyJWKS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signed := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"uid": 42,
+		"iat": time.Now().Add(30 * time.Second).Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	signed.Header["kid"] = "ec-1"
+	token, err := signed.SignedString(key)
+	require.NoError(t, err)
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks := writeFile(t, "jwks.json", fmt.Sprintf(`{"keys": [
+		{"kty": "oct", "kid": "hs-1", "k": %q},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": %q, "y": %q}
+	]}`, b64([]byte("test-secret")), b64(key.X.FillBytes(make([]byte, 32))), b64(key.Y.FillBytes(make([]byte, 32)))))
+
+	var out bytes.Buffer
+	require.NoError(t, run(context.Background(), []string{"tokens", "verify", "--jwks", jwks, "--output", "json", token}, &out))
+	var result verifyResult
+	require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+	require.Len(t, result.Problems, 1)
+	assert.Regexp(t, `^issued \d+s in the future`, result.Problems[0])
+	result.Problems = nil
+	assert.Equal(t, verifyResult{Valid: true, Alg: "ES256", Key: `jwks kid "ec-1"`}, result)
+
+	err = run(context.Background(), []string{"tokens", "verify", "--jwks", jwks, newTestToken(t, time.Hour)}, io.Discard)
+	assert.NoError(t, err)
+
+	err = run(context.Background(), []string{"tokens", "verify", "--jwks", jwks, "--secret", "test-secret", token}, io.Discard)
+	assert.ErrorContains(t, err, "exactly one of --secret and --jwks")
+}
+
+// newTestToken returns a token like the ones of Login that expires in
+// ttl. A token with a negative ttl was issued an hour before it expired.
+func newTestToken(t *testing.T, ttl time.Duration) string {
+	t.Helper()
+	if ttl >= 0 {
+		user := models.User{Id: 42, OrgId: models.DefaultOrgId, Email: "user@gmail.com"}
+		app := models.App{Id: 2, OrgId: models.DefaultOrgId, Name: "web", Secret: "test-secret"}
+		token, err := ssojwt.NewToken(user, app, ttl)
+		require.NoError(t, err)
+		return token
+	}
+	exp := time.Now().Add(ttl)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"uid":    42,
+		"email":  "user@gmail.com",
+		"iat":    exp.Add(-time.Hour).Unix(),
+		"exp":    exp.Unix(),
+		"app_id": 2,
+		"org_id": models.DefaultOrgId,
+	}).SignedString([]byte("test-secret"))
+	require.NoError(t, err)
+	return token
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"strconv"
+
+	ssov1 "github.com/sariya23/sso_proto/gen/sso"
+)
+
+// passwordEnv keeps passwords out of the shell history and ps.
+const passwordEnv = "SSOCTL_PASSWORD"
+
+type isAdminResult struct {
+	UserId  int64 `json:"user_id"`
+	AppId   int   `json:"app_id"`
+	IsAdmin bool  `json:"is_admin"`
+}
+
+func runUsersIsAdmin(ctx context.Context, args []string, stdout io.Writer) error {
+	var client clientFlags
+	flags := flag.NewFlagSet("users is-admin", flag.ContinueOnError)
+	client.register(flags)
+	userId := flags.Int64("user-id", 0, "id of the user")
+	appId := flags.Int("app-id", 0, "app whose organization the user belongs to")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *userId == 0 {
+		return errors.New("--user-id is required")
+	}
+	if *appId == 0 {
+		return errors.New("--app-id is required")
+	}
+	conn, err := client.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := client.callContext(ctx, *appId)
+	defer cancel()
+	resp, err := ssov1.NewAuthClient(conn).IsAdmin(ctx, &ssov1.IsAdminRequest{UserId: *userId})
+	if err != nil {
+		return callError(err)
+	}
+	result := isAdminResult{UserId: *userId, AppId: *appId, IsAdmin: resp.GetIsAdmin()}
+	return printResult(stdout, client.output, result, table{
+		header: []string{"USER", "APP", "ADMIN"},
+		rows: [][]string{{
+			strconv.FormatInt(result.UserId, 10),
+			strconv.Itoa(result.AppId),
+			strconv.FormatBool(result.IsAdmin),
+		}},
+	})
+}
+
+type registerResult struct {
+	UserId int64  `json:"user_id"`
+	AppId  int    `json:"app_id"`
+	Email  string `json:"email"`
+}
+
+func runUsersRegister(ctx context.Context, args []string, stdout io.Writer) error {
+	var client clientFlags
+	flags := flag.NewFlagSet("users register", flag.ContinueOnError)
+	client.register(flags)
+	email := flags.String("email", "", "email of the user")
+	password := flags.String("password", "", "password of the user, "+passwordEnv+" by default")
+	appId := flags.Int("app-id", 0, "app whose organization the user joins")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *email == "" {
+		return errors.New("--email is required")
+	}
+	if *appId == 0 {
+		return errors.New("--app-id is required")
+	}
+	if *password == "" {
+		*password = os.Getenv(passwordEnv)
+	}
+	conn, err := client.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := client.callContext(ctx, *appId)
+	defer cancel()
+	resp, err := ssov1.NewAuthClient(conn).Register(ctx, &ssov1.RegisterRequest{Email: *email, Password: *password})
+	if err != nil {
+		return callError(err)
+	}
+	result := registerResult{UserId: resp.GetUserId(), AppId: *appId, Email: *email}
+	return printResult(stdout, client.output, result, table{
+		header: []string{"USER", "APP", "EMAIL"},
+		rows: [][]string{{
+			strconv.FormatInt(result.UserId, 10),
+			strconv.Itoa(result.AppId),
+			result.Email,
+		}},
+	})
+}
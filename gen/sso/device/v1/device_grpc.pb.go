@@ -0,0 +1,259 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: sso/device/v1/device.proto
+
+package devicev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	DeviceService_StartDeviceAuthorization_FullMethodName   = "/sso.device.v1.DeviceService/StartDeviceAuthorization"
+	DeviceService_ApproveDeviceAuthorization_FullMethodName = "/sso.device.v1.DeviceService/ApproveDeviceAuthorization"
+	DeviceService_DenyDeviceAuthorization_FullMethodName    = "/sso.device.v1.DeviceService/DenyDeviceAuthorization"
+	DeviceService_PollDeviceToken_FullMethodName            = "/sso.device.v1.DeviceService/PollDeviceToken"
+)
+
+// DeviceServiceClient is the client API for DeviceService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// DeviceService implements the device authorization grant (RFC 8628)
+// for devices without a browser. ApproveDeviceAuthorization and
+// DenyDeviceAuthorization need the token of the user in the
+// "authorization: Bearer <token>" metadata, the device calls need none.
+type DeviceServiceClient interface {
+	// StartDeviceAuthorization issues a device code and a user code.
+	StartDeviceAuthorization(ctx context.Context, in *StartDeviceAuthorizationRequest, opts ...grpc.CallOption) (*StartDeviceAuthorizationResponse, error)
+	// ApproveDeviceAuthorization lets the device with the user code log
+	// in as the caller.
+	ApproveDeviceAuthorization(ctx context.Context, in *ApproveDeviceAuthorizationRequest, opts ...grpc.CallOption) (*ApproveDeviceAuthorizationResponse, error)
+	// DenyDeviceAuthorization rejects the device with the user code.
+	DenyDeviceAuthorization(ctx context.Context, in *DenyDeviceAuthorizationRequest, opts ...grpc.CallOption) (*DenyDeviceAuthorizationResponse, error)
+	// PollDeviceToken returns the token once the user approved the
+	// device. Until then the status message is an RFC 8628 error code:
+	// authorization_pending, slow_down, access_denied or expired_token.
+	PollDeviceToken(ctx context.Context, in *PollDeviceTokenRequest, opts ...grpc.CallOption) (*PollDeviceTokenResponse, error)
+}
+
+type deviceServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDeviceServiceClient(cc grpc.ClientConnInterface) DeviceServiceClient {
+	return &deviceServiceClient{cc}
+}
+
+func (c *deviceServiceClient) StartDeviceAuthorization(ctx context.Context, in *StartDeviceAuthorizationRequest, opts ...grpc.CallOption) (*StartDeviceAuthorizationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StartDeviceAuthorizationResponse)
+	err := c.cc.Invoke(ctx, DeviceService_StartDeviceAuthorization_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deviceServiceClient) ApproveDeviceAuthorization(ctx context.Context, in *ApproveDeviceAuthorizationRequest, opts ...grpc.CallOption) (*ApproveDeviceAuthorizationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ApproveDeviceAuthorizationResponse)
+	err := c.cc.Invoke(ctx, DeviceService_ApproveDeviceAuthorization_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deviceServiceClient) DenyDeviceAuthorization(ctx context.Context, in *DenyDeviceAuthorizationRequest, opts ...grpc.CallOption) (*DenyDeviceAuthorizationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DenyDeviceAuthorizationResponse)
+	err := c.cc.Invoke(ctx, DeviceService_DenyDeviceAuthorization_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deviceServiceClient) PollDeviceToken(ctx context.Context, in *PollDeviceTokenRequest, opts ...grpc.CallOption) (*PollDeviceTokenResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PollDeviceTokenResponse)
+	err := c.cc.Invoke(ctx, DeviceService_PollDeviceToken_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeviceServiceServer is the server API for DeviceService service.
+// All implementations must embed UnimplementedDeviceServiceServer
+// for forward compatibility.
+//
+// DeviceService implements the device authorization grant (RFC 8628)
+// for devices without a browser. ApproveDeviceAuthorization and
+// DenyDeviceAuthorization need the token of the user in the
+// "authorization: Bearer <token>" metadata, the device calls need none.
+type DeviceServiceServer interface {
+	// StartDeviceAuthorization issues a device code and a user code.
+	StartDeviceAuthorization(context.Context, *StartDeviceAuthorizationRequest) (*StartDeviceAuthorizationResponse, error)
+	// ApproveDeviceAuthorization lets the device with the user code log
+	// in as the caller.
+	ApproveDeviceAuthorization(context.Context, *ApproveDeviceAuthorizationRequest) (*ApproveDeviceAuthorizationResponse, error)
+	// DenyDeviceAuthorization rejects the device with the user code.
+	DenyDeviceAuthorization(context.Context, *DenyDeviceAuthorizationRequest) (*DenyDeviceAuthorizationResponse, error)
+	// PollDeviceToken returns the token once the user approved the
+	// device. Until then the status message is an RFC 8628 error code:
+	// authorization_pending, slow_down, access_denied or expired_token.
+	PollDeviceToken(context.Context, *PollDeviceTokenRequest) (*PollDeviceTokenResponse, error)
+	mustEmbedUnimplementedDeviceServiceServer()
+}
+
+// UnimplementedDeviceServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDeviceServiceServer struct{}
+
+func (UnimplementedDeviceServiceServer) StartDeviceAuthorization(context.Context, *StartDeviceAuthorizationRequest) (*StartDeviceAuthorizationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartDeviceAuthorization not implemented")
+}
+func (UnimplementedDeviceServiceServer) ApproveDeviceAuthorization(context.Context, *ApproveDeviceAuthorizationRequest) (*ApproveDeviceAuthorizationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ApproveDeviceAuthorization not implemented")
+}
+func (UnimplementedDeviceServiceServer) DenyDeviceAuthorization(context.Context, *DenyDeviceAuthorizationRequest) (*DenyDeviceAuthorizationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DenyDeviceAuthorization not implemented")
+}
+func (UnimplementedDeviceServiceServer) PollDeviceToken(context.Context, *PollDeviceTokenRequest) (*PollDeviceTokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PollDeviceToken not implemented")
+}
+func (UnimplementedDeviceServiceServer) mustEmbedUnimplementedDeviceServiceServer() {}
+func (UnimplementedDeviceServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeDeviceServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DeviceServiceServer will
+// result in compilation errors.
+type UnsafeDeviceServiceServer interface {
+	mustEmbedUnimplementedDeviceServiceServer()
+}
+
+func RegisterDeviceServiceServer(s grpc.ServiceRegistrar, srv DeviceServiceServer) {
+	// If the following call pancis, it indicates UnimplementedDeviceServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&DeviceService_ServiceDesc, srv)
+}
+
+func _DeviceService_StartDeviceAuthorization_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartDeviceAuthorizationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceServiceServer).StartDeviceAuthorization(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceService_StartDeviceAuthorization_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceServiceServer).StartDeviceAuthorization(ctx, req.(*StartDeviceAuthorizationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeviceService_ApproveDeviceAuthorization_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ApproveDeviceAuthorizationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceServiceServer).ApproveDeviceAuthorization(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceService_ApproveDeviceAuthorization_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceServiceServer).ApproveDeviceAuthorization(ctx, req.(*ApproveDeviceAuthorizationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeviceService_DenyDeviceAuthorization_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DenyDeviceAuthorizationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceServiceServer).DenyDeviceAuthorization(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceService_DenyDeviceAuthorization_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceServiceServer).DenyDeviceAuthorization(ctx, req.(*DenyDeviceAuthorizationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeviceService_PollDeviceToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PollDeviceTokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceServiceServer).PollDeviceToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceService_PollDeviceToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceServiceServer).PollDeviceToken(ctx, req.(*PollDeviceTokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// DeviceService_ServiceDesc is the grpc.ServiceDesc for DeviceService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DeviceService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sso.device.v1.DeviceService",
+	HandlerType: (*DeviceServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "StartDeviceAuthorization",
+			Handler:    _DeviceService_StartDeviceAuthorization_Handler,
+		},
+		{
+			MethodName: "ApproveDeviceAuthorization",
+			Handler:    _DeviceService_ApproveDeviceAuthorization_Handler,
+		},
+		{
+			MethodName: "DenyDeviceAuthorization",
+			Handler:    _DeviceService_DenyDeviceAuthorization_Handler,
+		},
+		{
+			MethodName: "PollDeviceToken",
+			Handler:    _DeviceService_PollDeviceToken_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sso/device/v1/device.proto",
+}
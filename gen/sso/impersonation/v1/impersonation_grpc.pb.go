@@ -0,0 +1,133 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: sso/impersonation/v1/impersonation.proto
+
+package impersonationv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ImpersonationService_Impersonate_FullMethodName = "/sso.impersonation.v1.ImpersonationService/Impersonate"
+)
+
+// ImpersonationServiceClient is the client API for ImpersonationService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ImpersonationService lets an organization admin act as a user of the
+// organization. The admin is the user of the token in the
+// "authorization: Bearer <token>" metadata.
+type ImpersonationServiceClient interface {
+	// Impersonate returns a token of the user with an act claim naming
+	// the admin. Every call is written to the audit log.
+	Impersonate(ctx context.Context, in *ImpersonateRequest, opts ...grpc.CallOption) (*ImpersonateResponse, error)
+}
+
+type impersonationServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewImpersonationServiceClient(cc grpc.ClientConnInterface) ImpersonationServiceClient {
+	return &impersonationServiceClient{cc}
+}
+
+func (c *impersonationServiceClient) Impersonate(ctx context.Context, in *ImpersonateRequest, opts ...grpc.CallOption) (*ImpersonateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ImpersonateResponse)
+	err := c.cc.Invoke(ctx, ImpersonationService_Impersonate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ImpersonationServiceServer is the server API for ImpersonationService service.
+// All implementations must embed UnimplementedImpersonationServiceServer
+// for forward compatibility.
+//
+// ImpersonationService lets an organization admin act as a user of the
+// organization. The admin is the user of the token in the
+// "authorization: Bearer <token>" metadata.
+type ImpersonationServiceServer interface {
+	// Impersonate returns a token of the user with an act claim naming
+	// the admin. Every call is written to the audit log.
+	Impersonate(context.Context, *ImpersonateRequest) (*ImpersonateResponse, error)
+	mustEmbedUnimplementedImpersonationServiceServer()
+}
+
+// UnimplementedImpersonationServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedImpersonationServiceServer struct{}
+
+func (UnimplementedImpersonationServiceServer) Impersonate(context.Context, *ImpersonateRequest) (*ImpersonateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Impersonate not implemented")
+}
+func (UnimplementedImpersonationServiceServer) mustEmbedUnimplementedImpersonationServiceServer() {}
+func (UnimplementedImpersonationServiceServer) testEmbeddedByValue()                              {}
+
+// UnsafeImpersonationServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ImpersonationServiceServer will
+// result in compilation errors.
+type UnsafeImpersonationServiceServer interface {
+	mustEmbedUnimplementedImpersonationServiceServer()
+}
+
+func RegisterImpersonationServiceServer(s grpc.ServiceRegistrar, srv ImpersonationServiceServer) {
+	// If the following call pancis, it indicates UnimplementedImpersonationServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ImpersonationService_ServiceDesc, srv)
+}
+
+func _ImpersonationService_Impersonate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImpersonateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ImpersonationServiceServer).Impersonate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ImpersonationService_Impersonate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ImpersonationServiceServer).Impersonate(ctx, req.(*ImpersonateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ImpersonationService_ServiceDesc is the grpc.ServiceDesc for ImpersonationService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ImpersonationService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sso.impersonation.v1.ImpersonationService",
+	HandlerType: (*ImpersonationServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Impersonate",
+			Handler:    _ImpersonationService_Impersonate_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sso/impersonation/v1/impersonation.proto",
+}
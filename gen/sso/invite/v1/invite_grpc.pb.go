@@ -0,0 +1,173 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: sso/invite/v1/invite.proto
+
+package invitev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	InviteService_CreateInvite_FullMethodName = "/sso.invite.v1.InviteService/CreateInvite"
+	InviteService_AcceptInvite_FullMethodName = "/sso.invite.v1.InviteService/AcceptInvite"
+)
+
+// InviteServiceClient is the client API for InviteService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// InviteService onboards users by invitation. CreateInvite needs the
+// token of an organization admin in the "authorization: Bearer <token>"
+// metadata, AcceptInvite is authorized by the invite token itself.
+type InviteServiceClient interface {
+	// CreateInvite invites an email into the organization of the caller.
+	CreateInvite(ctx context.Context, in *CreateInviteRequest, opts ...grpc.CallOption) (*CreateInviteResponse, error)
+	// AcceptInvite creates the invited user, or adds an existing user of
+	// the organization to it with the invited role.
+	AcceptInvite(ctx context.Context, in *AcceptInviteRequest, opts ...grpc.CallOption) (*AcceptInviteResponse, error)
+}
+
+type inviteServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewInviteServiceClient(cc grpc.ClientConnInterface) InviteServiceClient {
+	return &inviteServiceClient{cc}
+}
+
+func (c *inviteServiceClient) CreateInvite(ctx context.Context, in *CreateInviteRequest, opts ...grpc.CallOption) (*CreateInviteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateInviteResponse)
+	err := c.cc.Invoke(ctx, InviteService_CreateInvite_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *inviteServiceClient) AcceptInvite(ctx context.Context, in *AcceptInviteRequest, opts ...grpc.CallOption) (*AcceptInviteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AcceptInviteResponse)
+	err := c.cc.Invoke(ctx, InviteService_AcceptInvite_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// InviteServiceServer is the server API for InviteService service.
+// All implementations must embed UnimplementedInviteServiceServer
+// for forward compatibility.
+//
+// InviteService onboards users by invitation. CreateInvite needs the
+// token of an organization admin in the "authorization: Bearer <token>"
+// metadata, AcceptInvite is authorized by the invite token itself.
+type InviteServiceServer interface {
+	// CreateInvite invites an email into the organization of the caller.
+	CreateInvite(context.Context, *CreateInviteRequest) (*CreateInviteResponse, error)
+	// AcceptInvite creates the invited user, or adds an existing user of
+	// the organization to it with the invited role.
+	AcceptInvite(context.Context, *AcceptInviteRequest) (*AcceptInviteResponse, error)
+	mustEmbedUnimplementedInviteServiceServer()
+}
+
+// UnimplementedInviteServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedInviteServiceServer struct{}
+
+func (UnimplementedInviteServiceServer) CreateInvite(context.Context, *CreateInviteRequest) (*CreateInviteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateInvite not implemented")
+}
+func (UnimplementedInviteServiceServer) AcceptInvite(context.Context, *AcceptInviteRequest) (*AcceptInviteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AcceptInvite not implemented")
+}
+func (UnimplementedInviteServiceServer) mustEmbedUnimplementedInviteServiceServer() {}
+func (UnimplementedInviteServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeInviteServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to InviteServiceServer will
+// result in compilation errors.
+type UnsafeInviteServiceServer interface {
+	mustEmbedUnimplementedInviteServiceServer()
+}
+
+func RegisterInviteServiceServer(s grpc.ServiceRegistrar, srv InviteServiceServer) {
+	// If the following call pancis, it indicates UnimplementedInviteServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&InviteService_ServiceDesc, srv)
+}
+
+func _InviteService_CreateInvite_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateInviteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InviteServiceServer).CreateInvite(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: InviteService_CreateInvite_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InviteServiceServer).CreateInvite(ctx, req.(*CreateInviteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InviteService_AcceptInvite_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AcceptInviteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(InviteServiceServer).AcceptInvite(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: InviteService_AcceptInvite_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InviteServiceServer).AcceptInvite(ctx, req.(*AcceptInviteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// InviteService_ServiceDesc is the grpc.ServiceDesc for InviteService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var InviteService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sso.invite.v1.InviteService",
+	HandlerType: (*InviteServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateInvite",
+			Handler:    _InviteService_CreateInvite_Handler,
+		},
+		{
+			MethodName: "AcceptInvite",
+			Handler:    _InviteService_AcceptInvite_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sso/invite/v1/invite.proto",
+}
@@ -0,0 +1,335 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: sso/passkey/v1/passkey.proto
+
+package passkeyv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	PasskeyService_BeginRegistration_FullMethodName  = "/sso.passkey.v1.PasskeyService/BeginRegistration"
+	PasskeyService_FinishRegistration_FullMethodName = "/sso.passkey.v1.PasskeyService/FinishRegistration"
+	PasskeyService_BeginLogin_FullMethodName         = "/sso.passkey.v1.PasskeyService/BeginLogin"
+	PasskeyService_FinishLogin_FullMethodName        = "/sso.passkey.v1.PasskeyService/FinishLogin"
+	PasskeyService_ListCredentials_FullMethodName    = "/sso.passkey.v1.PasskeyService/ListCredentials"
+	PasskeyService_RemoveCredential_FullMethodName   = "/sso.passkey.v1.PasskeyService/RemoveCredential"
+)
+
+// PasskeyServiceClient is the client API for PasskeyService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// PasskeyService registers WebAuthn credentials and logs users in with
+// them. Each ceremony has a begin and a finish step tied together by a
+// session token. Registration and credential management need the token
+// of the user in the "authorization: Bearer <token>" metadata, login
+// does not.
+type PasskeyServiceClient interface {
+	// BeginRegistration returns the options for navigator.credentials.create.
+	BeginRegistration(ctx context.Context, in *BeginRegistrationRequest, opts ...grpc.CallOption) (*BeginRegistrationResponse, error)
+	// FinishRegistration verifies the attestation and stores the credential.
+	FinishRegistration(ctx context.Context, in *FinishRegistrationRequest, opts ...grpc.CallOption) (*FinishRegistrationResponse, error)
+	// BeginLogin returns the options for navigator.credentials.get.
+	BeginLogin(ctx context.Context, in *BeginLoginRequest, opts ...grpc.CallOption) (*BeginLoginResponse, error)
+	// FinishLogin verifies the assertion and returns the same token as Login.
+	FinishLogin(ctx context.Context, in *FinishLoginRequest, opts ...grpc.CallOption) (*FinishLoginResponse, error)
+	// ListCredentials lists the passkeys of the caller.
+	ListCredentials(ctx context.Context, in *ListCredentialsRequest, opts ...grpc.CallOption) (*ListCredentialsResponse, error)
+	// RemoveCredential removes a passkey of the caller.
+	RemoveCredential(ctx context.Context, in *RemoveCredentialRequest, opts ...grpc.CallOption) (*RemoveCredentialResponse, error)
+}
+
+type passkeyServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPasskeyServiceClient(cc grpc.ClientConnInterface) PasskeyServiceClient {
+	return &passkeyServiceClient{cc}
+}
+
+func (c *passkeyServiceClient) BeginRegistration(ctx context.Context, in *BeginRegistrationRequest, opts ...grpc.CallOption) (*BeginRegistrationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BeginRegistrationResponse)
+	err := c.cc.Invoke(ctx, PasskeyService_BeginRegistration_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passkeyServiceClient) FinishRegistration(ctx context.Context, in *FinishRegistrationRequest, opts ...grpc.CallOption) (*FinishRegistrationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FinishRegistrationResponse)
+	err := c.cc.Invoke(ctx, PasskeyService_FinishRegistration_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passkeyServiceClient) BeginLogin(ctx context.Context, in *BeginLoginRequest, opts ...grpc.CallOption) (*BeginLoginResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BeginLoginResponse)
+	err := c.cc.Invoke(ctx, PasskeyService_BeginLogin_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passkeyServiceClient) FinishLogin(ctx context.Context, in *FinishLoginRequest, opts ...grpc.CallOption) (*FinishLoginResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FinishLoginResponse)
+	err := c.cc.Invoke(ctx, PasskeyService_FinishLogin_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passkeyServiceClient) ListCredentials(ctx context.Context, in *ListCredentialsRequest, opts ...grpc.CallOption) (*ListCredentialsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListCredentialsResponse)
+	err := c.cc.Invoke(ctx, PasskeyService_ListCredentials_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passkeyServiceClient) RemoveCredential(ctx context.Context, in *RemoveCredentialRequest, opts ...grpc.CallOption) (*RemoveCredentialResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RemoveCredentialResponse)
+	err := c.cc.Invoke(ctx, PasskeyService_RemoveCredential_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PasskeyServiceServer is the server API for PasskeyService service.
+// All implementations must embed UnimplementedPasskeyServiceServer
+// for forward compatibility.
+//
+// PasskeyService registers WebAuthn credentials and logs users in with
+// them. Each ceremony has a begin and a finish step tied together by a
+// session token. Registration and credential management need the token
+// of the user in the "authorization: Bearer <token>" metadata, login
+// does not.
+type PasskeyServiceServer interface {
+	// BeginRegistration returns the options for navigator.credentials.create.
+	BeginRegistration(context.Context, *BeginRegistrationRequest) (*BeginRegistrationResponse, error)
+	// FinishRegistration verifies the attestation and stores the credential.
+	FinishRegistration(context.Context, *FinishRegistrationRequest) (*FinishRegistrationResponse, error)
+	// BeginLogin returns the options for navigator.credentials.get.
+	BeginLogin(context.Context, *BeginLoginRequest) (*BeginLoginResponse, error)
+	// FinishLogin verifies the assertion and returns the same token as Login.
+	FinishLogin(context.Context, *FinishLoginRequest) (*FinishLoginResponse, error)
+	// ListCredentials lists the passkeys of the caller.
+	ListCredentials(context.Context, *ListCredentialsRequest) (*ListCredentialsResponse, error)
+	// RemoveCredential removes a passkey of the caller.
+	RemoveCredential(context.Context, *RemoveCredentialRequest) (*RemoveCredentialResponse, error)
+	mustEmbedUnimplementedPasskeyServiceServer()
+}
+
+// UnimplementedPasskeyServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPasskeyServiceServer struct{}
+
+func (UnimplementedPasskeyServiceServer) BeginRegistration(context.Context, *BeginRegistrationRequest) (*BeginRegistrationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BeginRegistration not implemented")
+}
+func (UnimplementedPasskeyServiceServer) FinishRegistration(context.Context, *FinishRegistrationRequest) (*FinishRegistrationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FinishRegistration not implemented")
+}
+func (UnimplementedPasskeyServiceServer) BeginLogin(context.Context, *BeginLoginRequest) (*BeginLoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BeginLogin not implemented")
+}
+func (UnimplementedPasskeyServiceServer) FinishLogin(context.Context, *FinishLoginRequest) (*FinishLoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FinishLogin not implemented")
+}
+func (UnimplementedPasskeyServiceServer) ListCredentials(context.Context, *ListCredentialsRequest) (*ListCredentialsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCredentials not implemented")
+}
+func (UnimplementedPasskeyServiceServer) RemoveCredential(context.Context, *RemoveCredentialRequest) (*RemoveCredentialResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveCredential not implemented")
+}
+func (UnimplementedPasskeyServiceServer) mustEmbedUnimplementedPasskeyServiceServer() {}
+func (UnimplementedPasskeyServiceServer) testEmbeddedByValue()                        {}
+
+// UnsafePasskeyServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PasskeyServiceServer will
+// result in compilation errors.
+type UnsafePasskeyServiceServer interface {
+	mustEmbedUnimplementedPasskeyServiceServer()
+}
+
+func RegisterPasskeyServiceServer(s grpc.ServiceRegistrar, srv PasskeyServiceServer) {
+	// If the following call pancis, it indicates UnimplementedPasskeyServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&PasskeyService_ServiceDesc, srv)
+}
+
+func _PasskeyService_BeginRegistration_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BeginRegistrationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasskeyServiceServer).BeginRegistration(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PasskeyService_BeginRegistration_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasskeyServiceServer).BeginRegistration(ctx, req.(*BeginRegistrationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasskeyService_FinishRegistration_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FinishRegistrationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasskeyServiceServer).FinishRegistration(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PasskeyService_FinishRegistration_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasskeyServiceServer).FinishRegistration(ctx, req.(*FinishRegistrationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasskeyService_BeginLogin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BeginLoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasskeyServiceServer).BeginLogin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PasskeyService_BeginLogin_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasskeyServiceServer).BeginLogin(ctx, req.(*BeginLoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasskeyService_FinishLogin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FinishLoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasskeyServiceServer).FinishLogin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PasskeyService_FinishLogin_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasskeyServiceServer).FinishLogin(ctx, req.(*FinishLoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasskeyService_ListCredentials_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCredentialsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasskeyServiceServer).ListCredentials(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PasskeyService_ListCredentials_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasskeyServiceServer).ListCredentials(ctx, req.(*ListCredentialsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasskeyService_RemoveCredential_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveCredentialRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasskeyServiceServer).RemoveCredential(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PasskeyService_RemoveCredential_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasskeyServiceServer).RemoveCredential(ctx, req.(*RemoveCredentialRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// PasskeyService_ServiceDesc is the grpc.ServiceDesc for PasskeyService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PasskeyService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sso.passkey.v1.PasskeyService",
+	HandlerType: (*PasskeyServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "BeginRegistration",
+			Handler:    _PasskeyService_BeginRegistration_Handler,
+		},
+		{
+			MethodName: "FinishRegistration",
+			Handler:    _PasskeyService_FinishRegistration_Handler,
+		},
+		{
+			MethodName: "BeginLogin",
+			Handler:    _PasskeyService_BeginLogin_Handler,
+		},
+		{
+			MethodName: "FinishLogin",
+			Handler:    _PasskeyService_FinishLogin_Handler,
+		},
+		{
+			MethodName: "ListCredentials",
+			Handler:    _PasskeyService_ListCredentials_Handler,
+		},
+		{
+			MethodName: "RemoveCredential",
+			Handler:    _PasskeyService_RemoveCredential_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sso/passkey/v1/passkey.proto",
+}
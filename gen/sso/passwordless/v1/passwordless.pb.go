@@ -0,0 +1,321 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.35.1
+// 	protoc        (unknown)
+// source: sso/passwordless/v1/passwordless.proto
+
+package passwordlessv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type StartPasswordlessLoginRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Email string `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
+	AppId int32  `protobuf:"varint,2,opt,name=app_id,json=appId,proto3" json:"app_id,omitempty"`
+}
+
+func (x *StartPasswordlessLoginRequest) Reset() {
+	*x = StartPasswordlessLoginRequest{}
+	mi := &file_sso_passwordless_v1_passwordless_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartPasswordlessLoginRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartPasswordlessLoginRequest) ProtoMessage() {}
+
+func (x *StartPasswordlessLoginRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sso_passwordless_v1_passwordless_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartPasswordlessLoginRequest.ProtoReflect.Descriptor instead.
+func (*StartPasswordlessLoginRequest) Descriptor() ([]byte, []int) {
+	return file_sso_passwordless_v1_passwordless_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StartPasswordlessLoginRequest) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *StartPasswordlessLoginRequest) GetAppId() int32 {
+	if x != nil {
+		return x.AppId
+	}
+	return 0
+}
+
+type StartPasswordlessLoginResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *StartPasswordlessLoginResponse) Reset() {
+	*x = StartPasswordlessLoginResponse{}
+	mi := &file_sso_passwordless_v1_passwordless_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartPasswordlessLoginResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartPasswordlessLoginResponse) ProtoMessage() {}
+
+func (x *StartPasswordlessLoginResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sso_passwordless_v1_passwordless_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartPasswordlessLoginResponse.ProtoReflect.Descriptor instead.
+func (*StartPasswordlessLoginResponse) Descriptor() ([]byte, []int) {
+	return file_sso_passwordless_v1_passwordless_proto_rawDescGZIP(), []int{1}
+}
+
+type CompletePasswordlessLoginRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Email string `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
+	AppId int32  `protobuf:"varint,2,opt,name=app_id,json=appId,proto3" json:"app_id,omitempty"`
+	// Code from the email, or the token of the link in link mode.
+	Code string `protobuf:"bytes,3,opt,name=code,proto3" json:"code,omitempty"`
+}
+
+func (x *CompletePasswordlessLoginRequest) Reset() {
+	*x = CompletePasswordlessLoginRequest{}
+	mi := &file_sso_passwordless_v1_passwordless_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompletePasswordlessLoginRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompletePasswordlessLoginRequest) ProtoMessage() {}
+
+func (x *CompletePasswordlessLoginRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_sso_passwordless_v1_passwordless_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompletePasswordlessLoginRequest.ProtoReflect.Descriptor instead.
+func (*CompletePasswordlessLoginRequest) Descriptor() ([]byte, []int) {
+	return file_sso_passwordless_v1_passwordless_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CompletePasswordlessLoginRequest) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *CompletePasswordlessLoginRequest) GetAppId() int32 {
+	if x != nil {
+		return x.AppId
+	}
+	return 0
+}
+
+func (x *CompletePasswordlessLoginRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+type CompletePasswordlessLoginResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Token string `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+}
+
+func (x *CompletePasswordlessLoginResponse) Reset() {
+	*x = CompletePasswordlessLoginResponse{}
+	mi := &file_sso_passwordless_v1_passwordless_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompletePasswordlessLoginResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompletePasswordlessLoginResponse) ProtoMessage() {}
+
+func (x *CompletePasswordlessLoginResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sso_passwordless_v1_passwordless_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompletePasswordlessLoginResponse.ProtoReflect.Descriptor instead.
+func (*CompletePasswordlessLoginResponse) Descriptor() ([]byte, []int) {
+	return file_sso_passwordless_v1_passwordless_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CompletePasswordlessLoginResponse) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+var File_sso_passwordless_v1_passwordless_proto protoreflect.FileDescriptor
+
+var file_sso_passwordless_v1_passwordless_proto_rawDesc = []byte{
+	0x0a, 0x26, 0x73, 0x73, 0x6f, 0x2f, 0x70, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x6c, 0x65,
+	0x73, 0x73, 0x2f, 0x76, 0x31, 0x2f, 0x70, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x6c, 0x65,
+	0x73, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x13, 0x73, 0x73, 0x6f, 0x2e, 0x70, 0x61,
+	0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x6c, 0x65, 0x73, 0x73, 0x2e, 0x76, 0x31, 0x22, 0x4c, 0x0a,
+	0x1d, 0x53, 0x74, 0x61, 0x72, 0x74, 0x50, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x6c, 0x65,
+	0x73, 0x73, 0x4c, 0x6f, 0x67, 0x69, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14,
+	0x0a, 0x05, 0x65, 0x6d, 0x61, 0x69, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x65,
+	0x6d, 0x61, 0x69, 0x6c, 0x12, 0x15, 0x0a, 0x06, 0x61, 0x70, 0x70, 0x5f, 0x69, 0x64, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x61, 0x70, 0x70, 0x49, 0x64, 0x22, 0x20, 0x0a, 0x1e, 0x53,
+	0x74, 0x61, 0x72, 0x74, 0x50, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x6c, 0x65, 0x73, 0x73,
+	0x4c, 0x6f, 0x67, 0x69, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x63, 0x0a,
+	0x20, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x50, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72,
+	0x64, 0x6c, 0x65, 0x73, 0x73, 0x4c, 0x6f, 0x67, 0x69, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x6d, 0x61, 0x69, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x05, 0x65, 0x6d, 0x61, 0x69, 0x6c, 0x12, 0x15, 0x0a, 0x06, 0x61, 0x70, 0x70, 0x5f, 0x69,
+	0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x61, 0x70, 0x70, 0x49, 0x64, 0x12, 0x12,
+	0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x63, 0x6f,
+	0x64, 0x65, 0x22, 0x39, 0x0a, 0x21, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x50, 0x61,
+	0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x6c, 0x65, 0x73, 0x73, 0x4c, 0x6f, 0x67, 0x69, 0x6e, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x6b, 0x65, 0x6e,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x32, 0xa6, 0x02,
+	0x0a, 0x13, 0x50, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x6c, 0x65, 0x73, 0x73, 0x53, 0x65,
+	0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x81, 0x01, 0x0a, 0x16, 0x53, 0x74, 0x61, 0x72, 0x74, 0x50,
+	0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x6c, 0x65, 0x73, 0x73, 0x4c, 0x6f, 0x67, 0x69, 0x6e,
+	0x12, 0x32, 0x2e, 0x73, 0x73, 0x6f, 0x2e, 0x70, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x6c,
+	0x65, 0x73, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x61, 0x72, 0x74, 0x50, 0x61, 0x73, 0x73,
+	0x77, 0x6f, 0x72, 0x64, 0x6c, 0x65, 0x73, 0x73, 0x4c, 0x6f, 0x67, 0x69, 0x6e, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x33, 0x2e, 0x73, 0x73, 0x6f, 0x2e, 0x70, 0x61, 0x73, 0x73, 0x77,
+	0x6f, 0x72, 0x64, 0x6c, 0x65, 0x73, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x61, 0x72, 0x74,
+	0x50, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x6c, 0x65, 0x73, 0x73, 0x4c, 0x6f, 0x67, 0x69,
+	0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x8a, 0x01, 0x0a, 0x19, 0x43, 0x6f,
+	0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x50, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x6c, 0x65,
+	0x73, 0x73, 0x4c, 0x6f, 0x67, 0x69, 0x6e, 0x12, 0x35, 0x2e, 0x73, 0x73, 0x6f, 0x2e, 0x70, 0x61,
+	0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x6c, 0x65, 0x73, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f,
+	0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x50, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x6c, 0x65,
+	0x73, 0x73, 0x4c, 0x6f, 0x67, 0x69, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x36,
+	0x2e, 0x73, 0x73, 0x6f, 0x2e, 0x70, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x6c, 0x65, 0x73,
+	0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x50, 0x61, 0x73,
+	0x73, 0x77, 0x6f, 0x72, 0x64, 0x6c, 0x65, 0x73, 0x73, 0x4c, 0x6f, 0x67, 0x69, 0x6e, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x2c, 0x5a, 0x2a, 0x73, 0x73, 0x6f, 0x2f, 0x67, 0x65,
+	0x6e, 0x2f, 0x73, 0x73, 0x6f, 0x2f, 0x70, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x6c, 0x65,
+	0x73, 0x73, 0x2f, 0x76, 0x31, 0x3b, 0x70, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x6c, 0x65,
+	0x73, 0x73, 0x76, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_sso_passwordless_v1_passwordless_proto_rawDescOnce sync.Once
+	file_sso_passwordless_v1_passwordless_proto_rawDescData = file_sso_passwordless_v1_passwordless_proto_rawDesc
+)
+
+func file_sso_passwordless_v1_passwordless_proto_rawDescGZIP() []byte {
+	file_sso_passwordless_v1_passwordless_proto_rawDescOnce.Do(func() {
+		file_sso_passwordless_v1_passwordless_proto_rawDescData = protoimpl.X.CompressGZIP(file_sso_passwordless_v1_passwordless_proto_rawDescData)
+	})
+	return file_sso_passwordless_v1_passwordless_proto_rawDescData
+}
+
+var file_sso_passwordless_v1_passwordless_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_sso_passwordless_v1_passwordless_proto_goTypes = []any{
+	(*StartPasswordlessLoginRequest)(nil),     // 0: sso.passwordless.v1.StartPasswordlessLoginRequest
+	(*StartPasswordlessLoginResponse)(nil),    // 1: sso.passwordless.v1.StartPasswordlessLoginResponse
+	(*CompletePasswordlessLoginRequest)(nil),  // 2: sso.passwordless.v1.CompletePasswordlessLoginRequest
+	(*CompletePasswordlessLoginResponse)(nil), // 3: sso.passwordless.v1.CompletePasswordlessLoginResponse
+}
+var file_sso_passwordless_v1_passwordless_proto_depIdxs = []int32{
+	0, // 0: sso.passwordless.v1.PasswordlessService.StartPasswordlessLogin:input_type -> sso.passwordless.v1.StartPasswordlessLoginRequest
+	2, // 1: sso.passwordless.v1.PasswordlessService.CompletePasswordlessLogin:input_type -> sso.passwordless.v1.CompletePasswordlessLoginRequest
+	1, // 2: sso.passwordless.v1.PasswordlessService.StartPasswordlessLogin:output_type -> sso.passwordless.v1.StartPasswordlessLoginResponse
+	3, // 3: sso.passwordless.v1.PasswordlessService.CompletePasswordlessLogin:output_type -> sso.passwordless.v1.CompletePasswordlessLoginResponse
+	2, // [2:4] is the sub-list for method output_type
+	0, // [0:2] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_sso_passwordless_v1_passwordless_proto_init() }
+func file_sso_passwordless_v1_passwordless_proto_init() {
+	if File_sso_passwordless_v1_passwordless_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_sso_passwordless_v1_passwordless_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_sso_passwordless_v1_passwordless_proto_goTypes,
+		DependencyIndexes: file_sso_passwordless_v1_passwordless_proto_depIdxs,
+		MessageInfos:      file_sso_passwordless_v1_passwordless_proto_msgTypes,
+	}.Build()
+	File_sso_passwordless_v1_passwordless_proto = out.File
+	file_sso_passwordless_v1_passwordless_proto_rawDesc = nil
+	file_sso_passwordless_v1_passwordless_proto_goTypes = nil
+	file_sso_passwordless_v1_passwordless_proto_depIdxs = nil
+}
@@ -0,0 +1,175 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: sso/passwordless/v1/passwordless.proto
+
+package passwordlessv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	PasswordlessService_StartPasswordlessLogin_FullMethodName    = "/sso.passwordless.v1.PasswordlessService/StartPasswordlessLogin"
+	PasswordlessService_CompletePasswordlessLogin_FullMethodName = "/sso.passwordless.v1.PasswordlessService/CompletePasswordlessLogin"
+)
+
+// PasswordlessServiceClient is the client API for PasswordlessService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// PasswordlessService logs users in with a one-time code or link token
+// sent to their email instead of a password.
+type PasswordlessServiceClient interface {
+	// StartPasswordlessLogin sends a one-time secret to the email. It
+	// succeeds for unknown emails too, so it can't be used to find out
+	// which emails are registered.
+	StartPasswordlessLogin(ctx context.Context, in *StartPasswordlessLoginRequest, opts ...grpc.CallOption) (*StartPasswordlessLoginResponse, error)
+	// CompletePasswordlessLogin exchanges the secret for the same token
+	// as Login.
+	CompletePasswordlessLogin(ctx context.Context, in *CompletePasswordlessLoginRequest, opts ...grpc.CallOption) (*CompletePasswordlessLoginResponse, error)
+}
+
+type passwordlessServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPasswordlessServiceClient(cc grpc.ClientConnInterface) PasswordlessServiceClient {
+	return &passwordlessServiceClient{cc}
+}
+
+func (c *passwordlessServiceClient) StartPasswordlessLogin(ctx context.Context, in *StartPasswordlessLoginRequest, opts ...grpc.CallOption) (*StartPasswordlessLoginResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StartPasswordlessLoginResponse)
+	err := c.cc.Invoke(ctx, PasswordlessService_StartPasswordlessLogin_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *passwordlessServiceClient) CompletePasswordlessLogin(ctx context.Context, in *CompletePasswordlessLoginRequest, opts ...grpc.CallOption) (*CompletePasswordlessLoginResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CompletePasswordlessLoginResponse)
+	err := c.cc.Invoke(ctx, PasswordlessService_CompletePasswordlessLogin_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// PasswordlessServiceServer is the server API for PasswordlessService service.
+// All implementations must embed UnimplementedPasswordlessServiceServer
+// for forward compatibility.
+//
+// PasswordlessService logs users in with a one-time code or link token
+// sent to their email instead of a password.
+type PasswordlessServiceServer interface {
+	// StartPasswordlessLogin sends a one-time secret to the email. It
+	// succeeds for unknown emails too, so it can't be used to find out
+	// which emails are registered.
+	StartPasswordlessLogin(context.Context, *StartPasswordlessLoginRequest) (*StartPasswordlessLoginResponse, error)
+	// CompletePasswordlessLogin exchanges the secret for the same token
+	// as Login.
+	CompletePasswordlessLogin(context.Context, *CompletePasswordlessLoginRequest) (*CompletePasswordlessLoginResponse, error)
+	mustEmbedUnimplementedPasswordlessServiceServer()
+}
+
+// UnimplementedPasswordlessServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPasswordlessServiceServer struct{}
+
+func (UnimplementedPasswordlessServiceServer) StartPasswordlessLogin(context.Context, *StartPasswordlessLoginRequest) (*StartPasswordlessLoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartPasswordlessLogin not implemented")
+}
+func (UnimplementedPasswordlessServiceServer) CompletePasswordlessLogin(context.Context, *CompletePasswordlessLoginRequest) (*CompletePasswordlessLoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompletePasswordlessLogin not implemented")
+}
+func (UnimplementedPasswordlessServiceServer) mustEmbedUnimplementedPasswordlessServiceServer() {}
+func (UnimplementedPasswordlessServiceServer) testEmbeddedByValue()                             {}
+
+// UnsafePasswordlessServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PasswordlessServiceServer will
+// result in compilation errors.
+type UnsafePasswordlessServiceServer interface {
+	mustEmbedUnimplementedPasswordlessServiceServer()
+}
+
+func RegisterPasswordlessServiceServer(s grpc.ServiceRegistrar, srv PasswordlessServiceServer) {
+	// If the following call pancis, it indicates UnimplementedPasswordlessServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&PasswordlessService_ServiceDesc, srv)
+}
+
+func _PasswordlessService_StartPasswordlessLogin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartPasswordlessLoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordlessServiceServer).StartPasswordlessLogin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PasswordlessService_StartPasswordlessLogin_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordlessServiceServer).StartPasswordlessLogin(ctx, req.(*StartPasswordlessLoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PasswordlessService_CompletePasswordlessLogin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompletePasswordlessLoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PasswordlessServiceServer).CompletePasswordlessLogin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PasswordlessService_CompletePasswordlessLogin_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PasswordlessServiceServer).CompletePasswordlessLogin(ctx, req.(*CompletePasswordlessLoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// PasswordlessService_ServiceDesc is the grpc.ServiceDesc for PasswordlessService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PasswordlessService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sso.passwordless.v1.PasswordlessService",
+	HandlerType: (*PasswordlessServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "StartPasswordlessLogin",
+			Handler:    _PasswordlessService_StartPasswordlessLogin_Handler,
+		},
+		{
+			MethodName: "CompletePasswordlessLogin",
+			Handler:    _PasswordlessService_CompletePasswordlessLogin_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sso/passwordless/v1/passwordless.proto",
+}
@@ -2,25 +2,215 @@ package app
 
 import (
 	"context"
+	"crypto/tls"
 	"log/slog"
+	debugapp "sso/interanal/app/debug"
 	grpcapp "sso/interanal/app/grpc"
+	metricsapp "sso/interanal/app/metrics"
+	"sso/interanal/config"
+	"sso/interanal/delivery"
+	"sso/interanal/lifecycle"
+	"sso/interanal/metrics"
+	"sso/interanal/service/admin"
 	"sso/interanal/service/auth"
-	"sso/interanal/storage/postgres"
-	"time"
+	"sso/interanal/service/device"
+	"sso/interanal/service/impersonation"
+	"sso/interanal/service/invite"
+	"sso/interanal/service/passkey"
+	"sso/interanal/service/passwordless"
+	"sso/interanal/storage/cache"
+	"sso/interanal/tracing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 type App struct {
 	GrpcServer *grpcapp.GrpcApp
-	Conn       *postgres.Storage
+	// MetricsServer is nil when metrics are disabled in the config.
+	MetricsServer *metricsapp.MetricsApp
+	// DebugServer is nil when the debug listener is disabled in the config.
+	DebugServer   *debugapp.DebugApp
+	Conn          Storage
+	AuthService   *auth.AuthService
+	InviteService *invite.InviteService
+	// PasswordlessService uses the log sender until a real delivery is configured.
+	PasswordlessService *passwordless.PasswordlessService
+	PasskeyService      *passkey.PasskeyService
+	DeviceService       *device.DeviceService
+	// ImpersonationService issues short-lived tokens, see cfg.Impersonation.
+	ImpersonationService *impersonation.ImpersonationService
+	// AdminService is served only over mTLS, see grpcapp.Services.
+	AdminService   *admin.AdminService
+	TracerProvider *sdktrace.TracerProvider
+	lifecycle      *lifecycle.Manager
 }
 
-func New(ctx context.Context, logger *slog.Logger, port int, db string, tokenTTL time.Duration) *App {
-	storage := postgres.MustNewConnection(ctx, db)
-	logger.Info("storage init successfully")
-	authService := auth.New(logger, storage, storage, storage, tokenTTL)
-	grpcApp := grpcapp.New(logger, authService, port)
+func New(ctx context.Context, logger *slog.Logger, cfg *config.Config) *App {
+	tracerProvider, err := tracing.New(ctx, tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		panic(err)
+	}
+	if cfg.Storage.MigrateOnStart {
+		mustMigrate(ctx, logger, cfg)
+	}
+	storage := MustNewStorage(ctx, logger, cfg)
+	logger.Info("storage init successfully", slog.String("driver", cfg.Storage.Driver))
+	rotated, err := storage.RotateAppSecrets(ctx)
+	if err != nil {
+		panic(err)
+	}
+	if rotated > 0 {
+		logger.Info("app secrets encrypted with the primary key", slog.Int("count", rotated))
+	}
+	mustNotServeTestApp(ctx, storage, cfg)
+	// Only postgres has a connection pool worth exporting.
+	pool, hasPool := storage.(metrics.PoolStater)
+	// Login flows read apps and users through the cache, everything
+	// else goes to the storage. Writes of apps and users go through
+	// the cache too, so they invalidate its entries.
+	var lookup cache.Backend = storage
+	if cfg.Cache.Enabled {
+		c := cache.New(storage, cache.Options{
+			TTL:         cfg.Cache.TTL,
+			NegativeTTL: cfg.Cache.NegativeTTL,
+			MaxEntries:  cfg.Cache.MaxEntries,
+		})
+		lookup = c
+		storage = cachedStorage{Storage: storage, cache: c}
+	}
+	authService := auth.New(logger, lookup, lookup, lookup, cfg.TokenTTL, cfg.Registration.IsOpen())
+	// AcceptInvite reads users inside a transaction, where a cached
+	// entry would not see what the transaction wrote.
+	inviteService := invite.New(logger, storage, storage, storage, storage, storage, storage, cfg.Registration.InviteTTL)
+	passwordlessService := passwordless.New(
+		logger,
+		storage,
+		lookup,
+		lookup,
+		lookup,
+		newSender(logger, cfg),
+		passwordless.Options{
+			Mode:             cfg.Passwordless.Mode,
+			CodeLength:       cfg.Passwordless.CodeLength,
+			CodeTTL:          cfg.Passwordless.CodeTTL,
+			MaxAttempts:      cfg.Passwordless.MaxAttempts,
+			TokenTTL:         cfg.TokenTTL,
+			OpenRegistration: cfg.Registration.IsOpen(),
+		},
+	)
+	passkeyService, err := passkey.New(logger, storage, storage, lookup, lookup, passkey.Options{
+		RPID:          cfg.WebAuthn.RPID,
+		RPDisplayName: cfg.WebAuthn.RPDisplayName,
+		RPOrigins:     cfg.WebAuthn.RPOrigins,
+		SessionTTL:    cfg.WebAuthn.SessionTTL,
+		TokenTTL:      cfg.TokenTTL,
+	})
+	if err != nil {
+		panic(err)
+	}
+	deviceService := device.New(logger, storage, lookup, lookup, device.Options{
+		VerificationURI: cfg.Device.VerificationURI,
+		CodeTTL:         cfg.Device.CodeTTL,
+		PollInterval:    cfg.Device.PollInterval,
+		TokenTTL:        cfg.TokenTTL,
+	})
+	impersonationService := impersonation.New(logger, lookup, storage, lookup, storage, cfg.Impersonation.TokenTTL)
+	adminService := admin.New(logger, storage, storage, storage, storage, storage, storage)
+	var serverTLS *tls.Config
+	if cfg.GRPC.TLSCertFile != "" {
+		serverTLS, err = grpcapp.LoadTLS(cfg.GRPC.TLSCertFile, cfg.GRPC.TLSKeyFile, cfg.GRPC.TLSClientCAFile)
+		if err != nil {
+			panic(err)
+		}
+	}
+	grpcApp := grpcapp.New(logger, grpcapp.Services{
+		Auth:          authService,
+		Invite:        inviteService,
+		Passwordless:  passwordlessService,
+		Passkey:       passkeyService,
+		Device:        deviceService,
+		Impersonation: impersonationService,
+		Admin:         adminService,
+	}, lookup, storage, storage, grpcapp.Options{
+		Port:                cfg.GRPC.Port,
+		HealthCheckInterval: cfg.GRPC.HealthCheckInterval,
+		Reflection:          cfg.GRPC.Reflection,
+		DrainDelay:          cfg.GRPC.DrainDelay,
+		TLS:                 serverTLS,
+	})
+	var metricsApp *metricsapp.MetricsApp
+	if cfg.Metrics.Enabled {
+		registry := metrics.NewRegistry()
+		if hasPool {
+			if err := metrics.RegisterPool(registry, pool); err != nil {
+				panic(err)
+			}
+		}
+		metricsApp = metricsapp.New(logger, cfg.Metrics.Port, registry)
+	}
+	var debugApp *debugapp.DebugApp
+	if cfg.Debug.Enabled {
+		debugApp = debugapp.New(logger, cfg.Debug.Host, cfg.Debug.Port, config.Redacted(cfg))
+	}
+	manager := lifecycle.New(logger, cfg.ShutdownTimeout)
+	manager.Append(lifecycle.Hook{Name: "storage", Stop: storage.Stop})
+	manager.Append(lifecycle.Hook{Name: "tracing", Stop: tracerProvider.Shutdown})
+	if metricsApp != nil {
+		manager.Append(lifecycle.Hook{Name: "metrics", Run: metricsApp.Run, Stop: metricsApp.Stop})
+	}
+	if debugApp != nil {
+		manager.Append(lifecycle.Hook{Name: "debug", Run: debugApp.Run, Stop: debugApp.Stop})
+	}
+	manager.Append(lifecycle.Hook{Name: "grpc", Run: grpcApp.Run, Stop: grpcApp.Stop})
 	return &App{
-		GrpcServer: grpcApp,
-		Conn:       storage,
+		GrpcServer:           grpcApp,
+		MetricsServer:        metricsApp,
+		DebugServer:          debugApp,
+		Conn:                 storage,
+		AuthService:          authService,
+		InviteService:        inviteService,
+		PasswordlessService:  passwordlessService,
+		PasskeyService:       passkeyService,
+		DeviceService:        deviceService,
+		ImpersonationService: impersonationService,
+		AdminService:         adminService,
+		TracerProvider:       tracerProvider,
+		lifecycle:            manager,
+	}
+}
+
+// newSender returns the passwordless sender of cfg. Validate allows
+// the log sender only in the local env, as it logs plaintext codes.
+func newSender(logger *slog.Logger, cfg *config.Config) passwordless.Sender {
+	if cfg.Passwordless.Delivery == config.DeliveryLog {
+		return delivery.NewLogSender(logger)
 	}
+	return delivery.NoneSender{}
+}
+
+// Reload applies the settings that config.Config.RestartRequired
+// treats as reloadable. token_ttl applies to every login flow, while
+// impersonation tokens keep their own impersonation.token_ttl.
+func (a *App) Reload(cfg *config.Config) {
+	a.AuthService.SetTokenTTL(cfg.TokenTTL)
+	a.PasswordlessService.SetTokenTTL(cfg.TokenTTL)
+	a.PasskeyService.SetTokenTTL(cfg.TokenTTL)
+	a.DeviceService.SetTokenTTL(cfg.TokenTTL)
+	a.AuthService.SetOpenRegistration(cfg.Registration.IsOpen())
+	a.PasswordlessService.SetOpenRegistration(cfg.Registration.IsOpen())
+	a.PasswordlessService.SetMaxAttempts(cfg.Passwordless.MaxAttempts)
+	if a.DebugServer != nil {
+		a.DebugServer.SetConfig(config.Redacted(cfg))
+	}
+}
+
+// Run serves until ctx is canceled or a server fails, then stops
+// the gRPC server first and the storage last.
+func (a *App) Run(ctx context.Context) error {
+	return a.lifecycle.Run(ctx)
 }
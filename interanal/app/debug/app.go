@@ -0,0 +1,69 @@
+package debugapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"sync/atomic"
+)
+
+// DebugApp serves pprof and the redacted config. It is meant to be
+// bound to a loopback address and reached with port forwarding.
+type DebugApp struct {
+	logger *slog.Logger
+	server *http.Server
+	config atomic.Pointer[any]
+}
+
+func New(logger *slog.Logger, host string, port int, redactedConfig any) *DebugApp {
+	a := &DebugApp{logger: logger}
+	a.SetConfig(redactedConfig)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/config", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(*a.config.Load()); err != nil {
+			logger.Error("failed to write config", slog.String("err", err.Error()))
+		}
+	})
+	a.server = &http.Server{Addr: net.JoinHostPort(host, strconv.Itoa(port)), Handler: mux}
+	return a
+}
+
+// SetConfig replaces the dumped config, e.g. after a reload. It must
+// already be redacted.
+func (a *DebugApp) SetConfig(redactedConfig any) {
+	a.config.Store(&redactedConfig)
+}
+
+func (a *DebugApp) Run() error {
+	const op = "debugapp.Run"
+	logger := a.logger.With(slog.String("op", op))
+	logger.Info("debug server is running", slog.String("addr", a.server.Addr))
+	if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error(err.Error())
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (a *DebugApp) Stop(ctx context.Context) error {
+	const op = "debugapp.Stop"
+	a.logger.Info("stopping server", slog.String("op", op), slog.String("addr", a.server.Addr))
+	if err := a.server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package debugapp
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sso/interanal/config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConfigDumpIsRedacted проверяет, что дамп конфига
+// не содержит секретов.
+func TestConfigDumpIsRedacted(t *testing.T) {
+	a := newTestApp(config.DatabaseConfig{User: "sso", Password: "qwerty"})
+
+	resp := get(t, a, "/debug/config")
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"user": "sso"`)
+	assert.NotContains(t, resp.Body.String(), "qwerty")
+}
+
+// TestConfigDumpFollowsReload проверяет, что после
+// перезагрузки отдается новый конфиг.
+func TestConfigDumpFollowsReload(t *testing.T) {
+	a := newTestApp(config.Config{TokenTTL: time.Hour})
+
+	a.SetConfig(config.Redacted(config.Config{TokenTTL: 2 * time.Hour}))
+	resp := get(t, a, "/debug/config")
+
+	assert.Contains(t, resp.Body.String(), `"token_ttl": "2h0m0s"`)
+}
+
+// TestPprofIsServed проверяет, что индекс pprof доступен.
+func TestPprofIsServed(t *testing.T) {
+	a := newTestApp(config.Config{})
+
+	resp := get(t, a, "/debug/pprof/")
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), "goroutine")
+}
+
+func newTestApp(cfg any) *DebugApp {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return New(logger, "127.0.0.1", 0, config.Redacted(cfg))
+}
+
+func get(t *testing.T, a *DebugApp, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, path, nil)
+	require.NoError(t, err)
+	resp := httptest.NewRecorder()
+	a.server.Handler.ServeHTTP(resp, req)
+	return resp
+}
@@ -1,37 +1,154 @@
 package grpcapp
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"log/slog"
 	"net"
+	admingrpc "sso/interanal/grpc/admin"
 	authgrpc "sso/interanal/grpc/auth"
+	"sso/interanal/grpc/authn"
+	devicegrpc "sso/interanal/grpc/device"
+	impersonationgrpc "sso/interanal/grpc/impersonation"
+	invitegrpc "sso/interanal/grpc/invite"
+	passkeygrpc "sso/interanal/grpc/passkey"
+	passwordlessgrpc "sso/interanal/grpc/passwordless"
+	"sso/interanal/logging"
+	"sso/interanal/metrics"
+	"sso/interanal/tracing"
+	"sync"
+	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
 type GrpcApp struct {
 	logger     *slog.Logger
 	grpcServer *grpc.Server
-	port       int
+	health     *health.Server
+	pinger     Pinger
+	// dependingOnDB get NOT_SERVING while the database is unreachable.
+	// The empty name is the overall server status.
+	dependingOnDB  []string
+	healthInterval time.Duration
+	drainDelay     time.Duration
+	done           chan struct{}
+	stopOnce       sync.Once
+	port           int
 }
 
-func New(logger *slog.Logger, authService authgrpc.Auth, port int) *GrpcApp {
-	grpcServer := grpc.NewServer()
-	authgrpc.RegisterServerAPI(grpcServer, authService)
+type Options struct {
+	Port                int
+	HealthCheckInterval time.Duration
+	Reflection          bool
+	// DrainDelay keeps accepting calls for a while after the health
+	// status turns NOT_SERVING, so load balancers stop routing here
+	// before GracefulStop refuses new calls.
+	DrainDelay time.Duration
+	// TLS serves over TLS when set, see LoadTLS. Nil serves plaintext.
+	TLS *tls.Config
+}
+
+// Services are the services served over gRPC. Auth is required, the
+// others are registered only when set. Admin also needs Options.TLS
+// to require client certificates.
+type Services struct {
+	Auth          authgrpc.Auth
+	Invite        invitegrpc.Invite
+	Passwordless  passwordlessgrpc.Passwordless
+	Passkey       passkeygrpc.Passkey
+	Device        devicegrpc.Device
+	Impersonation impersonationgrpc.Impersonation
+	Admin         admingrpc.Admin
+}
+
+// New serves services on the port of opts. Bearer tokens are checked
+// against apps and against the session revocations of sessions.
+func New(
+	logger *slog.Logger,
+	services Services,
+	apps authgrpc.AppProvider,
+	sessions authn.SessionProvider,
+	pinger Pinger,
+	opts Options,
+) *GrpcApp {
+	serverOpts := []grpc.ServerOption{
+		tracing.ServerOption(),
+		grpc.ChainUnaryInterceptor(
+			logging.UnaryServerInterceptor(),
+			metrics.UnaryServerInterceptor(),
+			consistencyInterceptor(),
+		),
+	}
+	if opts.TLS != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(opts.TLS)))
+	}
+	grpcServer := grpc.NewServer(serverOpts...)
+	authenticator := authn.New(apps, sessions)
+	authgrpc.RegisterServerAPI(grpcServer, services.Auth, apps)
+	if services.Invite != nil {
+		invitegrpc.RegisterServerAPI(grpcServer, services.Invite, authenticator)
+	}
+	if services.Passwordless != nil {
+		passwordlessgrpc.RegisterServerAPI(grpcServer, services.Passwordless)
+	}
+	if services.Passkey != nil {
+		passkeygrpc.RegisterServerAPI(grpcServer, services.Passkey, authenticator)
+	}
+	if services.Device != nil {
+		devicegrpc.RegisterServerAPI(grpcServer, services.Device, authenticator)
+	}
+	if services.Impersonation != nil {
+		impersonationgrpc.RegisterServerAPI(grpcServer, services.Impersonation, authenticator)
+	}
+	if services.Admin != nil {
+		if requiresClientCerts(opts.TLS) {
+			admingrpc.RegisterServerAPI(grpcServer, services.Admin)
+		} else {
+			logger.Warn("admin service is not served: it needs grpc.tls_client_ca_file")
+		}
+	}
+	// Every service registered so far needs the database.
+	dependingOnDB := []string{""}
+	for name := range grpcServer.GetServiceInfo() {
+		dependingOnDB = append(dependingOnDB, name)
+	}
+	healthServer := health.NewServer()
+	// Nothing is ready until the first database ping succeeds.
+	for _, service := range dependingOnDB {
+		healthServer.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	if opts.Reflection {
+		reflection.Register(grpcServer)
+	}
 	return &GrpcApp{
-		logger:     logger,
-		grpcServer: grpcServer,
-		port:       port,
+		logger:         logger,
+		grpcServer:     grpcServer,
+		health:         healthServer,
+		pinger:         pinger,
+		dependingOnDB:  dependingOnDB,
+		healthInterval: opts.HealthCheckInterval,
+		drainDelay:     opts.DrainDelay,
+		done:           make(chan struct{}),
+		port:           opts.Port,
 	}
 }
 
-func (a *GrpcApp) MustRun() {
-	if err := a.run(); err != nil {
-		panic(err)
-	}
+// requiresClientCerts reports whether every client must present a
+// certificate the server verifies, which is the only authentication of
+// the admin service.
+func requiresClientCerts(cfg *tls.Config) bool {
+	return cfg != nil && cfg.ClientAuth == tls.RequireAndVerifyClientCert
 }
 
-func (a *GrpcApp) run() error {
+// Run listens on the configured port and serves until Stop is called.
+func (a *GrpcApp) Run() error {
 	const op = "grpcapp.Run"
 	logger := a.logger.With(slog.String("op", op))
 	logger.Info("starting grpc server")
@@ -40,7 +157,14 @@ func (a *GrpcApp) run() error {
 		logger.Error(err.Error())
 		return fmt.Errorf("%s: %w", op, err)
 	}
+	return a.serve(l)
+}
+
+func (a *GrpcApp) serve(l net.Listener) error {
+	const op = "grpcapp.serve"
+	logger := a.logger.With(slog.String("op", op))
 	logger.Info("grpc server is running", slog.String("addr", l.Addr().String()))
+	go a.watchReadiness()
 
 	if err := a.grpcServer.Serve(l); err != nil {
 		logger.Error(err.Error())
@@ -49,8 +173,37 @@ func (a *GrpcApp) run() error {
 	return nil
 }
 
-func (a *GrpcApp) Stop() {
+// Stop waits out the drain delay, then waits for in-flight calls until
+// ctx is done and closes the remaining connections.
+func (a *GrpcApp) Stop(ctx context.Context) error {
 	const op = "grpcapp.Stop"
-	a.logger.Info("stopping server", slog.String("op", op), slog.Int("port", a.port))
-	a.grpcServer.GracefulStop()
+	logger := a.logger.With(slog.String("op", op), slog.Int("port", a.port))
+	logger.Info("stopping server")
+	// Shutdown switches every service to NOT_SERVING and ignores
+	// later updates, so probes fail while in-flight calls finish.
+	a.health.Shutdown()
+	a.stopOnce.Do(func() { close(a.done) })
+	if a.drainDelay > 0 {
+		logger.Info("waiting for load balancers to notice", slog.Duration("drain_delay", a.drainDelay))
+		select {
+		case <-time.After(a.drainDelay):
+		case <-ctx.Done():
+		}
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		a.grpcServer.GracefulStop()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		logger.Warn("shutdown deadline exceeded, closing connections")
+		// Stop cancels the contexts of in-flight calls. A handler that
+		// ignores its context would block GracefulStop, so don't wait for it.
+		a.grpcServer.Stop()
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	}
 }
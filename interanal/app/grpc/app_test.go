@@ -0,0 +1,118 @@
+package grpcapp
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net"
+	"sso/interanal/service/admin"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// TestStopClosesConnectionsAfterDeadline проверяет, что
+// зависший вызов не держит остановку дольше дедлайна.
+func TestStopClosesConnectionsAfterDeadline(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := New(logger, Services{Auth: fakeAuth{}}, fakeAuth{}, fakeAuth{}, &fakePinger{}, Options{HealthCheckInterval: time.Second})
+	entered, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	a.grpcServer.RegisterService(blockingServiceDesc(entered, release), nil)
+	listener := bufconn.Listen(1 << 20)
+	served := make(chan error, 1)
+	go func() { served <- a.serve(listener) }()
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+	callErr := make(chan error, 1)
+	go func() {
+		callErr <- conn.Invoke(context.Background(), "/test.Blocking/Wait", &emptypb.Empty{}, &emptypb.Empty{})
+	}()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = a.Stop(ctx)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Error(t, <-callErr)
+	assert.NoError(t, <-served)
+}
+
+// TestStopWaitsDrainDelay проверяет, что до GracefulStop
+// сервер уже отвечает NOT_SERVING и ждет drain delay.
+func TestStopWaitsDrainDelay(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := New(logger, Services{Auth: fakeAuth{}}, fakeAuth{}, fakeAuth{}, &fakePinger{}, Options{HealthCheckInterval: time.Second, DrainDelay: 50 * time.Millisecond})
+	a.checkReadiness()
+	stopped := make(chan error, 1)
+
+	start := time.Now()
+	go func() { stopped <- a.Stop(context.Background()) }()
+
+	require.Eventually(t, func() bool {
+		resp, err := a.health.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		return err == nil && resp.GetStatus() == healthpb.HealthCheckResponse_NOT_SERVING
+	}, time.Second, time.Millisecond)
+	require.NoError(t, <-stopped)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
+
+// blockingServiceDesc describes a service whose only method
+// blocks until release is closed.
+func blockingServiceDesc(entered chan<- struct{}, release <-chan struct{}) *grpc.ServiceDesc {
+	return &grpc.ServiceDesc{
+		ServiceName: "test.Blocking",
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Wait",
+			Handler: func(_ any, ctx context.Context, dec func(any) error, _ grpc.UnaryServerInterceptor) (any, error) {
+				if err := dec(&emptypb.Empty{}); err != nil {
+					return nil, err
+				}
+				close(entered)
+				<-release
+				return &emptypb.Empty{}, nil
+			},
+		}},
+	}
+}
+
+// TestAdminServedOnlyWithClientCerts проверяет, что админский
+// сервис регистрируется, только если сервер требует клиентский сертификат.
+func TestAdminServedOnlyWithClientCerts(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tests := []struct {
+		name   string
+		tls    *tls.Config
+		served bool
+	}{
+		{name: "plaintext"},
+		{name: "tls", tls: &tls.Config{}},
+		{name: "mtls", tls: &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert}, served: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			services := Services{Auth: fakeAuth{}, Admin: &admin.AdminService{}}
+			a := New(logger, services, fakeAuth{}, fakeAuth{}, &fakePinger{}, Options{TLS: tt.tls})
+			_, ok := a.grpcServer.GetServiceInfo()["sso.admin.v1.AdminService"]
+			assert.Equal(t, tt.served, ok)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package grpcapp
+
+import (
+	"context"
+	"sso/interanal/storage"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// ConsistencyMetadataKey lets a client ask for reads that see its
+	// own writes, e.g. a Login right after Register. Without it reads
+	// may go to a lagging replica.
+	ConsistencyMetadataKey = "x-sso-consistency"
+	ConsistencyPrimary     = "primary"
+)
+
+// consistencyInterceptor sends the reads of calls with
+// ConsistencyMetadataKey set to ConsistencyPrimary to the primary.
+func consistencyInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		md, ok := metadata.FromIncomingContext(ctx)
+		if ok {
+			values := md.Get(ConsistencyMetadataKey)
+			if len(values) > 0 && values[0] == ConsistencyPrimary {
+				ctx = storage.WithPrimaryReads(ctx)
+			}
+		}
+		return handler(ctx, req)
+	}
+}
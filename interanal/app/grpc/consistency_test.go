@@ -0,0 +1,41 @@
+package grpcapp
+
+import (
+	"context"
+	"sso/interanal/storage"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// TestConsistencyInterceptor проверяет, что чтения из primary
+// включаются только метаданными x-sso-consistency: primary.
+func TestConsistencyInterceptor(t *testing.T) {
+	tests := []struct {
+		name    string
+		md      metadata.MD
+		primary bool
+	}{
+		{name: "no metadata"},
+		{name: "primary", md: metadata.Pairs(ConsistencyMetadataKey, ConsistencyPrimary), primary: true},
+		{name: "unknown value", md: metadata.Pairs(ConsistencyMetadataKey, "replica")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+			var primary bool
+			_, err := consistencyInterceptor()(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ any) (any, error) {
+				primary = storage.PrimaryReads(ctx)
+				return nil, nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.primary, primary)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package grpcapp
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Pinger reports whether the storage is reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// watchReadiness pings the storage every interval until Stop is called.
+func (a *GrpcApp) watchReadiness() {
+	ticker := time.NewTicker(a.healthInterval)
+	defer ticker.Stop()
+	for {
+		a.checkReadiness()
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *GrpcApp) checkReadiness() {
+	const op = "grpcapp.checkReadiness"
+	ctx, cancel := context.WithTimeout(context.Background(), a.healthInterval)
+	defer cancel()
+	status := healthpb.HealthCheckResponse_SERVING
+	if err := a.pinger.Ping(ctx); err != nil {
+		a.logger.Warn("storage is unreachable", slog.String("op", op), slog.String("err", err.Error()))
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	for _, service := range a.dependingOnDB {
+		a.health.SetServingStatus(service, status)
+	}
+}
@@ -0,0 +1,99 @@
+package grpcapp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sso/interanal/domain/models"
+	"sync"
+	"testing"
+	"time"
+
+	ssov1 "github.com/sariya23/sso_proto/gen/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// TestReadinessFollowsDatabase проверяет, что статус
+// сервисов меняется вместе с доступностью базы.
+func TestReadinessFollowsDatabase(t *testing.T) {
+	pinger := &fakePinger{}
+	a := newTestApp(pinger)
+	assertStatus(t, a, healthpb.HealthCheckResponse_NOT_SERVING)
+
+	a.checkReadiness()
+	assertStatus(t, a, healthpb.HealthCheckResponse_SERVING)
+
+	pinger.setErr(errors.New("connection refused"))
+	a.checkReadiness()
+	assertStatus(t, a, healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+// TestStopSwitchesToNotServing проверяет, что при
+// остановке сервер перестает быть готовым и больше
+// не возвращается в SERVING.
+func TestStopSwitchesToNotServing(t *testing.T) {
+	a := newTestApp(&fakePinger{})
+	a.checkReadiness()
+	assertStatus(t, a, healthpb.HealthCheckResponse_SERVING)
+
+	require.NoError(t, a.Stop(context.Background()))
+	a.checkReadiness()
+
+	assertStatus(t, a, healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+func assertStatus(t *testing.T, a *GrpcApp, want healthpb.HealthCheckResponse_ServingStatus) {
+	t.Helper()
+	for _, service := range []string{"", ssov1.Auth_ServiceDesc.ServiceName} {
+		resp, err := a.health.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+		assert.Equal(t, want, resp.GetStatus(), "service %q", service)
+	}
+}
+
+func newTestApp(pinger Pinger) *GrpcApp {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return New(logger, Services{Auth: fakeAuth{}}, fakeAuth{}, fakeAuth{}, pinger, Options{HealthCheckInterval: time.Second})
+}
+
+type fakePinger struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (p *fakePinger) setErr(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+}
+
+func (p *fakePinger) Ping(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+type fakeAuth struct{}
+
+func (fakeAuth) Login(ctx context.Context, email string, password string, appId int) (string, error) {
+	return "", nil
+}
+
+func (fakeAuth) RegisterNewUser(ctx context.Context, orgId int64, email string, password string) (int64, error) {
+	return 0, nil
+}
+
+func (fakeAuth) IsAdmin(ctx context.Context, orgId int64, userId int64) (bool, error) {
+	return false, nil
+}
+
+func (fakeAuth) GetApp(ctx context.Context, appId int) (models.App, error) {
+	return models.App{}, nil
+}
+
+func (fakeAuth) SessionsRevokedAt(ctx context.Context, orgId int64, userId int64) (time.Time, error) {
+	return time.Time{}, nil
+}
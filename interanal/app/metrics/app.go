@@ -0,0 +1,49 @@
+package metricsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type MetricsApp struct {
+	logger *slog.Logger
+	server *http.Server
+	port   int
+}
+
+// New serves the collectors of registry, see metrics.NewRegistry.
+func New(logger *slog.Logger, port int, registry *prometheus.Registry) *MetricsApp {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	return &MetricsApp{
+		logger: logger,
+		server: &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: mux},
+		port:   port,
+	}
+}
+
+func (a *MetricsApp) Run() error {
+	const op = "metricsapp.Run"
+	logger := a.logger.With(slog.String("op", op))
+	logger.Info("metrics server is running", slog.Int("port", a.port))
+	if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error(err.Error())
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (a *MetricsApp) Stop(ctx context.Context) error {
+	const op = "metricsapp.Stop"
+	a.logger.Info("stopping server", slog.String("op", op), slog.Int("port", a.port))
+	if err := a.server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
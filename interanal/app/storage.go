@@ -0,0 +1,160 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	grpcapp "sso/interanal/app/grpc"
+	"sso/interanal/config"
+	"sso/interanal/domain/models"
+	"sso/interanal/grpc/authn"
+	"sso/interanal/service/admin"
+	"sso/interanal/service/auth"
+	"sso/interanal/service/bootstrap"
+	"sso/interanal/service/device"
+	"sso/interanal/service/impersonation"
+	"sso/interanal/service/invite"
+	"sso/interanal/service/passkey"
+	"sso/interanal/service/passwordless"
+	"sso/interanal/storage"
+	"sso/interanal/storage/cache"
+	"sso/interanal/storage/postgres"
+	"sso/interanal/storage/sqlite"
+	"sso/lib/secretbox"
+)
+
+// Storage is everything the services need from a storage backend.
+type Storage interface {
+	auth.UserSaver
+	auth.UserProvider
+	auth.AppServiceProvider
+	invite.InviteSaver
+	invite.InviteConsumer
+	invite.MemberProvider
+	invite.Transactor
+	bootstrap.AppStorage
+	bootstrap.AdminSetter
+	passwordless.CodeStorage
+	passkey.CredentialStorage
+	passkey.SessionStorage
+	device.DeviceStorage
+	impersonation.UserProvider
+	impersonation.MemberProvider
+	impersonation.AuditSaver
+	admin.OrgProvider
+	admin.AppStorage
+	admin.MemberStorage
+	admin.SessionRevoker
+	grpcapp.Pinger
+	authn.SessionProvider
+	RotateAppSecrets(ctx context.Context) (int, error)
+	Stop(ctx context.Context) error
+}
+
+// MustNewStorage connects to the configured backend. App secrets are
+// sealed with cfg.AppSecrets.Keys when there are any.
+func MustNewStorage(ctx context.Context, logger *slog.Logger, cfg *config.Config) Storage {
+	const op = "app.MustNewStorage"
+	var secrets *secretbox.Keyring
+	if len(cfg.AppSecrets.Keys) > 0 {
+		var err error
+		secrets, err = secretbox.ParseKeyring(cfg.AppSecrets.Keys)
+		if err != nil {
+			log.Fatalf("%s: %v", op, err)
+		}
+	}
+	switch cfg.Storage.Driver {
+	case config.StoragePostgres:
+		return postgres.MustNewConnection(ctx, cfg.Database.URL(), postgres.Options{
+			MaxConns:             cfg.Database.MaxConns,
+			MinConns:             cfg.Database.MinConns,
+			MaxConnLifetime:      cfg.Database.MaxConnLifetime,
+			MaxConnIdleTime:      cfg.Database.MaxConnIdleTime,
+			ConnectTimeout:       cfg.Database.ConnectTimeout,
+			Secrets:              secrets,
+			Replicas:             cfg.Database.Replicas,
+			ReplicaCheckInterval: cfg.Database.ReplicaCheckInterval,
+			ReadAfterWriteWindow: cfg.Database.ReadAfterWriteWindow,
+			Logger:               logger,
+		})
+	case config.StorageSQLite:
+		return sqlite.MustNewConnection(ctx, cfg.Storage.SQLitePath, sqlite.Options{Secrets: secrets})
+	default:
+		log.Fatalf("%s: unknown storage driver %q", op, cfg.Storage.Driver)
+		return nil
+	}
+}
+
+// mustMigrate applies the embedded migrations of the configured
+// backend. It fails when the database is ahead of the binary, so an
+// older release never runs against a newer schema.
+func mustMigrate(ctx context.Context, logger *slog.Logger, cfg *config.Config) {
+	const op = "app.mustMigrate"
+	var from, to uint
+	var err error
+	switch cfg.Storage.Driver {
+	case config.StoragePostgres:
+		from, to, err = postgres.Migrate(ctx, cfg.Database.URL(), cfg.Storage.MigrationsTable)
+	case config.StorageSQLite:
+		from, to, err = sqlite.Migrate(cfg.Storage.SQLitePath, cfg.Storage.MigrationsTable)
+	default:
+		err = fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+	}
+	if err != nil {
+		log.Fatalf("%s: %v", op, err)
+	}
+	if from == to {
+		logger.Info("database schema is up to date", slog.Uint64("version", uint64(to)))
+		return
+	}
+	logger.Info("migrations applied", slog.Uint64("from", uint64(from)), slog.Uint64("to", uint64(to)))
+}
+
+// testApp is the app that migration 3 used to seed with a well-known
+// secret. It now comes from config/seed.dev.yaml.
+var testApp = models.App{Id: 1, OrgId: models.DefaultOrgId, Name: "test", Secret: "test-secret"}
+
+// mustNotServeTestApp refuses to start outside the local env while
+// the test app still has its well-known secret. Migration 11 removes
+// it, but can't compare a secret that is already sealed.
+func mustNotServeTestApp(ctx context.Context, s Storage, cfg *config.Config) {
+	const op = "app.mustNotServeTestApp"
+	if cfg.IsLocal() {
+		return
+	}
+	app, err := s.GetApp(ctx, testApp.Id)
+	if errors.Is(err, storage.ErrAppNotFound) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("%s: %v", op, err)
+	}
+	if app.Name == testApp.Name && app.Secret == testApp.Secret {
+		log.Fatalf("%s: app %d has the well-known secret of the test app, delete it or give it another secret", op, app.Id)
+	}
+}
+
+// cachedStorage sends the writes of apps and users through the cache,
+// so whoever writes them in process never leaves stale entries.
+type cachedStorage struct {
+	Storage
+	cache *cache.Cache
+}
+
+func (s cachedStorage) SaveUser(ctx context.Context, orgId int64, email string, passHash []byte) (int64, error) {
+	return s.cache.SaveUser(ctx, orgId, email, passHash)
+}
+
+func (s cachedStorage) SaveApp(ctx context.Context, app models.App) error {
+	return s.cache.SaveApp(ctx, app)
+}
+
+func (s cachedStorage) UpdateApp(ctx context.Context, app models.App) error {
+	return s.cache.UpdateApp(ctx, app)
+}
+
+func (s cachedStorage) SetAppSecret(ctx context.Context, appId int, secret string) error {
+	return s.cache.SetAppSecret(ctx, appId, secret)
+}
@@ -1,51 +1,211 @@
 package config
 
 import (
+	"errors"
 	"flag"
+	"log/slog"
+	"math"
+	"net"
+	"net/url"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
-type ConfigDataBase struct {
-	Port     string `yaml:"port"`
-	Host     string `yaml:"host"`
-	DBName   string `yaml:"db_name"`
-	User     string `yaml:"username"`
-	Password string `yaml:"password"`
-}
+const (
+	RegistrationOpen       = "open"
+	RegistrationInviteOnly = "invite_only"
+)
+
+const (
+	StoragePostgres = "postgres"
+	StorageSQLite   = "sqlite"
+)
 
+const (
+	// DeliveryLog writes passwordless codes to the log in plaintext,
+	// so it is allowed only in the local env.
+	DeliveryLog = "log"
+	// DeliveryNone sends nothing, passwordless logins fail to start.
+	DeliveryNone = "none"
+)
+
+// Every field can be overridden by the environment variable in its
+// env tag, prefixed with the env-prefix of its section. String values
+// may be file:///path or env://NAME references, see ResolveRefs.
 type Config struct {
-	Env      string        `yaml:"env" env-required:"true"`
-	TokenTTL time.Duration `yaml:"token_ttl" env-required:"true"`
-	GRPC     GRPCConfig    `yaml:"grpc"`
+	Env      string        `yaml:"env" env:"ENV" env-required:"true"`
+	TokenTTL time.Duration `yaml:"token_ttl" env:"TOKEN_TTL" env-required:"true"`
+	// ShutdownTimeout bounds draining; after it connections are closed.
+	// Every component gets it anew, so a slow drain doesn't cut the
+	// storage and tracing short.
+	ShutdownTimeout time.Duration       `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" env-default:"15s"`
+	Log             LogConfig           `yaml:"log" env-prefix:"LOG_"`
+	Storage         StorageConfig       `yaml:"storage" env-prefix:"STORAGE_"`
+	Database        DatabaseConfig      `yaml:"database" env-prefix:"DB_"`
+	Cache           CacheConfig         `yaml:"cache" env-prefix:"CACHE_"`
+	AppSecrets      AppSecretsConfig    `yaml:"app_secrets" env-prefix:"APP_SECRETS_"`
+	GRPC            GRPCConfig          `yaml:"grpc" env-prefix:"GRPC_"`
+	Registration    RegistrationConfig  `yaml:"registration" env-prefix:"REGISTRATION_"`
+	Passwordless    PasswordlessConfig  `yaml:"passwordless" env-prefix:"PASSWORDLESS_"`
+	WebAuthn        WebAuthnConfig      `yaml:"webauthn" env-prefix:"WEBAUTHN_"`
+	Device          DeviceConfig        `yaml:"device" env-prefix:"DEVICE_"`
+	Impersonation   ImpersonationConfig `yaml:"impersonation" env-prefix:"IMPERSONATION_"`
+	Metrics         MetricsConfig       `yaml:"metrics" env-prefix:"METRICS_"`
+	Tracing         TracingConfig       `yaml:"tracing" env-prefix:"TRACING_"`
+	Debug           DebugConfig         `yaml:"debug" env-prefix:"DEBUG_"`
+}
+
+type LogConfig struct {
+	// Level is one of debug, info, warn and error.
+	Level string `yaml:"level" env:"LEVEL" env-default:"info"`
+	// Format is text or json.
+	Format string `yaml:"format" env:"FORMAT" env-default:"json"`
+	// Output is stdout, stderr or a path to a file that is appended to.
+	Output string `yaml:"output" env:"OUTPUT" env-default:"stdout"`
+}
+
+// StorageConfig selects the storage backend. The database section
+// is used only by postgres.
+type StorageConfig struct {
+	Driver string `yaml:"driver" env:"DRIVER" env-default:"postgres"`
+	// SQLitePath is the database file of the sqlite driver.
+	SQLitePath string `yaml:"sqlite_path" env:"SQLITE_PATH" env-default:"./storage/sso.db"`
+	// MigrateOnStart applies the migrations embedded in the binary
+	// before the service starts, instead of a separate cmd/migrator run.
+	MigrateOnStart bool `yaml:"migrate_on_start" env:"MIGRATE_ON_START" env-default:"false"`
+	// MigrationsTable must match --migrations-table of cmd/migrator.
+	MigrationsTable string `yaml:"migrations_table" env:"MIGRATIONS_TABLE" env-default:"migrations"`
+}
+
+// DatabaseConfig is either a DSN or separate connection fields.
+// The DSN wins when both are set.
+type DatabaseConfig struct {
+	DSN             string        `yaml:"dsn" env:"DSN" secret:"true"`
+	Host            string        `yaml:"host" env:"HOST" env-default:"localhost"`
+	Port            int           `yaml:"port" env:"PORT" env-default:"5432"`
+	Name            string        `yaml:"name" env:"NAME"`
+	User            string        `yaml:"user" env:"USER"`
+	Password        string        `yaml:"password" env:"PASSWORD" secret:"true"`
+	SSLMode         string        `yaml:"sslmode" env:"SSLMODE" env-default:"disable"`
+	MaxConns        int32         `yaml:"max_conns" env:"MAX_CONNS" env-default:"10"`
+	MinConns        int32         `yaml:"min_conns" env:"MIN_CONNS" env-default:"0"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout" env:"CONNECT_TIMEOUT" env-default:"5s"`
+	MaxConnLifetime time.Duration `yaml:"max_conn_lifetime" env:"MAX_CONN_LIFETIME" env-default:"1h"`
+	MaxConnIdleTime time.Duration `yaml:"max_conn_idle_time" env:"MAX_CONN_IDLE_TIME" env-default:"30m"`
+	// Replicas are DSNs of read replicas for login lookups. A replica
+	// that fails its health check is skipped until it recovers.
+	Replicas             []string      `yaml:"replicas" env:"REPLICAS" secret:"true"`
+	ReplicaCheckInterval time.Duration `yaml:"replica_check_interval" env:"REPLICA_CHECK_INTERVAL" env-default:"5s"`
+	// ReadAfterWriteWindow reads a just registered user from the primary
+	// for a while, as replicas lag behind. Zero turns it off.
+	ReadAfterWriteWindow time.Duration `yaml:"read_after_write_window" env:"READ_AFTER_WRITE_WINDOW" env-default:"5s"`
+}
+
+// CacheConfig tunes the in-memory cache of apps and users
+// that login flows read on every request.
+type CacheConfig struct {
+	Enabled bool `yaml:"enabled" env:"ENABLED" env-default:"true"`
+	// TTL bounds how long a change made by another process, such as
+	// ssoctl bootstrap or another replica, may be served stale.
+	TTL time.Duration `yaml:"ttl" env:"TTL" env-default:"1m"`
+	// NegativeTTL is how long an unknown app id is remembered. An app
+	// created by another process is rejected for up to this long.
+	NegativeTTL time.Duration `yaml:"negative_ttl" env:"NEGATIVE_TTL" env-default:"10s"`
+	MaxEntries  int           `yaml:"max_entries" env:"MAX_ENTRIES" env-default:"10000"`
+}
+
+// AppSecretsConfig holds the master keys that encrypt app secrets
+// in the database. Keys have the form "id:base64" with 32 byte keys.
+// The first key encrypts, all of them decrypt: to rotate, put a new
+// key first, restart and drop the old key once secrets are re-encrypted.
+type AppSecretsConfig struct {
+	Keys []string `yaml:"keys" env:"KEYS" secret:"true"`
 }
 
 type GRPCConfig struct {
-	Port    int           `yaml:"port" env-default:"8080"`
-	Timeout time.Duration `yaml:"timeout" env-default:"1h"`
+	Port    int           `yaml:"port" env:"PORT" env-default:"8080"`
+	Timeout time.Duration `yaml:"timeout" env:"TIMEOUT" env-default:"1h"`
+	// HealthCheckInterval is how often the database is pinged for readiness.
+	HealthCheckInterval time.Duration `yaml:"health_check_interval" env:"HEALTH_CHECK_INTERVAL" env-default:"5s"`
+	// Reflection lets grpcurl and similar tools work without the proto files.
+	Reflection bool `yaml:"reflection" env:"REFLECTION" env-default:"false"`
+	// DrainDelay is how long the server keeps serving after readiness
+	// fails on shutdown, so load balancers stop sending calls first.
+	// It counts against ShutdownTimeout.
+	DrainDelay time.Duration `yaml:"drain_delay" env:"DRAIN_DELAY" env-default:"0s"`
+	// TLSCertFile and TLSKeyFile serve gRPC over TLS, plaintext without
+	// them. With TLSClientCAFile clients need a certificate it signed.
+	TLSCertFile     string `yaml:"tls_cert_file" env:"TLS_CERT_FILE"`
+	TLSKeyFile      string `yaml:"tls_key_file" env:"TLS_KEY_FILE"`
+	TLSClientCAFile string `yaml:"tls_client_ca_file" env:"TLS_CLIENT_CA_FILE"`
 }
 
-func MustLoad() *Config {
-	path := fetchConfigPath()
-	if path == "" {
-		panic("config path is empty")
-	}
-	return MustLoadByPath(path)
+type RegistrationConfig struct {
+	Mode      string        `yaml:"mode" env:"MODE" env-default:"open"`
+	InviteTTL time.Duration `yaml:"invite_ttl" env:"INVITE_TTL" env-default:"72h"`
 }
 
-func MustLoadDBConfig(path string) *ConfigDataBase {
-	var cfg ConfigDataBase
-	err := cleanenv.ReadConfig(path, &cfg)
-	if err != nil {
-		panic(err)
-	}
-	return &cfg
+type PasswordlessConfig struct {
+	Mode        string        `yaml:"mode" env:"MODE" env-default:"code"`
+	CodeLength  int           `yaml:"code_length" env:"CODE_LENGTH" env-default:"6"`
+	CodeTTL     time.Duration `yaml:"code_ttl" env:"CODE_TTL" env-default:"10m"`
+	MaxAttempts int           `yaml:"max_attempts" env:"MAX_ATTEMPTS" env-default:"5"`
+	// Delivery is how codes reach users, DeliveryLog or DeliveryNone.
+	Delivery string `yaml:"delivery" env:"DELIVERY" env-default:"none"`
+}
+
+type WebAuthnConfig struct {
+	RPID          string        `yaml:"rp_id" env:"RP_ID" env-default:"localhost"`
+	RPDisplayName string        `yaml:"rp_display_name" env:"RP_DISPLAY_NAME" env-default:"SSO"`
+	RPOrigins     []string      `yaml:"rp_origins" env:"RP_ORIGINS" env-default:"http://localhost"`
+	SessionTTL    time.Duration `yaml:"session_ttl" env:"SESSION_TTL" env-default:"5m"`
+}
+
+type DeviceConfig struct {
+	VerificationURI string        `yaml:"verification_uri" env:"VERIFICATION_URI" env-default:"http://localhost/device"`
+	CodeTTL         time.Duration `yaml:"code_ttl" env:"CODE_TTL" env-default:"10m"`
+	PollInterval    time.Duration `yaml:"poll_interval" env:"POLL_INTERVAL" env-default:"5s"`
+}
+
+type MetricsConfig struct {
+	Enabled bool `yaml:"enabled" env:"ENABLED" env-default:"false"`
+	Port    int  `yaml:"port" env:"PORT" env-default:"9090"`
 }
 
+// TracingConfig selects the span exporter: none, stdout or otlp.
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" env:"EXPORTER" env-default:"none"`
+	Endpoint    string  `yaml:"endpoint" env:"ENDPOINT" env-default:"localhost:4317"`
+	Insecure    bool    `yaml:"insecure" env:"INSECURE" env-default:"true"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"SAMPLE_RATIO" env-default:"1"`
+}
+
+// DebugConfig enables the pprof and config dump listener.
+type DebugConfig struct {
+	Enabled bool   `yaml:"enabled" env:"ENABLED" env-default:"false"`
+	Host    string `yaml:"host" env:"HOST" env-default:"127.0.0.1"`
+	Port    int    `yaml:"port" env:"PORT" env-default:"6060"`
+}
+
+type ImpersonationConfig struct {
+	TokenTTL time.Duration `yaml:"token_ttl" env:"TOKEN_TTL" env-default:"15m"`
+}
+
+func (c RegistrationConfig) IsOpen() bool {
+	return c.Mode != RegistrationInviteOnly
+}
+
+func MustLoad() *Config {
+	return MustLoadByPath(MustFetchPath())
+}
+
+// MustFetchPath returns the config path from the --config flag or
+// the CONFIG_PATH variable. It parses the command line flags.
 // Priority: flag > env > default
-func fetchConfigPath() string {
+func MustFetchPath() string {
 	var path string
 	flag.StringVar(&path, "config", "", "path to config file")
 	flag.Parse()
@@ -53,17 +213,63 @@ func fetchConfigPath() string {
 	if path == "" {
 		path = os.Getenv("CONFIG_PATH")
 	}
+	if path == "" {
+		panic("config path is empty")
+	}
 	return path
 }
 
 func MustLoadByPath(path string) *Config {
+	cfg, err := Load(path)
+	if err != nil {
+		panic(err)
+	}
+	return cfg
+}
+
+// Load reads, resolves and validates the config at path.
+func Load(path string) (*Config, error) {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		panic("config file does not exists: " + path)
+		return nil, errors.New("config file does not exists: " + path)
 	}
 
 	var cfg Config
 	if err := cleanenv.ReadConfig(path, &cfg); err != nil {
-		panic("cannot read config: " + err.Error())
+		return nil, errors.New("cannot read config: " + err.Error())
+	}
+	if err := ResolveRefs(&cfg); err != nil {
+		return nil, errors.New("cannot resolve config references:\n" + err.Error())
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.New("invalid config:\n" + err.Error())
+	}
+	return &cfg, nil
+}
+
+// SlogLevel returns Level for slog. Validate guarantees it parses.
+func (c LogConfig) SlogLevel() slog.Level {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(c.Level))
+	return level
+}
+
+// URL returns the connection string for pgx and golang-migrate.
+func (c DatabaseConfig) URL() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+	u := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(c.User, c.Password),
+		Host:   net.JoinHostPort(c.Host, strconv.Itoa(c.Port)),
+		Path:   "/" + c.Name,
+	}
+	q := url.Values{}
+	q.Set("sslmode", c.SSLMode)
+	if c.ConnectTimeout > 0 {
+		// libpq takes whole seconds and treats 0 as no timeout.
+		q.Set("connect_timeout", strconv.Itoa(int(math.Ceil(c.ConnectTimeout.Seconds()))))
 	}
-	return &cfg
+	u.RawQuery = q.Encode()
+	return u.String()
 }
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+const redactedValue = "[REDACTED]"
+
+// Redacted returns v as nested maps keyed by yaml names, with every
+// non-empty field tagged `secret:"true"` replaced by a placeholder.
+// Use it whenever a config is logged or exposed.
+func Redacted(v any) any {
+	return redact(reflect.ValueOf(v))
+}
+
+func redact(v reflect.Value) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redact(v.Elem())
+	case reflect.Struct:
+		out := make(map[string]any, v.NumField())
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "" {
+				name = field.Name
+			}
+			if field.Tag.Get("secret") == "true" {
+				if v.Field(i).IsZero() {
+					out[name] = ""
+				} else {
+					out[name] = redactedValue
+				}
+				continue
+			}
+			out[name] = redact(v.Field(i))
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = redact(v.Index(i))
+		}
+		return out
+	case reflect.Map:
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out[iter.Key().String()] = redact(iter.Value())
+		}
+		return out
+	default:
+		return v.Interface()
+	}
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// reloadable lists the settings that are applied on SIGHUP without
+// a restart, see app.App.Reload.
+var reloadable = map[string]bool{
+	"log.level":                 true,
+	"token_ttl":                 true,
+	"registration.mode":         true,
+	"passwordless.max_attempts": true,
+}
+
+// RestartRequired returns the fields that differ between c and next
+// but can only be applied by a restart.
+func (c *Config) RestartRequired(next *Config) []string {
+	var fields []string
+	diff(reflect.ValueOf(*c), reflect.ValueOf(*next), "", &fields)
+	return fields
+}
+
+func diff(a, b reflect.Value, path string, fields *[]string) {
+	if reloadable[path] {
+		return
+	}
+	if a.Kind() != reflect.Struct {
+		if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+			*fields = append(*fields, path)
+		}
+		return
+	}
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if path != "" {
+			name = path + "." + name
+		}
+		diff(a.Field(i), b.Field(i), name, fields)
+	}
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRestartRequired проверяет, что изменения, которые
+// применяются на лету, не требуют перезапуска, а остальные требуют.
+func TestRestartRequired(t *testing.T) {
+	cfg := MustLoadByPath("../../config/local.yaml")
+	next := MustLoadByPath("../../config/local.yaml")
+
+	next.TokenTTL = 2 * time.Hour
+	next.Log.Level = "debug"
+	next.Registration.Mode = RegistrationInviteOnly
+	next.Passwordless.MaxAttempts++
+	assert.Empty(t, cfg.RestartRequired(next))
+
+	next.GRPC.Port++
+	next.Database.Password = "changed"
+	next.WebAuthn.RPOrigins = append(next.WebAuthn.RPOrigins, "http://example.com")
+	assert.Equal(t, []string{"database.password", "grpc.port", "webauthn.rp_origins"}, cfg.RestartRequired(next))
+}
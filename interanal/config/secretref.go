@@ -0,0 +1,74 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+const (
+	fileRefPrefix = "file://"
+	envRefPrefix  = "env://"
+)
+
+// ResolveRefs replaces string values of the form file:///path and
+// env://NAME in the struct that v points to with the contents of the
+// file or the variable, so secrets can come from Docker or Kubernetes
+// secrets instead of YAML. Errors name fields by their yaml tags.
+func ResolveRefs(v any) error {
+	var errs []error
+	resolveValue(reflect.ValueOf(v).Elem(), "", &errs)
+	return errors.Join(errs...)
+}
+
+func resolveValue(v reflect.Value, path string, errs *[]error) {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if path != "" {
+				name = path + "." + name
+			}
+			resolveValue(v.Field(i), name, errs)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			resolveValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case reflect.String:
+		resolved, err := ResolveRef(v.String())
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %w", path, err))
+			return
+		}
+		v.SetString(resolved)
+	}
+}
+
+// ResolveRef returns the contents of the file or the variable that a
+// file:// or env:// reference names, and any other value as is.
+func ResolveRef(value string) (string, error) {
+	if path, ok := strings.CutPrefix(value, fileRefPrefix); ok {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		// Secret files usually end with a newline that is not part of the secret.
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+	if name, ok := strings.CutPrefix(value, envRefPrefix); ok {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return v, nil
+	}
+	return value, nil
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestResolveRefs проверяет, что значения file:// и env://
+// подставляются из файла и переменной окружения.
+func TestResolveRefs(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "db_password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("from-file\n"), 0o600))
+	t.Setenv("SSO_TEST_KEY", "k1:from-env")
+	cfg := Config{
+		Env:        "local",
+		Database:   DatabaseConfig{Host: "localhost", Password: "file://" + passwordFile},
+		AppSecrets: AppSecretsConfig{Keys: []string{"env://SSO_TEST_KEY"}},
+	}
+
+	require.NoError(t, ResolveRefs(&cfg))
+
+	assert.Equal(t, "from-file", cfg.Database.Password)
+	assert.Equal(t, []string{"k1:from-env"}, cfg.AppSecrets.Keys)
+	assert.Equal(t, "localhost", cfg.Database.Host)
+}
+
+// TestResolveRefsReportsField проверяет, что в ошибке
+// указано поле с неразрешимой ссылкой.
+func TestResolveRefsReportsField(t *testing.T) {
+	cfg := Config{
+		Database:   DatabaseConfig{Password: "file:///does/not/exist"},
+		AppSecrets: AppSecretsConfig{Keys: []string{"env://SSO_TEST_MISSING"}},
+	}
+
+	err := ResolveRefs(&cfg)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "database.password: open /does/not/exist")
+	assert.Contains(t, err.Error(), "app_secrets.keys[0]: environment variable SSO_TEST_MISSING is not set")
+}
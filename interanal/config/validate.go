@@ -0,0 +1,183 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"sso/lib/secretbox"
+	"time"
+)
+
+const (
+	envLocal = "local"
+	envProd  = "prod"
+)
+
+// IsLocal reports whether c is for a developer machine, where
+// well-known test credentials and plaintext codes in logs are fine.
+func (c *Config) IsLocal() bool {
+	return c.Env == envLocal
+}
+
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// Validate checks the semantics that tags cannot express and reports
+// every problem at once, so a broken config is fixed in one go.
+func (c *Config) Validate() error {
+	v := &validator{}
+	v.check(slices.Contains([]string{envLocal, envProd}, c.Env), "env", "must be one of %q, %q", envLocal, envProd)
+	var level slog.Level
+	v.check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level", `must be one of "debug", "info", "warn", "error"`)
+	v.check(slices.Contains([]string{"text", "json"}, c.Log.Format), "log.format", `must be one of "text", "json"`)
+	v.check(c.Log.Output != "", "log.output", "is required")
+	v.positive("token_ttl", c.TokenTTL)
+	v.positive("shutdown_timeout", c.ShutdownTimeout)
+	v.check(c.GRPC.DrainDelay >= 0 && c.GRPC.DrainDelay < c.ShutdownTimeout, "grpc.drain_delay", "must be between 0 and shutdown_timeout")
+
+	v.check(
+		slices.Contains([]string{StoragePostgres, StorageSQLite}, c.Storage.Driver),
+		"storage.driver", "must be one of %q, %q", StoragePostgres, StorageSQLite,
+	)
+	switch c.Storage.Driver {
+	case StoragePostgres:
+		c.Database.validate(v)
+	case StorageSQLite:
+		v.check(c.Storage.SQLitePath != "", "storage.sqlite_path", "is required for the sqlite driver")
+	}
+	v.check(c.Storage.MigrationsTable != "", "storage.migrations_table", "is required")
+
+	if c.Cache.Enabled {
+		v.positive("cache.ttl", c.Cache.TTL)
+		v.check(c.Cache.NegativeTTL >= 0, "cache.negative_ttl", "must not be negative")
+		v.check(c.Cache.MaxEntries > 0, "cache.max_entries", "must be positive")
+	}
+
+	v.check(c.Env != envProd || len(c.AppSecrets.Keys) > 0, "app_secrets.keys", "is required in %q env", envProd)
+	if len(c.AppSecrets.Keys) > 0 {
+		_, err := secretbox.ParseKeyring(c.AppSecrets.Keys)
+		v.check(err == nil, "app_secrets.keys", "%v", err)
+	}
+
+	v.port("grpc.port", c.GRPC.Port)
+	v.positive("grpc.timeout", c.GRPC.Timeout)
+	v.positive("grpc.health_check_interval", c.GRPC.HealthCheckInterval)
+	v.check((c.GRPC.TLSCertFile == "") == (c.GRPC.TLSKeyFile == ""), "grpc.tls_key_file", "must be set together with grpc.tls_cert_file")
+	v.check(c.GRPC.TLSClientCAFile == "" || c.GRPC.TLSCertFile != "", "grpc.tls_client_ca_file", "requires grpc.tls_cert_file")
+
+	v.check(
+		slices.Contains([]string{RegistrationOpen, RegistrationInviteOnly}, c.Registration.Mode),
+		"registration.mode", "must be one of %q, %q", RegistrationOpen, RegistrationInviteOnly,
+	)
+	v.positive("registration.invite_ttl", c.Registration.InviteTTL)
+
+	v.check(slices.Contains([]string{"code", "link"}, c.Passwordless.Mode), "passwordless.mode", `must be one of "code", "link"`)
+	v.check(c.Passwordless.CodeLength >= 4 && c.Passwordless.CodeLength <= 12, "passwordless.code_length", "must be between 4 and 12")
+	v.positive("passwordless.code_ttl", c.Passwordless.CodeTTL)
+	v.check(c.Passwordless.MaxAttempts > 0, "passwordless.max_attempts", "must be positive")
+	v.check(
+		slices.Contains([]string{DeliveryLog, DeliveryNone}, c.Passwordless.Delivery),
+		"passwordless.delivery", "must be one of %q, %q", DeliveryLog, DeliveryNone,
+	)
+	v.check(c.Passwordless.Delivery != DeliveryLog || c.IsLocal(), "passwordless.delivery", "%q is allowed only in %q env", DeliveryLog, envLocal)
+
+	v.check(c.WebAuthn.RPID != "", "webauthn.rp_id", "is required")
+	v.check(len(c.WebAuthn.RPOrigins) > 0, "webauthn.rp_origins", "is required")
+	for i, origin := range c.WebAuthn.RPOrigins {
+		v.absoluteURL(fmt.Sprintf("webauthn.rp_origins[%d]", i), origin)
+	}
+	v.positive("webauthn.session_ttl", c.WebAuthn.SessionTTL)
+
+	v.absoluteURL("device.verification_uri", c.Device.VerificationURI)
+	v.positive("device.code_ttl", c.Device.CodeTTL)
+	v.positive("device.poll_interval", c.Device.PollInterval)
+
+	v.positive("impersonation.token_ttl", c.Impersonation.TokenTTL)
+
+	v.check(
+		slices.Contains([]string{"none", "stdout", "otlp"}, c.Tracing.Exporter),
+		"tracing.exporter", `must be one of "none", "stdout", "otlp"`,
+	)
+	v.check(c.Tracing.Exporter != "otlp" || c.Tracing.Endpoint != "", "tracing.endpoint", "is required for the otlp exporter")
+	v.check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "must be between 0 and 1")
+
+	listeners := map[string]int{"grpc.port": c.GRPC.Port}
+	if c.Metrics.Enabled {
+		v.port("metrics.port", c.Metrics.Port)
+		listeners["metrics.port"] = c.Metrics.Port
+	}
+	if c.Debug.Enabled {
+		v.port("debug.port", c.Debug.Port)
+		v.check(c.Debug.Host != "", "debug.host", "is required")
+		listeners["debug.port"] = c.Debug.Port
+	}
+	seen := make(map[int]string)
+	for _, field := range []string{"grpc.port", "metrics.port", "debug.port"} {
+		port, ok := listeners[field]
+		if !ok {
+			continue
+		}
+		if other, taken := seen[port]; taken {
+			v.check(false, field, "is already used by %s", other)
+		}
+		seen[port] = field
+	}
+	return v.err()
+}
+
+func (c DatabaseConfig) validate(v *validator) {
+	if c.DSN != "" {
+		v.postgresURL("database.dsn", c.DSN)
+	} else {
+		v.check(c.Host != "", "database.host", "is required without database.dsn")
+		v.port("database.port", c.Port)
+		v.check(c.Name != "", "database.name", "is required without database.dsn")
+		v.check(c.User != "", "database.user", "is required without database.dsn")
+		v.check(slices.Contains(sslModes, c.SSLMode), "database.sslmode", "must be one of %q", sslModes)
+	}
+	v.check(c.MaxConns > 0, "database.max_conns", "must be positive")
+	v.check(c.MinConns >= 0 && c.MinConns <= c.MaxConns, "database.min_conns", "must be between 0 and database.max_conns")
+	v.check(c.ConnectTimeout >= 0, "database.connect_timeout", "must not be negative")
+	v.check(c.MaxConnLifetime >= 0, "database.max_conn_lifetime", "must not be negative")
+	v.check(c.MaxConnIdleTime >= 0, "database.max_conn_idle_time", "must not be negative")
+	for i, replica := range c.Replicas {
+		v.postgresURL(fmt.Sprintf("database.replicas[%d]", i), replica)
+	}
+	if len(c.Replicas) > 0 {
+		v.positive("database.replica_check_interval", c.ReplicaCheckInterval)
+	}
+	v.check(c.ReadAfterWriteWindow >= 0, "database.read_after_write_window", "must not be negative")
+}
+
+type validator struct {
+	errs []error
+}
+
+func (v *validator) check(ok bool, field string, format string, args ...any) {
+	if !ok {
+		v.errs = append(v.errs, fmt.Errorf("%s: "+format, append([]any{field}, args...)...))
+	}
+}
+
+func (v *validator) positive(field string, d time.Duration) {
+	v.check(d > 0, field, "must be positive")
+}
+
+func (v *validator) port(field string, port int) {
+	v.check(port > 0 && port <= 65535, field, "must be between 1 and 65535")
+}
+
+func (v *validator) absoluteURL(field string, raw string) {
+	u, err := url.Parse(raw)
+	v.check(err == nil && u.Scheme != "" && u.Host != "", field, "must be an absolute URL")
+}
+
+func (v *validator) postgresURL(field string, raw string) {
+	u, err := url.Parse(raw)
+	v.check(err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql"), field, "must be a postgres:// URL")
+}
+
+func (v *validator) err() error {
+	return errors.Join(v.errs...)
+}
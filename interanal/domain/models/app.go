@@ -2,6 +2,9 @@ package models
 
 type App struct {
 	Id     int
+	OrgId  int64
 	Name   string
 	Secret string
+	// AllowAutoProvision lets passwordless login create unknown users.
+	AllowAutoProvision bool
 }
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+const AuditActionImpersonate = "impersonate"
+
+type AuditEvent struct {
+	Id           int64
+	OrgId        int64
+	ActorId      int64
+	TargetUserId int64
+	AppId        int
+	Action       string
+	Reason       string
+	CreatedAt    time.Time
+}
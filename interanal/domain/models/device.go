@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+const (
+	DeviceStatusPending  = "pending"
+	DeviceStatusApproved = "approved"
+	DeviceStatusDenied   = "denied"
+	DeviceStatusConsumed = "consumed"
+)
+
+type DeviceAuthorization struct {
+	DeviceCodeHash []byte
+	UserCode       string
+	AppId          int
+	UserId         int64
+	Status         string
+	Interval       time.Duration
+	LastPolledAt   time.Time
+	ExpiresAt      time.Time
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type Invite struct {
+	Id         int64
+	OrgId      int64
+	Email      string
+	Role       string
+	CreatedBy  int64
+	ExpiresAt  time.Time
+	AcceptedAt time.Time
+}
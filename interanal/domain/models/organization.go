@@ -0,0 +1,35 @@
+package models
+
+const DefaultOrgId int64 = 1
+
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+type Organization struct {
+	Id   int64
+	Name string
+}
+
+type Membership struct {
+	OrgId  int64
+	UserId int64
+	Role   string
+}
+
+// Member is a user of an organization with their membership role.
+type Member struct {
+	UserId int64
+	Email  string
+	Role   string
+}
+
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleOwner, RoleAdmin, RoleMember:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type PasswordlessCode struct {
+	Id        int64
+	AppId     int
+	Email     string
+	CodeHash  []byte
+	Attempts  int
+	ExpiresAt time.Time
+}
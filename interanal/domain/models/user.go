@@ -2,6 +2,7 @@ package models
 
 type User struct {
 	Id          int64
+	OrgId       int64
 	Email       string
 	PaswordHash []byte
 }
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+const (
+	CeremonyRegistration = "registration"
+	CeremonyLogin        = "login"
+)
+
+type WebAuthnCredential struct {
+	Id              []byte
+	UserId          int64
+	PublicKey       []byte
+	AttestationType string
+	AAGUID          []byte
+	SignCount       uint32
+	CloneWarning    bool
+	Transports      []string
+	BackupEligible  bool
+	BackupState     bool
+	CreatedAt       time.Time
+	LastUsedAt      time.Time
+}
+
+// WebAuthnSession keeps the server side state of a ceremony between its begin and finish steps.
+type WebAuthnSession struct {
+	UserId    int64
+	AppId     int
+	Ceremony  string
+	Data      []byte
+	ExpiresAt time.Time
+}
@@ -0,0 +1,273 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	adminv1 "sso/gen/sso/admin/v1"
+	"sso/interanal/domain/models"
+	"sso/interanal/service/admin"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	emptyAppId  = 0
+	emptyOrgId  = 0
+	emptyUserId = 0
+)
+
+type Admin interface {
+	ListApps(ctx context.Context, orgId int64) ([]models.App, error)
+	CreateApp(ctx context.Context, app models.App) (generatedSecret string, err error)
+	UpdateApp(ctx context.Context, appId int, name string, allowAutoProvision bool) (models.App, error)
+	RotateAppSecret(ctx context.Context, appId int) (secret string, err error)
+	ListMembers(ctx context.Context, orgId int64) ([]models.Member, error)
+	AddMember(ctx context.Context, orgId int64, email string, role string) (models.Member, error)
+	SetMemberRole(ctx context.Context, orgId int64, userId int64, role string) error
+	RevokeSessions(ctx context.Context, orgId int64, userId int64) (revokedAt time.Time, err error)
+}
+
+// ServerAPI has no bearer tokens: the caller is an operator holding a
+// client certificate, see requireClientCert.
+type ServerAPI struct {
+	adminv1.UnimplementedAdminServiceServer
+	admin Admin
+}
+
+// RegisterServerAPI must be called only for servers that require and
+// verify client certificates.
+func RegisterServerAPI(grpcServer *grpc.Server, admin Admin) {
+	adminv1.RegisterAdminServiceServer(grpcServer, &ServerAPI{admin: admin})
+}
+
+func (s *ServerAPI) ListApps(
+	ctx context.Context,
+	req *adminv1.ListAppsRequest,
+) (*adminv1.ListAppsResponse, error) {
+	if req.GetOrgId() == emptyOrgId {
+		return nil, status.Error(codes.InvalidArgument, "org id is required")
+	}
+	if err := requireClientCert(ctx); err != nil {
+		return nil, err
+	}
+	apps, err := s.admin.ListApps(ctx, req.GetOrgId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &adminv1.ListAppsResponse{Apps: make([]*adminv1.App, 0, len(apps))}
+	for _, app := range apps {
+		resp.Apps = append(resp.Apps, toApp(app))
+	}
+	return resp, nil
+}
+
+func (s *ServerAPI) CreateApp(
+	ctx context.Context,
+	req *adminv1.CreateAppRequest,
+) (*adminv1.CreateAppResponse, error) {
+	if req.GetApp().GetId() == emptyAppId {
+		return nil, status.Error(codes.InvalidArgument, "app id is required")
+	}
+	if req.GetApp().GetOrgId() == emptyOrgId {
+		return nil, status.Error(codes.InvalidArgument, "org id is required")
+	}
+	if strings.TrimSpace(req.GetApp().GetName()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+	if err := requireClientCert(ctx); err != nil {
+		return nil, err
+	}
+	app := models.App{
+		Id:                 int(req.GetApp().GetId()),
+		OrgId:              req.GetApp().GetOrgId(),
+		Name:               strings.TrimSpace(req.GetApp().GetName()),
+		AllowAutoProvision: req.GetApp().GetAllowAutoProvision(),
+		Secret:             req.GetSecret(),
+	}
+	secret, err := s.admin.CreateApp(ctx, app)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &adminv1.CreateAppResponse{
+		App:    toApp(app),
+		Secret: secret,
+	}, nil
+}
+
+func (s *ServerAPI) UpdateApp(
+	ctx context.Context,
+	req *adminv1.UpdateAppRequest,
+) (*adminv1.UpdateAppResponse, error) {
+	if req.GetAppId() == emptyAppId {
+		return nil, status.Error(codes.InvalidArgument, "app id is required")
+	}
+	if strings.TrimSpace(req.GetName()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+	if err := requireClientCert(ctx); err != nil {
+		return nil, err
+	}
+	app, err := s.admin.UpdateApp(ctx, int(req.GetAppId()), req.GetName(), req.GetAllowAutoProvision())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &adminv1.UpdateAppResponse{App: toApp(app)}, nil
+}
+
+func (s *ServerAPI) RotateAppSecret(
+	ctx context.Context,
+	req *adminv1.RotateAppSecretRequest,
+) (*adminv1.RotateAppSecretResponse, error) {
+	if req.GetAppId() == emptyAppId {
+		return nil, status.Error(codes.InvalidArgument, "app id is required")
+	}
+	if err := requireClientCert(ctx); err != nil {
+		return nil, err
+	}
+	secret, err := s.admin.RotateAppSecret(ctx, int(req.GetAppId()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &adminv1.RotateAppSecretResponse{Secret: secret}, nil
+}
+
+func (s *ServerAPI) ListMembers(
+	ctx context.Context,
+	req *adminv1.ListMembersRequest,
+) (*adminv1.ListMembersResponse, error) {
+	if req.GetOrgId() == emptyOrgId {
+		return nil, status.Error(codes.InvalidArgument, "org id is required")
+	}
+	if err := requireClientCert(ctx); err != nil {
+		return nil, err
+	}
+	members, err := s.admin.ListMembers(ctx, req.GetOrgId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &adminv1.ListMembersResponse{Members: make([]*adminv1.Member, 0, len(members))}
+	for _, member := range members {
+		resp.Members = append(resp.Members, toMember(member))
+	}
+	return resp, nil
+}
+
+func (s *ServerAPI) AddMember(
+	ctx context.Context,
+	req *adminv1.AddMemberRequest,
+) (*adminv1.AddMemberResponse, error) {
+	if req.GetOrgId() == emptyOrgId {
+		return nil, status.Error(codes.InvalidArgument, "org id is required")
+	}
+	if _, err := mail.ParseAddress(req.GetEmail()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "email is invalid")
+	}
+	if err := requireClientCert(ctx); err != nil {
+		return nil, err
+	}
+	member, err := s.admin.AddMember(ctx, req.GetOrgId(), req.GetEmail(), req.GetRole())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &adminv1.AddMemberResponse{Member: toMember(member)}, nil
+}
+
+func (s *ServerAPI) SetMemberRole(
+	ctx context.Context,
+	req *adminv1.SetMemberRoleRequest,
+) (*adminv1.SetMemberRoleResponse, error) {
+	if req.GetOrgId() == emptyOrgId {
+		return nil, status.Error(codes.InvalidArgument, "org id is required")
+	}
+	if req.GetUserId() == emptyUserId {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+	if err := requireClientCert(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.admin.SetMemberRole(ctx, req.GetOrgId(), req.GetUserId(), req.GetRole()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &adminv1.SetMemberRoleResponse{}, nil
+}
+
+func (s *ServerAPI) RevokeSessions(
+	ctx context.Context,
+	req *adminv1.RevokeSessionsRequest,
+) (*adminv1.RevokeSessionsResponse, error) {
+	if req.GetOrgId() == emptyOrgId {
+		return nil, status.Error(codes.InvalidArgument, "org id is required")
+	}
+	if req.GetUserId() == emptyUserId {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+	if err := requireClientCert(ctx); err != nil {
+		return nil, err
+	}
+	revokedAt, err := s.admin.RevokeSessions(ctx, req.GetOrgId(), req.GetUserId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &adminv1.RevokeSessionsResponse{RevokedAt: timestamppb.New(revokedAt)}, nil
+}
+
+// requireClientCert lets through only calls over TLS with a client
+// certificate the server verified. The service is registered only on
+// servers that require one, so this guards against a misconfigured
+// server rather than against clients.
+func requireClientCert(ctx context.Context) error {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "client certificate is required")
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 {
+		return status.Error(codes.Unauthenticated, "client certificate is required")
+	}
+	return nil
+}
+
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, admin.ErrInvalidApp):
+		return status.Error(codes.InvalidArgument, "app id and name are required")
+	case errors.Is(err, admin.ErrInvalidRole):
+		return status.Error(codes.InvalidArgument, "invalid role")
+	case errors.Is(err, admin.ErrAppNotFound):
+		return status.Error(codes.NotFound, "app not found")
+	case errors.Is(err, admin.ErrOrgNotFound):
+		return status.Error(codes.NotFound, "organization not found")
+	case errors.Is(err, admin.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, admin.ErrAppExists):
+		return status.Error(codes.AlreadyExists, "app already exists")
+	case errors.Is(err, admin.ErrUserExists):
+		return status.Error(codes.AlreadyExists, "user already exists")
+	}
+	return status.Error(codes.Internal, "internal error")
+}
+
+func toApp(app models.App) *adminv1.App {
+	return &adminv1.App{
+		Id:                 int32(app.Id),
+		OrgId:              app.OrgId,
+		Name:               app.Name,
+		AllowAutoProvision: app.AllowAutoProvision,
+	}
+}
+
+func toMember(member models.Member) *adminv1.Member {
+	return &adminv1.Member{
+		UserId: member.UserId,
+		Email:  member.Email,
+		Role:   member.Role,
+	}
+}
@@ -0,0 +1,134 @@
+package admin
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log/slog"
+	adminv1 "sso/gen/sso/admin/v1"
+	"sso/interanal/domain/models"
+	"sso/interanal/service/admin"
+	"sso/interanal/storage/memory"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// TestApps проверяет создание, обновление, ротацию секрета
+// и список приложений организации.
+func TestApps(t *testing.T) {
+	ctx := withClientCert(context.Background())
+	s, st := newTestServer(t)
+
+	created, err := s.CreateApp(ctx, &adminv1.CreateAppRequest{
+		App: &adminv1.App{Id: 1, OrgId: models.DefaultOrgId, Name: "web"},
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, created.GetSecret())
+
+	updated, err := s.UpdateApp(ctx, &adminv1.UpdateAppRequest{AppId: 1, Name: "portal", AllowAutoProvision: true})
+	require.NoError(t, err)
+	assert.Equal(t, "portal", updated.GetApp().GetName())
+
+	rotated, err := s.RotateAppSecret(ctx, &adminv1.RotateAppSecretRequest{AppId: 1})
+	require.NoError(t, err)
+	assert.NotEqual(t, created.GetSecret(), rotated.GetSecret())
+	app, err := st.GetApp(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, rotated.GetSecret(), app.Secret)
+
+	list, err := s.ListApps(ctx, &adminv1.ListAppsRequest{OrgId: models.DefaultOrgId})
+	require.NoError(t, err)
+	require.Len(t, list.GetApps(), 1)
+	assert.Equal(t, int32(1), list.GetApps()[0].GetId())
+	assert.Equal(t, "portal", list.GetApps()[0].GetName())
+	assert.True(t, list.GetApps()[0].GetAllowAutoProvision())
+
+	_, err = s.CreateApp(ctx, &adminv1.CreateAppRequest{App: &adminv1.App{Id: 1, OrgId: models.DefaultOrgId, Name: "other"}})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	_, err = s.UpdateApp(ctx, &adminv1.UpdateAppRequest{AppId: 2, Name: "other"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = s.ListApps(ctx, &adminv1.ListAppsRequest{OrgId: 42})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+// TestMembersAndSessions проверяет добавление участника, смену
+// роли, список участников и отзыв сессий.
+func TestMembersAndSessions(t *testing.T) {
+	ctx := withClientCert(context.Background())
+	s, st := newTestServer(t)
+
+	added, err := s.AddMember(ctx, &adminv1.AddMemberRequest{OrgId: models.DefaultOrgId, Email: "user@gmail.com", Role: models.RoleMember})
+	require.NoError(t, err)
+	userId := added.GetMember().GetUserId()
+	_, err = s.SetMemberRole(ctx, &adminv1.SetMemberRoleRequest{OrgId: models.DefaultOrgId, UserId: userId, Role: models.RoleAdmin})
+	require.NoError(t, err)
+
+	list, err := s.ListMembers(ctx, &adminv1.ListMembersRequest{OrgId: models.DefaultOrgId})
+	require.NoError(t, err)
+	require.Len(t, list.GetMembers(), 1)
+	assert.Equal(t, "user@gmail.com", list.GetMembers()[0].GetEmail())
+	assert.Equal(t, models.RoleAdmin, list.GetMembers()[0].GetRole())
+
+	revoked, err := s.RevokeSessions(ctx, &adminv1.RevokeSessionsRequest{OrgId: models.DefaultOrgId, UserId: userId})
+	require.NoError(t, err)
+	revokedAt, err := st.SessionsRevokedAt(ctx, models.DefaultOrgId, userId)
+	require.NoError(t, err)
+	assert.True(t, revokedAt.Equal(revoked.GetRevokedAt().AsTime()))
+
+	_, err = s.SetMemberRole(ctx, &adminv1.SetMemberRoleRequest{OrgId: models.DefaultOrgId, UserId: userId, Role: "root"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = s.AddMember(ctx, &adminv1.AddMemberRequest{OrgId: models.DefaultOrgId, Email: "user@gmail.com", Role: models.RoleMember})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	_, err = s.RevokeSessions(ctx, &adminv1.RevokeSessionsRequest{OrgId: models.DefaultOrgId, UserId: 42})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+// TestRequiresClientCert проверяет, что без проверенного
+// клиентского сертификата вызовы отклоняются.
+func TestRequiresClientCert(t *testing.T) {
+	s, _ := newTestServer(t)
+	req := &adminv1.ListAppsRequest{OrgId: models.DefaultOrgId}
+	tests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{name: "no peer", ctx: context.Background()},
+		{name: "plaintext", ctx: peer.NewContext(context.Background(), &peer.Peer{})},
+		{name: "tls without client cert", ctx: peer.NewContext(context.Background(), &peer.Peer{
+			AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{}},
+		})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.ListApps(tt.ctx, req)
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		})
+	}
+}
+
+func newTestServer(t *testing.T) (*ServerAPI, *memory.Storage) {
+	t.Helper()
+	st := memory.New()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	service := admin.New(logger, st, st, st, st, st, st)
+	return &ServerAPI{admin: service}, st
+}
+
+// withClientCert makes ctx look like a call over mTLS with a verified
+// client certificate.
+func withClientCert(ctx context.Context) context.Context {
+	cert := &x509.Certificate{}
+	return peer.NewContext(ctx, &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{cert},
+			VerifiedChains:   [][]*x509.Certificate{{cert}},
+		}},
+	})
+}
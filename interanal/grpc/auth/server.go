@@ -4,17 +4,23 @@ import (
 	"context"
 	"errors"
 	"net/mail"
+	"sso/interanal/domain/models"
 	"sso/interanal/service/auth"
 	"sso/interanal/storage"
+	"strconv"
 
 	ssov1 "github.com/sariya23/sso_proto/gen/sso"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
 const (
 	emptyAppId = 0
+	// AppIdMetadataKey names the app of RPCs whose requests have no
+	// app_id. The organization of the app is the tenant of the call.
+	AppIdMetadataKey = "x-app-id"
 )
 
 type userCreds struct {
@@ -30,19 +36,25 @@ type Auth interface {
 	) (token string, err error)
 	RegisterNewUser(
 		ctx context.Context,
+		orgId int64,
 		email string,
 		password string,
 	) (userId int64, err error)
-	IsAdmin(ctx context.Context, userId int64) (bool, error)
+	IsAdmin(ctx context.Context, orgId int64, userId int64) (bool, error)
+}
+
+type AppProvider interface {
+	GetApp(ctx context.Context, appId int) (models.App, error)
 }
 
 type ServerAPI struct {
 	ssov1.UnimplementedAuthServer
 	auth Auth
+	apps AppProvider
 }
 
-func RegisterServerAPI(grpcServer *grpc.Server, auth Auth) {
-	ssov1.RegisterAuthServer(grpcServer, &ServerAPI{auth: auth})
+func RegisterServerAPI(grpcServer *grpc.Server, auth Auth, apps AppProvider) {
+	ssov1.RegisterAuthServer(grpcServer, &ServerAPI{auth: auth, apps: apps})
 }
 
 func (s *ServerAPI) Login(ctx context.Context, req *ssov1.LoginRequest) (*ssov1.LoginResponse, error) {
@@ -72,11 +84,21 @@ func (s *ServerAPI) Register(ctx context.Context, req *ssov1.RegisterRequest) (*
 	if err := validateUserCreds(userCreds{email: email, password: password}); err != nil {
 		return nil, err
 	}
-	userId, err := s.auth.RegisterNewUser(ctx, email, password)
+	orgId, err := s.orgIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userId, err := s.auth.RegisterNewUser(ctx, orgId, email, password)
 	if err != nil {
 		if errors.Is(err, auth.ErrUserExists) {
 			return nil, status.Error(codes.AlreadyExists, auth.ErrUserExists.Error())
 		}
+		if errors.Is(err, auth.ErrOrgNotFound) {
+			return nil, status.Error(codes.NotFound, "organization not found")
+		}
+		if errors.Is(err, auth.ErrRegistrationClosed) {
+			return nil, status.Error(codes.PermissionDenied, "registration is closed")
+		}
 		return nil, status.Error(codes.Internal, "internal error")
 	}
 	return &ssov1.RegisterResponse{
@@ -89,7 +111,11 @@ func (s *ServerAPI) IsAdmin(ctx context.Context, req *ssov1.IsAdminRequest) (*ss
 	if userId == 0 {
 		return nil, status.Error(codes.InvalidArgument, "user id is required")
 	}
-	isAdmin, err := s.auth.IsAdmin(ctx, userId)
+	orgId, err := s.orgIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	isAdmin, err := s.auth.IsAdmin(ctx, orgId, userId)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
 			return nil, status.Error(codes.NotFound, "user not found")
@@ -110,3 +136,26 @@ func validateUserCreds(creds userCreds) error {
 	}
 	return nil
 }
+
+// orgIdFromContext returns the organization of the app named by
+// AppIdMetadataKey, the same way Login takes it from app_id. Calls
+// without an app are rejected, so there is no tenant to fall back to.
+func (s *ServerAPI) orgIdFromContext(ctx context.Context) (int64, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(AppIdMetadataKey)
+	if len(values) == 0 {
+		return 0, status.Error(codes.InvalidArgument, "app id is required")
+	}
+	appId, err := strconv.Atoi(values[0])
+	if err != nil || appId <= 0 {
+		return 0, status.Error(codes.InvalidArgument, "app id is invalid")
+	}
+	app, err := s.apps.GetApp(ctx, appId)
+	if errors.Is(err, storage.ErrAppNotFound) {
+		return 0, status.Error(codes.NotFound, "app not found")
+	}
+	if err != nil {
+		return 0, status.Error(codes.Internal, "internal error")
+	}
+	return app.OrgId, nil
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"sso/interanal/domain/models"
+	"sso/interanal/storage"
+	"testing"
+
+	ssov1 "github.com/sariya23/sso_proto/gen/sso"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TestRegisterTakesOrgFromApp проверяет, что Register
+// регистрирует в организации приложения из x-app-id, а без
+// приложения вызов отклоняется, а не попадает в организацию 1.
+func TestRegisterTakesOrgFromApp(t *testing.T) {
+	auth := &fakeAuth{}
+	s := &ServerAPI{auth: auth, apps: fakeApps{7: {Id: 7, OrgId: 42}}}
+	req := &ssov1.RegisterRequest{Email: "user@gmail.com", Password: "secret"}
+
+	_, err := s.Register(withApp(context.Background(), "7"), req)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), auth.orgId)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		code codes.Code
+	}{
+		{name: "no app", ctx: context.Background(), code: codes.InvalidArgument},
+		{name: "invalid app", ctx: withApp(context.Background(), "x"), code: codes.InvalidArgument},
+		{name: "unknown app", ctx: withApp(context.Background(), "8"), code: codes.NotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Register(tt.ctx, req)
+			assert.Equal(t, tt.code, status.Code(err))
+			_, err = s.IsAdmin(tt.ctx, &ssov1.IsAdminRequest{UserId: 1})
+			assert.Equal(t, tt.code, status.Code(err))
+		})
+	}
+}
+
+func withApp(ctx context.Context, appId string) context.Context {
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(AppIdMetadataKey, appId))
+}
+
+type fakeAuth struct {
+	orgId int64
+}
+
+func (f *fakeAuth) Login(ctx context.Context, email string, password string, appId int) (string, error) {
+	return "", nil
+}
+
+func (f *fakeAuth) RegisterNewUser(ctx context.Context, orgId int64, email string, password string) (int64, error) {
+	f.orgId = orgId
+	return 1, nil
+}
+
+func (f *fakeAuth) IsAdmin(ctx context.Context, orgId int64, userId int64) (bool, error) {
+	f.orgId = orgId
+	return false, nil
+}
+
+type fakeApps map[int]models.App
+
+func (f fakeApps) GetApp(ctx context.Context, appId int) (models.App, error) {
+	app, ok := f[appId]
+	if !ok {
+		return models.App{}, storage.ErrAppNotFound
+	}
+	return app, nil
+}
@@ -0,0 +1,114 @@
+// Package authn authenticates the users that call RPCs with a token
+// of Login in the "authorization: Bearer <token>" metadata.
+package authn
+
+import (
+	"context"
+	"errors"
+	"sso/interanal/domain/models"
+	"sso/interanal/storage"
+	"sso/lib/jwt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	MetadataKey = "authorization"
+	scheme      = "bearer "
+)
+
+type AppProvider interface {
+	GetApp(ctx context.Context, appId int) (models.App, error)
+}
+
+// SessionProvider tells when the sessions of a user were last revoked.
+// It must not be cached, a revoked token has to stop working at once.
+type SessionProvider interface {
+	SessionsRevokedAt(ctx context.Context, orgId int64, userId int64) (time.Time, error)
+}
+
+// Caller is the user that a token was issued to.
+type Caller struct {
+	UserId int64
+	OrgId  int64
+	AppId  int
+	Email  string
+}
+
+type Authenticator struct {
+	apps     AppProvider
+	sessions SessionProvider
+}
+
+func New(apps AppProvider, sessions SessionProvider) *Authenticator {
+	return &Authenticator{apps: apps, sessions: sessions}
+}
+
+// Authenticate returns the caller of ctx. Its errors are gRPC status
+// errors that the handlers return as is. Impersonation tokens are
+// rejected, so an impersonating admin can read as the user but not
+// act on the account.
+func (a *Authenticator) Authenticate(ctx context.Context) (Caller, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(MetadataKey)
+	if len(values) == 0 {
+		return Caller{}, status.Error(codes.Unauthenticated, "token is required")
+	}
+	token, ok := cutPrefixFold(values[0], scheme)
+	if !ok || token == "" {
+		return Caller{}, status.Error(codes.Unauthenticated, "bearer token is required")
+	}
+	appId, err := jwt.AppId(token)
+	if err != nil {
+		return Caller{}, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	app, err := a.apps.GetApp(ctx, appId)
+	if errors.Is(err, storage.ErrAppNotFound) {
+		return Caller{}, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	if err != nil {
+		return Caller{}, status.Error(codes.Internal, "internal error")
+	}
+	claims, err := jwt.Verify(token, app.Secret)
+	if err != nil || claims.OrgId != app.OrgId {
+		return Caller{}, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	if claims.Impersonated {
+		return Caller{}, status.Error(codes.PermissionDenied, "impersonation tokens can't do this")
+	}
+	revokedAt, err := a.sessions.SessionsRevokedAt(ctx, claims.OrgId, claims.UserId)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return Caller{}, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	if err != nil {
+		return Caller{}, status.Error(codes.Internal, "internal error")
+	}
+	if isRevoked(claims.IssuedAt, revokedAt) {
+		return Caller{}, status.Error(codes.Unauthenticated, "token is revoked")
+	}
+	return Caller{UserId: claims.UserId, OrgId: claims.OrgId, AppId: claims.AppId, Email: claims.Email}, nil
+}
+
+// isRevoked reports whether a token issued at issuedAt was revoked.
+// iat has whole seconds, so a token issued in the second of the
+// revocation is revoked too, even if it came a moment later. Tokens
+// without iat are revoked by any revocation.
+func isRevoked(issuedAt time.Time, revokedAt time.Time) bool {
+	if revokedAt.IsZero() {
+		return false
+	}
+	return !issuedAt.After(revokedAt.Truncate(time.Second))
+}
+
+// cutPrefixFold is strings.CutPrefix that ignores the case of prefix,
+// as auth schemes are case-insensitive.
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
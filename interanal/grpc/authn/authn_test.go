@@ -0,0 +1,111 @@
+package authn
+
+import (
+	"context"
+	"sso/interanal/domain/models"
+	"sso/interanal/storage"
+	"sso/lib/jwt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	testApp     = models.App{Id: 2, OrgId: 7, Name: "web", Secret: "web-secret"}
+	testUser    = models.User{Id: 42, OrgId: 7, Email: "user@gmail.com"}
+	revokedUser = models.User{Id: 43, OrgId: 7, Email: "revoked@gmail.com"}
+	deletedUser = models.User{Id: 44, OrgId: 7, Email: "deleted@gmail.com"}
+)
+
+// TestAuthenticate проверяет, что валидный токен дает
+// вызывающего, а чужой, просроченный или имперсонированный
+// токен отклоняется.
+func TestAuthenticate(t *testing.T) {
+	valid, err := jwt.NewToken(testUser, testApp, time.Hour)
+	require.NoError(t, err)
+	expired, err := jwt.NewToken(testUser, testApp, -time.Hour)
+	require.NoError(t, err)
+	forged, err := jwt.NewToken(testUser, models.App{Id: testApp.Id, OrgId: testApp.OrgId, Secret: "other"}, time.Hour)
+	require.NoError(t, err)
+	otherOrg, err := jwt.NewToken(testUser, models.App{Id: testApp.Id, OrgId: 1, Secret: testApp.Secret}, time.Hour)
+	require.NoError(t, err)
+	unknownApp, err := jwt.NewToken(testUser, models.App{Id: 3, OrgId: 7, Secret: "x"}, time.Hour)
+	require.NoError(t, err)
+	impersonated, err := jwt.NewImpersonationToken(testUser, models.User{Id: 1, Email: "admin@gmail.com"}, testApp, time.Hour)
+	require.NoError(t, err)
+	revoked, err := jwt.NewToken(revokedUser, testApp, time.Hour)
+	require.NoError(t, err)
+	deleted, err := jwt.NewToken(deletedUser, testApp, time.Hour)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		header string
+		code   codes.Code
+	}{
+		{name: "valid", header: "Bearer " + valid, code: codes.OK},
+		{name: "lowercase scheme", header: "bearer " + valid, code: codes.OK},
+		{name: "no header", code: codes.Unauthenticated},
+		{name: "basic scheme", header: "Basic " + valid, code: codes.Unauthenticated},
+		{name: "garbage", header: "Bearer garbage", code: codes.Unauthenticated},
+		{name: "expired", header: "Bearer " + expired, code: codes.Unauthenticated},
+		{name: "wrong secret", header: "Bearer " + forged, code: codes.Unauthenticated},
+		{name: "other org", header: "Bearer " + otherOrg, code: codes.Unauthenticated},
+		{name: "unknown app", header: "Bearer " + unknownApp, code: codes.Unauthenticated},
+		{name: "impersonated", header: "Bearer " + impersonated, code: codes.PermissionDenied},
+		{name: "revoked", header: "Bearer " + revoked, code: codes.Unauthenticated},
+		{name: "deleted user", header: "Bearer " + deleted, code: codes.Unauthenticated},
+	}
+	a := New(fakeStorage{}, fakeStorage{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.header != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(MetadataKey, tt.header))
+			}
+
+			caller, err := a.Authenticate(ctx)
+
+			assert.Equal(t, tt.code, status.Code(err))
+			if tt.code == codes.OK {
+				assert.Equal(t, Caller{UserId: testUser.Id, OrgId: testApp.OrgId, AppId: testApp.Id, Email: testUser.Email}, caller)
+			}
+		})
+	}
+}
+
+// TestIsRevoked проверяет, что отзыв сессий отклоняет токены,
+// выданные до него и в ту же секунду, но не выданные позже.
+func TestIsRevoked(t *testing.T) {
+	revokedAt := time.Date(2026, 1, 1, 12, 0, 0, 500_000_000, time.UTC)
+
+	assert.False(t, isRevoked(revokedAt.Add(-time.Hour), time.Time{}))
+	assert.True(t, isRevoked(time.Time{}, revokedAt))
+	assert.True(t, isRevoked(revokedAt.Add(-time.Second), revokedAt))
+	assert.True(t, isRevoked(revokedAt.Truncate(time.Second), revokedAt))
+	assert.False(t, isRevoked(revokedAt.Truncate(time.Second).Add(time.Second), revokedAt))
+}
+
+type fakeStorage struct{}
+
+func (fakeStorage) SessionsRevokedAt(ctx context.Context, orgId int64, userId int64) (time.Time, error) {
+	switch userId {
+	case testUser.Id:
+		return time.Time{}, nil
+	case revokedUser.Id:
+		return time.Now(), nil
+	}
+	return time.Time{}, storage.ErrUserNotFound
+}
+
+func (fakeStorage) GetApp(ctx context.Context, appId int) (models.App, error) {
+	if appId != testApp.Id {
+		return models.App{}, storage.ErrAppNotFound
+	}
+	return testApp, nil
+}
@@ -0,0 +1,136 @@
+package device
+
+import (
+	"context"
+	"errors"
+	devicev1 "sso/gen/sso/device/v1"
+	"sso/interanal/grpc/authn"
+	"sso/interanal/service/device"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const emptyAppId = 0
+
+type Device interface {
+	StartDeviceAuthorization(ctx context.Context, appId int) (device.Authorization, error)
+	ApproveDeviceAuthorization(ctx context.Context, orgId int64, userId int64, userCode string) error
+	DenyDeviceAuthorization(ctx context.Context, orgId int64, userId int64, userCode string) error
+	PollDeviceToken(ctx context.Context, deviceCode string) (token string, err error)
+}
+
+type ServerAPI struct {
+	devicev1.UnimplementedDeviceServiceServer
+	device Device
+	authn  *authn.Authenticator
+}
+
+func RegisterServerAPI(grpcServer *grpc.Server, device Device, authenticator *authn.Authenticator) {
+	devicev1.RegisterDeviceServiceServer(grpcServer, &ServerAPI{device: device, authn: authenticator})
+}
+
+func (s *ServerAPI) StartDeviceAuthorization(
+	ctx context.Context,
+	req *devicev1.StartDeviceAuthorizationRequest,
+) (*devicev1.StartDeviceAuthorizationResponse, error) {
+	if req.GetAppId() == emptyAppId {
+		return nil, status.Error(codes.InvalidArgument, "app id is required")
+	}
+	auth, err := s.device.StartDeviceAuthorization(ctx, int(req.GetAppId()))
+	if err != nil {
+		if errors.Is(err, device.ErrAppNotFound) {
+			return nil, status.Error(codes.NotFound, "app not found")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return &devicev1.StartDeviceAuthorizationResponse{
+		DeviceCode:              auth.DeviceCode,
+		UserCode:                auth.UserCode,
+		VerificationUri:         auth.VerificationURI,
+		VerificationUriComplete: auth.VerificationURIComplete,
+		ExpiresIn:               int32(auth.ExpiresIn.Seconds()),
+		Interval:                int32(auth.Interval.Seconds()),
+	}, nil
+}
+
+// ApproveDeviceAuthorization approves the device as the caller, so the
+// device gets a token of the user who entered the code.
+func (s *ServerAPI) ApproveDeviceAuthorization(
+	ctx context.Context,
+	req *devicev1.ApproveDeviceAuthorizationRequest,
+) (*devicev1.ApproveDeviceAuthorizationResponse, error) {
+	if req.GetUserCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user code is required")
+	}
+	caller, err := s.authn.Authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.device.ApproveDeviceAuthorization(ctx, caller.OrgId, caller.UserId, req.GetUserCode()); err != nil {
+		return nil, resolveStatus(err)
+	}
+	return &devicev1.ApproveDeviceAuthorizationResponse{}, nil
+}
+
+func (s *ServerAPI) DenyDeviceAuthorization(
+	ctx context.Context,
+	req *devicev1.DenyDeviceAuthorizationRequest,
+) (*devicev1.DenyDeviceAuthorizationResponse, error) {
+	if req.GetUserCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "user code is required")
+	}
+	caller, err := s.authn.Authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.device.DenyDeviceAuthorization(ctx, caller.OrgId, caller.UserId, req.GetUserCode()); err != nil {
+		return nil, resolveStatus(err)
+	}
+	return &devicev1.DenyDeviceAuthorizationResponse{}, nil
+}
+
+// PollDeviceToken returns the RFC 8628 error codes as status messages,
+// so a device can tell pending from slow_down without parsing anything
+// else.
+func (s *ServerAPI) PollDeviceToken(
+	ctx context.Context,
+	req *devicev1.PollDeviceTokenRequest,
+) (*devicev1.PollDeviceTokenResponse, error) {
+	if req.GetDeviceCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "device code is required")
+	}
+	token, err := s.device.PollDeviceToken(ctx, req.GetDeviceCode())
+	if err != nil {
+		if errors.Is(err, device.ErrAuthorizationPending) {
+			return nil, status.Error(codes.FailedPrecondition, "authorization_pending")
+		}
+		if errors.Is(err, device.ErrSlowDown) {
+			return nil, status.Error(codes.ResourceExhausted, "slow_down")
+		}
+		if errors.Is(err, device.ErrAccessDenied) {
+			return nil, status.Error(codes.PermissionDenied, "access_denied")
+		}
+		if errors.Is(err, device.ErrExpiredToken) {
+			return nil, status.Error(codes.DeadlineExceeded, "expired_token")
+		}
+		if errors.Is(err, device.ErrInvalidDeviceCode) {
+			return nil, status.Error(codes.InvalidArgument, "invalid_grant")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return &devicev1.PollDeviceTokenResponse{
+		Token: token,
+	}, nil
+}
+
+func resolveStatus(err error) error {
+	if errors.Is(err, device.ErrInvalidUserCode) {
+		return status.Error(codes.NotFound, "user code is invalid or expired")
+	}
+	if errors.Is(err, device.ErrUserNotFound) {
+		return status.Error(codes.NotFound, "user not found")
+	}
+	return status.Error(codes.Internal, "internal error")
+}
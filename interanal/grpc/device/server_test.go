@@ -0,0 +1,106 @@
+package device
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	devicev1 "sso/gen/sso/device/v1"
+	"sso/interanal/domain/models"
+	"sso/interanal/grpc/authn"
+	"sso/interanal/service/device"
+	"sso/interanal/storage/memory"
+	"sso/lib/jwt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var testApp = models.App{Id: 1, OrgId: models.DefaultOrgId, Name: "test", Secret: "test-secret"}
+
+// TestDeviceFlow проверяет, что устройство получает токен
+// пользователя, который подтвердил код, а до этого —
+// authorization_pending.
+func TestDeviceFlow(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestServer(t, 0)
+	start, err := s.StartDeviceAuthorization(ctx, &devicev1.StartDeviceAuthorizationRequest{AppId: int32(testApp.Id)})
+	require.NoError(t, err)
+	poll := &devicev1.PollDeviceTokenRequest{DeviceCode: start.GetDeviceCode()}
+
+	_, err = s.PollDeviceToken(ctx, poll)
+	assertStatus(t, err, codes.FailedPrecondition, "authorization_pending")
+	_, err = s.ApproveDeviceAuthorization(ctx, &devicev1.ApproveDeviceAuthorizationRequest{UserCode: start.GetUserCode()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = s.ApproveDeviceAuthorization(withToken(t, ctx, user), &devicev1.ApproveDeviceAuthorizationRequest{UserCode: start.GetUserCode()})
+	require.NoError(t, err)
+
+	resp, err := s.PollDeviceToken(ctx, poll)
+	require.NoError(t, err)
+	claims, err := jwt.Verify(resp.GetToken(), testApp.Secret)
+	require.NoError(t, err)
+	assert.Equal(t, user.Id, claims.UserId)
+	_, err = s.PollDeviceToken(ctx, poll)
+	assertStatus(t, err, codes.InvalidArgument, "invalid_grant")
+}
+
+// TestDevicePollErrors проверяет, что slow_down и access_denied
+// возвращаются кодами из RFC 8628 в сообщении.
+func TestDevicePollErrors(t *testing.T) {
+	ctx := context.Background()
+	s, user := newTestServer(t, time.Hour)
+	start, err := s.StartDeviceAuthorization(ctx, &devicev1.StartDeviceAuthorizationRequest{AppId: int32(testApp.Id)})
+	require.NoError(t, err)
+	assert.Equal(t, int32(time.Hour.Seconds()), start.GetInterval())
+	poll := &devicev1.PollDeviceTokenRequest{DeviceCode: start.GetDeviceCode()}
+
+	_, err = s.PollDeviceToken(ctx, poll)
+	assertStatus(t, err, codes.FailedPrecondition, "authorization_pending")
+	_, err = s.PollDeviceToken(ctx, poll)
+	assertStatus(t, err, codes.ResourceExhausted, "slow_down")
+
+	_, err = s.DenyDeviceAuthorization(withToken(t, ctx, user), &devicev1.DenyDeviceAuthorizationRequest{UserCode: start.GetUserCode()})
+	require.NoError(t, err)
+	_, err = s.ApproveDeviceAuthorization(withToken(t, ctx, user), &devicev1.ApproveDeviceAuthorizationRequest{UserCode: start.GetUserCode()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = s.StartDeviceAuthorization(ctx, &devicev1.StartDeviceAuthorizationRequest{AppId: 42})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func newTestServer(t *testing.T, pollInterval time.Duration) (*ServerAPI, models.User) {
+	t.Helper()
+	ctx := context.Background()
+	st := memory.New()
+	require.NoError(t, st.SaveApp(ctx, testApp))
+	_, err := st.SaveUser(ctx, models.DefaultOrgId, "user@gmail.com", []byte("hash"))
+	require.NoError(t, err)
+	user, err := st.GetUser(ctx, models.DefaultOrgId, "user@gmail.com")
+	require.NoError(t, err)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	service := device.New(logger, st, st, st, device.Options{
+		VerificationURI: "http://localhost/device",
+		CodeTTL:         time.Minute,
+		PollInterval:    pollInterval,
+		TokenTTL:        time.Hour,
+	})
+	return &ServerAPI{device: service, authn: authn.New(st, st)}, user
+}
+
+func withToken(t *testing.T, ctx context.Context, user models.User) context.Context {
+	t.Helper()
+	token, err := jwt.NewToken(user, testApp, time.Hour)
+	require.NoError(t, err)
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(authn.MetadataKey, "Bearer "+token))
+}
+
+func assertStatus(t *testing.T, err error, code codes.Code, message string) {
+	t.Helper()
+	st, ok := status.FromError(err)
+	require.True(t, ok, "not a status error: %v", err)
+	assert.Equal(t, code, st.Code())
+	assert.Equal(t, message, st.Message())
+}
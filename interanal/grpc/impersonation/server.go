@@ -0,0 +1,92 @@
+package impersonation
+
+import (
+	"context"
+	"errors"
+	impersonationv1 "sso/gen/sso/impersonation/v1"
+	"sso/interanal/grpc/authn"
+	"sso/interanal/service/impersonation"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	emptyAppId  = 0
+	emptyUserId = 0
+)
+
+type Impersonation interface {
+	Impersonate(
+		ctx context.Context,
+		orgId int64,
+		actorId int64,
+		targetUserId int64,
+		appId int,
+		reason string,
+	) (token string, err error)
+}
+
+type ServerAPI struct {
+	impersonationv1.UnimplementedImpersonationServiceServer
+	impersonation Impersonation
+	authn         *authn.Authenticator
+}
+
+func RegisterServerAPI(grpcServer *grpc.Server, impersonation Impersonation, authenticator *authn.Authenticator) {
+	impersonationv1.RegisterImpersonationServiceServer(grpcServer, &ServerAPI{impersonation: impersonation, authn: authenticator})
+}
+
+// Impersonate takes the admin and the organization from the token, so
+// the actor written to the audit log is the one who actually called.
+// Authenticate rejects impersonation tokens, so an impersonated
+// session can't impersonate further.
+func (s *ServerAPI) Impersonate(
+	ctx context.Context,
+	req *impersonationv1.ImpersonateRequest,
+) (*impersonationv1.ImpersonateResponse, error) {
+	if req.GetUserId() == emptyUserId {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+	if req.GetAppId() == emptyAppId {
+		return nil, status.Error(codes.InvalidArgument, "app id is required")
+	}
+	if strings.TrimSpace(req.GetReason()) == "" {
+		return nil, status.Error(codes.InvalidArgument, "reason is required")
+	}
+	caller, err := s.authn.Authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.impersonation.Impersonate(
+		ctx,
+		caller.OrgId,
+		caller.UserId,
+		req.GetUserId(),
+		int(req.GetAppId()),
+		req.GetReason(),
+	)
+	if err != nil {
+		if errors.Is(err, impersonation.ErrReasonRequired) {
+			return nil, status.Error(codes.InvalidArgument, "reason is required")
+		}
+		if errors.Is(err, impersonation.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, "only org admins can impersonate")
+		}
+		if errors.Is(err, impersonation.ErrCannotImpersonateAdmin) {
+			return nil, status.Error(codes.PermissionDenied, "admins cannot be impersonated")
+		}
+		if errors.Is(err, impersonation.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		if errors.Is(err, impersonation.ErrAppNotFound) {
+			return nil, status.Error(codes.NotFound, "app not found")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return &impersonationv1.ImpersonateResponse{
+		Token: token,
+	}, nil
+}
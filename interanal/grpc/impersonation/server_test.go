@@ -0,0 +1,99 @@
+package impersonation
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	impersonationv1 "sso/gen/sso/impersonation/v1"
+	"sso/interanal/domain/models"
+	"sso/interanal/grpc/authn"
+	"sso/interanal/service/impersonation"
+	"sso/interanal/storage/memory"
+	"sso/lib/jwt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var testApp = models.App{Id: 1, OrgId: models.DefaultOrgId, Name: "test", Secret: "test-secret"}
+
+// TestImpersonate проверяет, что админ из токена получает
+// токен пользователя, а в журнал пишется именно он.
+func TestImpersonate(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t)
+	admin := saveUser(t, st, "admin@gmail.com")
+	require.NoError(t, st.SetMemberRole(ctx, models.DefaultOrgId, admin.Id, models.RoleAdmin))
+	user := saveUser(t, st, "user@gmail.com")
+
+	resp, err := s.Impersonate(withToken(t, ctx, admin), &impersonationv1.ImpersonateRequest{
+		UserId: user.Id,
+		AppId:  int32(testApp.Id),
+		Reason: "ticket #42",
+	})
+	require.NoError(t, err)
+
+	claims, err := jwt.Verify(resp.GetToken(), testApp.Secret)
+	require.NoError(t, err)
+	assert.Equal(t, user.Id, claims.UserId)
+	assert.True(t, claims.Impersonated)
+}
+
+// TestImpersonateNeedsAdmin проверяет, что без токена, от обычного
+// участника и с токеном имперсонации имперсонировать нельзя.
+func TestImpersonateNeedsAdmin(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t)
+	admin := saveUser(t, st, "admin@gmail.com")
+	require.NoError(t, st.SetMemberRole(ctx, models.DefaultOrgId, admin.Id, models.RoleAdmin))
+	member := saveUser(t, st, "member@gmail.com")
+	user := saveUser(t, st, "user@gmail.com")
+	req := &impersonationv1.ImpersonateRequest{UserId: user.Id, AppId: int32(testApp.Id), Reason: "ticket #42"}
+
+	_, err := s.Impersonate(ctx, req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = s.Impersonate(withToken(t, ctx, member), req)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	impersonated, err := jwt.NewImpersonationToken(member, admin, testApp, time.Hour)
+	require.NoError(t, err)
+	impersonatedCtx := metadata.NewIncomingContext(ctx, metadata.Pairs(authn.MetadataKey, "Bearer "+impersonated))
+	_, err = s.Impersonate(impersonatedCtx, req)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = s.Impersonate(withToken(t, ctx, admin), &impersonationv1.ImpersonateRequest{UserId: user.Id, AppId: int32(testApp.Id)})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = s.Impersonate(withToken(t, ctx, admin), &impersonationv1.ImpersonateRequest{UserId: 42, AppId: int32(testApp.Id), Reason: "ticket #42"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func newTestServer(t *testing.T) (*ServerAPI, *memory.Storage) {
+	t.Helper()
+	st := memory.New()
+	require.NoError(t, st.SaveApp(context.Background(), testApp))
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	service := impersonation.New(logger, st, st, st, st, time.Hour)
+	return &ServerAPI{impersonation: service, authn: authn.New(st, st)}, st
+}
+
+func saveUser(t *testing.T, st *memory.Storage, email string) models.User {
+	t.Helper()
+	ctx := context.Background()
+	_, err := st.SaveUser(ctx, models.DefaultOrgId, email, []byte("hash"))
+	require.NoError(t, err)
+	user, err := st.GetUser(ctx, models.DefaultOrgId, email)
+	require.NoError(t, err)
+	return user
+}
+
+func withToken(t *testing.T, ctx context.Context, user models.User) context.Context {
+	t.Helper()
+	token, err := jwt.NewToken(user, testApp, time.Hour)
+	require.NoError(t, err)
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(authn.MetadataKey, "Bearer "+token))
+}
@@ -0,0 +1,82 @@
+package invite
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	invitev1 "sso/gen/sso/invite/v1"
+	"sso/interanal/grpc/authn"
+	"sso/interanal/service/invite"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type Invite interface {
+	CreateInvite(
+		ctx context.Context,
+		orgId int64,
+		inviterId int64,
+		email string,
+		role string,
+	) (token string, err error)
+	AcceptInvite(ctx context.Context, token string, password string) (userId int64, err error)
+}
+
+type ServerAPI struct {
+	invitev1.UnimplementedInviteServiceServer
+	invite Invite
+	authn  *authn.Authenticator
+}
+
+func RegisterServerAPI(grpcServer *grpc.Server, invite Invite, authenticator *authn.Authenticator) {
+	invitev1.RegisterInviteServiceServer(grpcServer, &ServerAPI{invite: invite, authn: authenticator})
+}
+
+// CreateInvite invites into the organization of the caller, so an
+// admin can't invite into an organization of another tenant.
+func (s *ServerAPI) CreateInvite(ctx context.Context, req *invitev1.CreateInviteRequest) (*invitev1.CreateInviteResponse, error) {
+	if _, err := mail.ParseAddress(req.GetEmail()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "email is invalid")
+	}
+	caller, err := s.authn.Authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.invite.CreateInvite(ctx, caller.OrgId, caller.UserId, req.GetEmail(), req.GetRole())
+	if err != nil {
+		if errors.Is(err, invite.ErrInvalidRole) {
+			return nil, status.Error(codes.InvalidArgument, "role is invalid")
+		}
+		if errors.Is(err, invite.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, "only org admins can invite")
+		}
+		if errors.Is(err, invite.ErrOrgNotFound) {
+			return nil, status.Error(codes.NotFound, "organization not found")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return &invitev1.CreateInviteResponse{
+		Token: token,
+	}, nil
+}
+
+func (s *ServerAPI) AcceptInvite(ctx context.Context, req *invitev1.AcceptInviteRequest) (*invitev1.AcceptInviteResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+	userId, err := s.invite.AcceptInvite(ctx, req.GetToken(), req.GetPassword())
+	if err != nil {
+		if errors.Is(err, invite.ErrInvalidInvite) {
+			return nil, status.Error(codes.NotFound, "invite is invalid or expired")
+		}
+		if errors.Is(err, invite.ErrPasswordRequired) {
+			return nil, status.Error(codes.InvalidArgument, "password is required")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return &invitev1.AcceptInviteResponse{
+		UserId: userId,
+	}, nil
+}
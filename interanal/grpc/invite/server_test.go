@@ -0,0 +1,78 @@
+package invite
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	invitev1 "sso/gen/sso/invite/v1"
+	"sso/interanal/domain/models"
+	"sso/interanal/grpc/authn"
+	"sso/interanal/service/invite"
+	"sso/interanal/storage/memory"
+	"sso/lib/jwt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var testApp = models.App{Id: 1, OrgId: models.DefaultOrgId, Name: "test", Secret: "test-secret"}
+
+// TestInviteFlow проверяет, что админ приглашает в организацию
+// своего токена, а приглашенный принимает приглашение без токена.
+func TestInviteFlow(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t)
+	adminId, err := st.SaveUser(ctx, models.DefaultOrgId, "admin@gmail.com", []byte("hash"))
+	require.NoError(t, err)
+	require.NoError(t, st.SetMemberRole(ctx, models.DefaultOrgId, adminId, models.RoleAdmin))
+
+	created, err := s.CreateInvite(
+		withToken(t, ctx, models.User{Id: adminId, Email: "admin@gmail.com"}),
+		&invitev1.CreateInviteRequest{Email: "new@gmail.com", Role: models.RoleMember},
+	)
+	require.NoError(t, err)
+	accepted, err := s.AcceptInvite(ctx, &invitev1.AcceptInviteRequest{Token: created.GetToken(), Password: "secret"})
+	require.NoError(t, err)
+
+	user, err := st.GetUser(ctx, models.DefaultOrgId, "new@gmail.com")
+	require.NoError(t, err)
+	assert.Equal(t, user.Id, accepted.GetUserId())
+	_, err = s.AcceptInvite(ctx, &invitev1.AcceptInviteRequest{Token: created.GetToken(), Password: "secret"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+// TestCreateInviteRequiresAdmin проверяет, что без токена
+// и от обычного участника приглашение не создается.
+func TestCreateInviteRequiresAdmin(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t)
+	memberId, err := st.SaveUser(ctx, models.DefaultOrgId, "member@gmail.com", []byte("hash"))
+	require.NoError(t, err)
+	req := &invitev1.CreateInviteRequest{Email: "new@gmail.com", Role: models.RoleMember}
+
+	_, err = s.CreateInvite(ctx, req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = s.CreateInvite(withToken(t, ctx, models.User{Id: memberId, Email: "member@gmail.com"}), req)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func newTestServer(t *testing.T) (*ServerAPI, *memory.Storage) {
+	t.Helper()
+	st := memory.New()
+	require.NoError(t, st.SaveApp(context.Background(), testApp))
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	service := invite.New(logger, st, st, st, st, st, st, time.Hour)
+	return &ServerAPI{invite: service, authn: authn.New(st, st)}, st
+}
+
+func withToken(t *testing.T, ctx context.Context, user models.User) context.Context {
+	t.Helper()
+	token, err := jwt.NewToken(user, testApp, time.Hour)
+	require.NoError(t, err)
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(authn.MetadataKey, "Bearer "+token))
+}
@@ -0,0 +1,191 @@
+package passkey
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	passkeyv1 "sso/gen/sso/passkey/v1"
+	"sso/interanal/domain/models"
+	"sso/interanal/grpc/authn"
+	"sso/interanal/service/passkey"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const emptyAppId = 0
+
+type Passkey interface {
+	BeginRegistration(ctx context.Context, orgId int64, userId int64) (sessionToken string, options []byte, err error)
+	FinishRegistration(ctx context.Context, orgId int64, sessionToken string, response []byte) (credentialId []byte, err error)
+	BeginLogin(ctx context.Context, email string, appId int) (sessionToken string, options []byte, err error)
+	FinishLogin(ctx context.Context, sessionToken string, response []byte) (token string, err error)
+	ListCredentials(ctx context.Context, orgId int64, userId int64) ([]models.WebAuthnCredential, error)
+	RemoveCredential(ctx context.Context, orgId int64, userId int64, credentialId []byte) error
+}
+
+type ServerAPI struct {
+	passkeyv1.UnimplementedPasskeyServiceServer
+	passkey Passkey
+	authn   *authn.Authenticator
+}
+
+func RegisterServerAPI(grpcServer *grpc.Server, passkey Passkey, authenticator *authn.Authenticator) {
+	passkeyv1.RegisterPasskeyServiceServer(grpcServer, &ServerAPI{passkey: passkey, authn: authenticator})
+}
+
+// BeginRegistration registers a passkey for the caller, so a user can
+// only add passkeys to their own account.
+func (s *ServerAPI) BeginRegistration(
+	ctx context.Context,
+	req *passkeyv1.BeginRegistrationRequest,
+) (*passkeyv1.BeginRegistrationResponse, error) {
+	caller, err := s.authn.Authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sessionToken, options, err := s.passkey.BeginRegistration(ctx, caller.OrgId, caller.UserId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &passkeyv1.BeginRegistrationResponse{
+		SessionToken: sessionToken,
+		OptionsJson:  string(options),
+	}, nil
+}
+
+func (s *ServerAPI) FinishRegistration(
+	ctx context.Context,
+	req *passkeyv1.FinishRegistrationRequest,
+) (*passkeyv1.FinishRegistrationResponse, error) {
+	if err := validateCeremony(req.GetSessionToken(), req.GetResponseJson()); err != nil {
+		return nil, err
+	}
+	caller, err := s.authn.Authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	credentialId, err := s.passkey.FinishRegistration(ctx, caller.OrgId, req.GetSessionToken(), []byte(req.GetResponseJson()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &passkeyv1.FinishRegistrationResponse{
+		CredentialId: credentialId,
+	}, nil
+}
+
+func (s *ServerAPI) BeginLogin(ctx context.Context, req *passkeyv1.BeginLoginRequest) (*passkeyv1.BeginLoginResponse, error) {
+	if _, err := mail.ParseAddress(req.GetEmail()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "email is invalid")
+	}
+	if req.GetAppId() == emptyAppId {
+		return nil, status.Error(codes.InvalidArgument, "app id is required")
+	}
+	sessionToken, options, err := s.passkey.BeginLogin(ctx, req.GetEmail(), int(req.GetAppId()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &passkeyv1.BeginLoginResponse{
+		SessionToken: sessionToken,
+		OptionsJson:  string(options),
+	}, nil
+}
+
+func (s *ServerAPI) FinishLogin(ctx context.Context, req *passkeyv1.FinishLoginRequest) (*passkeyv1.FinishLoginResponse, error) {
+	if err := validateCeremony(req.GetSessionToken(), req.GetResponseJson()); err != nil {
+		return nil, err
+	}
+	token, err := s.passkey.FinishLogin(ctx, req.GetSessionToken(), []byte(req.GetResponseJson()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &passkeyv1.FinishLoginResponse{
+		Token: token,
+	}, nil
+}
+
+func (s *ServerAPI) ListCredentials(
+	ctx context.Context,
+	req *passkeyv1.ListCredentialsRequest,
+) (*passkeyv1.ListCredentialsResponse, error) {
+	caller, err := s.authn.Authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := s.passkey.ListCredentials(ctx, caller.OrgId, caller.UserId)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &passkeyv1.ListCredentialsResponse{}
+	for _, cred := range creds {
+		resp.Credentials = append(resp.Credentials, &passkeyv1.Credential{
+			Id:             cred.Id,
+			Transports:     cred.Transports,
+			BackupEligible: cred.BackupEligible,
+			BackupState:    cred.BackupState,
+			CreatedAt:      timestamp(cred.CreatedAt),
+			LastUsedAt:     timestamp(cred.LastUsedAt),
+		})
+	}
+	return resp, nil
+}
+
+func (s *ServerAPI) RemoveCredential(
+	ctx context.Context,
+	req *passkeyv1.RemoveCredentialRequest,
+) (*passkeyv1.RemoveCredentialResponse, error) {
+	if len(req.GetCredentialId()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "credential id is required")
+	}
+	caller, err := s.authn.Authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.passkey.RemoveCredential(ctx, caller.OrgId, caller.UserId, req.GetCredentialId()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &passkeyv1.RemoveCredentialResponse{}, nil
+}
+
+func validateCeremony(sessionToken string, response string) error {
+	if sessionToken == "" {
+		return status.Error(codes.InvalidArgument, "session token is required")
+	}
+	if response == "" {
+		return status.Error(codes.InvalidArgument, "response is required")
+	}
+	return nil
+}
+
+// timestamp leaves a zero time unset, e.g. a passkey that was never used.
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, passkey.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, passkey.ErrAppNotFound):
+		return status.Error(codes.NotFound, "app not found")
+	case errors.Is(err, passkey.ErrNoCredentials):
+		return status.Error(codes.NotFound, "user has no passkeys")
+	case errors.Is(err, passkey.ErrCredentialNotFound):
+		return status.Error(codes.NotFound, "credential not found")
+	case errors.Is(err, passkey.ErrCredentialExists):
+		return status.Error(codes.AlreadyExists, "credential already registered")
+	case errors.Is(err, passkey.ErrInvalidSession):
+		return status.Error(codes.InvalidArgument, "session is invalid or expired")
+	case errors.Is(err, passkey.ErrInvalidResponse):
+		return status.Error(codes.InvalidArgument, "invalid authenticator response")
+	case errors.Is(err, passkey.ErrCloneDetected):
+		return status.Error(codes.PermissionDenied, "authenticator sign counter went backwards")
+	}
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,122 @@
+package passkey
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	passkeyv1 "sso/gen/sso/passkey/v1"
+	"sso/interanal/domain/models"
+	"sso/interanal/grpc/authn"
+	"sso/interanal/service/passkey"
+	"sso/interanal/storage/memory"
+	"sso/lib/jwt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var testApp = models.App{Id: 1, OrgId: models.DefaultOrgId, Name: "test", Secret: "test-secret"}
+
+// TestRegistrationNeedsToken проверяет, что регистрация ключа
+// и управление ключами требуют токен пользователя.
+func TestRegistrationNeedsToken(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestServer(t)
+
+	_, err := s.BeginRegistration(ctx, &passkeyv1.BeginRegistrationRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = s.FinishRegistration(ctx, &passkeyv1.FinishRegistrationRequest{SessionToken: "session", ResponseJson: "{}"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = s.ListCredentials(ctx, &passkeyv1.ListCredentialsRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = s.RemoveCredential(ctx, &passkeyv1.RemoveCredentialRequest{CredentialId: []byte("id")})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+// TestBeginRegistration проверяет, что опции регистрации
+// выдаются для пользователя из токена.
+func TestBeginRegistration(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t)
+	user := saveUser(t, st, "user@gmail.com")
+
+	resp, err := s.BeginRegistration(withToken(t, ctx, user), &passkeyv1.BeginRegistrationRequest{})
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, resp.GetSessionToken())
+	var options struct {
+		PublicKey struct {
+			User struct {
+				Name string `json:"name"`
+			} `json:"user"`
+		} `json:"publicKey"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(resp.GetOptionsJson()), &options))
+	assert.Equal(t, user.Email, options.PublicKey.User.Name)
+}
+
+// TestPasskeyErrors проверяет коды ошибок церемоний и управления ключами.
+func TestPasskeyErrors(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestServer(t)
+	user := saveUser(t, st, "user@gmail.com")
+	userCtx := withToken(t, ctx, user)
+	begin, err := s.BeginRegistration(userCtx, &passkeyv1.BeginRegistrationRequest{})
+	require.NoError(t, err)
+
+	_, err = s.FinishRegistration(userCtx, &passkeyv1.FinishRegistrationRequest{SessionToken: begin.GetSessionToken()})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = s.FinishRegistration(userCtx, &passkeyv1.FinishRegistrationRequest{SessionToken: begin.GetSessionToken(), ResponseJson: "{}"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = s.FinishLogin(ctx, &passkeyv1.FinishLoginRequest{SessionToken: "unknown", ResponseJson: "{}"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = s.BeginLogin(ctx, &passkeyv1.BeginLoginRequest{Email: user.Email, AppId: int32(testApp.Id)})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = s.BeginLogin(ctx, &passkeyv1.BeginLoginRequest{Email: user.Email})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = s.RemoveCredential(userCtx, &passkeyv1.RemoveCredentialRequest{CredentialId: []byte("id")})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	list, err := s.ListCredentials(userCtx, &passkeyv1.ListCredentialsRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, list.GetCredentials())
+}
+
+func newTestServer(t *testing.T) (*ServerAPI, *memory.Storage) {
+	t.Helper()
+	st := memory.New()
+	require.NoError(t, st.SaveApp(context.Background(), testApp))
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	service, err := passkey.New(logger, st, st, st, st, passkey.Options{
+		RPID:          "localhost",
+		RPDisplayName: "SSO",
+		RPOrigins:     []string{"http://localhost"},
+		SessionTTL:    time.Minute,
+		TokenTTL:      time.Hour,
+	})
+	require.NoError(t, err)
+	return &ServerAPI{passkey: service, authn: authn.New(st, st)}, st
+}
+
+func saveUser(t *testing.T, st *memory.Storage, email string) models.User {
+	t.Helper()
+	ctx := context.Background()
+	_, err := st.SaveUser(ctx, models.DefaultOrgId, email, []byte("hash"))
+	require.NoError(t, err)
+	user, err := st.GetUser(ctx, models.DefaultOrgId, email)
+	require.NoError(t, err)
+	return user
+}
+
+func withToken(t *testing.T, ctx context.Context, user models.User) context.Context {
+	t.Helper()
+	token, err := jwt.NewToken(user, testApp, time.Hour)
+	require.NoError(t, err)
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(authn.MetadataKey, "Bearer "+token))
+}
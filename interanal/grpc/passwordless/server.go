@@ -0,0 +1,91 @@
+package passwordless
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	passwordlessv1 "sso/gen/sso/passwordless/v1"
+	"sso/interanal/delivery"
+	"sso/interanal/service/passwordless"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const emptyAppId = 0
+
+type Passwordless interface {
+	StartPasswordlessLogin(ctx context.Context, email string, appId int) error
+	CompletePasswordlessLogin(ctx context.Context, email string, appId int, secret string) (token string, err error)
+}
+
+type ServerAPI struct {
+	passwordlessv1.UnimplementedPasswordlessServiceServer
+	passwordless Passwordless
+}
+
+func RegisterServerAPI(grpcServer *grpc.Server, passwordless Passwordless) {
+	passwordlessv1.RegisterPasswordlessServiceServer(grpcServer, &ServerAPI{passwordless: passwordless})
+}
+
+func (s *ServerAPI) StartPasswordlessLogin(
+	ctx context.Context,
+	req *passwordlessv1.StartPasswordlessLoginRequest,
+) (*passwordlessv1.StartPasswordlessLoginResponse, error) {
+	if err := validateEmailAndApp(req.GetEmail(), req.GetAppId()); err != nil {
+		return nil, err
+	}
+	err := s.passwordless.StartPasswordlessLogin(ctx, req.GetEmail(), int(req.GetAppId()))
+	if err != nil {
+		if errors.Is(err, passwordless.ErrAppNotFound) {
+			return nil, status.Error(codes.NotFound, "app not found")
+		}
+		if errors.Is(err, delivery.ErrDisabled) {
+			return nil, status.Error(codes.FailedPrecondition, "passwordless login is not configured")
+		}
+		if errors.Is(err, passwordless.ErrDeliveryFailed) {
+			return nil, status.Error(codes.Unavailable, "failed to deliver code")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return &passwordlessv1.StartPasswordlessLoginResponse{}, nil
+}
+
+func (s *ServerAPI) CompletePasswordlessLogin(
+	ctx context.Context,
+	req *passwordlessv1.CompletePasswordlessLoginRequest,
+) (*passwordlessv1.CompletePasswordlessLoginResponse, error) {
+	if err := validateEmailAndApp(req.GetEmail(), req.GetAppId()); err != nil {
+		return nil, err
+	}
+	if req.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+	token, err := s.passwordless.CompletePasswordlessLogin(ctx, req.GetEmail(), int(req.GetAppId()), req.GetCode())
+	if err != nil {
+		if errors.Is(err, passwordless.ErrAppNotFound) {
+			return nil, status.Error(codes.NotFound, "app not found")
+		}
+		if errors.Is(err, passwordless.ErrInvalidCode) {
+			return nil, status.Error(codes.InvalidArgument, "invalid code")
+		}
+		if errors.Is(err, passwordless.ErrTooManyAttempts) {
+			return nil, status.Error(codes.ResourceExhausted, "too many attempts, request a new code")
+		}
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	return &passwordlessv1.CompletePasswordlessLoginResponse{
+		Token: token,
+	}, nil
+}
+
+func validateEmailAndApp(email string, appId int32) error {
+	if _, err := mail.ParseAddress(email); err != nil {
+		return status.Error(codes.InvalidArgument, "email is invalid")
+	}
+	if appId == emptyAppId {
+		return status.Error(codes.InvalidArgument, "app id is required")
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package passwordless
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	passwordlessv1 "sso/gen/sso/passwordless/v1"
+	"sso/interanal/delivery"
+	"sso/interanal/domain/models"
+	"sso/interanal/service/passwordless"
+	"sso/interanal/storage/memory"
+	"sso/lib/jwt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var testApp = models.App{Id: 1, OrgId: models.DefaultOrgId, Name: "test", Secret: "test-secret"}
+
+// TestPasswordlessFlow проверяет, что код, отправленный
+// StartPasswordlessLogin, обменивается на токен приложения.
+func TestPasswordlessFlow(t *testing.T) {
+	ctx := context.Background()
+	sender := &fakeSender{}
+	s := newTestServer(t, sender)
+
+	_, err := s.StartPasswordlessLogin(ctx, &passwordlessv1.StartPasswordlessLoginRequest{Email: "user@gmail.com", AppId: int32(testApp.Id)})
+	require.NoError(t, err)
+	resp, err := s.CompletePasswordlessLogin(ctx, &passwordlessv1.CompletePasswordlessLoginRequest{
+		Email: "user@gmail.com",
+		AppId: int32(testApp.Id),
+		Code:  sender.secret,
+	})
+	require.NoError(t, err)
+
+	claims, err := jwt.Verify(resp.GetToken(), testApp.Secret)
+	require.NoError(t, err)
+	assert.Equal(t, "user@gmail.com", claims.Email)
+}
+
+// TestPasswordlessErrors проверяет коды ошибок беспарольного входа.
+func TestPasswordlessErrors(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t, &fakeSender{})
+
+	_, err := s.StartPasswordlessLogin(ctx, &passwordlessv1.StartPasswordlessLoginRequest{Email: "user", AppId: int32(testApp.Id)})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = s.StartPasswordlessLogin(ctx, &passwordlessv1.StartPasswordlessLoginRequest{Email: "user@gmail.com", AppId: 42})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = s.CompletePasswordlessLogin(ctx, &passwordlessv1.CompletePasswordlessLoginRequest{Email: "user@gmail.com", AppId: int32(testApp.Id)})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = s.CompletePasswordlessLogin(ctx, &passwordlessv1.CompletePasswordlessLoginRequest{
+		Email: "user@gmail.com",
+		AppId: int32(testApp.Id),
+		Code:  "000000",
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+// TestDeliveryDisabled проверяет, что при выключенной доставке
+// StartPasswordlessLogin возвращает FailedPrecondition.
+func TestDeliveryDisabled(t *testing.T) {
+	s := newTestServer(t, delivery.NoneSender{})
+
+	_, err := s.StartPasswordlessLogin(context.Background(), &passwordlessv1.StartPasswordlessLoginRequest{Email: "user@gmail.com", AppId: int32(testApp.Id)})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func newTestServer(t *testing.T, sender passwordless.Sender) *ServerAPI {
+	t.Helper()
+	ctx := context.Background()
+	st := memory.New()
+	require.NoError(t, st.SaveApp(ctx, testApp))
+	_, err := st.SaveUser(ctx, models.DefaultOrgId, "user@gmail.com", nil)
+	require.NoError(t, err)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	service := passwordless.New(logger, st, st, st, st, sender, passwordless.Options{
+		Mode:             passwordless.ModeCode,
+		CodeLength:       6,
+		CodeTTL:          time.Minute,
+		MaxAttempts:      3,
+		TokenTTL:         time.Hour,
+		OpenRegistration: true,
+	})
+	return &ServerAPI{passwordless: service}
+}
+
+type fakeSender struct {
+	mu     sync.Mutex
+	secret string
+}
+
+func (f *fakeSender) SendLoginCode(ctx context.Context, email string, appName string, secret string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.secret = secret
+	return nil
+}
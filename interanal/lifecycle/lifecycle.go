@@ -0,0 +1,107 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Hook is one component of the application. Run blocks while the
+// component serves and may be nil for components that only need to be
+// stopped, such as the database pool. Stop must return once ctx is done.
+type Hook struct {
+	Name string
+	Run  func() error
+	Stop func(ctx context.Context) error
+}
+
+// Manager starts hooks in the order they were appended and stops them
+// in reverse order, so servers are drained before the storage closes.
+type Manager struct {
+	logger          *slog.Logger
+	hooks           []Hook
+	shutdownTimeout time.Duration
+}
+
+func New(logger *slog.Logger, shutdownTimeout time.Duration) *Manager {
+	return &Manager{logger: logger, shutdownTimeout: shutdownTimeout}
+}
+
+func (m *Manager) Append(hook Hook) {
+	m.hooks = append(m.hooks, hook)
+}
+
+// Run starts every hook and waits until ctx is canceled or a hook fails.
+// Then it stops all hooks within the shutdown timeout. The returned
+// error joins the run error, if any, with the stop errors.
+func (m *Manager) Run(ctx context.Context) error {
+	const op = "lifecycle.Run"
+	logger := m.logger.With(slog.String("op", op))
+	runErrs := make(chan error, len(m.hooks))
+	for _, hook := range m.hooks {
+		if hook.Run == nil {
+			continue
+		}
+		logger.Info("starting", slog.String("hook", hook.Name))
+		go func() {
+			if err := hook.Run(); err != nil {
+				runErrs <- fmt.Errorf("%s: %w", hook.Name, err)
+				return
+			}
+			runErrs <- nil
+		}()
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		logger.Info("shutdown requested")
+	case runErr = <-runErrs:
+		if runErr != nil {
+			logger.Error("hook failed", slog.String("err", runErr.Error()))
+		} else {
+			logger.Warn("hook stopped serving on its own")
+		}
+	}
+	return errors.Join(runErr, m.stop())
+}
+
+// stop stops every hook within one shutdownTimeout. Half of it is split
+// equally between the hooks as a floor that each of them keeps, the
+// other half goes to whichever hook needs it first. So the server
+// drains for most of the timeout, while the storage and tracing still
+// have time to close and flush.
+func (m *Manager) stop() error {
+	const op = "lifecycle.stop"
+	logger := m.logger.With(slog.String("op", op))
+	var stoppers []Hook
+	for i := len(m.hooks) - 1; i >= 0; i-- {
+		if m.hooks[i].Stop != nil {
+			stoppers = append(stoppers, m.hooks[i])
+		}
+	}
+	if len(stoppers) == 0 {
+		return nil
+	}
+	deadline := time.Now().Add(m.shutdownTimeout)
+	floor := m.shutdownTimeout / time.Duration(2*len(stoppers))
+	var errs []error
+	for i, hook := range stoppers {
+		reserved := floor * time.Duration(len(stoppers)-i-1)
+		timeout := time.Until(deadline) - reserved
+		logger.Info("stopping", slog.String("hook", hook.Name), slog.Duration("timeout", timeout))
+		if err := stopHook(hook, timeout); err != nil {
+			logger.Error("failed to stop", slog.String("hook", hook.Name), slog.String("err", err.Error()))
+			errs = append(errs, fmt.Errorf("%s: %w", hook.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func stopHook(hook Hook, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return hook.Stop(ctx)
+}
@@ -0,0 +1,148 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHooksStopInReverseOrder проверяет, что компоненты
+// останавливаются в обратном порядке после отмены ctx.
+func TestHooksStopInReverseOrder(t *testing.T) {
+	m := New(newLogger(), time.Second)
+	rec := &recorder{}
+	m.Append(Hook{Name: "storage", Stop: rec.stop("storage", nil)})
+	srv := newBlockingServer()
+	m.Append(Hook{Name: "grpc", Run: srv.run, Stop: func(ctx context.Context) error {
+		srv.stop()
+		return rec.stop("grpc", nil)(ctx)
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := m.Run(ctx)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"grpc", "storage"}, rec.stopped)
+}
+
+// TestRunErrorStopsApplication проверяет, что ошибка
+// сервера приводит к остановке и возвращается из Run.
+func TestRunErrorStopsApplication(t *testing.T) {
+	m := New(newLogger(), time.Second)
+	rec := &recorder{}
+	errListen := errors.New("address already in use")
+	m.Append(Hook{Name: "storage", Stop: rec.stop("storage", nil)})
+	m.Append(Hook{Name: "grpc", Run: func() error { return errListen }})
+
+	err := m.Run(context.Background())
+
+	assert.ErrorIs(t, err, errListen)
+	assert.Equal(t, []string{"storage"}, rec.stopped)
+}
+
+// TestStopErrorsAreReturned проверяет, что ошибки
+// остановки не теряются, а остальные компоненты все
+// равно останавливаются.
+func TestStopErrorsAreReturned(t *testing.T) {
+	m := New(newLogger(), time.Second)
+	rec := &recorder{}
+	m.Append(Hook{Name: "storage", Stop: rec.stop("storage", nil)})
+	m.Append(Hook{Name: "grpc", Stop: rec.stop("grpc", context.DeadlineExceeded)})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := m.Run(ctx)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []string{"grpc", "storage"}, rec.stopped)
+}
+
+// TestStopHasDeadline проверяет, что Stop получает
+// контекст с дедлайном остановки.
+func TestStopHasDeadline(t *testing.T) {
+	m := New(newLogger(), 10*time.Millisecond)
+	m.Append(Hook{Name: "slow", Stop: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	err := m.Run(ctx)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+// TestStopSharesOneDeadline проверяет, что остановка
+// укладывается в один таймаут: первый компонент получает
+// большую часть, но следующим остается их доля.
+func TestStopSharesOneDeadline(t *testing.T) {
+	const timeout = 120 * time.Millisecond
+	m := New(newLogger(), timeout)
+	budgets := make(map[string]time.Duration)
+	for _, name := range []string{"storage", "tracing", "grpc"} {
+		m.Append(Hook{Name: name, Stop: func(ctx context.Context) error {
+			deadline, _ := ctx.Deadline()
+			budgets[name] = time.Until(deadline)
+			<-ctx.Done()
+			return ctx.Err()
+		}})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	err := m.Run(ctx)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 2*timeout)
+	assert.Greater(t, budgets["grpc"], timeout/2)
+	assert.Greater(t, budgets["tracing"], timeout/12)
+	assert.Greater(t, budgets["storage"], timeout/12)
+}
+
+func newLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+type recorder struct {
+	mu      sync.Mutex
+	stopped []string
+}
+
+func (r *recorder) stop(name string, err error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.stopped = append(r.stopped, name)
+		return err
+	}
+}
+
+type blockingServer struct {
+	done chan struct{}
+	once sync.Once
+}
+
+func newBlockingServer() *blockingServer {
+	return &blockingServer{done: make(chan struct{})}
+}
+
+func (s *blockingServer) run() error {
+	<-s.done
+	return nil
+}
+
+func (s *blockingServer) stop() {
+	s.once.Do(func() { close(s.done) })
+}
@@ -0,0 +1,96 @@
+// Package logging builds the service logger and attaches the request
+// correlation ID from the context to every record.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+const (
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+)
+
+// emailKey is the attribute key whose values are masked.
+const emailKey = "email"
+
+type Options struct {
+	Level  slog.Leveler
+	Format string
+	Output io.Writer
+}
+
+// New returns a logger that adds request_id to records logged with
+// a context from the interceptor and masks "email" attributes.
+func New(opts Options) *slog.Logger {
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level, ReplaceAttr: maskEmail}
+	var h slog.Handler
+	if opts.Format == FormatText {
+		h = slog.NewTextHandler(opts.Output, handlerOpts)
+	} else {
+		h = slog.NewJSONHandler(opts.Output, handlerOpts)
+	}
+	return slog.New(contextHandler{h})
+}
+
+// Open returns the writer for output: stdout, stderr or a file that
+// is appended to. close is a no-op for the standard streams.
+func Open(output string) (w io.Writer, close func() error, err error) {
+	switch output {
+	case OutputStdout, "":
+		return os.Stdout, func() error { return nil }, nil
+	case OutputStderr:
+		return os.Stderr, func() error { return nil }, nil
+	}
+	f, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, nil, fmt.Errorf("logging.Open: %w", err)
+	}
+	return f, f.Close, nil
+}
+
+// MaskEmail keeps the first letter of the local part and the domain:
+// "john@example.com" becomes "j***@example.com".
+func MaskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		return "***"
+	}
+	return local[:1] + "***@" + domain
+}
+
+func maskEmail(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == emailKey && a.Value.Kind() == slog.KindString {
+		return slog.String(a.Key, MaskEmail(a.Value.String()))
+	}
+	return a
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String(requestIDKey, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
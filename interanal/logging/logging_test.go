@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// TestRecordHasRequestID проверяет, что ID запроса из
+// контекста попадает в каждую запись, в том числе через With.
+func TestRecordHasRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Options{Level: slog.LevelInfo, Format: FormatJSON, Output: &buf}).With(slog.String("op", "test"))
+
+	logger.InfoContext(WithRequestID(context.Background(), "req-1"), "hello")
+
+	record := decode(t, &buf)
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Equal(t, "test", record["op"])
+}
+
+// TestEmailIsMasked проверяет, что email не попадает
+// в лог целиком.
+func TestEmailIsMasked(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Options{Level: slog.LevelInfo, Format: FormatJSON, Output: &buf})
+
+	logger.Info("code sent", slog.String("email", "john@example.com"))
+
+	assert.Equal(t, "j***@example.com", decode(t, &buf)["email"])
+	assert.Equal(t, "***", MaskEmail("not-an-email"))
+}
+
+// TestInterceptorKeepsIncomingRequestID проверяет, что ID
+// из метаданных клиента передается в контекст и в заголовок ответа.
+func TestInterceptorKeepsIncomingRequestID(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDMetadataKey, "client-id-1"))
+
+	id, header := intercept(t, ctx)
+
+	assert.Equal(t, "client-id-1", id)
+	assert.Equal(t, []string{"client-id-1"}, header.Get(RequestIDMetadataKey))
+}
+
+// TestInterceptorGeneratesRequestID проверяет, что без ID
+// или с некорректным ID генерируется новый.
+func TestInterceptorGeneratesRequestID(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDMetadataKey, "bad id\nlevel=ERROR"))
+
+	id, header := intercept(t, ctx)
+
+	assert.Len(t, id, 32)
+	assert.Equal(t, []string{id}, header.Get(RequestIDMetadataKey))
+
+	other, _ := intercept(t, context.Background())
+	assert.NotEqual(t, id, other)
+}
+
+func intercept(t *testing.T, ctx context.Context) (string, metadata.MD) {
+	t.Helper()
+	stream := &fakeStream{}
+	ctx = grpc.NewContextWithServerTransportStream(ctx, stream)
+	var id string
+	_, err := UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test/Method"},
+		func(ctx context.Context, req any) (any, error) {
+			id = RequestID(ctx)
+			return nil, nil
+		})
+	require.NoError(t, err)
+	return id, stream.header
+}
+
+func decode(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	return record
+}
+
+type fakeStream struct {
+	header metadata.MD
+}
+
+func (s *fakeStream) Method() string { return "/test/Method" }
+
+func (s *fakeStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *fakeStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+
+func (s *fakeStream) SetTrailer(md metadata.MD) error { return nil }
@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDMetadataKey is read from incoming metadata and echoed in
+// the response header.
+const RequestIDMetadataKey = "x-request-id"
+
+const (
+	requestIDKey       = "request_id"
+	maxRequestIDLength = 128
+)
+
+type requestIDCtxKey struct{}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey{}, id)
+}
+
+// RequestID returns the correlation ID of the request or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDCtxKey{}).(string)
+	return id
+}
+
+// UnaryServerInterceptor takes the correlation ID from the incoming
+// metadata or generates one, puts it into the context and sends it
+// back in the response header.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		id := incomingRequestID(ctx)
+		if id == "" {
+			id = newRequestID()
+		}
+		// The header is best effort, a failed send must not fail the call.
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadataKey, id))
+		return handler(WithRequestID(ctx, id), req)
+	}
+}
+
+func incomingRequestID(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get(RequestIDMetadataKey)
+	if len(values) == 0 || !validRequestID(values[0]) {
+		return ""
+	}
+	return values[0]
+}
+
+// validRequestID keeps client-provided IDs short and free of
+// characters that could forge log fields.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		ok := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.'
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	// crypto/rand.Read never returns an error on supported platforms.
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
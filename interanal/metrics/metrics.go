@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const namespace = "sso"
+
+// Login failure reasons.
+const (
+	LoginAppNotFound     = "app_not_found"
+	LoginUserNotFound    = "user_not_found"
+	LoginInvalidPassword = "invalid_password"
+	LoginInternal        = "internal"
+)
+
+// Registration results.
+const (
+	RegistrationSuccess     = "success"
+	RegistrationUserExists  = "user_exists"
+	RegistrationOrgNotFound = "org_not_found"
+	RegistrationClosed      = "closed"
+	RegistrationInternal    = "internal"
+)
+
+// Bcrypt operations.
+const (
+	BcryptHash    = "hash"
+	BcryptCompare = "compare"
+)
+
+// Caches and lookup results.
+const (
+	CacheApp  = "app"
+	CacheUser = "user"
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+var (
+	grpcRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_requests_total",
+		Help:      "Number of handled gRPC requests.",
+	}, []string{"method", "code"})
+	grpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_request_duration_seconds",
+		Help:      "Duration of handled gRPC requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+	logins = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_success_total",
+		Help:      "Number of successful logins.",
+	})
+	loginFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_failures_total",
+		Help:      "Number of failed logins by reason.",
+	}, []string{"reason"})
+	registrations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "Number of registration attempts by result.",
+	}, []string{"result"})
+	bcryptDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "bcrypt_duration_seconds",
+		Help:      "Duration of bcrypt hashing and comparison.",
+		// bcrypt with the default cost takes tens of milliseconds.
+		Buckets: []float64{.01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"op"})
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Number of storage cache lookups by cache and result.",
+	}, []string{"cache", "result"})
+)
+
+// NewRegistry returns a registry with only the collectors of this
+// service, so the metrics endpoint does not depend on the global
+// default registry. Each app gets its own registry, which lets several
+// apps live in one process, while the counters are shared by all of them.
+func NewRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		grpcRequests,
+		grpcDuration,
+		logins,
+		loginFailures,
+		registrations,
+		bcryptDuration,
+		cacheLookups,
+	)
+	return registry
+}
+
+// UnaryServerInterceptor counts requests and measures their
+// duration per method and gRPC status code.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		code := status.Code(err).String()
+		grpcRequests.WithLabelValues(info.FullMethod, code).Inc()
+		grpcDuration.WithLabelValues(info.FullMethod, code).Observe(time.Since(start).Seconds())
+		return resp, err
+	}
+}
+
+func LoginSucceeded() {
+	logins.Inc()
+}
+
+func LoginFailed(reason string) {
+	loginFailures.WithLabelValues(reason).Inc()
+}
+
+func Registration(result string) {
+	registrations.WithLabelValues(result).Inc()
+}
+
+func ObserveBcrypt(op string, start time.Time) {
+	bcryptDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+}
+
+func CacheLookup(cache string, result string) {
+	cacheLookups.WithLabelValues(cache, result).Inc()
+}
+
+type PoolStater interface {
+	Stat() *pgxpool.Stat
+}
+
+// RegisterPool exposes the pgxpool statistics of pool in registry.
+func RegisterPool(registry *prometheus.Registry, pool PoolStater) error {
+	return registry.Register(newPoolCollector(pool))
+}
+
+type poolCollector struct {
+	pool                 PoolStater
+	acquiredConns        *prometheus.Desc
+	idleConns            *prometheus.Desc
+	totalConns           *prometheus.Desc
+	maxConns             *prometheus.Desc
+	acquireCount         *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	emptyAcquireCount    *prometheus.Desc
+	canceledAcquireCount *prometheus.Desc
+}
+
+func newPoolCollector(pool PoolStater) *poolCollector {
+	desc := func(name string, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+	return &poolCollector{
+		pool:                 pool,
+		acquiredConns:        desc("acquired_conns", "Number of currently acquired connections."),
+		idleConns:            desc("idle_conns", "Number of currently idle connections."),
+		totalConns:           desc("total_conns", "Total number of connections in the pool."),
+		maxConns:             desc("max_conns", "Maximum size of the pool."),
+		acquireCount:         desc("acquire_total", "Number of successful acquires."),
+		acquireDuration:      desc("acquire_duration_seconds_total", "Total time spent on successful acquires."),
+		emptyAcquireCount:    desc("empty_acquire_total", "Number of acquires that waited for a connection."),
+		canceledAcquireCount: desc("canceled_acquire_total", "Number of acquires canceled by context."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquiredConns
+	ch <- c.idleConns
+	ch <- c.totalConns
+	ch <- c.maxConns
+	ch <- c.acquireCount
+	ch <- c.acquireDuration
+	ch <- c.emptyAcquireCount
+	ch <- c.canceledAcquireCount
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.canceledAcquireCount, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+}
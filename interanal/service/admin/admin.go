@@ -0,0 +1,319 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/interanal/domain/models"
+	"sso/interanal/storage"
+	"sso/lib/secure"
+	"strings"
+	"time"
+)
+
+// secretBytes is the entropy of generated app secrets, the same as of
+// the ones ssoctl bootstrap generates.
+const secretBytes = 32
+
+var (
+	ErrInvalidApp   = errors.New("app id and name are required")
+	ErrInvalidRole  = errors.New("invalid role")
+	ErrAppNotFound  = errors.New("app not found")
+	ErrAppExists    = errors.New("app already exists")
+	ErrOrgNotFound  = errors.New("organization not found")
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserExists   = errors.New("user already exists")
+)
+
+// AdminService manages apps, members and sessions on behalf of an
+// operator. It doesn't check who the operator is, the transport does.
+type AdminService struct {
+	logger         *slog.Logger
+	transactor     Transactor
+	orgProvider    OrgProvider
+	appStorage     AppStorage
+	userSaver      UserSaver
+	memberStorage  MemberStorage
+	sessionRevoker SessionRevoker
+}
+
+// Transactor runs fn in a storage transaction that the storage
+// methods called with the ctx of fn join.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type OrgProvider interface {
+	GetOrganization(ctx context.Context, orgId int64) (models.Organization, error)
+}
+
+type AppStorage interface {
+	GetApp(ctx context.Context, appId int) (models.App, error)
+	ListApps(ctx context.Context, orgId int64) ([]models.App, error)
+	SaveApp(ctx context.Context, app models.App) error
+	UpdateApp(ctx context.Context, app models.App) error
+	SetAppSecret(ctx context.Context, appId int, secret string) error
+}
+
+type UserSaver interface {
+	SaveUser(
+		ctx context.Context,
+		orgId int64,
+		email string,
+		passwordHash []byte,
+	) (userId int64, err error)
+}
+
+type MemberStorage interface {
+	ListMembers(ctx context.Context, orgId int64) ([]models.Member, error)
+	SetMemberRole(ctx context.Context, orgId int64, userId int64, role string) error
+}
+
+type SessionRevoker interface {
+	RevokeSessions(ctx context.Context, orgId int64, userId int64, revokedAt time.Time) error
+}
+
+func New(
+	logger *slog.Logger,
+	transactor Transactor,
+	orgProvider OrgProvider,
+	appStorage AppStorage,
+	userSaver UserSaver,
+	memberStorage MemberStorage,
+	sessionRevoker SessionRevoker,
+) *AdminService {
+	return &AdminService{
+		logger:         logger,
+		transactor:     transactor,
+		orgProvider:    orgProvider,
+		appStorage:     appStorage,
+		userSaver:      userSaver,
+		memberStorage:  memberStorage,
+		sessionRevoker: sessionRevoker,
+	}
+}
+
+// ListApps returns the apps of an organization without their secrets.
+// Unlike the storage, it tells an unknown organization from one
+// without apps.
+func (s *AdminService) ListApps(ctx context.Context, orgId int64) ([]models.App, error) {
+	const op = "service.admin.ListApps"
+	logger := s.logger.With(slog.String("op", op), slog.Int64("org_id", orgId))
+
+	if err := s.checkOrg(ctx, orgId); err != nil {
+		logger.WarnContext(ctx, "failed to check organization", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	apps, err := s.appStorage.ListApps(ctx, orgId)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to list apps", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return apps, nil
+}
+
+// CreateApp saves the app. When app.Secret is empty a secret is
+// generated and returned, otherwise the returned secret is empty.
+func (s *AdminService) CreateApp(ctx context.Context, app models.App) (string, error) {
+	const op = "service.admin.CreateApp"
+	logger := s.logger.With(slog.String("op", op), slog.Int("app_id", app.Id), slog.Int64("org_id", app.OrgId))
+	logger.InfoContext(ctx, "create app")
+
+	app.Name = strings.TrimSpace(app.Name)
+	if app.Id <= 0 || app.Name == "" {
+		logger.WarnContext(ctx, "invalid app")
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidApp)
+	}
+	var generated string
+	if app.Secret == "" {
+		var err error
+		generated, err = secure.RandomToken(secretBytes)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to generate secret", slog.String("err", err.Error()))
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+		app.Secret = generated
+	}
+	if err := s.appStorage.SaveApp(ctx, app); err != nil {
+		logger.WarnContext(ctx, "failed to save app", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, appError(err))
+	}
+	logger.InfoContext(ctx, "app created")
+	return generated, nil
+}
+
+// UpdateApp replaces the name and the auto provisioning of an app. The
+// organization and the secret stay.
+func (s *AdminService) UpdateApp(
+	ctx context.Context,
+	appId int,
+	name string,
+	allowAutoProvision bool,
+) (models.App, error) {
+	const op = "service.admin.UpdateApp"
+	logger := s.logger.With(slog.String("op", op), slog.Int("app_id", appId))
+	logger.InfoContext(ctx, "update app")
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		logger.WarnContext(ctx, "name is empty")
+		return models.App{}, fmt.Errorf("%s: %w", op, ErrInvalidApp)
+	}
+	app, err := s.appStorage.GetApp(ctx, appId)
+	if err != nil {
+		logger.WarnContext(ctx, "failed to get app", slog.String("err", err.Error()))
+		return models.App{}, fmt.Errorf("%s: %w", op, appError(err))
+	}
+	app.Name = name
+	app.AllowAutoProvision = allowAutoProvision
+	if err := s.appStorage.UpdateApp(ctx, app); err != nil {
+		logger.WarnContext(ctx, "failed to update app", slog.String("err", err.Error()))
+		return models.App{}, fmt.Errorf("%s: %w", op, appError(err))
+	}
+	app.Secret = ""
+	return app, nil
+}
+
+// RotateAppSecret replaces the secret of an app with a generated one
+// and returns it. Tokens signed with the old secret stop verifying.
+func (s *AdminService) RotateAppSecret(ctx context.Context, appId int) (string, error) {
+	const op = "service.admin.RotateAppSecret"
+	logger := s.logger.With(slog.String("op", op), slog.Int("app_id", appId))
+	logger.InfoContext(ctx, "rotate app secret")
+
+	secret, err := secure.RandomToken(secretBytes)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to generate secret", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if err := s.appStorage.SetAppSecret(ctx, appId, secret); err != nil {
+		logger.WarnContext(ctx, "failed to set app secret", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, appError(err))
+	}
+	logger.InfoContext(ctx, "app secret rotated")
+	return secret, nil
+}
+
+// ListMembers returns the users of an organization with their roles.
+func (s *AdminService) ListMembers(ctx context.Context, orgId int64) ([]models.Member, error) {
+	const op = "service.admin.ListMembers"
+	logger := s.logger.With(slog.String("op", op), slog.Int64("org_id", orgId))
+
+	if err := s.checkOrg(ctx, orgId); err != nil {
+		logger.WarnContext(ctx, "failed to check organization", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	members, err := s.memberStorage.ListMembers(ctx, orgId)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to list members", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return members, nil
+}
+
+// AddMember creates a user without a password in the organization with
+// the role. An empty hash never matches in bcrypt, so the user signs in
+// without a password or after a password reset.
+func (s *AdminService) AddMember(ctx context.Context, orgId int64, email string, role string) (models.Member, error) {
+	const op = "service.admin.AddMember"
+	logger := s.logger.With(slog.String("op", op), slog.Int64("org_id", orgId))
+	logger.InfoContext(ctx, "add member")
+
+	if !models.IsValidRole(role) {
+		logger.WarnContext(ctx, "invalid role", slog.String("role", role))
+		return models.Member{}, fmt.Errorf("%s: %w", op, ErrInvalidRole)
+	}
+	if err := s.checkOrg(ctx, orgId); err != nil {
+		logger.WarnContext(ctx, "failed to check organization", slog.String("err", err.Error()))
+		return models.Member{}, fmt.Errorf("%s: %w", op, err)
+	}
+	member := models.Member{Email: email, Role: role}
+	err := s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		userId, err := s.userSaver.SaveUser(ctx, orgId, email, []byte{})
+		if errors.Is(err, storage.ErrUserExists) {
+			return ErrUserExists
+		}
+		if err != nil {
+			return err
+		}
+		member.UserId = userId
+		if role == models.RoleMember {
+			return nil
+		}
+		return s.memberStorage.SetMemberRole(ctx, orgId, userId, role)
+	})
+	if err != nil {
+		logger.WarnContext(ctx, "failed to add member", slog.String("err", err.Error()))
+		return models.Member{}, fmt.Errorf("%s: %w", op, err)
+	}
+	logger.InfoContext(ctx, "member added", slog.Int64("user_id", member.UserId))
+	return member, nil
+}
+
+// SetMemberRole changes the role of a user of the organization.
+func (s *AdminService) SetMemberRole(ctx context.Context, orgId int64, userId int64, role string) error {
+	const op = "service.admin.SetMemberRole"
+	logger := s.logger.With(slog.String("op", op), slog.Int64("org_id", orgId), slog.Int64("user_id", userId))
+	logger.InfoContext(ctx, "set member role", slog.String("role", role))
+
+	if !models.IsValidRole(role) {
+		logger.WarnContext(ctx, "invalid role")
+		return fmt.Errorf("%s: %w", op, ErrInvalidRole)
+	}
+	err := s.memberStorage.SetMemberRole(ctx, orgId, userId, role)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		logger.WarnContext(ctx, "user not found")
+		return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to set member role", slog.String("err", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RevokeSessions makes the tokens issued to the user so far invalid
+// for the calls that check revocations and returns the revocation time.
+// Tokens carry their issue time in whole seconds, so tokens issued
+// later within the same second are rejected too.
+func (s *AdminService) RevokeSessions(ctx context.Context, orgId int64, userId int64) (time.Time, error) {
+	const op = "service.admin.RevokeSessions"
+	logger := s.logger.With(slog.String("op", op), slog.Int64("org_id", orgId), slog.Int64("user_id", userId))
+	logger.InfoContext(ctx, "revoke sessions")
+
+	revokedAt := time.Now()
+	err := s.sessionRevoker.RevokeSessions(ctx, orgId, userId, revokedAt)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		logger.WarnContext(ctx, "user not found")
+		return time.Time{}, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to revoke sessions", slog.String("err", err.Error()))
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+	logger.InfoContext(ctx, "sessions revoked")
+	return revokedAt, nil
+}
+
+func (s *AdminService) checkOrg(ctx context.Context, orgId int64) error {
+	_, err := s.orgProvider.GetOrganization(ctx, orgId)
+	if errors.Is(err, storage.ErrOrgNotFound) {
+		return ErrOrgNotFound
+	}
+	return err
+}
+
+// appError maps the storage errors of app writes to the errors of the
+// service.
+func appError(err error) error {
+	switch {
+	case errors.Is(err, storage.ErrAppNotFound):
+		return ErrAppNotFound
+	case errors.Is(err, storage.ErrAppExists):
+		return ErrAppExists
+	case errors.Is(err, storage.ErrOrgNotFound):
+		return ErrOrgNotFound
+	}
+	return err
+}
@@ -0,0 +1,150 @@
+package admin
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sso/interanal/domain/models"
+	"sso/interanal/storage/memory"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCreateApp проверяет, что пустой секрет генерируется и
+// возвращается, а заданный сохраняется как есть и не возвращается.
+func TestCreateApp(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService(t)
+
+	secret, err := s.CreateApp(ctx, models.App{Id: 1, OrgId: models.DefaultOrgId, Name: "web"})
+	require.NoError(t, err)
+	require.NotEmpty(t, secret)
+	app, err := st.GetApp(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, secret, app.Secret)
+
+	secret, err = s.CreateApp(ctx, models.App{Id: 2, OrgId: models.DefaultOrgId, Name: "mobile", Secret: "given"})
+	require.NoError(t, err)
+	assert.Empty(t, secret)
+	app, err = st.GetApp(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "given", app.Secret)
+
+	_, err = s.CreateApp(ctx, models.App{Id: 3, OrgId: models.DefaultOrgId, Name: "web"})
+	assert.ErrorIs(t, err, ErrAppExists)
+	_, err = s.CreateApp(ctx, models.App{Id: 3, OrgId: 42, Name: "other"})
+	assert.ErrorIs(t, err, ErrOrgNotFound)
+	_, err = s.CreateApp(ctx, models.App{Id: 3, OrgId: models.DefaultOrgId, Name: " "})
+	assert.ErrorIs(t, err, ErrInvalidApp)
+}
+
+// TestUpdateApp проверяет, что обновление меняет имя и
+// автосоздание пользователей, но не секрет и не организацию.
+func TestUpdateApp(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService(t)
+	require.NoError(t, st.SaveApp(ctx, models.App{Id: 1, OrgId: models.DefaultOrgId, Name: "web", Secret: "secret"}))
+
+	app, err := s.UpdateApp(ctx, 1, "portal", true)
+	require.NoError(t, err)
+	assert.Equal(t, models.App{Id: 1, OrgId: models.DefaultOrgId, Name: "portal", AllowAutoProvision: true}, app)
+	stored, err := st.GetApp(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", stored.Secret)
+	assert.Equal(t, "portal", stored.Name)
+
+	_, err = s.UpdateApp(ctx, 2, "portal", false)
+	assert.ErrorIs(t, err, ErrAppNotFound)
+}
+
+// TestRotateAppSecret проверяет, что ротация сохраняет
+// и возвращает новый секрет.
+func TestRotateAppSecret(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService(t)
+	require.NoError(t, st.SaveApp(ctx, models.App{Id: 1, OrgId: models.DefaultOrgId, Name: "web", Secret: "secret"}))
+
+	secret, err := s.RotateAppSecret(ctx, 1)
+	require.NoError(t, err)
+	assert.NotEqual(t, "secret", secret)
+	app, err := st.GetApp(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, secret, app.Secret)
+
+	_, err = s.RotateAppSecret(ctx, 2)
+	assert.ErrorIs(t, err, ErrAppNotFound)
+}
+
+// TestListUnknownOrg проверяет, что списки для несуществующей
+// организации возвращают ошибку, а не пустой результат.
+func TestListUnknownOrg(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t)
+
+	apps, err := s.ListApps(ctx, models.DefaultOrgId)
+	require.NoError(t, err)
+	assert.Empty(t, apps)
+	_, err = s.ListApps(ctx, 42)
+	assert.ErrorIs(t, err, ErrOrgNotFound)
+	_, err = s.ListMembers(ctx, 42)
+	assert.ErrorIs(t, err, ErrOrgNotFound)
+}
+
+// TestMembers проверяет добавление участника, смену роли
+// и то, что оба видны в списке участников.
+func TestMembers(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService(t)
+
+	owner, err := s.AddMember(ctx, models.DefaultOrgId, "owner@gmail.com", models.RoleOwner)
+	require.NoError(t, err)
+	member, err := s.AddMember(ctx, models.DefaultOrgId, "member@gmail.com", models.RoleMember)
+	require.NoError(t, err)
+	require.NoError(t, s.SetMemberRole(ctx, models.DefaultOrgId, member.UserId, models.RoleAdmin))
+
+	members, err := s.ListMembers(ctx, models.DefaultOrgId)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Member{
+		{UserId: owner.UserId, Email: "owner@gmail.com", Role: models.RoleOwner},
+		{UserId: member.UserId, Email: "member@gmail.com", Role: models.RoleAdmin},
+	}, members)
+	user, err := st.GetUserById(ctx, models.DefaultOrgId, owner.UserId)
+	require.NoError(t, err)
+	assert.Empty(t, user.PaswordHash)
+
+	_, err = s.AddMember(ctx, models.DefaultOrgId, "owner@gmail.com", models.RoleMember)
+	assert.ErrorIs(t, err, ErrUserExists)
+	_, err = s.AddMember(ctx, models.DefaultOrgId, "new@gmail.com", "root")
+	assert.ErrorIs(t, err, ErrInvalidRole)
+	err = s.SetMemberRole(ctx, models.DefaultOrgId, 42, models.RoleAdmin)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+// TestRevokeSessions проверяет, что время отзыва
+// сохраняется у пользователя.
+func TestRevokeSessions(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService(t)
+	userId, err := st.SaveUser(ctx, models.DefaultOrgId, "user@gmail.com", []byte("hash"))
+	require.NoError(t, err)
+
+	revokedAt, err := s.RevokeSessions(ctx, models.DefaultOrgId, userId)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), revokedAt, time.Minute)
+	stored, err := st.SessionsRevokedAt(ctx, models.DefaultOrgId, userId)
+	require.NoError(t, err)
+	assert.True(t, stored.Equal(revokedAt))
+
+	_, err = s.RevokeSessions(ctx, models.DefaultOrgId, 42)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func newTestService(t *testing.T) (*AdminService, *memory.Storage) {
+	t.Helper()
+	st := memory.New()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return New(logger, st, st, st, st, st, st), st
+}
@@ -6,17 +6,27 @@ import (
 	"fmt"
 	"log/slog"
 	"sso/interanal/domain/models"
+	"sso/interanal/metrics"
 	"sso/interanal/storage"
+	"sso/interanal/tracing"
 	ssojwt "sso/lib/jwt"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var tracer = otel.Tracer("sso/interanal/service/auth")
+
 var (
 	ErrInvalidCreds = errors.New("invalid creds")
 	ErrAppNotFound  = errors.New("app not found")
 	ErrUserExists   = errors.New("user already exists")
+	ErrOrgNotFound  = errors.New("organization not found")
+	// ErrRegistrationClosed is returned when only invited users can join.
+	ErrRegistrationClosed = errors.New("registration is closed")
 )
 
 type AuthService struct {
@@ -24,20 +34,23 @@ type AuthService struct {
 	userSaver          UserSaver
 	userProvider       UserProvider
 	appServiceProvider AppServiceProvider
-	tokenTTL           time.Duration
+	// tokenTTL and openRegistration change on config reload.
+	tokenTTL         atomic.Int64
+	openRegistration atomic.Bool
 }
 
 type UserSaver interface {
 	SaveUser(
 		ctx context.Context,
+		orgId int64,
 		email string,
 		passwordHash []byte,
 	) (userId int64, err error)
 }
 
 type UserProvider interface {
-	GetUser(ctx context.Context, email string) (models.User, error)
-	IsAdmin(ctx context.Context, userId int64) (bool, error)
+	GetUser(ctx context.Context, orgId int64, email string) (models.User, error)
+	IsAdmin(ctx context.Context, orgId int64, userId int64) (bool, error)
 }
 
 type AppServiceProvider interface {
@@ -50,14 +63,27 @@ func New(
 	userProvider UserProvider,
 	appServiceProvider AppServiceProvider,
 	tokenTTL time.Duration,
+	openRegistration bool,
 ) *AuthService {
-	return &AuthService{
+	a := &AuthService{
 		logger:             logger,
 		userSaver:          userSaver,
 		userProvider:       userProvider,
 		appServiceProvider: appServiceProvider,
-		tokenTTL:           tokenTTL,
 	}
+	a.SetTokenTTL(tokenTTL)
+	a.SetOpenRegistration(openRegistration)
+	return a
+}
+
+// SetTokenTTL changes the lifetime of tokens issued by later logins.
+func (a *AuthService) SetTokenTTL(ttl time.Duration) {
+	a.tokenTTL.Store(int64(ttl))
+}
+
+// SetOpenRegistration opens or closes registration without invites.
+func (a *AuthService) SetOpenRegistration(open bool) {
+	a.openRegistration.Store(open)
 }
 
 func (a *AuthService) Login(
@@ -67,77 +93,131 @@ func (a *AuthService) Login(
 	appId int,
 ) (string, error) {
 	const op = "service.auth.Login"
+	ctx, span := tracer.Start(ctx, "AuthService.Login")
+	defer span.End()
+	span.SetAttributes(attribute.Int("app_id", appId))
 	logger := a.logger.With(slog.String("op", op))
-	logger.Info("login user", slog.Int("app_id", appId))
+	logger.InfoContext(ctx, "login user", slog.Int("app_id", appId))
 
-	user, err := a.userProvider.GetUser(ctx, email)
-	if errors.Is(err, storage.ErrUserNotFound) {
-		logger.Warn("user not found")
-		return "", fmt.Errorf("%s: %w", op, ErrInvalidCreds)
+	app, err := a.appServiceProvider.GetApp(ctx, appId)
+	if errors.Is(err, storage.ErrAppNotFound) {
+		logger.WarnContext(ctx, "app not found")
+		metrics.LoginFailed(metrics.LoginAppNotFound)
+		tracing.RecordError(span, ErrAppNotFound)
+		return "", fmt.Errorf("%s: %w", op, ErrAppNotFound)
 	}
 	if err != nil {
-		logger.Error("failed to get user", slog.String("err", err.Error()))
+		logger.ErrorContext(ctx, "failed to get app", slog.String("err", err.Error()))
+		metrics.LoginFailed(metrics.LoginInternal)
+		tracing.RecordError(span, err)
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
+	logger = logger.With(slog.Int64("org_id", app.OrgId))
 
-	if err := bcrypt.CompareHashAndPassword(user.PaswordHash, []byte(password)); err != nil {
-		logger.Warn("invalid creds")
+	user, err := a.userProvider.GetUser(ctx, app.OrgId, email)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		logger.WarnContext(ctx, "user not found")
+		metrics.LoginFailed(metrics.LoginUserNotFound)
+		tracing.RecordError(span, ErrInvalidCreds)
 		return "", fmt.Errorf("%s: %w", op, ErrInvalidCreds)
 	}
-
-	app, err := a.appServiceProvider.GetApp(ctx, appId)
-	if errors.Is(err, storage.ErrAppNotFound) {
-		logger.Warn("app not found")
-		return "", fmt.Errorf("%s: %w", op, ErrAppNotFound)
-	}
 	if err != nil {
-		logger.Error("failed to get app", slog.String("err", err.Error()))
+		logger.ErrorContext(ctx, "failed to get user", slog.String("err", err.Error()))
+		metrics.LoginFailed(metrics.LoginInternal)
+		tracing.RecordError(span, err)
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
-	logger.Info("user logged successfully")
-	token, err := ssojwt.NewToken(user, app, a.tokenTTL)
+
+	_, bcryptSpan := tracer.Start(ctx, "bcrypt.CompareHashAndPassword")
+	start := time.Now()
+	err = bcrypt.CompareHashAndPassword(user.PaswordHash, []byte(password))
+	metrics.ObserveBcrypt(metrics.BcryptCompare, start)
+	bcryptSpan.End()
+	if err != nil {
+		logger.WarnContext(ctx, "invalid creds")
+		metrics.LoginFailed(metrics.LoginInvalidPassword)
+		tracing.RecordError(span, ErrInvalidCreds)
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidCreds)
+	}
+
+	logger.InfoContext(ctx, "user logged successfully")
+	token, err := ssojwt.NewToken(user, app, time.Duration(a.tokenTTL.Load()))
 	if err != nil {
-		a.logger.Error("failed to generate token", slog.String("err", err.Error()))
+		a.logger.ErrorContext(ctx, "failed to generate token", slog.String("err", err.Error()))
+		metrics.LoginFailed(metrics.LoginInternal)
+		tracing.RecordError(span, err)
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
+	metrics.LoginSucceeded()
 	return token, nil
 }
 
 func (a *AuthService) RegisterNewUser(
 	ctx context.Context,
+	orgId int64,
 	email string,
 	password string,
 ) (int64, error) {
 	const op = "service.auth.RegisterNewUser"
-	logger := a.logger.With(slog.String("op", op))
-	logger.Info("register user")
+	ctx, span := tracer.Start(ctx, "AuthService.RegisterNewUser")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("org_id", orgId))
+	logger := a.logger.With(slog.String("op", op), slog.Int64("org_id", orgId))
+	logger.InfoContext(ctx, "register user")
+	if !a.openRegistration.Load() {
+		logger.WarnContext(ctx, "open registration is disabled")
+		metrics.Registration(metrics.RegistrationClosed)
+		tracing.RecordError(span, ErrRegistrationClosed)
+		return 0, fmt.Errorf("%s: %w", op, ErrRegistrationClosed)
+	}
+	_, bcryptSpan := tracer.Start(ctx, "bcrypt.GenerateFromPassword")
+	start := time.Now()
 	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	metrics.ObserveBcrypt(metrics.BcryptHash, start)
+	bcryptSpan.End()
 	if err != nil {
-		logger.Error("failed to generate password hash", slog.String("err", err.Error()))
+		logger.ErrorContext(ctx, "failed to generate password hash", slog.String("err", err.Error()))
+		metrics.Registration(metrics.RegistrationInternal)
+		tracing.RecordError(span, err)
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
-	userId, err := a.userSaver.SaveUser(ctx, email, passwordHash)
+	userId, err := a.userSaver.SaveUser(ctx, orgId, email, passwordHash)
 	if errors.Is(err, storage.ErrUserExists) {
-		logger.Warn("user already exists", slog.String("err", err.Error()))
+		logger.WarnContext(ctx, "user already exists", slog.String("err", err.Error()))
+		metrics.Registration(metrics.RegistrationUserExists)
+		tracing.RecordError(span, ErrUserExists)
 		return 0, fmt.Errorf("%s: %w", op, ErrUserExists)
 	}
+	if errors.Is(err, storage.ErrOrgNotFound) {
+		logger.WarnContext(ctx, "organization not found", slog.String("err", err.Error()))
+		metrics.Registration(metrics.RegistrationOrgNotFound)
+		tracing.RecordError(span, ErrOrgNotFound)
+		return 0, fmt.Errorf("%s: %w", op, ErrOrgNotFound)
+	}
 	if err != nil {
-		logger.Error("failed to save user", slog.String("err", err.Error()))
+		logger.ErrorContext(ctx, "failed to save user", slog.String("err", err.Error()))
+		metrics.Registration(metrics.RegistrationInternal)
+		tracing.RecordError(span, err)
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
-	logger.Info("user saved successfully")
+	logger.InfoContext(ctx, "user saved successfully")
+	metrics.Registration(metrics.RegistrationSuccess)
 	return userId, nil
 }
 
-func (a *AuthService) IsAdmin(ctx context.Context, userId int64) (bool, error) {
+func (a *AuthService) IsAdmin(ctx context.Context, orgId int64, userId int64) (bool, error) {
 	const op = "service.auth.IsAdmin"
-	logger := a.logger.With(slog.String("op", op))
-	logger.Info("checking if user id admin")
-	isAdmin, err := a.userProvider.IsAdmin(ctx, userId)
+	ctx, span := tracer.Start(ctx, "AuthService.IsAdmin")
+	defer span.End()
+	span.SetAttributes(attribute.Int64("org_id", orgId))
+	logger := a.logger.With(slog.String("op", op), slog.Int64("org_id", orgId))
+	logger.InfoContext(ctx, "checking if user id admin")
+	isAdmin, err := a.userProvider.IsAdmin(ctx, orgId, userId)
 	if err != nil {
-		logger.Error("failed to determinate admin")
+		logger.ErrorContext(ctx, "failed to determinate admin")
+		tracing.RecordError(span, err)
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
-	logger.Info("checked if user is admin", slog.Bool("is_admin", isAdmin))
+	logger.InfoContext(ctx, "checked if user is admin", slog.Bool("is_admin", isAdmin))
 	return isAdmin, nil
 }
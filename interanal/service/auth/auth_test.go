@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sso/interanal/domain/models"
+	"sso/interanal/storage"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	appId    = 1
+	orgId    = models.DefaultOrgId
+	email    = "tracing@gmail.com"
+	password = "password"
+)
+
+// TestLoginSpans проверяет, что Login создает span,
+// а сравнение пароля выделено в дочерний span.
+func TestLoginSpans(t *testing.T) {
+	exporter := newTestExporter(t)
+	s := newTestService(t)
+
+	_, err := s.Login(context.Background(), email, password, appId)
+	require.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	login := findSpan(t, spans, "AuthService.Login")
+	bcryptSpan := findSpan(t, spans, "bcrypt.CompareHashAndPassword")
+	assert.Equal(t, login.SpanContext.SpanID(), bcryptSpan.Parent.SpanID())
+	assert.Equal(t, codes.Unset, login.Status.Code)
+}
+
+// TestFailedLoginSpanHasError проверяет, что неудачный
+// вход помечает span ошибкой.
+func TestFailedLoginSpanHasError(t *testing.T) {
+	exporter := newTestExporter(t)
+	s := newTestService(t)
+
+	_, err := s.Login(context.Background(), email, "wrong password", appId)
+	require.ErrorIs(t, err, ErrInvalidCreds)
+
+	login := findSpan(t, exporter.GetSpans(), "AuthService.Login")
+	assert.Equal(t, codes.Error, login.Status.Code)
+	assert.Equal(t, ErrInvalidCreds.Error(), login.Status.Description)
+}
+
+// TestRegisterSpans проверяет span регистрации и
+// дочерний span хеширования пароля.
+func TestRegisterSpans(t *testing.T) {
+	exporter := newTestExporter(t)
+	s := newTestService(t)
+
+	_, err := s.RegisterNewUser(context.Background(), orgId, "new@gmail.com", password)
+	require.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	register := findSpan(t, spans, "AuthService.RegisterNewUser")
+	bcryptSpan := findSpan(t, spans, "bcrypt.GenerateFromPassword")
+	assert.Equal(t, register.SpanContext.SpanID(), bcryptSpan.Parent.SpanID())
+}
+
+// TestReloadedSettingsApply проверяет, что новые TTL токена
+// и режим регистрации действуют без пересоздания сервиса.
+func TestReloadedSettingsApply(t *testing.T) {
+	s := newTestService(t)
+
+	s.SetTokenTTL(2 * time.Minute)
+	token, err := s.Login(context.Background(), email, password, appId)
+	require.NoError(t, err)
+	claims := jwt.MapClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(token, claims)
+	require.NoError(t, err)
+	exp, err := claims.GetExpirationTime()
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(2*time.Minute), exp.Time, 5*time.Second)
+
+	s.SetOpenRegistration(false)
+	_, err = s.RegisterNewUser(context.Background(), orgId, "closed@gmail.com", password)
+	assert.ErrorIs(t, err, ErrRegistrationClosed)
+}
+
+// exporter is shared by the package because the global tracer
+// provider can be set only once for the tracers created on init.
+var exporter = tracetest.NewInMemoryExporter()
+
+func init() {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+}
+
+func newTestExporter(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter.Reset()
+	return exporter
+}
+
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	require.Failf(t, "span not found", "no span %q", name)
+	return tracetest.SpanStub{}
+}
+
+func newTestService(t *testing.T) *AuthService {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	st := &fakeStorage{user: models.User{Id: 1, OrgId: orgId, Email: email, PaswordHash: hash}}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return New(logger, st, st, st, time.Hour, true)
+}
+
+type fakeStorage struct {
+	user models.User
+}
+
+func (s *fakeStorage) SaveUser(ctx context.Context, org int64, email string, passwordHash []byte) (int64, error) {
+	if email == s.user.Email {
+		return 0, storage.ErrUserExists
+	}
+	return s.user.Id + 1, nil
+}
+
+func (s *fakeStorage) GetUser(ctx context.Context, org int64, email string) (models.User, error) {
+	if org != s.user.OrgId || email != s.user.Email {
+		return models.User{}, storage.ErrUserNotFound
+	}
+	return s.user, nil
+}
+
+func (s *fakeStorage) IsAdmin(ctx context.Context, org int64, userId int64) (bool, error) {
+	return false, nil
+}
+
+func (s *fakeStorage) GetApp(ctx context.Context, id int) (models.App, error) {
+	if id != appId {
+		return models.App{}, storage.ErrAppNotFound
+	}
+	return models.App{Id: appId, OrgId: orgId, Name: "test", Secret: "test-secret"}, nil
+}
@@ -0,0 +1,285 @@
+// Package bootstrap applies a declarative seed of apps and users, so
+// a fresh installation gets its first admin without hand-written SQL.
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/interanal/domain/models"
+	"sso/interanal/metrics"
+	"sso/interanal/storage"
+	"sso/lib/secure"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// generatedBytes is the entropy of generated app secrets and passwords.
+const generatedBytes = 32
+
+const (
+	StatusCreated   = "created"
+	StatusUpdated   = "updated"
+	StatusUnchanged = "unchanged"
+)
+
+var ErrInvalidSeed = errors.New("invalid seed")
+
+type BootstrapService struct {
+	logger         *slog.Logger
+	transactor     Transactor
+	appStorage     AppStorage
+	userSaver      UserSaver
+	userProvider   UserProvider
+	memberProvider MemberProvider
+	adminSetter    AdminSetter
+}
+
+// Transactor runs fn in a storage transaction that the storage
+// methods called with the ctx of fn join.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type AppStorage interface {
+	GetApp(ctx context.Context, appId int) (models.App, error)
+	SaveApp(ctx context.Context, app models.App) error
+	UpdateApp(ctx context.Context, app models.App) error
+	SetAppSecret(ctx context.Context, appId int, secret string) error
+}
+
+type UserSaver interface {
+	SaveUser(
+		ctx context.Context,
+		orgId int64,
+		email string,
+		passwordHash []byte,
+	) (userId int64, err error)
+}
+
+type UserProvider interface {
+	GetUser(ctx context.Context, orgId int64, email string) (models.User, error)
+	IsAdmin(ctx context.Context, orgId int64, userId int64) (bool, error)
+}
+
+type MemberProvider interface {
+	MemberRole(ctx context.Context, orgId int64, userId int64) (string, error)
+	SetMemberRole(ctx context.Context, orgId int64, userId int64, role string) error
+}
+
+type AdminSetter interface {
+	SetAdmin(ctx context.Context, orgId int64, userId int64, isAdmin bool) error
+}
+
+// AppResult is what Apply did with an app. Secret is set only when it
+// was generated, it can't be read back later.
+type AppResult struct {
+	Id     int
+	Name   string
+	Status string
+	Secret string
+}
+
+// UserResult is what Apply did with a user. Password is set only when
+// it was generated, it can't be read back later.
+type UserResult struct {
+	Id       int64
+	OrgId    int64
+	Email    string
+	Status   string
+	Password string
+}
+
+type Result struct {
+	Apps  []AppResult
+	Users []UserResult
+}
+
+func New(
+	logger *slog.Logger,
+	transactor Transactor,
+	appStorage AppStorage,
+	userSaver UserSaver,
+	userProvider UserProvider,
+	memberProvider MemberProvider,
+	adminSetter AdminSetter,
+) *BootstrapService {
+	return &BootstrapService{
+		logger:         logger,
+		transactor:     transactor,
+		appStorage:     appStorage,
+		userSaver:      userSaver,
+		userProvider:   userProvider,
+		memberProvider: memberProvider,
+		adminSetter:    adminSetter,
+	}
+}
+
+// Apply makes the storage match the seed in one transaction, so it can
+// be run again after a failure or with an extended seed. A secret given
+// in the seed replaces the one of an existing app, an empty one keeps
+// it. Passwords are set only when a user is created.
+func (s *BootstrapService) Apply(ctx context.Context, seed Seed) (Result, error) {
+	const op = "service.bootstrap.Apply"
+	logger := s.logger.With(slog.String("op", op))
+	if err := seed.Validate(); err != nil {
+		return Result{}, fmt.Errorf("%s: %w: %w", op, ErrInvalidSeed, err)
+	}
+	var result Result
+	err := s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		// A retried transaction starts over.
+		result = Result{}
+		for _, app := range seed.Apps {
+			r, err := s.applyApp(ctx, logger, app)
+			if err != nil {
+				return fmt.Errorf("app %d: %w", app.Id, err)
+			}
+			result.Apps = append(result.Apps, r)
+		}
+		for _, user := range seed.Users {
+			r, err := s.applyUser(ctx, logger, user)
+			if err != nil {
+				return fmt.Errorf("user %s: %w", user.Email, err)
+			}
+			result.Users = append(result.Users, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return Result{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return result, nil
+}
+
+func (s *BootstrapService) applyApp(ctx context.Context, logger *slog.Logger, seed AppSeed) (AppResult, error) {
+	logger = logger.With(slog.Int("app_id", seed.Id))
+	want := models.App{
+		Id:                 seed.Id,
+		OrgId:              seed.orgId(),
+		Name:               seed.Name,
+		Secret:             seed.Secret,
+		AllowAutoProvision: seed.AllowAutoProvision,
+	}
+	result := AppResult{Id: seed.Id, Name: seed.Name}
+	current, err := s.appStorage.GetApp(ctx, seed.Id)
+	switch {
+	case err == nil:
+		result.Status = StatusUnchanged
+		if want.Secret == "" {
+			want.Secret = current.Secret
+		}
+		if want.Secret != current.Secret {
+			if err := s.appStorage.SetAppSecret(ctx, want.Id, want.Secret); err != nil {
+				logger.ErrorContext(ctx, "failed to set app secret", slog.String("err", err.Error()))
+				return AppResult{}, err
+			}
+			current.Secret = want.Secret
+			result.Status = StatusUpdated
+		}
+		if current != want {
+			if err := s.appStorage.UpdateApp(ctx, want); err != nil {
+				logger.ErrorContext(ctx, "failed to update app", slog.String("err", err.Error()))
+				return AppResult{}, err
+			}
+			result.Status = StatusUpdated
+		}
+		if result.Status == StatusUpdated {
+			logger.InfoContext(ctx, "app updated")
+		}
+		return result, nil
+	case !errors.Is(err, storage.ErrAppNotFound):
+		logger.ErrorContext(ctx, "failed to get app", slog.String("err", err.Error()))
+		return AppResult{}, err
+	}
+
+	if want.Secret == "" {
+		want.Secret, err = secure.RandomToken(generatedBytes)
+		if err != nil {
+			return AppResult{}, err
+		}
+		result.Secret = want.Secret
+	}
+	if err := s.appStorage.SaveApp(ctx, want); err != nil {
+		logger.ErrorContext(ctx, "failed to save app", slog.String("err", err.Error()))
+		return AppResult{}, err
+	}
+	logger.InfoContext(ctx, "app created")
+	result.Status = StatusCreated
+	return result, nil
+}
+
+func (s *BootstrapService) applyUser(ctx context.Context, logger *slog.Logger, seed UserSeed) (UserResult, error) {
+	orgId := seed.orgId()
+	logger = logger.With(slog.Int64("org_id", orgId), slog.String("email", seed.Email))
+	result := UserResult{OrgId: orgId, Email: seed.Email, Status: StatusUnchanged}
+	user, err := s.userProvider.GetUser(ctx, orgId, seed.Email)
+	switch {
+	case err == nil:
+		result.Id = user.Id
+	case errors.Is(err, storage.ErrUserNotFound):
+		password := seed.Password
+		if password == "" {
+			password, err = secure.RandomToken(generatedBytes)
+			if err != nil {
+				return UserResult{}, err
+			}
+			result.Password = password
+		}
+		start := time.Now()
+		passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		metrics.ObserveBcrypt(metrics.BcryptHash, start)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to generate password hash", slog.String("err", err.Error()))
+			return UserResult{}, err
+		}
+		result.Id, err = s.userSaver.SaveUser(ctx, orgId, seed.Email, passwordHash)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to save user", slog.String("err", err.Error()))
+			return UserResult{}, err
+		}
+		logger.InfoContext(ctx, "user created", slog.Int64("user_id", result.Id))
+		result.Status = StatusCreated
+	default:
+		logger.ErrorContext(ctx, "failed to get user", slog.String("err", err.Error()))
+		return UserResult{}, err
+	}
+
+	role, err := s.memberProvider.MemberRole(ctx, orgId, result.Id)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get member role", slog.String("err", err.Error()))
+		return UserResult{}, err
+	}
+	if want := seed.role(); role != want {
+		if err := s.memberProvider.SetMemberRole(ctx, orgId, result.Id, want); err != nil {
+			logger.ErrorContext(ctx, "failed to set member role", slog.String("err", err.Error()))
+			return UserResult{}, err
+		}
+		result.markUpdated()
+	}
+	isAdmin, err := s.userProvider.IsAdmin(ctx, orgId, result.Id)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get admin flag", slog.String("err", err.Error()))
+		return UserResult{}, err
+	}
+	if isAdmin != seed.Admin {
+		if err := s.adminSetter.SetAdmin(ctx, orgId, result.Id, seed.Admin); err != nil {
+			logger.ErrorContext(ctx, "failed to set admin flag", slog.String("err", err.Error()))
+			return UserResult{}, err
+		}
+		result.markUpdated()
+	}
+	if result.Status == StatusUpdated {
+		logger.InfoContext(ctx, "user updated", slog.Int64("user_id", result.Id))
+	}
+	return result, nil
+}
+
+// markUpdated reports a change of an existing user. A created user
+// stays created.
+func (r *UserResult) markUpdated() {
+	if r.Status == StatusUnchanged {
+		r.Status = StatusUpdated
+	}
+}
@@ -0,0 +1,146 @@
+package bootstrap
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sso/interanal/domain/models"
+	"sso/interanal/storage"
+	"sso/interanal/storage/memory"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TestApplyIsIdempotent проверяет, что повторное применение
+// сида ничего не меняет, а сгенерированные секреты
+// возвращаются только при создании.
+func TestApplyIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService()
+	seed := Seed{
+		Apps:  []AppSeed{{Id: 2, Name: "web"}},
+		Users: []UserSeed{{Email: "admin@gmail.com", Role: models.RoleOwner, Admin: true}},
+	}
+
+	result, err := s.Apply(ctx, seed)
+	require.NoError(t, err)
+	require.Len(t, result.Apps, 1)
+	require.Len(t, result.Users, 1)
+	assert.Equal(t, StatusCreated, result.Apps[0].Status)
+	assert.NotEmpty(t, result.Apps[0].Secret)
+	assert.Equal(t, StatusCreated, result.Users[0].Status)
+	assert.NotEmpty(t, result.Users[0].Password)
+
+	app, err := st.GetApp(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, result.Apps[0].Secret, app.Secret)
+	user, err := st.GetUser(ctx, models.DefaultOrgId, "admin@gmail.com")
+	require.NoError(t, err)
+	assert.NoError(t, bcrypt.CompareHashAndPassword(user.PaswordHash, []byte(result.Users[0].Password)))
+	role, err := st.MemberRole(ctx, models.DefaultOrgId, user.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleOwner, role)
+	isAdmin, err := st.IsAdmin(ctx, models.DefaultOrgId, user.Id)
+	require.NoError(t, err)
+	assert.True(t, isAdmin)
+
+	again, err := s.Apply(ctx, seed)
+	require.NoError(t, err)
+	assert.Equal(t, Result{
+		Apps:  []AppResult{{Id: 2, Name: "web", Status: StatusUnchanged}},
+		Users: []UserResult{{Id: user.Id, OrgId: models.DefaultOrgId, Email: "admin@gmail.com", Status: StatusUnchanged}},
+	}, again)
+}
+
+// TestApplyUpdatesExisting проверяет, что существующие приложение
+// и пользователь приводятся к сиду: секрет приложения из сида
+// применяется, а пароль пользователя сохраняется.
+func TestApplyUpdatesExisting(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService()
+	require.NoError(t, st.SaveApp(ctx, models.App{Id: 1, OrgId: models.DefaultOrgId, Name: "test", Secret: "test-secret"}))
+	userId, err := st.SaveUser(ctx, models.DefaultOrgId, "member@gmail.com", []byte("hash"))
+	require.NoError(t, err)
+
+	result, err := s.Apply(ctx, Seed{
+		Apps:  []AppSeed{{Id: 1, Name: "renamed", Secret: "new-secret", AllowAutoProvision: true}},
+		Users: []UserSeed{{Email: "member@gmail.com", Password: "ignored", Role: models.RoleAdmin}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []AppResult{{Id: 1, Name: "renamed", Status: StatusUpdated}}, result.Apps)
+	assert.Equal(t, []UserResult{{Id: userId, OrgId: models.DefaultOrgId, Email: "member@gmail.com", Status: StatusUpdated}}, result.Users)
+	app, err := st.GetApp(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.App{Id: 1, OrgId: models.DefaultOrgId, Name: "renamed", Secret: "new-secret", AllowAutoProvision: true}, app)
+	user, err := st.GetUser(ctx, models.DefaultOrgId, "member@gmail.com")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hash"), user.PaswordHash)
+	role, err := st.MemberRole(ctx, models.DefaultOrgId, userId)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleAdmin, role)
+}
+
+// TestApplySetsSecret проверяет, что смена только секрета
+// приложения считается обновлением, а пустой секрет в сиде
+// оставляет текущий.
+func TestApplySetsSecret(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService()
+	require.NoError(t, st.SaveApp(ctx, models.App{Id: 2, OrgId: models.DefaultOrgId, Name: "web", Secret: "old-secret"}))
+
+	result, err := s.Apply(ctx, Seed{Apps: []AppSeed{{Id: 2, Name: "web", Secret: "new-secret"}}})
+	require.NoError(t, err)
+	assert.Equal(t, []AppResult{{Id: 2, Name: "web", Status: StatusUpdated}}, result.Apps)
+	app, err := st.GetApp(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "new-secret", app.Secret)
+
+	result, err = s.Apply(ctx, Seed{Apps: []AppSeed{{Id: 2, Name: "web"}}})
+	require.NoError(t, err)
+	assert.Equal(t, []AppResult{{Id: 2, Name: "web", Status: StatusUnchanged}}, result.Apps)
+	app, err = st.GetApp(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "new-secret", app.Secret)
+}
+
+// TestApplyRollsBack проверяет, что при ошибке сид
+// не применяется частично.
+func TestApplyRollsBack(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService()
+
+	_, err := s.Apply(ctx, Seed{
+		Apps:  []AppSeed{{Id: 2, Name: "web"}},
+		Users: []UserSeed{{Email: "admin@gmail.com", OrgId: 42}},
+	})
+	require.ErrorIs(t, err, storage.ErrOrgNotFound)
+
+	_, err = st.GetApp(ctx, 2)
+	assert.ErrorIs(t, err, storage.ErrAppNotFound)
+}
+
+// TestApplyRejectsInvalidSeed проверяет, что некорректный
+// сид отклоняется целиком со всеми ошибками сразу.
+func TestApplyRejectsInvalidSeed(t *testing.T) {
+	s, _ := newTestService()
+
+	_, err := s.Apply(context.Background(), Seed{
+		Apps:  []AppSeed{{Id: 2, Name: "web"}, {Id: 2, Name: "web"}},
+		Users: []UserSeed{{Email: "admin", Role: "root"}},
+	})
+
+	require.ErrorIs(t, err, ErrInvalidSeed)
+	for _, field := range []string{"apps[1].id", "apps[1].name", "users[0].email", "users[0].role"} {
+		assert.ErrorContains(t, err, field)
+	}
+}
+
+func newTestService() (*BootstrapService, *memory.Storage) {
+	st := memory.New()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return New(logger, st, st, st, st, st, st), st
+}
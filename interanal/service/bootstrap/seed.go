@@ -0,0 +1,108 @@
+package bootstrap
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sso/interanal/domain/models"
+	"strings"
+)
+
+// Seed is the desired state of apps and users. Zero org ids mean the
+// default organization.
+type Seed struct {
+	Apps  []AppSeed  `yaml:"apps"`
+	Users []UserSeed `yaml:"users"`
+}
+
+type AppSeed struct {
+	Id    int    `yaml:"id"`
+	OrgId int64  `yaml:"org_id"`
+	Name  string `yaml:"name"`
+	// Secret replaces the secret of an existing app. When empty, a new
+	// app gets a generated one and an existing app keeps its own.
+	Secret             string `yaml:"secret"`
+	AllowAutoProvision bool   `yaml:"allow_auto_provision"`
+}
+
+type UserSeed struct {
+	Email string `yaml:"email"`
+	OrgId int64  `yaml:"org_id"`
+	// Password is generated when empty. It is used only to create the user.
+	Password string `yaml:"password"`
+	// Role is the role in the organization, member by default.
+	Role string `yaml:"role"`
+	// Admin is the flag that the IsAdmin RPC reports.
+	Admin bool `yaml:"admin"`
+}
+
+// Validate reports every problem of the seed at once.
+func (s Seed) Validate() error {
+	var errs []error
+	ids := make(map[int]bool)
+	names := make(map[string]bool)
+	for i, app := range s.Apps {
+		field := fmt.Sprintf("apps[%d]", i)
+		if app.Id <= 0 || app.Id > math.MaxInt16 {
+			errs = append(errs, fmt.Errorf("%s.id: must be between 1 and %d", field, math.MaxInt16))
+		}
+		if ids[app.Id] {
+			errs = append(errs, fmt.Errorf("%s.id: %d is repeated", field, app.Id))
+		}
+		ids[app.Id] = true
+		if app.Name == "" {
+			errs = append(errs, fmt.Errorf("%s.name: is required", field))
+		}
+		if names[app.Name] {
+			errs = append(errs, fmt.Errorf("%s.name: %q is repeated", field, app.Name))
+		}
+		names[app.Name] = true
+		if app.OrgId < 0 {
+			errs = append(errs, fmt.Errorf("%s.org_id: must not be negative", field))
+		}
+	}
+	type userKey struct {
+		orgId int64
+		email string
+	}
+	users := make(map[userKey]bool)
+	for i, user := range s.Users {
+		field := fmt.Sprintf("users[%d]", i)
+		if !strings.Contains(user.Email, "@") {
+			errs = append(errs, fmt.Errorf("%s.email: must be an email", field))
+		}
+		key := userKey{user.orgId(), user.Email}
+		if users[key] {
+			errs = append(errs, fmt.Errorf("%s.email: %q is repeated in org %d", field, user.Email, key.orgId))
+		}
+		users[key] = true
+		if user.OrgId < 0 {
+			errs = append(errs, fmt.Errorf("%s.org_id: must not be negative", field))
+		}
+		if !models.IsValidRole(user.role()) {
+			errs = append(errs, fmt.Errorf("%s.role: must be one of %q, %q, %q", field, models.RoleOwner, models.RoleAdmin, models.RoleMember))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s AppSeed) orgId() int64 {
+	if s.OrgId == 0 {
+		return models.DefaultOrgId
+	}
+	return s.OrgId
+}
+
+func (s UserSeed) orgId() int64 {
+	if s.OrgId == 0 {
+		return models.DefaultOrgId
+	}
+	return s.OrgId
+}
+
+func (s UserSeed) role() string {
+	if s.Role == "" {
+		return models.RoleMember
+	}
+	return s.Role
+}
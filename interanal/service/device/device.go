@@ -0,0 +1,316 @@
+package device
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/url"
+	"sso/interanal/domain/models"
+	"sso/interanal/storage"
+	ssojwt "sso/lib/jwt"
+	"sso/lib/secure"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	deviceCodeBytes = 32
+	// userCodeAlphabet has no vowels and no ambiguous characters, as RFC 8628 suggests.
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+	slowDownStep     = 5 * time.Second
+)
+
+// Poll errors follow the error codes of RFC 8628, section 3.5.
+var (
+	ErrAppNotFound          = errors.New("app not found")
+	ErrUserNotFound         = errors.New("user not found")
+	ErrInvalidUserCode      = errors.New("user code is invalid or expired")
+	ErrInvalidDeviceCode    = errors.New("invalid_grant")
+	ErrAuthorizationPending = errors.New("authorization_pending")
+	ErrSlowDown             = errors.New("slow_down")
+	ErrAccessDenied         = errors.New("access_denied")
+	ErrExpiredToken         = errors.New("expired_token")
+)
+
+type Options struct {
+	VerificationURI string
+	CodeTTL         time.Duration
+	PollInterval    time.Duration
+	TokenTTL        time.Duration
+}
+
+// Authorization is the device authorization response of RFC 8628, section 3.2.
+type Authorization struct {
+	DeviceCode              string
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string
+	ExpiresIn               time.Duration
+	Interval                time.Duration
+}
+
+type DeviceService struct {
+	logger        *slog.Logger
+	deviceStorage DeviceStorage
+	userProvider  UserProvider
+	appProvider   AppProvider
+	opts          Options
+	// tokenTTL starts as opts.TokenTTL and changes on config reload.
+	tokenTTL atomic.Int64
+}
+
+type DeviceStorage interface {
+	SaveDeviceAuthorization(ctx context.Context, auth models.DeviceAuthorization) error
+	GetDeviceAuthorization(ctx context.Context, deviceCodeHash []byte) (models.DeviceAuthorization, error)
+	GetDeviceAuthorizationByUserCode(ctx context.Context, userCode string) (models.DeviceAuthorization, error)
+	ResolveDeviceAuthorization(ctx context.Context, userCode string, userId int64, status string) error
+	UpdateDevicePoll(ctx context.Context, deviceCodeHash []byte, polledAt time.Time, interval time.Duration) error
+	ConsumeDeviceAuthorization(ctx context.Context, deviceCodeHash []byte) error
+}
+
+type UserProvider interface {
+	GetUserById(ctx context.Context, orgId int64, userId int64) (models.User, error)
+}
+
+type AppProvider interface {
+	GetApp(ctx context.Context, appId int) (models.App, error)
+}
+
+func New(
+	logger *slog.Logger,
+	deviceStorage DeviceStorage,
+	userProvider UserProvider,
+	appProvider AppProvider,
+	opts Options,
+) *DeviceService {
+	d := &DeviceService{
+		logger:        logger,
+		deviceStorage: deviceStorage,
+		userProvider:  userProvider,
+		appProvider:   appProvider,
+		opts:          opts,
+	}
+	d.SetTokenTTL(opts.TokenTTL)
+	return d
+}
+
+// SetTokenTTL changes the lifetime of tokens issued by later logins.
+func (d *DeviceService) SetTokenTTL(ttl time.Duration) {
+	d.tokenTTL.Store(int64(ttl))
+}
+
+func (d *DeviceService) StartDeviceAuthorization(ctx context.Context, appId int) (Authorization, error) {
+	const op = "service.device.StartDeviceAuthorization"
+	logger := d.logger.With(slog.String("op", op), slog.Int("app_id", appId))
+	logger.InfoContext(ctx, "start device authorization")
+
+	if _, err := d.appProvider.GetApp(ctx, appId); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			logger.WarnContext(ctx, "app not found")
+			return Authorization{}, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+		}
+		logger.ErrorContext(ctx, "failed to get app", slog.String("err", err.Error()))
+		return Authorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	deviceCode, err := secure.RandomToken(deviceCodeBytes)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to generate device code", slog.String("err", err.Error()))
+		return Authorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+	userCode, err := newUserCode()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to generate user code", slog.String("err", err.Error()))
+		return Authorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+	err = d.deviceStorage.SaveDeviceAuthorization(ctx, models.DeviceAuthorization{
+		DeviceCodeHash: secure.HashToken(deviceCode),
+		UserCode:       userCode,
+		AppId:          appId,
+		Status:         models.DeviceStatusPending,
+		Interval:       d.opts.PollInterval,
+		ExpiresAt:      time.Now().Add(d.opts.CodeTTL),
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to save device authorization", slog.String("err", err.Error()))
+		return Authorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+	logger.InfoContext(ctx, "device authorization started")
+	return Authorization{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		VerificationURI:         d.opts.VerificationURI,
+		VerificationURIComplete: d.opts.VerificationURI + "?user_code=" + url.QueryEscape(userCode),
+		ExpiresIn:               d.opts.CodeTTL,
+		Interval:                d.opts.PollInterval,
+	}, nil
+}
+
+// ApproveDeviceAuthorization is called by an authenticated user who entered the user code.
+func (d *DeviceService) ApproveDeviceAuthorization(ctx context.Context, orgId int64, userId int64, userCode string) error {
+	return d.resolve(ctx, "service.device.ApproveDeviceAuthorization", orgId, userId, userCode, models.DeviceStatusApproved)
+}
+
+func (d *DeviceService) DenyDeviceAuthorization(ctx context.Context, orgId int64, userId int64, userCode string) error {
+	return d.resolve(ctx, "service.device.DenyDeviceAuthorization", orgId, userId, userCode, models.DeviceStatusDenied)
+}
+
+// PollDeviceToken returns the token once the user approved the request.
+// Until then it returns one of the RFC 8628 poll errors.
+func (d *DeviceService) PollDeviceToken(ctx context.Context, deviceCode string) (string, error) {
+	const op = "service.device.PollDeviceToken"
+	logger := d.logger.With(slog.String("op", op))
+	hash := secure.HashToken(deviceCode)
+
+	auth, err := d.deviceStorage.GetDeviceAuthorization(ctx, hash)
+	if errors.Is(err, storage.ErrDeviceCodeNotFound) {
+		logger.WarnContext(ctx, "device code not found")
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidDeviceCode)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get device authorization", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	logger = logger.With(slog.Int("app_id", auth.AppId))
+
+	now := time.Now()
+	if !now.Before(auth.ExpiresAt) {
+		logger.InfoContext(ctx, "device code expired")
+		return "", fmt.Errorf("%s: %w", op, ErrExpiredToken)
+	}
+	interval := auth.Interval
+	tooFast := !auth.LastPolledAt.IsZero() && now.Sub(auth.LastPolledAt) < auth.Interval
+	if tooFast {
+		interval += slowDownStep
+	}
+	if err := d.deviceStorage.UpdateDevicePoll(ctx, hash, now, interval); err != nil {
+		logger.ErrorContext(ctx, "failed to update poll", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if tooFast {
+		logger.InfoContext(ctx, "device polls too fast", slog.Duration("interval", interval))
+		return "", fmt.Errorf("%s: %w", op, ErrSlowDown)
+	}
+
+	switch auth.Status {
+	case models.DeviceStatusPending:
+		return "", fmt.Errorf("%s: %w", op, ErrAuthorizationPending)
+	case models.DeviceStatusDenied:
+		return "", fmt.Errorf("%s: %w", op, ErrAccessDenied)
+	case models.DeviceStatusConsumed:
+		logger.WarnContext(ctx, "device code already used")
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidDeviceCode)
+	}
+
+	err = d.deviceStorage.ConsumeDeviceAuthorization(ctx, hash)
+	if errors.Is(err, storage.ErrDeviceCodeNotFound) {
+		logger.WarnContext(ctx, "device code already used")
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidDeviceCode)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to consume device authorization", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	app, err := d.appProvider.GetApp(ctx, auth.AppId)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get app", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	user, err := d.userProvider.GetUserById(ctx, app.OrgId, auth.UserId)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get user", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	token, err := ssojwt.NewToken(user, app, time.Duration(d.tokenTTL.Load()))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to generate token", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	logger.InfoContext(ctx, "device token issued", slog.Int64("user_id", user.Id))
+	return token, nil
+}
+
+func (d *DeviceService) resolve(
+	ctx context.Context,
+	op string,
+	orgId int64,
+	userId int64,
+	userCode string,
+	status string,
+) error {
+	logger := d.logger.With(slog.String("op", op), slog.Int64("org_id", orgId), slog.Int64("user_id", userId))
+	logger.InfoContext(ctx, "resolve device authorization", slog.String("status", status))
+
+	userCode = normalizeUserCode(userCode)
+	auth, err := d.deviceStorage.GetDeviceAuthorizationByUserCode(ctx, userCode)
+	if errors.Is(err, storage.ErrDeviceCodeNotFound) {
+		logger.WarnContext(ctx, "user code not found")
+		return fmt.Errorf("%s: %w", op, ErrInvalidUserCode)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get device authorization", slog.String("err", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	app, err := d.appProvider.GetApp(ctx, auth.AppId)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get app", slog.String("err", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	// A user can only approve devices of apps owned by their own organization.
+	if app.OrgId != orgId {
+		logger.WarnContext(ctx, "app belongs to another organization", slog.Int("app_id", app.Id))
+		return fmt.Errorf("%s: %w", op, ErrInvalidUserCode)
+	}
+	if _, err := d.userProvider.GetUserById(ctx, orgId, userId); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			logger.WarnContext(ctx, "user not found")
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		logger.ErrorContext(ctx, "failed to get user", slog.String("err", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = d.deviceStorage.ResolveDeviceAuthorization(ctx, userCode, userId, status)
+	if errors.Is(err, storage.ErrDeviceCodeNotFound) {
+		logger.WarnContext(ctx, "user code is not pending")
+		return fmt.Errorf("%s: %w", op, ErrInvalidUserCode)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to resolve device authorization", slog.String("err", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	logger.InfoContext(ctx, "device authorization resolved")
+	return nil
+}
+
+// newUserCode returns a code formatted as XXXX-XXXX.
+func newUserCode() (string, error) {
+	var b strings.Builder
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := 0; i < userCodeLength; i++ {
+		if i == userCodeLength/2 {
+			b.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(userCodeAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// normalizeUserCode accepts codes typed in lower case or without the dash.
+func normalizeUserCode(code string) string {
+	code = strings.ToUpper(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	if len(code) != userCodeLength {
+		return code
+	}
+	return code[:userCodeLength/2] + "-" + code[userCodeLength/2:]
+}
@@ -0,0 +1,219 @@
+package device
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sso/interanal/domain/models"
+	"sso/interanal/storage"
+	"sso/lib/secure"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	appId  = 1
+	userId = 1
+	orgId  = models.DefaultOrgId
+)
+
+// TestDeviceFlow проверяет полный сценарий: устройство
+// получает authorization_pending, пользователь подтверждает
+// код, устройство получает токен, а повторный опрос отклоняется.
+func TestDeviceFlow(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService(t, time.Minute)
+	auth, err := s.StartDeviceAuthorization(ctx, appId)
+	require.NoError(t, err)
+	assert.Regexp(t, `^[BCDFGHJKLMNPQRSTVWXZ]{4}-[BCDFGHJKLMNPQRSTVWXZ]{4}$`, auth.UserCode)
+	assert.Contains(t, auth.VerificationURIComplete, auth.UserCode)
+
+	_, err = s.PollDeviceToken(ctx, auth.DeviceCode)
+	assert.ErrorIs(t, err, ErrAuthorizationPending)
+
+	err = s.ApproveDeviceAuthorization(ctx, orgId, userId, auth.UserCode)
+	require.NoError(t, err)
+	st.rewindPoll(auth.DeviceCode)
+
+	token, err := s.PollDeviceToken(ctx, auth.DeviceCode)
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	st.rewindPoll(auth.DeviceCode)
+	_, err = s.PollDeviceToken(ctx, auth.DeviceCode)
+	assert.ErrorIs(t, err, ErrInvalidDeviceCode)
+}
+
+// TestDevicePollingTooFastSlowsDown проверяет, что
+// слишком частый опрос возвращает slow_down и
+// увеличивает интервал на 5 секунд.
+func TestDevicePollingTooFastSlowsDown(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService(t, time.Minute)
+	auth, err := s.StartDeviceAuthorization(ctx, appId)
+	require.NoError(t, err)
+
+	_, err = s.PollDeviceToken(ctx, auth.DeviceCode)
+	assert.ErrorIs(t, err, ErrAuthorizationPending)
+	_, err = s.PollDeviceToken(ctx, auth.DeviceCode)
+	assert.ErrorIs(t, err, ErrSlowDown)
+
+	assert.Equal(t, auth.Interval+slowDownStep, st.get(auth.DeviceCode).Interval)
+}
+
+// TestDeviceCodeExpires проверяет, что после истечения
+// срока опрос возвращает expired_token, а подтвердить
+// код уже нельзя.
+func TestDeviceCodeExpires(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t, -time.Second)
+	auth, err := s.StartDeviceAuthorization(ctx, appId)
+	require.NoError(t, err)
+
+	_, err = s.PollDeviceToken(ctx, auth.DeviceCode)
+	assert.ErrorIs(t, err, ErrExpiredToken)
+	err = s.ApproveDeviceAuthorization(ctx, orgId, userId, auth.UserCode)
+	assert.ErrorIs(t, err, ErrInvalidUserCode)
+}
+
+// TestDeniedDeviceAuthorization проверяет, что после отказа
+// пользователя устройство получает access_denied.
+func TestDeniedDeviceAuthorization(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t, time.Minute)
+	auth, err := s.StartDeviceAuthorization(ctx, appId)
+	require.NoError(t, err)
+
+	err = s.DenyDeviceAuthorization(ctx, orgId, userId, auth.UserCode)
+	require.NoError(t, err)
+
+	_, err = s.PollDeviceToken(ctx, auth.DeviceCode)
+	assert.ErrorIs(t, err, ErrAccessDenied)
+}
+
+// TestUserCodeIsNormalized проверяет, что код можно ввести
+// в нижнем регистре и без дефиса.
+func TestUserCodeIsNormalized(t *testing.T) {
+	assert.Equal(t, "BCDF-GHJK", normalizeUserCode("bcdfghjk"))
+	assert.Equal(t, "BCDF-GHJK", normalizeUserCode("bcdf-ghjk"))
+	assert.Equal(t, "BCDF-GHJK", normalizeUserCode("BCDF GHJK"))
+}
+
+func newTestService(t *testing.T, codeTTL time.Duration) (*DeviceService, *fakeStorage) {
+	t.Helper()
+	st := &fakeStorage{auths: make(map[string]models.DeviceAuthorization)}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := New(logger, st, st, st, Options{
+		VerificationURI: "http://localhost/device",
+		CodeTTL:         codeTTL,
+		PollInterval:    5 * time.Second,
+		TokenTTL:        time.Hour,
+	})
+	return s, st
+}
+
+type fakeStorage struct {
+	mu    sync.Mutex
+	auths map[string]models.DeviceAuthorization
+}
+
+func (s *fakeStorage) get(deviceCode string) models.DeviceAuthorization {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.auths[string(secure.HashToken(deviceCode))]
+}
+
+// rewindPoll pretends that the poll interval has passed since the last poll.
+func (s *fakeStorage) rewindPoll(deviceCode string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := string(secure.HashToken(deviceCode))
+	auth := s.auths[key]
+	auth.LastPolledAt = auth.LastPolledAt.Add(-auth.Interval)
+	s.auths[key] = auth
+}
+
+func (s *fakeStorage) SaveDeviceAuthorization(ctx context.Context, auth models.DeviceAuthorization) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auths[string(auth.DeviceCodeHash)] = auth
+	return nil
+}
+
+func (s *fakeStorage) GetDeviceAuthorization(ctx context.Context, deviceCodeHash []byte) (models.DeviceAuthorization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	auth, ok := s.auths[string(deviceCodeHash)]
+	if !ok {
+		return models.DeviceAuthorization{}, storage.ErrDeviceCodeNotFound
+	}
+	return auth, nil
+}
+
+func (s *fakeStorage) GetDeviceAuthorizationByUserCode(ctx context.Context, userCode string) (models.DeviceAuthorization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, auth := range s.auths {
+		if auth.UserCode == userCode {
+			return auth, nil
+		}
+	}
+	return models.DeviceAuthorization{}, storage.ErrDeviceCodeNotFound
+}
+
+func (s *fakeStorage) ResolveDeviceAuthorization(ctx context.Context, userCode string, userId int64, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, auth := range s.auths {
+		if auth.UserCode == userCode && auth.Status == models.DeviceStatusPending && time.Now().Before(auth.ExpiresAt) {
+			auth.UserId = userId
+			auth.Status = status
+			s.auths[key] = auth
+			return nil
+		}
+	}
+	return storage.ErrDeviceCodeNotFound
+}
+
+func (s *fakeStorage) UpdateDevicePoll(ctx context.Context, deviceCodeHash []byte, polledAt time.Time, interval time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	auth, ok := s.auths[string(deviceCodeHash)]
+	if !ok {
+		return storage.ErrDeviceCodeNotFound
+	}
+	auth.LastPolledAt = polledAt
+	auth.Interval = interval
+	s.auths[string(deviceCodeHash)] = auth
+	return nil
+}
+
+func (s *fakeStorage) ConsumeDeviceAuthorization(ctx context.Context, deviceCodeHash []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	auth, ok := s.auths[string(deviceCodeHash)]
+	if !ok || auth.Status != models.DeviceStatusApproved {
+		return storage.ErrDeviceCodeNotFound
+	}
+	auth.Status = models.DeviceStatusConsumed
+	s.auths[string(deviceCodeHash)] = auth
+	return nil
+}
+
+func (s *fakeStorage) GetUserById(ctx context.Context, org int64, id int64) (models.User, error) {
+	if org != orgId || id != userId {
+		return models.User{}, storage.ErrUserNotFound
+	}
+	return models.User{Id: userId, OrgId: orgId, Email: "device@gmail.com"}, nil
+}
+
+func (s *fakeStorage) GetApp(ctx context.Context, id int) (models.App, error) {
+	if id != appId {
+		return models.App{}, storage.ErrAppNotFound
+	}
+	return models.App{Id: appId, OrgId: orgId, Name: "test", Secret: "test-secret"}, nil
+}
@@ -0,0 +1,173 @@
+package impersonation
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sso/interanal/domain/models"
+	"sso/interanal/storage"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	appId     = 1
+	appSecret = "test-secret"
+	orgId     = models.DefaultOrgId
+	adminId   = 1
+	userId    = 2
+	otherId   = 3
+	ownerId   = 4
+	orgAdmId  = 5
+)
+
+// TestImpersonate проверяет, что админ получает токен
+// пользователя с claim act и в журнал пишется событие.
+func TestImpersonate(t *testing.T) {
+	s, st := newTestService(t)
+
+	token, err := s.Impersonate(context.Background(), orgId, adminId, userId, appId, "  ticket #42  ")
+	require.NoError(t, err)
+
+	parsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		return []byte(appSecret), nil
+	})
+	require.NoError(t, err)
+	claims := parsed.Claims.(jwt.MapClaims)
+	assert.Equal(t, userId, int(claims["uid"].(float64)))
+	assert.Equal(t, orgId, int64(claims["org_id"].(float64)))
+	act := claims["act"].(map[string]any)
+	assert.Equal(t, "1", act["sub"])
+	assert.Equal(t, "admin@gmail.com", act["email"])
+
+	require.Len(t, st.events, 1)
+	assert.Equal(t, models.AuditEvent{
+		OrgId:        orgId,
+		ActorId:      adminId,
+		TargetUserId: userId,
+		AppId:        appId,
+		Action:       models.AuditActionImpersonate,
+		Reason:       "ticket #42",
+	}, st.events[0])
+}
+
+// TestNonAdminCannotImpersonate проверяет, что обычный
+// пользователь не может выдать себе чужой токен.
+func TestNonAdminCannotImpersonate(t *testing.T) {
+	s, st := newTestService(t)
+
+	_, err := s.Impersonate(context.Background(), orgId, otherId, userId, appId, "curious")
+
+	assert.ErrorIs(t, err, ErrPermissionDenied)
+	assert.Empty(t, st.events)
+}
+
+// TestOrgAdminsImpersonate проверяет, что владелец и админ
+// организации могут имперсонировать участников, но не друг друга.
+func TestOrgAdminsImpersonate(t *testing.T) {
+	s, st := newTestService(t)
+	ctx := context.Background()
+
+	_, err := s.Impersonate(ctx, orgId, ownerId, userId, appId, "support")
+	require.NoError(t, err)
+	_, err = s.Impersonate(ctx, orgId, orgAdmId, userId, appId, "support")
+	require.NoError(t, err)
+	require.Len(t, st.events, 2)
+
+	_, err = s.Impersonate(ctx, orgId, orgAdmId, ownerId, appId, "support")
+	assert.ErrorIs(t, err, ErrCannotImpersonateAdmin)
+	_, err = s.Impersonate(ctx, orgId, ownerId, orgAdmId, appId, "support")
+	assert.ErrorIs(t, err, ErrCannotImpersonateAdmin)
+	assert.Len(t, st.events, 2)
+}
+
+// TestCannotImpersonateAdmin проверяет, что админа
+// нельзя имперсонировать.
+func TestCannotImpersonateAdmin(t *testing.T) {
+	s, st := newTestService(t)
+
+	_, err := s.Impersonate(context.Background(), orgId, adminId, adminId, appId, "debug")
+
+	assert.ErrorIs(t, err, ErrCannotImpersonateAdmin)
+	assert.Empty(t, st.events)
+}
+
+// TestImpersonateRequiresReason проверяет, что без
+// причины токен не выдается.
+func TestImpersonateRequiresReason(t *testing.T) {
+	s, st := newTestService(t)
+
+	_, err := s.Impersonate(context.Background(), orgId, adminId, userId, appId, " ")
+
+	assert.ErrorIs(t, err, ErrReasonRequired)
+	assert.Empty(t, st.events)
+}
+
+func newTestService(t *testing.T) (*ImpersonationService, *fakeStorage) {
+	t.Helper()
+	st := &fakeStorage{
+		users: map[int64]models.User{
+			adminId:  {Id: adminId, OrgId: orgId, Email: "admin@gmail.com"},
+			userId:   {Id: userId, OrgId: orgId, Email: "user@gmail.com"},
+			otherId:  {Id: otherId, OrgId: orgId, Email: "other@gmail.com"},
+			ownerId:  {Id: ownerId, OrgId: orgId, Email: "owner@gmail.com"},
+			orgAdmId: {Id: orgAdmId, OrgId: orgId, Email: "org-admin@gmail.com"},
+		},
+		admins: map[int64]bool{adminId: true},
+		roles: map[int64]string{
+			userId:   models.RoleMember,
+			otherId:  models.RoleMember,
+			ownerId:  models.RoleOwner,
+			orgAdmId: models.RoleAdmin,
+		},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return New(logger, st, st, st, st, time.Minute), st
+}
+
+type fakeStorage struct {
+	mu     sync.Mutex
+	users  map[int64]models.User
+	admins map[int64]bool
+	roles  map[int64]string
+	events []models.AuditEvent
+}
+
+func (s *fakeStorage) GetUserById(ctx context.Context, org int64, id int64) (models.User, error) {
+	user, ok := s.users[id]
+	if !ok || user.OrgId != org {
+		return models.User{}, storage.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *fakeStorage) IsAdmin(ctx context.Context, org int64, id int64) (bool, error) {
+	return org == orgId && s.admins[id], nil
+}
+
+func (s *fakeStorage) MemberRole(ctx context.Context, org int64, id int64) (string, error) {
+	role, ok := s.roles[id]
+	if !ok || org != orgId {
+		return "", storage.ErrUserNotFound
+	}
+	return role, nil
+}
+
+func (s *fakeStorage) GetApp(ctx context.Context, id int) (models.App, error) {
+	if id != appId {
+		return models.App{}, storage.ErrAppNotFound
+	}
+	return models.App{Id: appId, OrgId: orgId, Name: "test", Secret: appSecret}, nil
+}
+
+func (s *fakeStorage) SaveAuditEvent(ctx context.Context, event models.AuditEvent) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return int64(len(s.events)), nil
+}
@@ -0,0 +1,258 @@
+package invite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/interanal/domain/models"
+	"sso/interanal/metrics"
+	"sso/interanal/storage"
+	"sso/lib/secure"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const inviteTokenBytes = 32
+
+var (
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrInvalidRole      = errors.New("invalid role")
+	ErrInvalidInvite    = errors.New("invite is invalid or expired")
+	ErrPasswordRequired = errors.New("password is required")
+	ErrOrgNotFound      = errors.New("organization not found")
+)
+
+type InviteService struct {
+	logger         *slog.Logger
+	transactor     Transactor
+	inviteSaver    InviteSaver
+	inviteConsumer InviteConsumer
+	userSaver      UserSaver
+	userProvider   UserProvider
+	memberProvider MemberProvider
+	inviteTTL      time.Duration
+}
+
+// Transactor runs fn in a storage transaction that the storage
+// methods called with the ctx of fn join.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type InviteSaver interface {
+	SaveInvite(ctx context.Context, invite models.Invite, tokenHash []byte) (inviteId int64, err error)
+}
+
+type InviteConsumer interface {
+	GetActiveInvite(ctx context.Context, tokenHash []byte) (models.Invite, error)
+	ConsumeInvite(ctx context.Context, tokenHash []byte) (models.Invite, error)
+}
+
+type UserSaver interface {
+	SaveUser(
+		ctx context.Context,
+		orgId int64,
+		email string,
+		passwordHash []byte,
+	) (userId int64, err error)
+}
+
+type UserProvider interface {
+	GetUser(ctx context.Context, orgId int64, email string) (models.User, error)
+	IsAdmin(ctx context.Context, orgId int64, userId int64) (bool, error)
+}
+
+type MemberProvider interface {
+	MemberRole(ctx context.Context, orgId int64, userId int64) (string, error)
+	SetMemberRole(ctx context.Context, orgId int64, userId int64, role string) error
+}
+
+func New(
+	logger *slog.Logger,
+	transactor Transactor,
+	inviteSaver InviteSaver,
+	inviteConsumer InviteConsumer,
+	userSaver UserSaver,
+	userProvider UserProvider,
+	memberProvider MemberProvider,
+	inviteTTL time.Duration,
+) *InviteService {
+	return &InviteService{
+		logger:         logger,
+		transactor:     transactor,
+		inviteSaver:    inviteSaver,
+		inviteConsumer: inviteConsumer,
+		userSaver:      userSaver,
+		userProvider:   userProvider,
+		memberProvider: memberProvider,
+		inviteTTL:      inviteTTL,
+	}
+}
+
+// CreateInvite returns the raw invite token. Only its hash is stored,
+// so the token cannot be recovered later.
+func (s *InviteService) CreateInvite(
+	ctx context.Context,
+	orgId int64,
+	inviterId int64,
+	email string,
+	role string,
+) (string, error) {
+	const op = "service.invite.CreateInvite"
+	logger := s.logger.With(slog.String("op", op), slog.Int64("org_id", orgId), slog.Int64("inviter_id", inviterId))
+	logger.InfoContext(ctx, "create invite")
+
+	if !models.IsValidRole(role) {
+		logger.WarnContext(ctx, "invalid role", slog.String("role", role))
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidRole)
+	}
+	allowed, err := s.canInvite(ctx, orgId, inviterId)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to check inviter", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if !allowed {
+		logger.WarnContext(ctx, "inviter is not an org admin")
+		return "", fmt.Errorf("%s: %w", op, ErrPermissionDenied)
+	}
+
+	token, err := secure.RandomToken(inviteTokenBytes)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to generate invite token", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	invite := models.Invite{
+		OrgId:     orgId,
+		Email:     email,
+		Role:      role,
+		CreatedBy: inviterId,
+		ExpiresAt: time.Now().Add(s.inviteTTL),
+	}
+	inviteId, err := s.inviteSaver.SaveInvite(ctx, invite, secure.HashToken(token))
+	if errors.Is(err, storage.ErrOrgNotFound) {
+		logger.WarnContext(ctx, "organization not found")
+		return "", fmt.Errorf("%s: %w", op, ErrOrgNotFound)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to save invite", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	logger.InfoContext(ctx, "invite created", slog.Int64("invite_id", inviteId))
+	return token, nil
+}
+
+// AcceptInvite creates the invited user or, if the email is already
+// registered in the organization, links the existing user with the invite role.
+// The password is checked before the invite is consumed, and the invite
+// is consumed in the same transaction as the user is saved, so it stays
+// valid if the user can't be created.
+func (s *InviteService) AcceptInvite(ctx context.Context, token string, password string) (int64, error) {
+	const op = "service.invite.AcceptInvite"
+	logger := s.logger.With(slog.String("op", op))
+	logger.InfoContext(ctx, "accept invite")
+
+	var userId int64
+	err := s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		userId, err = s.acceptInvite(ctx, logger, token, password)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return userId, nil
+}
+
+func (s *InviteService) acceptInvite(ctx context.Context, logger *slog.Logger, token string, password string) (int64, error) {
+	tokenHash := secure.HashToken(token)
+	invite, err := s.inviteConsumer.GetActiveInvite(ctx, tokenHash)
+	if errors.Is(err, storage.ErrInviteNotFound) {
+		logger.WarnContext(ctx, "invite not found")
+		return 0, ErrInvalidInvite
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get invite", slog.String("err", err.Error()))
+		return 0, err
+	}
+	logger = logger.With(slog.Int64("invite_id", invite.Id), slog.Int64("org_id", invite.OrgId))
+
+	user, err := s.userProvider.GetUser(ctx, invite.OrgId, invite.Email)
+	userExists := err == nil
+	if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+		logger.ErrorContext(ctx, "failed to get user", slog.String("err", err.Error()))
+		return 0, err
+	}
+	var passwordHash []byte
+	if !userExists {
+		if password == "" {
+			logger.WarnContext(ctx, "password is required for a new user")
+			return 0, ErrPasswordRequired
+		}
+		start := time.Now()
+		passwordHash, err = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		metrics.ObserveBcrypt(metrics.BcryptHash, start)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to generate password hash", slog.String("err", err.Error()))
+			return 0, err
+		}
+	}
+
+	// Consuming is what makes the token single-use: of concurrent
+	// accepts that all found the invite active, only one gets here.
+	if _, err := s.inviteConsumer.ConsumeInvite(ctx, tokenHash); err != nil {
+		if errors.Is(err, storage.ErrInviteNotFound) {
+			logger.WarnContext(ctx, "invite already accepted")
+			return 0, ErrInvalidInvite
+		}
+		logger.ErrorContext(ctx, "failed to consume invite", slog.String("err", err.Error()))
+		return 0, err
+	}
+
+	if userExists {
+		logger.InfoContext(ctx, "link existing user", slog.Int64("user_id", user.Id))
+		if err := s.memberProvider.SetMemberRole(ctx, invite.OrgId, user.Id, invite.Role); err != nil {
+			logger.ErrorContext(ctx, "failed to set member role", slog.String("err", err.Error()))
+			return 0, err
+		}
+		return user.Id, nil
+	}
+	userId, err := s.userSaver.SaveUser(ctx, invite.OrgId, invite.Email, passwordHash)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to save user", slog.String("err", err.Error()))
+		return 0, err
+	}
+	if invite.Role != models.RoleMember {
+		if err := s.memberProvider.SetMemberRole(ctx, invite.OrgId, userId, invite.Role); err != nil {
+			logger.ErrorContext(ctx, "failed to set member role", slog.String("err", err.Error()))
+			return 0, err
+		}
+	}
+	logger.InfoContext(ctx, "invited user created", slog.Int64("user_id", userId))
+	return userId, nil
+}
+
+// canInvite reports whether the user is a global admin or has the
+// owner or admin membership role in the organization, like the
+// admins that may impersonate.
+func (s *InviteService) canInvite(ctx context.Context, orgId int64, userId int64) (bool, error) {
+	isAdmin, err := s.userProvider.IsAdmin(ctx, orgId, userId)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if isAdmin {
+		return true, nil
+	}
+	role, err := s.memberProvider.MemberRole(ctx, orgId, userId)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return role == models.RoleOwner || role == models.RoleAdmin, nil
+}
@@ -0,0 +1,89 @@
+package invite
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sso/interanal/domain/models"
+	"sso/interanal/storage/memory"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAcceptInviteCreatesUserWithRole проверяет, что по
+// приглашению владельца создается пользователь с ролью из приглашения.
+func TestAcceptInviteCreatesUserWithRole(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService(t)
+	ownerId := saveMember(t, st, "owner@gmail.com", models.RoleOwner)
+
+	token, err := s.CreateInvite(ctx, models.DefaultOrgId, ownerId, "new@gmail.com", models.RoleAdmin)
+	require.NoError(t, err)
+	userId, err := s.AcceptInvite(ctx, token, "password")
+	require.NoError(t, err)
+
+	role, err := st.MemberRole(ctx, models.DefaultOrgId, userId)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleAdmin, role)
+	_, err = s.AcceptInvite(ctx, token, "password")
+	assert.ErrorIs(t, err, ErrInvalidInvite)
+}
+
+// TestFailedAcceptKeepsInvite проверяет, что приглашение
+// остается действительным, если пользователя не удалось создать.
+func TestFailedAcceptKeepsInvite(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService(t)
+	ownerId := saveMember(t, st, "owner@gmail.com", models.RoleOwner)
+	token, err := s.CreateInvite(ctx, models.DefaultOrgId, ownerId, "new@gmail.com", models.RoleMember)
+	require.NoError(t, err)
+
+	_, err = s.AcceptInvite(ctx, token, "")
+	require.ErrorIs(t, err, ErrPasswordRequired)
+
+	_, err = s.AcceptInvite(ctx, token, "password")
+	assert.NoError(t, err)
+}
+
+// TestMemberCannotInvite проверяет, что обычный
+// участник не может приглашать.
+func TestMemberCannotInvite(t *testing.T) {
+	s, st := newTestService(t)
+	memberId := saveMember(t, st, "member@gmail.com", models.RoleMember)
+
+	_, err := s.CreateInvite(context.Background(), models.DefaultOrgId, memberId, "new@gmail.com", models.RoleMember)
+
+	assert.ErrorIs(t, err, ErrPermissionDenied)
+}
+
+// TestOrgAdminCanInvite проверяет, что приглашать могут
+// владелец и админ организации.
+func TestOrgAdminCanInvite(t *testing.T) {
+	s, st := newTestService(t)
+	for _, role := range []string{models.RoleOwner, models.RoleAdmin} {
+		inviterId := saveMember(t, st, role+"@gmail.com", role)
+
+		_, err := s.CreateInvite(context.Background(), models.DefaultOrgId, inviterId, "new-"+role+"@gmail.com", models.RoleMember)
+
+		assert.NoError(t, err, role)
+	}
+}
+
+func newTestService(t *testing.T) (*InviteService, *memory.Storage) {
+	t.Helper()
+	st := memory.New()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return New(logger, st, st, st, st, st, st, time.Hour), st
+}
+
+func saveMember(t *testing.T, st *memory.Storage, email string, role string) int64 {
+	t.Helper()
+	ctx := context.Background()
+	id, err := st.SaveUser(ctx, models.DefaultOrgId, email, []byte("hash"))
+	require.NoError(t, err)
+	require.NoError(t, st.SetMemberRole(ctx, models.DefaultOrgId, id, role))
+	return id
+}
@@ -0,0 +1,490 @@
+package passkey
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/interanal/domain/models"
+	"sso/interanal/storage"
+	ssojwt "sso/lib/jwt"
+	"sso/lib/secure"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const sessionTokenBytes = 32
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrAppNotFound        = errors.New("app not found")
+	ErrInvalidSession     = errors.New("session is invalid or expired")
+	ErrInvalidResponse    = errors.New("invalid authenticator response")
+	ErrCredentialExists   = errors.New("credential already registered")
+	ErrCredentialNotFound = errors.New("credential not found")
+	ErrNoCredentials      = errors.New("user has no passkeys")
+	ErrCloneDetected      = errors.New("authenticator sign counter went backwards")
+)
+
+type Options struct {
+	RPID          string
+	RPDisplayName string
+	RPOrigins     []string
+	SessionTTL    time.Duration
+	TokenTTL      time.Duration
+}
+
+type PasskeyService struct {
+	logger       *slog.Logger
+	webAuthn     *webauthn.WebAuthn
+	credentials  CredentialStorage
+	sessions     SessionStorage
+	userProvider UserProvider
+	appProvider  AppProvider
+	opts         Options
+	// tokenTTL starts as opts.TokenTTL and changes on config reload.
+	tokenTTL atomic.Int64
+}
+
+type CredentialStorage interface {
+	SaveWebAuthnCredential(ctx context.Context, cred models.WebAuthnCredential) error
+	ListWebAuthnCredentials(ctx context.Context, userId int64) ([]models.WebAuthnCredential, error)
+	UpdateWebAuthnSignCount(ctx context.Context, credentialId []byte, signCount uint32, cloneWarning bool) error
+	DeleteWebAuthnCredential(ctx context.Context, userId int64, credentialId []byte) error
+}
+
+type SessionStorage interface {
+	SaveWebAuthnSession(ctx context.Context, sessionHash []byte, session models.WebAuthnSession) error
+	ConsumeWebAuthnSession(ctx context.Context, sessionHash []byte, ceremony string) (models.WebAuthnSession, error)
+}
+
+type UserProvider interface {
+	GetUser(ctx context.Context, orgId int64, email string) (models.User, error)
+	GetUserById(ctx context.Context, orgId int64, userId int64) (models.User, error)
+}
+
+type AppProvider interface {
+	GetApp(ctx context.Context, appId int) (models.App, error)
+}
+
+func New(
+	logger *slog.Logger,
+	credentials CredentialStorage,
+	sessions SessionStorage,
+	userProvider UserProvider,
+	appProvider AppProvider,
+	opts Options,
+) (*PasskeyService, error) {
+	const op = "service.passkey.New"
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          opts.RPID,
+		RPDisplayName: opts.RPDisplayName,
+		RPOrigins:     opts.RPOrigins,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	p := &PasskeyService{
+		logger:       logger,
+		webAuthn:     w,
+		credentials:  credentials,
+		sessions:     sessions,
+		userProvider: userProvider,
+		appProvider:  appProvider,
+		opts:         opts,
+	}
+	p.SetTokenTTL(opts.TokenTTL)
+	return p, nil
+}
+
+// SetTokenTTL changes the lifetime of tokens issued by later logins.
+func (p *PasskeyService) SetTokenTTL(ttl time.Duration) {
+	p.tokenTTL.Store(int64(ttl))
+}
+
+// BeginRegistration starts a registration ceremony for an authenticated user.
+// It returns the session token to pass to FinishRegistration and the
+// PublicKeyCredentialCreationOptions as JSON for navigator.credentials.create.
+func (p *PasskeyService) BeginRegistration(ctx context.Context, orgId int64, userId int64) (string, []byte, error) {
+	const op = "service.passkey.BeginRegistration"
+	logger := p.logger.With(slog.String("op", op), slog.Int64("org_id", orgId), slog.Int64("user_id", userId))
+	logger.InfoContext(ctx, "begin passkey registration")
+
+	user, err := p.userProvider.GetUserById(ctx, orgId, userId)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		logger.WarnContext(ctx, "user not found")
+		return "", nil, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get user", slog.String("err", err.Error()))
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+	waUser, err := p.loadUser(ctx, user)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to list credentials", slog.String("err", err.Error()))
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var exclusions []protocol.CredentialDescriptor
+	for _, cred := range waUser.credentials {
+		exclusions = append(exclusions, cred.Descriptor())
+	}
+	creation, session, err := p.webAuthn.BeginRegistration(waUser, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to begin registration", slog.String("err", err.Error()))
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+	token, options, err := p.saveSession(ctx, user.Id, 0, models.CeremonyRegistration, session, creation)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to save session", slog.String("err", err.Error()))
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return token, options, nil
+}
+
+// FinishRegistration verifies the attestation response and stores the new credential.
+func (p *PasskeyService) FinishRegistration(ctx context.Context, orgId int64, sessionToken string, response []byte) ([]byte, error) {
+	const op = "service.passkey.FinishRegistration"
+	logger := p.logger.With(slog.String("op", op), slog.Int64("org_id", orgId))
+	logger.InfoContext(ctx, "finish passkey registration")
+
+	session, data, err := p.consumeSession(ctx, sessionToken, models.CeremonyRegistration)
+	if err != nil {
+		logger.WarnContext(ctx, "failed to consume session", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	user, err := p.userProvider.GetUserById(ctx, orgId, session.UserId)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		logger.WarnContext(ctx, "user not found")
+		return nil, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get user", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(response))
+	if err != nil {
+		logger.WarnContext(ctx, "failed to parse attestation", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidResponse)
+	}
+	cred, err := p.webAuthn.CreateCredential(&webAuthnUser{user: user}, data, parsed)
+	if err != nil {
+		logger.WarnContext(ctx, "attestation verification failed", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidResponse)
+	}
+
+	err = p.credentials.SaveWebAuthnCredential(ctx, toModel(user.Id, cred))
+	if errors.Is(err, storage.ErrCredentialExists) {
+		logger.WarnContext(ctx, "credential already registered")
+		return nil, fmt.Errorf("%s: %w", op, ErrCredentialExists)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to save credential", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	logger.InfoContext(ctx, "passkey registered", slog.Int64("user_id", user.Id))
+	return cred.ID, nil
+}
+
+// BeginLogin starts an assertion ceremony and returns the session token and
+// the PublicKeyCredentialRequestOptions as JSON for navigator.credentials.get.
+func (p *PasskeyService) BeginLogin(ctx context.Context, email string, appId int) (string, []byte, error) {
+	const op = "service.passkey.BeginLogin"
+	logger := p.logger.With(slog.String("op", op), slog.Int("app_id", appId))
+	logger.InfoContext(ctx, "begin passkey login")
+
+	app, err := p.appProvider.GetApp(ctx, appId)
+	if errors.Is(err, storage.ErrAppNotFound) {
+		logger.WarnContext(ctx, "app not found")
+		return "", nil, fmt.Errorf("%s: %w", op, ErrAppNotFound)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get app", slog.String("err", err.Error()))
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+	user, err := p.userProvider.GetUser(ctx, app.OrgId, email)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		logger.WarnContext(ctx, "user not found")
+		return "", nil, fmt.Errorf("%s: %w", op, ErrNoCredentials)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get user", slog.String("err", err.Error()))
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+	waUser, err := p.loadUser(ctx, user)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to list credentials", slog.String("err", err.Error()))
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(waUser.credentials) == 0 {
+		logger.WarnContext(ctx, "user has no passkeys")
+		return "", nil, fmt.Errorf("%s: %w", op, ErrNoCredentials)
+	}
+
+	assertion, session, err := p.webAuthn.BeginLogin(waUser)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to begin login", slog.String("err", err.Error()))
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+	token, options, err := p.saveSession(ctx, user.Id, appId, models.CeremonyLogin, session, assertion)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to save session", slog.String("err", err.Error()))
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return token, options, nil
+}
+
+// FinishLogin verifies the assertion and returns the same token as Login.
+func (p *PasskeyService) FinishLogin(ctx context.Context, sessionToken string, response []byte) (string, error) {
+	const op = "service.passkey.FinishLogin"
+	logger := p.logger.With(slog.String("op", op))
+	logger.InfoContext(ctx, "finish passkey login")
+
+	session, data, err := p.consumeSession(ctx, sessionToken, models.CeremonyLogin)
+	if err != nil {
+		logger.WarnContext(ctx, "failed to consume session", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	logger = logger.With(slog.Int("app_id", session.AppId), slog.Int64("user_id", session.UserId))
+
+	app, err := p.appProvider.GetApp(ctx, session.AppId)
+	if errors.Is(err, storage.ErrAppNotFound) {
+		logger.WarnContext(ctx, "app not found")
+		return "", fmt.Errorf("%s: %w", op, ErrAppNotFound)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get app", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	user, err := p.userProvider.GetUserById(ctx, app.OrgId, session.UserId)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		logger.WarnContext(ctx, "user not found")
+		return "", fmt.Errorf("%s: %w", op, ErrUserNotFound)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get user", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	waUser, err := p.loadUser(ctx, user)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to list credentials", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(response))
+	if err != nil {
+		logger.WarnContext(ctx, "failed to parse assertion", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidResponse)
+	}
+	cred, err := p.webAuthn.ValidateLogin(waUser, data, parsed)
+	if err != nil {
+		logger.WarnContext(ctx, "assertion verification failed", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidResponse)
+	}
+	err = p.credentials.UpdateWebAuthnSignCount(ctx, cred.ID, cred.Authenticator.SignCount, cred.Authenticator.CloneWarning)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to update sign count", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if cred.Authenticator.CloneWarning {
+		logger.WarnContext(ctx, "possible cloned authenticator")
+		return "", fmt.Errorf("%s: %w", op, ErrCloneDetected)
+	}
+
+	token, err := ssojwt.NewToken(user, app, time.Duration(p.tokenTTL.Load()))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to generate token", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	logger.InfoContext(ctx, "user logged successfully")
+	return token, nil
+}
+
+func (p *PasskeyService) ListCredentials(ctx context.Context, orgId int64, userId int64) ([]models.WebAuthnCredential, error) {
+	const op = "service.passkey.ListCredentials"
+	logger := p.logger.With(slog.String("op", op), slog.Int64("org_id", orgId), slog.Int64("user_id", userId))
+
+	if _, err := p.userProvider.GetUserById(ctx, orgId, userId); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			logger.WarnContext(ctx, "user not found")
+			return nil, fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		logger.ErrorContext(ctx, "failed to get user", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	creds, err := p.credentials.ListWebAuthnCredentials(ctx, userId)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to list credentials", slog.String("err", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return creds, nil
+}
+
+func (p *PasskeyService) RemoveCredential(ctx context.Context, orgId int64, userId int64, credentialId []byte) error {
+	const op = "service.passkey.RemoveCredential"
+	logger := p.logger.With(slog.String("op", op), slog.Int64("org_id", orgId), slog.Int64("user_id", userId))
+	logger.InfoContext(ctx, "remove passkey")
+
+	if _, err := p.userProvider.GetUserById(ctx, orgId, userId); err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			logger.WarnContext(ctx, "user not found")
+			return fmt.Errorf("%s: %w", op, ErrUserNotFound)
+		}
+		logger.ErrorContext(ctx, "failed to get user", slog.String("err", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	err := p.credentials.DeleteWebAuthnCredential(ctx, userId, credentialId)
+	if errors.Is(err, storage.ErrCredentialNotFound) {
+		logger.WarnContext(ctx, "credential not found")
+		return fmt.Errorf("%s: %w", op, ErrCredentialNotFound)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to delete credential", slog.String("err", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	logger.InfoContext(ctx, "passkey removed")
+	return nil
+}
+
+func (p *PasskeyService) loadUser(ctx context.Context, user models.User) (*webAuthnUser, error) {
+	creds, err := p.credentials.ListWebAuthnCredentials(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	waUser := &webAuthnUser{user: user}
+	for _, cred := range creds {
+		waUser.credentials = append(waUser.credentials, fromModel(cred))
+	}
+	return waUser, nil
+}
+
+func (p *PasskeyService) saveSession(
+	ctx context.Context,
+	userId int64,
+	appId int,
+	ceremony string,
+	session *webauthn.SessionData,
+	options any,
+) (string, []byte, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", nil, err
+	}
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return "", nil, err
+	}
+	token, err := secure.RandomToken(sessionTokenBytes)
+	if err != nil {
+		return "", nil, err
+	}
+	err = p.sessions.SaveWebAuthnSession(ctx, secure.HashToken(token), models.WebAuthnSession{
+		UserId:    userId,
+		AppId:     appId,
+		Ceremony:  ceremony,
+		Data:      data,
+		ExpiresAt: time.Now().Add(p.opts.SessionTTL),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return token, optionsJSON, nil
+}
+
+func (p *PasskeyService) consumeSession(
+	ctx context.Context,
+	token string,
+	ceremony string,
+) (models.WebAuthnSession, webauthn.SessionData, error) {
+	session, err := p.sessions.ConsumeWebAuthnSession(ctx, secure.HashToken(token), ceremony)
+	if errors.Is(err, storage.ErrSessionNotFound) {
+		return models.WebAuthnSession{}, webauthn.SessionData{}, ErrInvalidSession
+	}
+	if err != nil {
+		return models.WebAuthnSession{}, webauthn.SessionData{}, err
+	}
+	var data webauthn.SessionData
+	if err := json.Unmarshal(session.Data, &data); err != nil {
+		return models.WebAuthnSession{}, webauthn.SessionData{}, err
+	}
+	return session, data, nil
+}
+
+type webAuthnUser struct {
+	user        models.User
+	credentials []webauthn.Credential
+}
+
+// WebAuthnID is the user handle. It is derived from the user id, so it is
+// stable and does not expose the email.
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return userHandle(u.user.Id)
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+func (u *webAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func userHandle(userId int64) []byte {
+	handle := make([]byte, 8)
+	binary.BigEndian.PutUint64(handle, uint64(userId))
+	return handle
+}
+
+func toModel(userId int64, cred *webauthn.Credential) models.WebAuthnCredential {
+	transports := make([]string, 0, len(cred.Transport))
+	for _, t := range cred.Transport {
+		transports = append(transports, string(t))
+	}
+	return models.WebAuthnCredential{
+		Id:              cred.ID,
+		UserId:          userId,
+		PublicKey:       cred.PublicKey,
+		AttestationType: cred.AttestationType,
+		AAGUID:          cred.Authenticator.AAGUID,
+		SignCount:       cred.Authenticator.SignCount,
+		Transports:      transports,
+		BackupEligible:  cred.Flags.BackupEligible,
+		BackupState:     cred.Flags.BackupState,
+	}
+}
+
+func fromModel(cred models.WebAuthnCredential) webauthn.Credential {
+	transports := make([]protocol.AuthenticatorTransport, 0, len(cred.Transports))
+	for _, t := range cred.Transports {
+		transports = append(transports, protocol.AuthenticatorTransport(t))
+	}
+	return webauthn.Credential{
+		ID:              cred.Id,
+		PublicKey:       cred.PublicKey,
+		AttestationType: cred.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			BackupEligible: cred.BackupEligible,
+			BackupState:    cred.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:       cred.AAGUID,
+			SignCount:    cred.SignCount,
+			CloneWarning: cred.CloneWarning,
+		},
+	}
+}
@@ -0,0 +1,266 @@
+package passkey
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sso/interanal/domain/models"
+	"sso/interanal/storage"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	rpId      = "localhost"
+	rpOrigin  = "http://localhost"
+	appId     = 1
+	appSecret = "test-secret"
+	orgId     = models.DefaultOrgId
+)
+
+// TestRegisterAndLoginWithPasskey проверяет, что
+// пользователь регистрирует ключ и входит с ним,
+// получая тот же jwt, что и при Login.
+func TestRegisterAndLoginWithPasskey(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService(t)
+	user := st.addUser("TestRegisterAndLoginWithPasskey@gmail.com")
+	authenticator := newSoftAuthenticator(t, rpId, rpOrigin)
+
+	sessionToken, options, err := s.BeginRegistration(ctx, orgId, user.Id)
+	require.NoError(t, err)
+	credentialId, err := s.FinishRegistration(ctx, orgId, sessionToken, authenticator.register(options))
+	require.NoError(t, err)
+	assert.Equal(t, authenticator.credentialId, credentialId)
+
+	creds, err := s.ListCredentials(ctx, orgId, user.Id)
+	require.NoError(t, err)
+	require.Len(t, creds, 1)
+	assert.Equal(t, []string{"internal"}, creds[0].Transports)
+	assert.Equal(t, "none", creds[0].AttestationType)
+
+	sessionToken, options, err = s.BeginLogin(ctx, user.Email, appId)
+	require.NoError(t, err)
+	token, err := s.FinishLogin(ctx, sessionToken, authenticator.login(options, userHandle(user.Id)))
+	require.NoError(t, err)
+
+	parsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		return []byte(appSecret), nil
+	})
+	require.NoError(t, err)
+	claims := parsed.Claims.(jwt.MapClaims)
+	assert.Equal(t, user.Id, int64(claims["uid"].(float64)))
+	assert.Equal(t, orgId, int64(claims["org_id"].(float64)))
+	assert.Equal(t, uint32(1), st.credentials[string(credentialId)].SignCount)
+}
+
+// TestCannotFinishCeremonyTwice проверяет, что
+// сессия церемонии одноразовая.
+func TestCannotFinishCeremonyTwice(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService(t)
+	user := st.addUser("TestCannotFinishCeremonyTwice@gmail.com")
+	authenticator := newSoftAuthenticator(t, rpId, rpOrigin)
+	sessionToken, options, err := s.BeginRegistration(ctx, orgId, user.Id)
+	require.NoError(t, err)
+	response := authenticator.register(options)
+
+	_, err = s.FinishRegistration(ctx, orgId, sessionToken, response)
+	require.NoError(t, err)
+	_, err = s.FinishRegistration(ctx, orgId, sessionToken, response)
+
+	assert.ErrorIs(t, err, ErrInvalidSession)
+}
+
+// TestCannotRegisterPasskeyFromAnotherOrigin проверяет, что
+// ответ аутентификатора с чужого origin отклоняется.
+func TestCannotRegisterPasskeyFromAnotherOrigin(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService(t)
+	user := st.addUser("TestCannotRegisterPasskeyFromAnotherOrigin@gmail.com")
+	authenticator := newSoftAuthenticator(t, rpId, "https://evil.example")
+	sessionToken, options, err := s.BeginRegistration(ctx, orgId, user.Id)
+	require.NoError(t, err)
+
+	_, err = s.FinishRegistration(ctx, orgId, sessionToken, authenticator.register(options))
+
+	assert.ErrorIs(t, err, ErrInvalidResponse)
+	assert.Empty(t, st.credentials)
+}
+
+// TestClonedAuthenticatorIsRejected проверяет, что
+// вход отклоняется, если счетчик подписей не вырос.
+func TestClonedAuthenticatorIsRejected(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService(t)
+	user := st.addUser("TestClonedAuthenticatorIsRejected@gmail.com")
+	authenticator := newSoftAuthenticator(t, rpId, rpOrigin)
+	sessionToken, options, err := s.BeginRegistration(ctx, orgId, user.Id)
+	require.NoError(t, err)
+	_, err = s.FinishRegistration(ctx, orgId, sessionToken, authenticator.register(options))
+	require.NoError(t, err)
+	for range 2 {
+		sessionToken, options, err = s.BeginLogin(ctx, user.Email, appId)
+		require.NoError(t, err)
+		_, err = s.FinishLogin(ctx, sessionToken, authenticator.login(options, userHandle(user.Id)))
+		require.NoError(t, err)
+	}
+
+	authenticator.signCount = 0
+	sessionToken, options, err = s.BeginLogin(ctx, user.Email, appId)
+	require.NoError(t, err)
+	_, err = s.FinishLogin(ctx, sessionToken, authenticator.login(options, userHandle(user.Id)))
+
+	assert.ErrorIs(t, err, ErrCloneDetected)
+}
+
+// TestRemoveCredential проверяет, что удаленным ключом
+// войти больше нельзя.
+func TestRemoveCredential(t *testing.T) {
+	ctx := context.Background()
+	s, st := newTestService(t)
+	user := st.addUser("TestRemoveCredential@gmail.com")
+	authenticator := newSoftAuthenticator(t, rpId, rpOrigin)
+	sessionToken, options, err := s.BeginRegistration(ctx, orgId, user.Id)
+	require.NoError(t, err)
+	credentialId, err := s.FinishRegistration(ctx, orgId, sessionToken, authenticator.register(options))
+	require.NoError(t, err)
+
+	require.NoError(t, s.RemoveCredential(ctx, orgId, user.Id, credentialId))
+
+	_, _, err = s.BeginLogin(ctx, user.Email, appId)
+	assert.ErrorIs(t, err, ErrNoCredentials)
+	err = s.RemoveCredential(ctx, orgId, user.Id, credentialId)
+	assert.ErrorIs(t, err, ErrCredentialNotFound)
+}
+
+func newTestService(t *testing.T) (*PasskeyService, *fakeStorage) {
+	t.Helper()
+	st := &fakeStorage{
+		users:       make(map[int64]models.User),
+		credentials: make(map[string]models.WebAuthnCredential),
+		sessions:    make(map[string]models.WebAuthnSession),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s, err := New(logger, st, st, st, st, Options{
+		RPID:          rpId,
+		RPDisplayName: "SSO",
+		RPOrigins:     []string{rpOrigin},
+		SessionTTL:    time.Minute,
+		TokenTTL:      time.Hour,
+	})
+	require.NoError(t, err)
+	return s, st
+}
+
+type fakeStorage struct {
+	mu          sync.Mutex
+	users       map[int64]models.User
+	credentials map[string]models.WebAuthnCredential
+	sessions    map[string]models.WebAuthnSession
+}
+
+func (s *fakeStorage) addUser(email string) models.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user := models.User{Id: int64(len(s.users) + 1), OrgId: orgId, Email: email}
+	s.users[user.Id] = user
+	return user
+}
+
+func (s *fakeStorage) GetUser(ctx context.Context, orgId int64, email string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range s.users {
+		if user.OrgId == orgId && user.Email == email {
+			return user, nil
+		}
+	}
+	return models.User{}, storage.ErrUserNotFound
+}
+
+func (s *fakeStorage) GetUserById(ctx context.Context, orgId int64, userId int64) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[userId]
+	if !ok || user.OrgId != orgId {
+		return models.User{}, storage.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *fakeStorage) GetApp(ctx context.Context, id int) (models.App, error) {
+	if id != appId {
+		return models.App{}, storage.ErrAppNotFound
+	}
+	return models.App{Id: appId, OrgId: orgId, Name: "test", Secret: appSecret}, nil
+}
+
+func (s *fakeStorage) SaveWebAuthnCredential(ctx context.Context, cred models.WebAuthnCredential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.credentials[string(cred.Id)]; ok {
+		return storage.ErrCredentialExists
+	}
+	s.credentials[string(cred.Id)] = cred
+	return nil
+}
+
+func (s *fakeStorage) ListWebAuthnCredentials(ctx context.Context, userId int64) ([]models.WebAuthnCredential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var creds []models.WebAuthnCredential
+	for _, cred := range s.credentials {
+		if cred.UserId == userId {
+			creds = append(creds, cred)
+		}
+	}
+	return creds, nil
+}
+
+func (s *fakeStorage) UpdateWebAuthnSignCount(ctx context.Context, credentialId []byte, signCount uint32, cloneWarning bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cred, ok := s.credentials[string(credentialId)]
+	if !ok {
+		return storage.ErrCredentialNotFound
+	}
+	cred.SignCount = signCount
+	cred.CloneWarning = cloneWarning
+	s.credentials[string(credentialId)] = cred
+	return nil
+}
+
+func (s *fakeStorage) DeleteWebAuthnCredential(ctx context.Context, userId int64, credentialId []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cred, ok := s.credentials[string(credentialId)]
+	if !ok || cred.UserId != userId {
+		return storage.ErrCredentialNotFound
+	}
+	delete(s.credentials, string(credentialId))
+	return nil
+}
+
+func (s *fakeStorage) SaveWebAuthnSession(ctx context.Context, sessionHash []byte, session models.WebAuthnSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[string(sessionHash)] = session
+	return nil
+}
+
+func (s *fakeStorage) ConsumeWebAuthnSession(ctx context.Context, sessionHash []byte, ceremony string) (models.WebAuthnSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[string(sessionHash)]
+	if !ok || session.Ceremony != ceremony || !session.ExpiresAt.After(time.Now()) {
+		return models.WebAuthnSession{}, storage.ErrSessionNotFound
+	}
+	delete(s.sessions, string(sessionHash))
+	return session, nil
+}
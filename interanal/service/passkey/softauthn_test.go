@@ -0,0 +1,147 @@
+package passkey
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+// softAuthenticator is a software fixture that produces WebAuthn
+// responses with the "none" attestation format and an ES256 key.
+type softAuthenticator struct {
+	t            *testing.T
+	rpId         string
+	origin       string
+	credentialId []byte
+	key          *ecdsa.PrivateKey
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T, rpId string, origin string) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	credentialId := make([]byte, 16)
+	_, err = rand.Read(credentialId)
+	require.NoError(t, err)
+	return &softAuthenticator{t: t, rpId: rpId, origin: origin, credentialId: credentialId, key: key}
+}
+
+type creationOptions struct {
+	PublicKey struct {
+		Challenge string `json:"challenge"`
+	} `json:"publicKey"`
+}
+
+type requestOptions struct {
+	PublicKey struct {
+		Challenge string `json:"challenge"`
+	} `json:"publicKey"`
+}
+
+// register answers navigator.credentials.create with the given options JSON.
+func (a *softAuthenticator) register(options []byte) []byte {
+	a.t.Helper()
+	var opts creationOptions
+	require.NoError(a.t, json.Unmarshal(options, &opts))
+
+	clientData := a.clientData("webauthn.create", opts.PublicKey.Challenge)
+	cosePublicKey, err := cbor.Marshal(map[int]any{
+		1:  2,
+		3:  -7,
+		-1: 1,
+		-2: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		-3: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	require.NoError(a.t, err)
+
+	authData := a.authData(flagUserPresent | flagUserVerified | flagAttestedData)
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialId)))
+	authData = append(authData, a.credentialId...)
+	authData = append(authData, cosePublicKey...)
+
+	attestationObject, err := cbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	require.NoError(a.t, err)
+
+	return a.marshal(map[string]any{
+		"id":    b64(a.credentialId),
+		"rawId": b64(a.credentialId),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    b64(clientData),
+			"attestationObject": b64(attestationObject),
+			"transports":        []string{"internal"},
+		},
+	})
+}
+
+// login answers navigator.credentials.get with the given options JSON.
+func (a *softAuthenticator) login(options []byte, userHandle []byte) []byte {
+	a.t.Helper()
+	var opts requestOptions
+	require.NoError(a.t, json.Unmarshal(options, &opts))
+
+	a.signCount++
+	clientData := a.clientData("webauthn.get", opts.PublicKey.Challenge)
+	authData := a.authData(flagUserPresent | flagUserVerified)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	require.NoError(a.t, err)
+
+	return a.marshal(map[string]any{
+		"id":    b64(a.credentialId),
+		"rawId": b64(a.credentialId),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    b64(clientData),
+			"authenticatorData": b64(authData),
+			"signature":         b64(signature),
+			"userHandle":        b64(userHandle),
+		},
+	})
+}
+
+func (a *softAuthenticator) clientData(ceremony string, challenge string) []byte {
+	return a.marshal(map[string]any{
+		"type":      ceremony,
+		"challenge": challenge,
+		"origin":    a.origin,
+	})
+}
+
+func (a *softAuthenticator) authData(flags byte) []byte {
+	rpIdHash := sha256.Sum256([]byte(a.rpId))
+	data := append([]byte{}, rpIdHash[:]...)
+	data = append(data, flags)
+	return binary.BigEndian.AppendUint32(data, a.signCount)
+}
+
+func (a *softAuthenticator) marshal(v any) []byte {
+	b, err := json.Marshal(v)
+	require.NoError(a.t, err)
+	return b
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,284 @@
+package passwordless
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sso/interanal/domain/models"
+	"sso/interanal/storage"
+	ssojwt "sso/lib/jwt"
+	"sso/lib/secure"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ModeCode = "code"
+	ModeLink = "link"
+
+	linkTokenBytes = 32
+)
+
+var (
+	ErrAppNotFound         = errors.New("app not found")
+	ErrInvalidCode         = errors.New("invalid code")
+	ErrTooManyAttempts     = errors.New("too many attempts")
+	ErrDeliveryFailed      = errors.New("failed to deliver code")
+	ErrUnknownDeliveryMode = errors.New("unknown delivery mode")
+)
+
+type Options struct {
+	Mode        string
+	CodeLength  int
+	CodeTTL     time.Duration
+	MaxAttempts int
+	TokenTTL    time.Duration
+	// OpenRegistration allows auto provisioning. In the invite_only
+	// registration mode unknown users are not created even for apps
+	// that allow it.
+	OpenRegistration bool
+}
+
+type PasswordlessService struct {
+	logger       *slog.Logger
+	codeStorage  CodeStorage
+	userSaver    UserSaver
+	userProvider UserProvider
+	appProvider  AppProvider
+	sender       Sender
+	opts         Options
+	// tokenTTL, maxAttempts and openRegistration start as in opts and
+	// change on config reload.
+	tokenTTL         atomic.Int64
+	maxAttempts      atomic.Int64
+	openRegistration atomic.Bool
+}
+
+// Sender delivers the one-time secret to the user, e.g. by email.
+// In link mode the secret is a token that the sender embeds into a link.
+type Sender interface {
+	SendLoginCode(ctx context.Context, email string, appName string, secret string) error
+}
+
+type CodeStorage interface {
+	SavePasswordlessCode(ctx context.Context, code models.PasswordlessCode) (codeId int64, err error)
+	GetActivePasswordlessCode(ctx context.Context, appId int, email string) (models.PasswordlessCode, error)
+	// IncrementPasswordlessAttempts returns storage.ErrAttemptsExhausted
+	// instead of spending more than maxAttempts.
+	IncrementPasswordlessAttempts(ctx context.Context, codeId int64, maxAttempts int) error
+	MarkPasswordlessCodeUsed(ctx context.Context, codeId int64) error
+}
+
+type UserSaver interface {
+	SaveUser(
+		ctx context.Context,
+		orgId int64,
+		email string,
+		passwordHash []byte,
+	) (userId int64, err error)
+}
+
+type UserProvider interface {
+	GetUser(ctx context.Context, orgId int64, email string) (models.User, error)
+}
+
+type AppProvider interface {
+	GetApp(ctx context.Context, appId int) (models.App, error)
+}
+
+func New(
+	logger *slog.Logger,
+	codeStorage CodeStorage,
+	userSaver UserSaver,
+	userProvider UserProvider,
+	appProvider AppProvider,
+	sender Sender,
+	opts Options,
+) *PasswordlessService {
+	p := &PasswordlessService{
+		logger:       logger,
+		codeStorage:  codeStorage,
+		userSaver:    userSaver,
+		userProvider: userProvider,
+		appProvider:  appProvider,
+		sender:       sender,
+		opts:         opts,
+	}
+	p.SetTokenTTL(opts.TokenTTL)
+	p.SetMaxAttempts(opts.MaxAttempts)
+	p.SetOpenRegistration(opts.OpenRegistration)
+	return p
+}
+
+// SetTokenTTL changes the lifetime of tokens issued by later logins.
+func (p *PasswordlessService) SetTokenTTL(ttl time.Duration) {
+	p.tokenTTL.Store(int64(ttl))
+}
+
+// SetMaxAttempts changes how many wrong secrets a code survives,
+// including codes that are already sent.
+func (p *PasswordlessService) SetMaxAttempts(n int) {
+	p.maxAttempts.Store(int64(n))
+}
+
+// SetOpenRegistration allows or forbids auto provisioning of unknown users.
+func (p *PasswordlessService) SetOpenRegistration(open bool) {
+	p.openRegistration.Store(open)
+}
+
+// StartPasswordlessLogin sends a one-time secret to the email. To avoid
+// disclosing which emails are registered, it returns no error for unknown
+// users that can't be provisioned and just sends nothing.
+func (p *PasswordlessService) StartPasswordlessLogin(ctx context.Context, email string, appId int) error {
+	const op = "service.passwordless.StartPasswordlessLogin"
+	logger := p.logger.With(slog.String("op", op), slog.Int("app_id", appId))
+	logger.InfoContext(ctx, "start passwordless login")
+
+	app, err := p.appProvider.GetApp(ctx, appId)
+	if errors.Is(err, storage.ErrAppNotFound) {
+		logger.WarnContext(ctx, "app not found")
+		return fmt.Errorf("%s: %w", op, ErrAppNotFound)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get app", slog.String("err", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = p.userProvider.GetUser(ctx, app.OrgId, email)
+	if errors.Is(err, storage.ErrUserNotFound) && !p.canProvision(app) {
+		logger.WarnContext(ctx, "user not found and auto provisioning is disabled")
+		return nil
+	}
+	if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+		logger.ErrorContext(ctx, "failed to get user", slog.String("err", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err := p.newSecret()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to generate secret", slog.String("err", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	code := models.PasswordlessCode{
+		AppId:     appId,
+		Email:     email,
+		CodeHash:  secure.HashToken(secret),
+		ExpiresAt: time.Now().Add(p.opts.CodeTTL),
+	}
+	if _, err := p.codeStorage.SavePasswordlessCode(ctx, code); err != nil {
+		logger.ErrorContext(ctx, "failed to save code", slog.String("err", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := p.sender.SendLoginCode(ctx, email, app.Name, secret); err != nil {
+		logger.ErrorContext(ctx, "failed to send code", slog.String("err", err.Error()))
+		return fmt.Errorf("%s: %w: %w", op, ErrDeliveryFailed, err)
+	}
+	logger.InfoContext(ctx, "passwordless code sent")
+	return nil
+}
+
+// CompletePasswordlessLogin checks the secret and returns the same token as Login.
+func (p *PasswordlessService) CompletePasswordlessLogin(
+	ctx context.Context,
+	email string,
+	appId int,
+	secret string,
+) (string, error) {
+	const op = "service.passwordless.CompletePasswordlessLogin"
+	logger := p.logger.With(slog.String("op", op), slog.Int("app_id", appId))
+	logger.InfoContext(ctx, "complete passwordless login")
+
+	app, err := p.appProvider.GetApp(ctx, appId)
+	if errors.Is(err, storage.ErrAppNotFound) {
+		logger.WarnContext(ctx, "app not found")
+		return "", fmt.Errorf("%s: %w", op, ErrAppNotFound)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get app", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	code, err := p.codeStorage.GetActivePasswordlessCode(ctx, appId, email)
+	if errors.Is(err, storage.ErrCodeNotFound) {
+		logger.WarnContext(ctx, "no active code")
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidCode)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get code", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	// The attempt is spent before the comparison, so parallel guesses
+	// can't get past the limit.
+	err = p.codeStorage.IncrementPasswordlessAttempts(ctx, code.Id, int(p.maxAttempts.Load()))
+	if errors.Is(err, storage.ErrAttemptsExhausted) {
+		logger.WarnContext(ctx, "too many attempts", slog.Int64("code_id", code.Id))
+		return "", fmt.Errorf("%s: %w", op, ErrTooManyAttempts)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to increment attempts", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if subtle.ConstantTimeCompare(secure.HashToken(secret), code.CodeHash) != 1 {
+		logger.WarnContext(ctx, "code mismatch", slog.Int64("code_id", code.Id))
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidCode)
+	}
+	err = p.codeStorage.MarkPasswordlessCodeUsed(ctx, code.Id)
+	if errors.Is(err, storage.ErrCodeNotFound) {
+		logger.WarnContext(ctx, "code already used", slog.Int64("code_id", code.Id))
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidCode)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to mark code used", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := p.userProvider.GetUser(ctx, app.OrgId, email)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		if !p.canProvision(app) {
+			logger.WarnContext(ctx, "user not found")
+			return "", fmt.Errorf("%s: %w", op, ErrInvalidCode)
+		}
+		user, err = p.provisionUser(ctx, app.OrgId, email)
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get user", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err := ssojwt.NewToken(user, app, time.Duration(p.tokenTTL.Load()))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to generate token", slog.String("err", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	logger.InfoContext(ctx, "user logged successfully")
+	return token, nil
+}
+
+// canProvision reports whether unknown users of the app are created on
+// login. Like Register, it is closed in the invite_only registration mode.
+func (p *PasswordlessService) canProvision(app models.App) bool {
+	return app.AllowAutoProvision && p.openRegistration.Load()
+}
+
+// provisionUser creates a user without a password. An empty hash never
+// matches in bcrypt, so such a user can sign in only without a password.
+func (p *PasswordlessService) provisionUser(ctx context.Context, orgId int64, email string) (models.User, error) {
+	userId, err := p.userSaver.SaveUser(ctx, orgId, email, []byte{})
+	if err != nil {
+		return models.User{}, err
+	}
+	p.logger.InfoContext(ctx, "user auto provisioned", slog.Int64("user_id", userId), slog.Int64("org_id", orgId))
+	return models.User{Id: userId, OrgId: orgId, Email: email}, nil
+}
+
+func (p *PasswordlessService) newSecret() (string, error) {
+	switch p.opts.Mode {
+	case ModeCode:
+		return secure.RandomDigits(p.opts.CodeLength)
+	case ModeLink:
+		return secure.RandomToken(linkTokenBytes)
+	}
+	return "", ErrUnknownDeliveryMode
+}
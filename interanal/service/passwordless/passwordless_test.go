@@ -0,0 +1,210 @@
+package passwordless
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sso/interanal/domain/models"
+	"sso/interanal/storage"
+	"sso/interanal/storage/memory"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	appId          = 1
+	provisionAppId = 2
+	maxAttempts    = 3
+)
+
+// TestPasswordlessLogin проверяет, что по отправленному коду
+// выдается токен, а повторно код использовать нельзя.
+func TestPasswordlessLogin(t *testing.T) {
+	ctx := context.Background()
+	s, st, sender := newTestService(t, time.Minute)
+	_, err := st.SaveUser(ctx, models.DefaultOrgId, "user@gmail.com", []byte("hash"))
+	require.NoError(t, err)
+
+	require.NoError(t, s.StartPasswordlessLogin(ctx, "user@gmail.com", appId))
+	code := sender.last(t, "user@gmail.com")
+	assert.Regexp(t, `^\d{6}$`, code)
+
+	token, err := s.CompletePasswordlessLogin(ctx, "user@gmail.com", appId, code)
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+	_, err = s.CompletePasswordlessLogin(ctx, "user@gmail.com", appId, code)
+	assert.ErrorIs(t, err, ErrInvalidCode)
+}
+
+// TestPasswordlessCodeExpires проверяет, что истекший
+// код не принимается.
+func TestPasswordlessCodeExpires(t *testing.T) {
+	ctx := context.Background()
+	s, st, sender := newTestService(t, -time.Second)
+	_, err := st.SaveUser(ctx, models.DefaultOrgId, "user@gmail.com", []byte("hash"))
+	require.NoError(t, err)
+
+	require.NoError(t, s.StartPasswordlessLogin(ctx, "user@gmail.com", appId))
+	_, err = s.CompletePasswordlessLogin(ctx, "user@gmail.com", appId, sender.last(t, "user@gmail.com"))
+
+	assert.ErrorIs(t, err, ErrInvalidCode)
+}
+
+// TestPasswordlessAttemptLimit проверяет, что после
+// исчерпания попыток не принимается даже верный код,
+// в том числе при параллельном переборе.
+func TestPasswordlessAttemptLimit(t *testing.T) {
+	ctx := context.Background()
+	s, st, sender := newTestService(t, time.Minute)
+	_, err := st.SaveUser(ctx, models.DefaultOrgId, "user@gmail.com", []byte("hash"))
+	require.NoError(t, err)
+	require.NoError(t, s.StartPasswordlessLogin(ctx, "user@gmail.com", appId))
+	code := sender.last(t, "user@gmail.com")
+
+	const guesses = 20
+	errs := make(chan error, guesses)
+	var wg sync.WaitGroup
+	for range guesses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.CompletePasswordlessLogin(ctx, "user@gmail.com", appId, "wrong")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	var invalid, tooMany int
+	for err := range errs {
+		switch {
+		case errors.Is(err, ErrInvalidCode):
+			invalid++
+		case errors.Is(err, ErrTooManyAttempts):
+			tooMany++
+		}
+	}
+	assert.Equal(t, maxAttempts, invalid)
+	assert.Equal(t, guesses-maxAttempts, tooMany)
+
+	_, err = s.CompletePasswordlessLogin(ctx, "user@gmail.com", appId, code)
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+}
+
+// TestSetMaxAttempts проверяет, что новый лимит попыток
+// действует и для уже отправленного кода.
+func TestSetMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	s, st, sender := newTestService(t, time.Minute)
+	_, err := st.SaveUser(ctx, models.DefaultOrgId, "user@gmail.com", []byte("hash"))
+	require.NoError(t, err)
+	require.NoError(t, s.StartPasswordlessLogin(ctx, "user@gmail.com", appId))
+	code := sender.last(t, "user@gmail.com")
+
+	s.SetMaxAttempts(1)
+	_, err = s.CompletePasswordlessLogin(ctx, "user@gmail.com", appId, "wrong")
+	require.ErrorIs(t, err, ErrInvalidCode)
+
+	_, err = s.CompletePasswordlessLogin(ctx, "user@gmail.com", appId, code)
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+}
+
+// TestPasswordlessAutoProvision проверяет, что неизвестный
+// пользователь создается только в приложении с автосозданием
+// и только при открытой регистрации.
+func TestPasswordlessAutoProvision(t *testing.T) {
+	ctx := context.Background()
+	s, st, sender := newTestService(t, time.Minute)
+
+	require.NoError(t, s.StartPasswordlessLogin(ctx, "unknown@gmail.com", appId))
+	assert.Empty(t, sender.sent)
+
+	require.NoError(t, s.StartPasswordlessLogin(ctx, "new@gmail.com", provisionAppId))
+	_, err := s.CompletePasswordlessLogin(ctx, "new@gmail.com", provisionAppId, sender.last(t, "new@gmail.com"))
+	require.NoError(t, err)
+	user, err := st.GetUser(ctx, models.DefaultOrgId, "new@gmail.com")
+	require.NoError(t, err)
+	assert.Empty(t, user.PaswordHash)
+
+	require.NoError(t, s.StartPasswordlessLogin(ctx, "late@gmail.com", provisionAppId))
+	code := sender.last(t, "late@gmail.com")
+	s.SetOpenRegistration(false)
+	_, err = s.CompletePasswordlessLogin(ctx, "late@gmail.com", provisionAppId, code)
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	require.NoError(t, s.StartPasswordlessLogin(ctx, "closed@gmail.com", provisionAppId))
+	assert.NotContains(t, sender.sent, "closed@gmail.com")
+	_, err = st.GetUser(ctx, models.DefaultOrgId, "late@gmail.com")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+}
+
+// TestPasswordlessTokenTTLReload проверяет, что новый
+// token_ttl применяется к следующим токенам.
+func TestPasswordlessTokenTTLReload(t *testing.T) {
+	ctx := context.Background()
+	s, st, sender := newTestService(t, time.Minute)
+	_, err := st.SaveUser(ctx, models.DefaultOrgId, "user@gmail.com", []byte("hash"))
+	require.NoError(t, err)
+
+	s.SetTokenTTL(5 * time.Minute)
+	require.NoError(t, s.StartPasswordlessLogin(ctx, "user@gmail.com", appId))
+	token, err := s.CompletePasswordlessLogin(ctx, "user@gmail.com", appId, sender.last(t, "user@gmail.com"))
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(token, claims)
+	require.NoError(t, err)
+	exp, err := claims.GetExpirationTime()
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), exp.Time, 2*time.Second)
+}
+
+// fakeSender remembers the last secret sent to each email.
+type fakeSender struct {
+	mu   sync.Mutex
+	sent map[string]string
+}
+
+func (f *fakeSender) SendLoginCode(ctx context.Context, email string, appName string, secret string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent[email] = secret
+	return nil
+}
+
+func (f *fakeSender) last(t *testing.T, email string) string {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	secret, ok := f.sent[email]
+	require.True(t, ok, "nothing sent to %s", email)
+	return secret
+}
+
+func newTestService(t *testing.T, codeTTL time.Duration) (*PasswordlessService, *memory.Storage, *fakeSender) {
+	t.Helper()
+	st := memory.New()
+	require.NoError(t, st.SaveApp(context.Background(), models.App{Id: appId, OrgId: models.DefaultOrgId, Name: "test", Secret: "test-secret"}))
+	require.NoError(t, st.SaveApp(context.Background(), models.App{
+		Id:                 provisionAppId,
+		OrgId:              models.DefaultOrgId,
+		Name:               "provision",
+		Secret:             "provision-secret",
+		AllowAutoProvision: true,
+	}))
+	sender := &fakeSender{sent: make(map[string]string)}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := New(logger, st, st, st, st, sender, Options{
+		Mode:             ModeCode,
+		CodeLength:       6,
+		CodeTTL:          codeTTL,
+		MaxAttempts:      maxAttempts,
+		TokenTTL:         time.Hour,
+		OpenRegistration: true,
+	})
+	return s, st, sender
+}
@@ -0,0 +1,168 @@
+// Package cache is a read-through cache of apps and users in front of
+// a storage. Writes made through the cache invalidate its entries right
+// away. It lives in process memory, so writes made by another process,
+// such as ssoctl bootstrap or another replica of the service, become
+// visible only after the TTL, or the NegativeTTL for an unknown app.
+package cache
+
+import (
+	"context"
+	"errors"
+	"sso/interanal/domain/models"
+	"sso/interanal/metrics"
+	"sso/interanal/storage"
+	"time"
+)
+
+// Backend is the storage the cache reads through.
+type Backend interface {
+	GetApp(ctx context.Context, appId int) (models.App, error)
+	GetUser(ctx context.Context, orgId int64, email string) (models.User, error)
+	GetUserById(ctx context.Context, orgId int64, userId int64) (models.User, error)
+	IsAdmin(ctx context.Context, orgId int64, userId int64) (bool, error)
+	SaveUser(ctx context.Context, orgId int64, email string, passHash []byte) (int64, error)
+	SaveApp(ctx context.Context, app models.App) error
+	UpdateApp(ctx context.Context, app models.App) error
+	SetAppSecret(ctx context.Context, appId int, secret string) error
+}
+
+type Options struct {
+	// TTL bounds how long a changed app or user may be served stale.
+	TTL time.Duration
+	// NegativeTTL is how long an unknown app id is remembered.
+	NegativeTTL time.Duration
+	// MaxEntries bounds each of the app, user by email and user by id caches.
+	MaxEntries int
+}
+
+type Cache struct {
+	backend     Backend
+	ttl         time.Duration
+	negativeTTL time.Duration
+	apps        *lru[int, models.App]
+	usersByMail *lru[userEmailKey, models.User]
+	usersById   *lru[userIdKey, models.User]
+}
+
+type userEmailKey struct {
+	orgId int64
+	email string
+}
+
+type userIdKey struct {
+	orgId  int64
+	userId int64
+}
+
+func New(backend Backend, opts Options) *Cache {
+	return newCache(backend, opts, time.Now)
+}
+
+func newCache(backend Backend, opts Options, now func() time.Time) *Cache {
+	return &Cache{
+		backend:     backend,
+		ttl:         opts.TTL,
+		negativeTTL: opts.NegativeTTL,
+		apps:        newLRU[int, models.App](opts.MaxEntries, now),
+		usersByMail: newLRU[userEmailKey, models.User](opts.MaxEntries, now),
+		usersById:   newLRU[userIdKey, models.User](opts.MaxEntries, now),
+	}
+}
+
+func (c *Cache) GetApp(ctx context.Context, appId int) (models.App, error) {
+	if app, err, ok := c.apps.get(appId); ok {
+		metrics.CacheLookup(metrics.CacheApp, metrics.CacheHit)
+		return app, err
+	}
+	metrics.CacheLookup(metrics.CacheApp, metrics.CacheMiss)
+	app, err := c.backend.GetApp(ctx, appId)
+	switch {
+	case err == nil:
+		c.apps.set(appId, app, nil, c.ttl)
+	case errors.Is(err, storage.ErrAppNotFound) && c.negativeTTL > 0:
+		c.apps.set(appId, models.App{}, err, c.negativeTTL)
+	}
+	return app, err
+}
+
+func (c *Cache) GetUser(ctx context.Context, orgId int64, email string) (models.User, error) {
+	key := userEmailKey{orgId: orgId, email: email}
+	if user, _, ok := c.usersByMail.get(key); ok {
+		metrics.CacheLookup(metrics.CacheUser, metrics.CacheHit)
+		return user, nil
+	}
+	metrics.CacheLookup(metrics.CacheUser, metrics.CacheMiss)
+	user, err := c.backend.GetUser(ctx, orgId, email)
+	if err != nil {
+		return models.User{}, err
+	}
+	c.usersByMail.set(key, user, nil, c.ttl)
+	return user, nil
+}
+
+func (c *Cache) GetUserById(ctx context.Context, orgId int64, userId int64) (models.User, error) {
+	key := userIdKey{orgId: orgId, userId: userId}
+	if user, _, ok := c.usersById.get(key); ok {
+		metrics.CacheLookup(metrics.CacheUser, metrics.CacheHit)
+		return user, nil
+	}
+	metrics.CacheLookup(metrics.CacheUser, metrics.CacheMiss)
+	user, err := c.backend.GetUserById(ctx, orgId, userId)
+	if err != nil {
+		return models.User{}, err
+	}
+	c.usersById.set(key, user, nil, c.ttl)
+	return user, nil
+}
+
+// IsAdmin is not cached: it guards impersonation and invites, and a
+// revoked admin must lose access at once.
+func (c *Cache) IsAdmin(ctx context.Context, orgId int64, userId int64) (bool, error) {
+	return c.backend.IsAdmin(ctx, orgId, userId)
+}
+
+// SaveUser drops the cached entries of the email, so a user created
+// after a failed lookup is found right away.
+func (c *Cache) SaveUser(ctx context.Context, orgId int64, email string, passHash []byte) (int64, error) {
+	userId, err := c.backend.SaveUser(ctx, orgId, email, passHash)
+	c.usersByMail.delete(userEmailKey{orgId: orgId, email: email})
+	if err == nil {
+		c.InvalidateUser(orgId, userId)
+	}
+	return userId, err
+}
+
+// SaveApp drops a cached miss of the app id, so a new app can be used
+// at once instead of after the NegativeTTL.
+func (c *Cache) SaveApp(ctx context.Context, app models.App) error {
+	err := c.backend.SaveApp(ctx, app)
+	c.InvalidateApp(app.Id)
+	return err
+}
+
+func (c *Cache) UpdateApp(ctx context.Context, app models.App) error {
+	err := c.backend.UpdateApp(ctx, app)
+	c.InvalidateApp(app.Id)
+	return err
+}
+
+func (c *Cache) SetAppSecret(ctx context.Context, appId int, secret string) error {
+	err := c.backend.SetAppSecret(ctx, appId, secret)
+	c.InvalidateApp(appId)
+	return err
+}
+
+// InvalidateApp drops the cached app, including a cached miss.
+// Call it after the app is changed or created.
+func (c *Cache) InvalidateApp(appId int) {
+	c.apps.delete(appId)
+}
+
+// InvalidateUser drops every cached entry of the user.
+// Call it after the user is changed or deleted.
+func (c *Cache) InvalidateUser(orgId int64, userId int64) {
+	c.usersById.delete(userIdKey{orgId: orgId, userId: userId})
+	c.usersByMail.deleteFunc(func(key userEmailKey, user models.User) bool {
+		return key.orgId == orgId && user.Id == userId
+	})
+}
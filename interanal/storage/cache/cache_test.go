@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"context"
+	"sso/interanal/domain/models"
+	"sso/interanal/storage"
+	"sso/interanal/storage/memory"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingBackend считает обращения к хранилищу.
+type countingBackend struct {
+	*memory.Storage
+	getApp      atomic.Int32
+	getUser     atomic.Int32
+	getUserById atomic.Int32
+}
+
+func (b *countingBackend) GetApp(ctx context.Context, appId int) (models.App, error) {
+	b.getApp.Add(1)
+	return b.Storage.GetApp(ctx, appId)
+}
+
+func (b *countingBackend) GetUser(ctx context.Context, orgId int64, email string) (models.User, error) {
+	b.getUser.Add(1)
+	return b.Storage.GetUser(ctx, orgId, email)
+}
+
+func (b *countingBackend) GetUserById(ctx context.Context, orgId int64, userId int64) (models.User, error) {
+	b.getUserById.Add(1)
+	return b.Storage.GetUserById(ctx, orgId, userId)
+}
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+// newTestCache возвращает кэш над хранилищем с приложением 1.
+func newTestCache(t *testing.T, opts Options) (*Cache, *countingBackend, *clock) {
+	t.Helper()
+	backend := &countingBackend{Storage: memory.New()}
+	require.NoError(t, backend.SaveApp(context.Background(), models.App{Id: 1, OrgId: models.DefaultOrgId, Name: "test", Secret: "secret"}))
+	c := &clock{now: time.Now()}
+	return newCache(backend, opts, c.Now), backend, c
+}
+
+var testOptions = Options{TTL: time.Minute, NegativeTTL: 10 * time.Second, MaxEntries: 100}
+
+// TestGetAppReadsThrough проверяет, что повторное чтение
+// приложения не идет в хранилище до истечения TTL.
+func TestGetAppReadsThrough(t *testing.T) {
+	cache, backend, clock := newTestCache(t, testOptions)
+	ctx := context.Background()
+
+	for range 3 {
+		app, err := cache.GetApp(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, "test", app.Name)
+	}
+	assert.EqualValues(t, 1, backend.getApp.Load())
+
+	clock.now = clock.now.Add(time.Minute)
+	_, err := cache.GetApp(ctx, 1)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, backend.getApp.Load())
+}
+
+// TestGetAppCachesMiss проверяет, что неизвестное приложение
+// запоминается на NegativeTTL, а создание приложения через кэш
+// сбрасывает промах.
+func TestGetAppCachesMiss(t *testing.T) {
+	cache, backend, clock := newTestCache(t, testOptions)
+	ctx := context.Background()
+
+	for range 2 {
+		_, err := cache.GetApp(ctx, 2)
+		assert.ErrorIs(t, err, storage.ErrAppNotFound)
+	}
+	assert.EqualValues(t, 1, backend.getApp.Load())
+
+	clock.now = clock.now.Add(10 * time.Second)
+	_, err := cache.GetApp(ctx, 2)
+	assert.ErrorIs(t, err, storage.ErrAppNotFound)
+	assert.EqualValues(t, 2, backend.getApp.Load())
+
+	require.NoError(t, cache.SaveApp(ctx, models.App{Id: 2, OrgId: models.DefaultOrgId, Name: "new", Secret: "new-secret"}))
+	app, err := cache.GetApp(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "new", app.Name)
+}
+
+// TestUpdateAppInvalidates проверяет, что изменение приложения
+// через кэш сразу видно при чтении.
+func TestUpdateAppInvalidates(t *testing.T) {
+	cache, _, _ := newTestCache(t, testOptions)
+	ctx := context.Background()
+	app, err := cache.GetApp(ctx, 1)
+	require.NoError(t, err)
+
+	app.Name = "renamed"
+	require.NoError(t, cache.UpdateApp(ctx, app))
+	got, err := cache.GetApp(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", got.Name)
+}
+
+// TestGetUserDoesNotCacheMiss проверяет, что пользователь,
+// созданный после неудачного поиска, находится сразу.
+func TestGetUserDoesNotCacheMiss(t *testing.T) {
+	cache, _, _ := newTestCache(t, testOptions)
+	ctx := context.Background()
+
+	_, err := cache.GetUser(ctx, models.DefaultOrgId, "late@gmail.com")
+	require.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	userId, err := cache.SaveUser(ctx, models.DefaultOrgId, "late@gmail.com", []byte("hash"))
+	require.NoError(t, err)
+	user, err := cache.GetUser(ctx, models.DefaultOrgId, "late@gmail.com")
+	require.NoError(t, err)
+	assert.Equal(t, userId, user.Id)
+}
+
+// TestInvalidateUser проверяет, что InvalidateUser сбрасывает
+// пользователя в кэше по email и по id.
+func TestInvalidateUser(t *testing.T) {
+	cache, backend, _ := newTestCache(t, testOptions)
+	ctx := context.Background()
+	userId, err := backend.SaveUser(ctx, models.DefaultOrgId, "cached@gmail.com", []byte("hash"))
+	require.NoError(t, err)
+
+	for range 2 {
+		_, err = cache.GetUser(ctx, models.DefaultOrgId, "cached@gmail.com")
+		require.NoError(t, err)
+		_, err = cache.GetUserById(ctx, models.DefaultOrgId, userId)
+		require.NoError(t, err)
+	}
+	assert.EqualValues(t, 1, backend.getUser.Load())
+	assert.EqualValues(t, 1, backend.getUserById.Load())
+
+	cache.InvalidateUser(models.DefaultOrgId, userId)
+	_, err = cache.GetUser(ctx, models.DefaultOrgId, "cached@gmail.com")
+	require.NoError(t, err)
+	_, err = cache.GetUserById(ctx, models.DefaultOrgId, userId)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, backend.getUser.Load())
+	assert.EqualValues(t, 2, backend.getUserById.Load())
+}
+
+// TestIsAdminIsNotCached проверяет, что снятие прав
+// администратора видно сразу.
+func TestIsAdminIsNotCached(t *testing.T) {
+	cache, backend, _ := newTestCache(t, testOptions)
+	ctx := context.Background()
+	userId, err := backend.SaveUser(ctx, models.DefaultOrgId, "admin@gmail.com", []byte("hash"))
+	require.NoError(t, err)
+	require.NoError(t, backend.SetAdmin(ctx, models.DefaultOrgId, userId, true))
+
+	isAdmin, err := cache.IsAdmin(ctx, models.DefaultOrgId, userId)
+	require.NoError(t, err)
+	assert.True(t, isAdmin)
+
+	require.NoError(t, backend.SetAdmin(ctx, models.DefaultOrgId, userId, false))
+	isAdmin, err = cache.IsAdmin(ctx, models.DefaultOrgId, userId)
+	require.NoError(t, err)
+	assert.False(t, isAdmin)
+}
+
+// TestMaxEntries проверяет, что при переполнении
+// вытесняется давно не читавшаяся запись.
+func TestMaxEntries(t *testing.T) {
+	cache, backend, _ := newTestCache(t, Options{TTL: time.Minute, NegativeTTL: time.Minute, MaxEntries: 2})
+	ctx := context.Background()
+
+	cache.GetApp(ctx, 1)
+	cache.GetApp(ctx, 2)
+	cache.GetApp(ctx, 1)
+	cache.GetApp(ctx, 3)
+	require.EqualValues(t, 3, backend.getApp.Load())
+
+	cache.GetApp(ctx, 1)
+	assert.EqualValues(t, 3, backend.getApp.Load())
+	cache.GetApp(ctx, 2)
+	assert.EqualValues(t, 4, backend.getApp.Load())
+}
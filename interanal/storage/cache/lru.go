@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lru is a size-bounded map whose entries also expire after a TTL.
+// An entry holds either a value or the error to return, so misses can
+// be cached as well.
+type lru[K comparable, V any] struct {
+	mu      sync.Mutex
+	size    int
+	items   map[K]*list.Element
+	order   *list.List
+	nowFunc func() time.Time
+}
+
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	err       error
+	expiresAt time.Time
+}
+
+func newLRU[K comparable, V any](size int, now func() time.Time) *lru[K, V] {
+	return &lru[K, V]{
+		size:    size,
+		items:   make(map[K]*list.Element),
+		order:   list.New(),
+		nowFunc: now,
+	}
+}
+
+func (c *lru[K, V]) get(key K) (V, error, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, nil, false
+	}
+	e := el.Value.(*entry[K, V])
+	if !c.nowFunc().Before(e.expiresAt) {
+		c.removeElement(el)
+		var zero V
+		return zero, nil, false
+	}
+	c.order.MoveToFront(el)
+	return e.value, e.err, true
+}
+
+func (c *lru[K, V]) set(key K, value V, err error, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := &entry[K, V]{key: key, value: value, err: err, expiresAt: c.nowFunc().Add(ttl)}
+	if el, ok := c.items[key]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(e)
+	if c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+	}
+}
+
+func (c *lru[K, V]) delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// deleteFunc removes every entry for which match returns true.
+func (c *lru[K, V]) deleteFunc(match func(key K, value V) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		e := el.Value.(*entry[K, V])
+		if match(e.key, e.value) {
+			c.removeElement(el)
+		}
+		el = next
+	}
+}
+
+func (c *lru[K, V]) removeElement(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*entry[K, V]).key)
+}
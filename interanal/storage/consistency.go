@@ -0,0 +1,18 @@
+package storage
+
+import "context"
+
+type primaryReadsKey struct{}
+
+// WithPrimaryReads asks storages with read replicas to read from the
+// primary, so a read within ctx sees every write committed before it.
+// Storages without replicas ignore it.
+func WithPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadsKey{}, true)
+}
+
+// PrimaryReads reports whether ctx was made by WithPrimaryReads.
+func PrimaryReads(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryReadsKey{}).(bool)
+	return primary
+}
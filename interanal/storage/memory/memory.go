@@ -0,0 +1,685 @@
+// Package memory is a concurrency-safe in-memory storage with the
+// same behavior and errors as the postgres one. It is meant for tests.
+package memory
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sso/interanal/domain/models"
+	"sso/interanal/storage"
+	"sync"
+	"time"
+)
+
+type Storage struct {
+	mu sync.Mutex
+	// txMu runs transactions one at a time, see WithinTx.
+	txMu sync.Mutex
+	data
+}
+
+// data is everything a rolled back transaction restores.
+type data struct {
+	users       map[int64]*user
+	memberships map[membershipKey]string
+	orgs        map[int64]models.Organization
+	apps        map[int]models.App
+	invites     []*invite
+	codes       []*passwordlessCode
+	credentials []*models.WebAuthnCredential
+	sessions    map[string]models.WebAuthnSession
+	devices     []*models.DeviceAuthorization
+	events      []models.AuditEvent
+	lastUserId  int64
+	lastOrgId   int64
+	lastId      int64
+}
+
+type user struct {
+	models.User
+	isAdmin           bool
+	sessionsRevokedAt time.Time
+}
+
+type membershipKey struct {
+	orgId  int64
+	userId int64
+}
+
+type invite struct {
+	models.Invite
+	tokenHash []byte
+}
+
+type passwordlessCode struct {
+	models.PasswordlessCode
+	used bool
+}
+
+// New returns a storage with the data that migrations/ seeds: the
+// default organization.
+func New() *Storage {
+	s := &Storage{data: data{
+		users:       make(map[int64]*user),
+		memberships: make(map[membershipKey]string),
+		orgs:        make(map[int64]models.Organization),
+		apps:        make(map[int]models.App),
+		sessions:    make(map[string]models.WebAuthnSession),
+	}}
+	s.orgs[models.DefaultOrgId] = models.Organization{Id: models.DefaultOrgId, Name: "default"}
+	s.lastOrgId = models.DefaultOrgId
+	return s
+}
+
+func (s *Storage) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (s *Storage) Stop(ctx context.Context) error {
+	return nil
+}
+
+func (s *Storage) SaveUser(ctx context.Context, orgId int64, email string, passHash []byte) (int64, error) {
+	const op = "storage.memory.SaveUser"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.orgs[orgId]; !ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+	}
+	for _, u := range s.users {
+		if u.OrgId == orgId && u.Email == email {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+		}
+	}
+	s.lastUserId++
+	s.users[s.lastUserId] = &user{User: models.User{
+		Id:          s.lastUserId,
+		OrgId:       orgId,
+		Email:       email,
+		PaswordHash: bytes.Clone(passHash),
+	}}
+	s.memberships[membershipKey{orgId, s.lastUserId}] = models.RoleMember
+	return s.lastUserId, nil
+}
+
+func (s *Storage) GetUser(ctx context.Context, orgId int64, email string) (models.User, error) {
+	const op = "storage.memory.GetUser"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if u.OrgId == orgId && u.Email == email {
+			return cloneUser(u.User), nil
+		}
+	}
+	return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+}
+
+func (s *Storage) GetUserById(ctx context.Context, orgId int64, userId int64) (models.User, error) {
+	const op = "storage.memory.GetUserById"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userId]
+	if !ok || u.OrgId != orgId {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	return cloneUser(u.User), nil
+}
+
+func (s *Storage) IsAdmin(ctx context.Context, orgId int64, userId int64) (bool, error) {
+	const op = "storage.memory.IsAdmin"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userId]
+	if !ok || u.OrgId != orgId {
+		return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	return u.isAdmin, nil
+}
+
+func (s *Storage) GetApp(ctx context.Context, appId int) (models.App, error) {
+	const op = "storage.memory.GetApp"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app, ok := s.apps[appId]
+	if !ok {
+		return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+	return app, nil
+}
+
+func (s *Storage) SaveApp(ctx context.Context, app models.App) error {
+	const op = "storage.memory.SaveApp"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.apps[app.Id]; ok || s.appNameTaken(app) {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+	}
+	if _, ok := s.orgs[app.OrgId]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+	}
+	s.apps[app.Id] = app
+	return nil
+}
+
+// UpdateApp changes everything but the secret of an app.
+func (s *Storage) UpdateApp(ctx context.Context, app models.App) error {
+	const op = "storage.memory.UpdateApp"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.apps[app.Id]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+	if s.appNameTaken(app) {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+	}
+	if _, ok := s.orgs[app.OrgId]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+	}
+	app.Secret = current.Secret
+	s.apps[app.Id] = app
+	return nil
+}
+
+func (s *Storage) SetAppSecret(ctx context.Context, appId int, secret string) error {
+	const op = "storage.memory.SetAppSecret"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	app, ok := s.apps[appId]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+	app.Secret = secret
+	s.apps[appId] = app
+	return nil
+}
+
+// ListApps returns the apps of an organization without their secrets.
+func (s *Storage) ListApps(ctx context.Context, orgId int64) ([]models.App, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var apps []models.App
+	for _, app := range s.apps {
+		if app.OrgId == orgId {
+			app.Secret = ""
+			apps = append(apps, app)
+		}
+	}
+	slices.SortFunc(apps, func(a, b models.App) int { return a.Id - b.Id })
+	return apps, nil
+}
+
+func (s *Storage) SaveOrganization(ctx context.Context, name string) (int64, error) {
+	const op = "storage.memory.SaveOrganization"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, org := range s.orgs {
+		if org.Name == name {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrOrgExists)
+		}
+	}
+	s.lastOrgId++
+	s.orgs[s.lastOrgId] = models.Organization{Id: s.lastOrgId, Name: name}
+	return s.lastOrgId, nil
+}
+
+func (s *Storage) GetOrganization(ctx context.Context, orgId int64) (models.Organization, error) {
+	const op = "storage.memory.GetOrganization"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	org, ok := s.orgs[orgId]
+	if !ok {
+		return models.Organization{}, fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+	}
+	return org, nil
+}
+
+func (s *Storage) MemberRole(ctx context.Context, orgId int64, userId int64) (string, error) {
+	const op = "storage.memory.MemberRole"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	role, ok := s.memberships[membershipKey{orgId, userId}]
+	if !ok {
+		return "", fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	return role, nil
+}
+
+func (s *Storage) SetMemberRole(ctx context.Context, orgId int64, userId int64, role string) error {
+	const op = "storage.memory.SetMemberRole"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := membershipKey{orgId, userId}
+	if _, ok := s.memberships[key]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	s.memberships[key] = role
+	return nil
+}
+
+func (s *Storage) ListMembers(ctx context.Context, orgId int64) ([]models.Member, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var members []models.Member
+	for key, role := range s.memberships {
+		if key.orgId != orgId {
+			continue
+		}
+		u, ok := s.users[key.userId]
+		if !ok {
+			continue
+		}
+		members = append(members, models.Member{UserId: key.userId, Email: u.Email, Role: role})
+	}
+	slices.SortFunc(members, func(a, b models.Member) int { return cmp.Compare(a.UserId, b.UserId) })
+	return members, nil
+}
+
+// SetAdmin sets the legacy is_admin flag that IsAdmin reports.
+func (s *Storage) SetAdmin(ctx context.Context, orgId int64, userId int64, isAdmin bool) error {
+	const op = "storage.memory.SetAdmin"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userId]
+	if !ok || u.OrgId != orgId {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.isAdmin = isAdmin
+	return nil
+}
+
+// RevokeSessions rejects the tokens of a user issued up to revokedAt.
+func (s *Storage) RevokeSessions(ctx context.Context, orgId int64, userId int64, revokedAt time.Time) error {
+	const op = "storage.memory.RevokeSessions"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userId]
+	if !ok || u.OrgId != orgId {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.sessionsRevokedAt = revokedAt
+	return nil
+}
+
+// SessionsRevokedAt returns the zero time for a user whose sessions
+// were never revoked.
+func (s *Storage) SessionsRevokedAt(ctx context.Context, orgId int64, userId int64) (time.Time, error) {
+	const op = "storage.memory.SessionsRevokedAt"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userId]
+	if !ok || u.OrgId != orgId {
+		return time.Time{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	return u.sessionsRevokedAt, nil
+}
+
+func (s *Storage) SaveInvite(ctx context.Context, inv models.Invite, tokenHash []byte) (int64, error) {
+	const op = "storage.memory.SaveInvite"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Like postgres, any broken reference is reported as a missing organization.
+	_, orgExists := s.orgs[inv.OrgId]
+	_, creatorExists := s.users[inv.CreatedBy]
+	if !orgExists || !creatorExists {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+	}
+	for _, other := range s.invites {
+		if bytes.Equal(other.tokenHash, tokenHash) {
+			return 0, fmt.Errorf("%s: duplicate token hash", op)
+		}
+	}
+	s.lastId++
+	inv.Id = s.lastId
+	inv.AcceptedAt = time.Time{}
+	s.invites = append(s.invites, &invite{Invite: inv, tokenHash: bytes.Clone(tokenHash)})
+	return inv.Id, nil
+}
+
+// GetActiveInvite returns an invite that is neither expired nor accepted.
+func (s *Storage) GetActiveInvite(ctx context.Context, tokenHash []byte) (models.Invite, error) {
+	const op = "storage.memory.GetActiveInvite"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, inv := range s.invites {
+		if bytes.Equal(inv.tokenHash, tokenHash) && inv.AcceptedAt.IsZero() && inv.ExpiresAt.After(now) {
+			return inv.Invite, nil
+		}
+	}
+	return models.Invite{}, fmt.Errorf("%s: %w", op, storage.ErrInviteNotFound)
+}
+
+// ConsumeInvite marks an active invite as accepted, so a token can be used only once.
+func (s *Storage) ConsumeInvite(ctx context.Context, tokenHash []byte) (models.Invite, error) {
+	const op = "storage.memory.ConsumeInvite"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, inv := range s.invites {
+		if bytes.Equal(inv.tokenHash, tokenHash) && inv.AcceptedAt.IsZero() && inv.ExpiresAt.After(now) {
+			inv.AcceptedAt = now
+			return inv.Invite, nil
+		}
+	}
+	return models.Invite{}, fmt.Errorf("%s: %w", op, storage.ErrInviteNotFound)
+}
+
+// SavePasswordlessCode invalidates previous codes of the same email and app,
+// so only the latest sent code can be used.
+func (s *Storage) SavePasswordlessCode(ctx context.Context, code models.PasswordlessCode) (int64, error) {
+	const op = "storage.memory.SavePasswordlessCode"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.apps[code.AppId]; !ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+	for _, other := range s.codes {
+		if other.AppId == code.AppId && other.Email == code.Email {
+			other.used = true
+		}
+	}
+	s.lastId++
+	code.Id = s.lastId
+	code.Attempts = 0
+	code.CodeHash = bytes.Clone(code.CodeHash)
+	s.codes = append(s.codes, &passwordlessCode{PasswordlessCode: code})
+	return code.Id, nil
+}
+
+func (s *Storage) GetActivePasswordlessCode(ctx context.Context, appId int, email string) (models.PasswordlessCode, error) {
+	const op = "storage.memory.GetActivePasswordlessCode"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for i := len(s.codes) - 1; i >= 0; i-- {
+		code := s.codes[i]
+		if code.AppId == appId && code.Email == email && !code.used && code.ExpiresAt.After(now) {
+			found := code.PasswordlessCode
+			found.CodeHash = bytes.Clone(found.CodeHash)
+			return found, nil
+		}
+	}
+	return models.PasswordlessCode{}, fmt.Errorf("%s: %w", op, storage.ErrCodeNotFound)
+}
+
+func (s *Storage) IncrementPasswordlessAttempts(ctx context.Context, codeId int64, maxAttempts int) error {
+	const op = "storage.memory.IncrementPasswordlessAttempts"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code := s.findCode(codeId)
+	if code == nil || code.Attempts >= maxAttempts {
+		return fmt.Errorf("%s: %w", op, storage.ErrAttemptsExhausted)
+	}
+	code.Attempts++
+	return nil
+}
+
+func (s *Storage) MarkPasswordlessCodeUsed(ctx context.Context, codeId int64) error {
+	const op = "storage.memory.MarkPasswordlessCodeUsed"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code := s.findCode(codeId)
+	if code == nil || code.used {
+		return fmt.Errorf("%s: %w", op, storage.ErrCodeNotFound)
+	}
+	code.used = true
+	return nil
+}
+
+func (s *Storage) SaveWebAuthnCredential(ctx context.Context, cred models.WebAuthnCredential) error {
+	const op = "storage.memory.SaveWebAuthnCredential"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.findCredential(cred.Id) != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrCredentialExists)
+	}
+	if _, ok := s.users[cred.UserId]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	cred = cloneCredential(cred)
+	cred.CloneWarning = false
+	cred.CreatedAt = time.Now()
+	cred.LastUsedAt = time.Time{}
+	if cred.Transports == nil {
+		cred.Transports = []string{}
+	}
+	s.credentials = append(s.credentials, &cred)
+	return nil
+}
+
+func (s *Storage) ListWebAuthnCredentials(ctx context.Context, userId int64) ([]models.WebAuthnCredential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var creds []models.WebAuthnCredential
+	for _, cred := range s.credentials {
+		if cred.UserId == userId {
+			creds = append(creds, cloneCredential(*cred))
+		}
+	}
+	return creds, nil
+}
+
+func (s *Storage) UpdateWebAuthnSignCount(ctx context.Context, credentialId []byte, signCount uint32, cloneWarning bool) error {
+	const op = "storage.memory.UpdateWebAuthnSignCount"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cred := s.findCredential(credentialId)
+	if cred == nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrCredentialNotFound)
+	}
+	cred.SignCount = signCount
+	cred.CloneWarning = cloneWarning
+	cred.LastUsedAt = time.Now()
+	return nil
+}
+
+func (s *Storage) DeleteWebAuthnCredential(ctx context.Context, userId int64, credentialId []byte) error {
+	const op = "storage.memory.DeleteWebAuthnCredential"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.credentials, func(cred *models.WebAuthnCredential) bool {
+		return cred.UserId == userId && bytes.Equal(cred.Id, credentialId)
+	})
+	if i < 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrCredentialNotFound)
+	}
+	s.credentials = slices.Delete(s.credentials, i, i+1)
+	return nil
+}
+
+func (s *Storage) SaveWebAuthnSession(ctx context.Context, sessionHash []byte, session models.WebAuthnSession) error {
+	const op = "storage.memory.SaveWebAuthnSession"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[string(sessionHash)]; ok {
+		return fmt.Errorf("%s: duplicate session hash", op)
+	}
+	if _, ok := s.users[session.UserId]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	session.Data = bytes.Clone(session.Data)
+	s.sessions[string(sessionHash)] = session
+	return nil
+}
+
+// ConsumeWebAuthnSession deletes the session, so a ceremony can be finished only once.
+func (s *Storage) ConsumeWebAuthnSession(ctx context.Context, sessionHash []byte, ceremony string) (models.WebAuthnSession, error) {
+	const op = "storage.memory.ConsumeWebAuthnSession"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[string(sessionHash)]
+	if !ok || session.Ceremony != ceremony || !session.ExpiresAt.After(time.Now()) {
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+	}
+	delete(s.sessions, string(sessionHash))
+	return session, nil
+}
+
+func (s *Storage) SaveDeviceAuthorization(ctx context.Context, auth models.DeviceAuthorization) error {
+	const op = "storage.memory.SaveDeviceAuthorization"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, other := range s.devices {
+		if bytes.Equal(other.DeviceCodeHash, auth.DeviceCodeHash) || other.UserCode == auth.UserCode {
+			return fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeExists)
+		}
+	}
+	if _, ok := s.apps[auth.AppId]; !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+	auth.DeviceCodeHash = bytes.Clone(auth.DeviceCodeHash)
+	auth.UserId = 0
+	auth.Status = models.DeviceStatusPending
+	// Postgres keeps the interval in whole seconds.
+	auth.Interval = auth.Interval.Truncate(time.Second)
+	auth.LastPolledAt = time.Time{}
+	s.devices = append(s.devices, &auth)
+	return nil
+}
+
+func (s *Storage) GetDeviceAuthorization(ctx context.Context, deviceCodeHash []byte) (models.DeviceAuthorization, error) {
+	const op = "storage.memory.GetDeviceAuthorization"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	auth := s.findDevice(func(auth *models.DeviceAuthorization) bool {
+		return bytes.Equal(auth.DeviceCodeHash, deviceCodeHash)
+	})
+	if auth == nil {
+		return models.DeviceAuthorization{}, fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeNotFound)
+	}
+	return cloneDevice(*auth), nil
+}
+
+func (s *Storage) GetDeviceAuthorizationByUserCode(ctx context.Context, userCode string) (models.DeviceAuthorization, error) {
+	const op = "storage.memory.GetDeviceAuthorizationByUserCode"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	auth := s.findDevice(func(auth *models.DeviceAuthorization) bool {
+		return auth.UserCode == userCode
+	})
+	if auth == nil {
+		return models.DeviceAuthorization{}, fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeNotFound)
+	}
+	return cloneDevice(*auth), nil
+}
+
+// ResolveDeviceAuthorization approves or denies a pending authorization.
+func (s *Storage) ResolveDeviceAuthorization(ctx context.Context, userCode string, userId int64, status string) error {
+	const op = "storage.memory.ResolveDeviceAuthorization"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	auth := s.findDevice(func(auth *models.DeviceAuthorization) bool {
+		return auth.UserCode == userCode && auth.Status == models.DeviceStatusPending && auth.ExpiresAt.After(now)
+	})
+	if auth == nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeNotFound)
+	}
+	auth.UserId = userId
+	auth.Status = status
+	return nil
+}
+
+func (s *Storage) UpdateDevicePoll(ctx context.Context, deviceCodeHash []byte, polledAt time.Time, interval time.Duration) error {
+	const op = "storage.memory.UpdateDevicePoll"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	auth := s.findDevice(func(auth *models.DeviceAuthorization) bool {
+		return bytes.Equal(auth.DeviceCodeHash, deviceCodeHash)
+	})
+	if auth == nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeNotFound)
+	}
+	auth.LastPolledAt = polledAt
+	auth.Interval = interval.Truncate(time.Second)
+	return nil
+}
+
+// ConsumeDeviceAuthorization moves an approved authorization to consumed,
+// so tokens are issued for a device code only once.
+func (s *Storage) ConsumeDeviceAuthorization(ctx context.Context, deviceCodeHash []byte) error {
+	const op = "storage.memory.ConsumeDeviceAuthorization"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	auth := s.findDevice(func(auth *models.DeviceAuthorization) bool {
+		return bytes.Equal(auth.DeviceCodeHash, deviceCodeHash) && auth.Status == models.DeviceStatusApproved
+	})
+	if auth == nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeNotFound)
+	}
+	auth.Status = models.DeviceStatusConsumed
+	return nil
+}
+
+func (s *Storage) SaveAuditEvent(ctx context.Context, event models.AuditEvent) (int64, error) {
+	const op = "storage.memory.SaveAuditEvent"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.orgs[event.OrgId]; !ok {
+		return 0, fmt.Errorf("%s: organization %d does not exist", op, event.OrgId)
+	}
+	s.lastId++
+	event.Id = s.lastId
+	event.CreatedAt = time.Now()
+	s.events = append(s.events, event)
+	return event.Id, nil
+}
+
+// appNameTaken reports whether another app has the name of app.
+func (s *Storage) appNameTaken(app models.App) bool {
+	for _, other := range s.apps {
+		if other.Id != app.Id && other.Name == app.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Storage) findCode(codeId int64) *passwordlessCode {
+	for _, code := range s.codes {
+		if code.Id == codeId {
+			return code
+		}
+	}
+	return nil
+}
+
+func (s *Storage) findCredential(credentialId []byte) *models.WebAuthnCredential {
+	for _, cred := range s.credentials {
+		if bytes.Equal(cred.Id, credentialId) {
+			return cred
+		}
+	}
+	return nil
+}
+
+func (s *Storage) findDevice(match func(*models.DeviceAuthorization) bool) *models.DeviceAuthorization {
+	for _, auth := range s.devices {
+		if match(auth) {
+			return auth
+		}
+	}
+	return nil
+}
+
+func cloneUser(u models.User) models.User {
+	u.PaswordHash = bytes.Clone(u.PaswordHash)
+	return u
+}
+
+func cloneDevice(auth models.DeviceAuthorization) models.DeviceAuthorization {
+	auth.DeviceCodeHash = bytes.Clone(auth.DeviceCodeHash)
+	return auth
+}
+
+func cloneCredential(cred models.WebAuthnCredential) models.WebAuthnCredential {
+	cred.Id = bytes.Clone(cred.Id)
+	cred.PublicKey = bytes.Clone(cred.PublicKey)
+	cred.AAGUID = bytes.Clone(cred.AAGUID)
+	cred.Transports = slices.Clone(cred.Transports)
+	return cred
+}
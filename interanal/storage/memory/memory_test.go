@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"context"
+	"sso/interanal/domain/models"
+	"sso/interanal/storage/storagetest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConformance проверяет, что хранилище в памяти
+// ведет себя так же, как postgres.
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, New())
+}
+
+// TestConcurrentSaveUser проверяет, что при параллельной
+// регистрации одного email сохраняется ровно один пользователь.
+func TestConcurrentSaveUser(t *testing.T) {
+	s := New()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	saved := 0
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.SaveUser(context.Background(), models.DefaultOrgId, "race@gmail.com", []byte("hash")); err == nil {
+				mu.Lock()
+				saved++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, saved)
+	_, err := s.GetUser(context.Background(), models.DefaultOrgId, "race@gmail.com")
+	require.NoError(t, err)
+}
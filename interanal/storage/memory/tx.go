@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"context"
+	"maps"
+)
+
+type txKey struct{}
+
+// WithinTx runs fn as one transaction, see postgres.Storage.WithinTx.
+// Transactions run one at a time, and when fn fails the data is
+// restored to the state before fn. Writes made outside of WithinTx
+// while fn runs are rolled back too, which is fine for tests.
+func (s *Storage) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(txKey{}) != nil {
+		return fn(ctx)
+	}
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+	s.mu.Lock()
+	saved := s.data.clone()
+	s.mu.Unlock()
+	if err := fn(context.WithValue(ctx, txKey{}, true)); err != nil {
+		s.mu.Lock()
+		s.data = saved
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// clone copies d deep enough that later writes to d don't change the copy.
+func (d *data) clone() data {
+	c := *d
+	c.users = make(map[int64]*user, len(d.users))
+	for id, u := range d.users {
+		copied := *u
+		c.users[id] = &copied
+	}
+	c.memberships = maps.Clone(d.memberships)
+	c.orgs = maps.Clone(d.orgs)
+	c.apps = maps.Clone(d.apps)
+	c.sessions = maps.Clone(d.sessions)
+	c.invites = clonePointers(d.invites)
+	c.codes = clonePointers(d.codes)
+	c.credentials = clonePointers(d.credentials)
+	c.devices = clonePointers(d.devices)
+	c.events = append(d.events[:0:0], d.events...)
+	return c
+}
+
+func clonePointers[T any](items []*T) []*T {
+	out := make([]*T, len(items))
+	for i, item := range items {
+		copied := *item
+		out[i] = &copied
+	}
+	return out
+}
@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sso/interanal/storage/schema"
+	"sso/migrations"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5"
+)
+
+// migrateLockKey serializes startup migrations of all instances. It
+// must differ from the lock golang-migrate takes itself on its own
+// connection, or the migrations would wait for us forever.
+const migrateLockKey int64 = 0x73736f5f6d6967 // "sso_mig"
+
+// Migrate applies the embedded migrations to the database at dbURL and
+// records them in table, like cmd/migrator does. Instances starting at
+// once take turns on an advisory lock, so the first one migrates and
+// the others find the schema up to date.
+func Migrate(ctx context.Context, dbURL string, table string) (from uint, to uint, err error) {
+	const op = "storage.postgres.Migrate"
+	u, err := url.Parse(dbURL)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: cannot parse db URL: %w", op, err)
+	}
+	u.Scheme = "pgx5"
+	q := u.Query()
+	q.Set("x-migrations-table", table)
+	u.RawQuery = q.Encode()
+
+	lockConn, err := pgx.Connect(ctx, dbURL)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: cannot connect to db: %w", op, err)
+	}
+	// Closing the session releases the lock as well.
+	defer lockConn.Close(context.WithoutCancel(ctx))
+	if _, err := lockConn.Exec(ctx, "select pg_advisory_lock($1)", migrateLockKey); err != nil {
+		return 0, 0, fmt.Errorf("%s: cannot take the migration lock: %w", op, err)
+	}
+
+	src, err := iofs.New(migrations.FS, migrations.PostgresDir)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", src, u.String())
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer m.Close()
+	from, to, err = schema.Up(m, src)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return from, to, nil
+}
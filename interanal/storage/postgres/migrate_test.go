@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConcurrentMigrate проверяет, что несколько экземпляров,
+// стартующих одновременно, накатывают миграции без ошибок
+// и приходят к одной версии.
+func TestConcurrentMigrate(t *testing.T) {
+	const instances = 3
+	type result struct {
+		to  uint
+		err error
+	}
+	results := make(chan result, instances)
+	for range instances {
+		go func() {
+			_, to, err := Migrate(context.Background(), testDBURL(), "migrations")
+			results <- result{to: to, err: err}
+		}()
+	}
+
+	var versions []uint
+	for range instances {
+		r := <-results
+		require.NoError(t, r.err)
+		versions = append(versions, r.to)
+	}
+	assert.Equal(t, []uint{versions[0], versions[0], versions[0]}, versions)
+	assert.NotZero(t, versions[0])
+}
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"sso/interanal/domain/models"
 	"sso/interanal/storage"
+	"sso/lib/secretbox"
 	"time"
 
 	"github.com/jackc/pgerrcode"
@@ -17,54 +19,175 @@ import (
 
 type Storage struct {
 	connection *pgxpool.Pool
+	secrets    *secretbox.Keyring
+	// replicas is nil without read replicas.
+	replicas *replicaSet
+	// recent is nil when reads after writes may be stale.
+	recent *recentWrites
 }
 
-func MustNewConnection(ctx context.Context, dbURL string) *Storage {
+// Options tunes the connection pool. Zero values keep the pgxpool
+// defaults or the ones from the URL.
+type Options struct {
+	MaxConns        int32
+	MinConns        int32
+	MaxConnLifetime time.Duration
+	MaxConnIdleTime time.Duration
+	// ConnectTimeout bounds the initial connection and ping, 4s by default.
+	ConnectTimeout time.Duration
+	// Secrets encrypts app secrets at rest. Without it secrets are
+	// read and written as plaintext.
+	Secrets *secretbox.Keyring
+	// Replicas are URLs of read replicas for user, app and organization
+	// lookups. Each gets a pool with the options above.
+	Replicas []string
+	// ReplicaCheckInterval is how often replicas are pinged, 5s by default.
+	ReplicaCheckInterval time.Duration
+	// ReadAfterWriteWindow sends lookups of a user or organization
+	// written by this storage to the primary for the window, so a user
+	// can log in right after registration. Zero turns it off.
+	ReadAfterWriteWindow time.Duration
+	// Logger reports replicas going down and back, slog.Default() if nil.
+	Logger *slog.Logger
+}
+
+func MustNewConnection(ctx context.Context, dbURL string, opts Options) *Storage {
 	const op = "storage.postgres.MustNewConnection"
-	ctx, cancel := context.WithTimeout(ctx, time.Second*4)
+	connectTimeout := time.Second * 4
+	if opts.ConnectTimeout > 0 {
+		connectTimeout = opts.ConnectTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
 	defer cancel()
-	conn, err := pgxpool.New(ctx, dbURL)
+	conn, err := newPool(ctx, dbURL, opts)
 	if err != nil {
-		log.Fatalf("%s: cannot connect to db with URL: %s, with error: %v", op, dbURL, err)
+		log.Fatalf("%s: %v", op, err)
 	}
 	err = conn.Ping(ctx)
 	if err != nil {
 		log.Fatalf("%s: db is unreachable: %v", op, err)
 	}
-	return &Storage{connection: conn}
+	s := &Storage{connection: conn, secrets: opts.Secrets}
+	if opts.ReadAfterWriteWindow > 0 && len(opts.Replicas) > 0 {
+		s.recent = newRecentWrites(opts.ReadAfterWriteWindow)
+	}
+	if len(opts.Replicas) == 0 {
+		return s
+	}
+	interval := 5 * time.Second
+	if opts.ReplicaCheckInterval > 0 {
+		interval = opts.ReplicaCheckInterval
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	s.replicas = &replicaSet{logger: logger, interval: interval, done: make(chan struct{})}
+	for i, replicaURL := range opts.Replicas {
+		pool, err := newPool(ctx, replicaURL, opts)
+		if err != nil {
+			log.Fatalf("%s: replica %d: %v", op, i, err)
+		}
+		s.replicas.replicas = append(s.replicas.replicas, &replica{pool: pool, host: pool.Config().ConnConfig.Host})
+	}
+	// A replica that is down at start doesn't stop the service,
+	// reads go to the primary until it is back.
+	s.replicas.check()
+	go s.replicas.watch()
+	return s
 }
 
-func (s *Storage) Stop(ctx context.Context) {
-	s.connection.Close()
+func newPool(ctx context.Context, dbURL string, opts Options) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse db URL: %w", err)
+	}
+	if opts.MaxConns > 0 {
+		poolConfig.MaxConns = opts.MaxConns
+	}
+	if opts.MinConns > 0 {
+		poolConfig.MinConns = opts.MinConns
+	}
+	if opts.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = opts.MaxConnLifetime
+	}
+	if opts.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = opts.MaxConnIdleTime
+	}
+	poolConfig.ConnConfig.Tracer = queryTracer{}
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to db: %w", err)
+	}
+	return pool, nil
 }
 
-func (s *Storage) SaveUser(ctx context.Context, email string, passHash []byte) (int64, error) {
+// Stop closes the pools. Close waits for acquired connections to be
+// released, so Stop gives up when ctx is done.
+func (s *Storage) Stop(ctx context.Context) error {
+	const op = "storage.postgres.Stop"
+	closed := make(chan struct{})
+	go func() {
+		if s.replicas != nil {
+			s.replicas.stop()
+		}
+		s.connection.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", op, ctx.Err())
+	}
+}
+
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.connection.Ping(ctx)
+}
+
+func (s *Storage) Stat() *pgxpool.Stat {
+	return s.connection.Stat()
+}
+
+func (s *Storage) SaveUser(ctx context.Context, orgId int64, email string, passHash []byte) (int64, error) {
 	const op = "storage.postgres.SaveUser"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
 	var pgErr *pgconn.PgError
 	var userId int64
-	stmt := `insert into "user"(email, pass_hash) values ($1, $2) returning user_id`
-	err := s.connection.QueryRow(ctx, stmt, email, passHash).Scan(&userId)
+	stmt := `with u as (
+		insert into "user"(org_id, email, pass_hash) values ($1, $2, $3) returning org_id, user_id
+	)
+	insert into membership(org_id, user_id, role) select org_id, user_id, $4 from u returning user_id`
+	err := s.conn(ctx).QueryRow(ctx, stmt, orgId, email, passHash, models.RoleMember).Scan(&userId)
 	if err != nil {
 		if ok := errors.As(err, &pgErr); ok && pgErr.Code == pgerrcode.UniqueViolation {
 			return 0, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
 		}
+		if ok := errors.As(err, &pgErr); ok && pgErr.Code == pgerrcode.ForeignKeyViolation {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+		}
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
-
+	s.recent.mark(userEmailKey(orgId, email), userIdKey(orgId, userId))
 	return userId, nil
 }
 
-func (s *Storage) GetUser(ctx context.Context, email string) (models.User, error) {
+func (s *Storage) GetUser(ctx context.Context, orgId int64, email string) (models.User, error) {
 	const op = "storage.postgres.GetUser"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
 	type Row struct {
 		id       int
+		orgId    int64
 		email    string
 		passHash []byte
 	}
 	var r Row
-	stmt := `select user_id, email, pass_hash from "user" where email=$1`
-	row := s.connection.QueryRow(ctx, stmt, email)
-	err := row.Scan(&r.id, &r.email, &r.passHash)
+	stmt := `select user_id, org_id, email, pass_hash from "user" where org_id=$1 and email=$2`
+	row := s.reader(ctx, userEmailKey(orgId, email)).QueryRow(ctx, stmt, orgId, email)
+	err := row.Scan(&r.id, &r.orgId, &r.email, &r.passHash)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
@@ -72,14 +195,32 @@ func (s *Storage) GetUser(ctx context.Context, email string) (models.User, error
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return models.User{Id: int64(r.id), Email: r.email, PaswordHash: r.passHash}, nil
+	return models.User{Id: int64(r.id), OrgId: r.orgId, Email: r.email, PaswordHash: r.passHash}, nil
+}
+
+func (s *Storage) GetUserById(ctx context.Context, orgId int64, userId int64) (models.User, error) {
+	const op = "storage.postgres.GetUserById"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var user models.User
+	stmt := `select user_id, org_id, email, pass_hash from "user" where org_id=$1 and user_id=$2`
+	err := s.reader(ctx, userIdKey(orgId, userId)).QueryRow(ctx, stmt, orgId, userId).Scan(&user.Id, &user.OrgId, &user.Email, &user.PaswordHash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return user, nil
 }
 
-func (s *Storage) IsAdmin(ctx context.Context, userId int64) (bool, error) {
+func (s *Storage) IsAdmin(ctx context.Context, orgId int64, userId int64) (bool, error) {
 	const op = "storage.postgres.IsAdmin"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
 	var isAdmin bool
-	stmt := `select is_admin from "user" where user_id=$1`
-	err := s.connection.QueryRow(ctx, stmt, userId).Scan(&isAdmin)
+	stmt := `select is_admin from "user" where org_id=$1 and user_id=$2`
+	err := s.reader(ctx, userIdKey(orgId, userId)).QueryRow(ctx, stmt, orgId, userId).Scan(&isAdmin)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
@@ -91,26 +232,805 @@ func (s *Storage) IsAdmin(ctx context.Context, userId int64) (bool, error) {
 
 func (s *Storage) GetApp(ctx context.Context, appId int) (models.App, error) {
 	const op = "storage.postgres.GetApp"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
 	type Row struct {
-		id     int
-		name   string
-		secret string
+		id                 int
+		orgId              int64
+		name               string
+		secret             string
+		allowAutoProvision bool
 	}
 	var r Row
-	stmt := `select app_id, name, secret from app where app_id=$1`
-	err := s.connection.QueryRow(ctx, stmt, appId).Scan(&r.id, &r.name, &r.secret)
+	stmt := `select app_id, org_id, name, secret, allow_auto_provision from app where app_id=$1`
+	err := s.reader(ctx, "").QueryRow(ctx, stmt, appId).Scan(&r.id, &r.orgId, &r.name, &r.secret, &r.allowAutoProvision)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
 		}
 		return models.App{}, fmt.Errorf("%s: %w", op, err)
 	}
-	return models.App{Id: r.id, Name: r.name, Secret: r.secret}, nil
+	secret, err := storage.OpenSecret(s.secrets, r.secret)
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: app %d: %w", op, r.id, err)
+	}
+	return models.App{
+		Id:                 r.id,
+		OrgId:              r.orgId,
+		Name:               r.name,
+		Secret:             secret,
+		AllowAutoProvision: r.allowAutoProvision,
+	}, nil
+}
+
+// RotateAppSecrets encrypts plaintext app secrets and re-encrypts the
+// ones sealed with an old key under the primary key. Rows are locked,
+// so instances starting together don't rotate the same secret twice.
+func (s *Storage) RotateAppSecrets(ctx context.Context) (int, error) {
+	const op = "storage.postgres.RotateAppSecrets"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	if s.secrets == nil {
+		return 0, nil
+	}
+	tx, err := s.conn(ctx).Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+	rows, err := tx.Query(ctx, `select app_id, secret from app for update`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	stale := make(map[int]string)
+	for rows.Next() {
+		var appId int
+		var secret string
+		if err := rows.Scan(&appId, &secret); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		if s.secrets.NeedsRotation(secret) {
+			stale[appId] = secret
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	for appId, secret := range stale {
+		plaintext, err := storage.OpenSecret(s.secrets, secret)
+		if err != nil {
+			return 0, fmt.Errorf("%s: app %d: %w", op, appId, err)
+		}
+		sealed, err := s.secrets.Encrypt(plaintext)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+		if _, err := tx.Exec(ctx, `update app set secret=$1 where app_id=$2`, sealed, appId); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return len(stale), nil
+}
+
+// SaveApp creates an app. The secret is sealed like RotateAppSecrets does.
+func (s *Storage) SaveApp(ctx context.Context, app models.App) error {
+	const op = "storage.postgres.SaveApp"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	secret, err := storage.SealSecret(s.secrets, app.Secret)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	var pgErr *pgconn.PgError
+	stmt := `insert into app(app_id, org_id, name, secret, allow_auto_provision) values ($1, $2, $3, $4, $5)`
+	_, err = s.conn(ctx).Exec(ctx, stmt, app.Id, app.OrgId, app.Name, secret, app.AllowAutoProvision)
+	if err != nil {
+		if ok := errors.As(err, &pgErr); ok && pgErr.Code == pgerrcode.UniqueViolation {
+			return fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+		}
+		if ok := errors.As(err, &pgErr); ok && pgErr.Code == pgerrcode.ForeignKeyViolation {
+			return fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// UpdateApp changes everything but the secret of an app.
+func (s *Storage) UpdateApp(ctx context.Context, app models.App) error {
+	const op = "storage.postgres.UpdateApp"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var pgErr *pgconn.PgError
+	stmt := `update app set org_id=$2, name=$3, allow_auto_provision=$4 where app_id=$1`
+	tag, err := s.conn(ctx).Exec(ctx, stmt, app.Id, app.OrgId, app.Name, app.AllowAutoProvision)
+	if err != nil {
+		if ok := errors.As(err, &pgErr); ok && pgErr.Code == pgerrcode.UniqueViolation {
+			return fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+		}
+		if ok := errors.As(err, &pgErr); ok && pgErr.Code == pgerrcode.ForeignKeyViolation {
+			return fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+	return nil
+}
+
+// SetAppSecret replaces the secret of an app, sealed like SaveApp does.
+func (s *Storage) SetAppSecret(ctx context.Context, appId int, secret string) error {
+	const op = "storage.postgres.SetAppSecret"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	sealed, err := storage.SealSecret(s.secrets, secret)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	tag, err := s.conn(ctx).Exec(ctx, `update app set secret=$2 where app_id=$1`, appId, sealed)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+	return nil
+}
+
+// ListApps returns the apps of an organization without their secrets.
+func (s *Storage) ListApps(ctx context.Context, orgId int64) ([]models.App, error) {
+	const op = "storage.postgres.ListApps"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `select app_id, org_id, name, allow_auto_provision from app where org_id=$1 order by app_id`
+	rows, err := s.reader(ctx, orgKey(orgId)).Query(ctx, stmt, orgId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+	var apps []models.App
+	for rows.Next() {
+		var app models.App
+		if err := rows.Scan(&app.Id, &app.OrgId, &app.Name, &app.AllowAutoProvision); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		apps = append(apps, app)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return apps, nil
+}
+
+func (s *Storage) SaveOrganization(ctx context.Context, name string) (int64, error) {
+	const op = "storage.postgres.SaveOrganization"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var pgErr *pgconn.PgError
+	var orgId int64
+	stmt := `insert into organization(name) values ($1) returning org_id`
+	err := s.conn(ctx).QueryRow(ctx, stmt, name).Scan(&orgId)
+	if err != nil {
+		if ok := errors.As(err, &pgErr); ok && pgErr.Code == pgerrcode.UniqueViolation {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrOrgExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	s.recent.mark(orgKey(orgId))
+	return orgId, nil
+}
+
+func (s *Storage) GetOrganization(ctx context.Context, orgId int64) (models.Organization, error) {
+	const op = "storage.postgres.GetOrganization"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var org models.Organization
+	stmt := `select org_id, name from organization where org_id=$1`
+	err := s.reader(ctx, orgKey(orgId)).QueryRow(ctx, stmt, orgId).Scan(&org.Id, &org.Name)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Organization{}, fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+		}
+		return models.Organization{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return org, nil
+}
+
+func (s *Storage) MemberRole(ctx context.Context, orgId int64, userId int64) (string, error) {
+	const op = "storage.postgres.MemberRole"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var role string
+	stmt := `select role from membership where org_id=$1 and user_id=$2`
+	err := s.reader(ctx, userIdKey(orgId, userId)).QueryRow(ctx, stmt, orgId, userId).Scan(&role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return role, nil
+}
+
+func (s *Storage) SetMemberRole(ctx context.Context, orgId int64, userId int64, role string) error {
+	const op = "storage.postgres.SetMemberRole"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `update membership set role=$3 where org_id=$1 and user_id=$2`
+	tag, err := s.conn(ctx).Exec(ctx, stmt, orgId, userId, role)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	s.recent.mark(userIdKey(orgId, userId))
+	return nil
+}
+
+func (s *Storage) ListMembers(ctx context.Context, orgId int64) ([]models.Member, error) {
+	const op = "storage.postgres.ListMembers"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `select m.user_id, u.email, m.role from membership m
+		join "user" u on u.org_id = m.org_id and u.user_id = m.user_id
+		where m.org_id=$1 order by m.user_id`
+	rows, err := s.reader(ctx, orgKey(orgId)).Query(ctx, stmt, orgId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+	var members []models.Member
+	for rows.Next() {
+		var member models.Member
+		if err := rows.Scan(&member.UserId, &member.Email, &member.Role); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return members, nil
+}
+
+// SetAdmin sets the legacy is_admin flag that IsAdmin reports.
+func (s *Storage) SetAdmin(ctx context.Context, orgId int64, userId int64, isAdmin bool) error {
+	const op = "storage.postgres.SetAdmin"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `update "user" set is_admin=$3 where org_id=$1 and user_id=$2`
+	tag, err := s.conn(ctx).Exec(ctx, stmt, orgId, userId, isAdmin)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	s.recent.mark(userIdKey(orgId, userId))
+	return nil
+}
+
+// RevokeSessions rejects the tokens of a user issued up to revokedAt.
+func (s *Storage) RevokeSessions(ctx context.Context, orgId int64, userId int64, revokedAt time.Time) error {
+	const op = "storage.postgres.RevokeSessions"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `update "user" set sessions_revoked_at=$3 where org_id=$1 and user_id=$2`
+	tag, err := s.conn(ctx).Exec(ctx, stmt, orgId, userId, revokedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	return nil
+}
+
+// SessionsRevokedAt returns the zero time for a user whose sessions
+// were never revoked. It reads from the primary, as a lagging replica
+// would let revoked tokens through.
+func (s *Storage) SessionsRevokedAt(ctx context.Context, orgId int64, userId int64) (time.Time, error) {
+	const op = "storage.postgres.SessionsRevokedAt"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var revokedAt *time.Time
+	stmt := `select sessions_revoked_at from "user" where org_id=$1 and user_id=$2`
+	err := s.conn(ctx).QueryRow(ctx, stmt, orgId, userId).Scan(&revokedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if revokedAt == nil {
+		return time.Time{}, nil
+	}
+	return *revokedAt, nil
+}
+
+func (s *Storage) SaveInvite(ctx context.Context, invite models.Invite, tokenHash []byte) (int64, error) {
+	const op = "storage.postgres.SaveInvite"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var pgErr *pgconn.PgError
+	var inviteId int64
+	stmt := `insert into invite(org_id, email, role, token_hash, created_by, expires_at)
+		values ($1, $2, $3, $4, $5, $6) returning invite_id`
+	err := s.conn(ctx).QueryRow(
+		ctx,
+		stmt,
+		invite.OrgId,
+		invite.Email,
+		invite.Role,
+		tokenHash,
+		invite.CreatedBy,
+		invite.ExpiresAt,
+	).Scan(&inviteId)
+	if err != nil {
+		if ok := errors.As(err, &pgErr); ok && pgErr.Code == pgerrcode.ForeignKeyViolation {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return inviteId, nil
+}
+
+// GetActiveInvite returns an invite that is neither expired nor accepted.
+func (s *Storage) GetActiveInvite(ctx context.Context, tokenHash []byte) (models.Invite, error) {
+	const op = "storage.postgres.GetActiveInvite"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var invite models.Invite
+	stmt := `select invite_id, org_id, email, role, created_by, expires_at from invite
+		where token_hash=$1 and accepted_at is null and expires_at > now()`
+	err := s.conn(ctx).QueryRow(ctx, stmt, tokenHash).Scan(
+		&invite.Id,
+		&invite.OrgId,
+		&invite.Email,
+		&invite.Role,
+		&invite.CreatedBy,
+		&invite.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Invite{}, fmt.Errorf("%s: %w", op, storage.ErrInviteNotFound)
+		}
+		return models.Invite{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return invite, nil
+}
+
+// ConsumeInvite marks an active invite as accepted, so a token can be used only once.
+func (s *Storage) ConsumeInvite(ctx context.Context, tokenHash []byte) (models.Invite, error) {
+	const op = "storage.postgres.ConsumeInvite"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var invite models.Invite
+	stmt := `update invite set accepted_at=now()
+		where token_hash=$1 and accepted_at is null and expires_at > now()
+		returning invite_id, org_id, email, role, created_by, expires_at, accepted_at`
+	err := s.conn(ctx).QueryRow(ctx, stmt, tokenHash).Scan(
+		&invite.Id,
+		&invite.OrgId,
+		&invite.Email,
+		&invite.Role,
+		&invite.CreatedBy,
+		&invite.ExpiresAt,
+		&invite.AcceptedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Invite{}, fmt.Errorf("%s: %w", op, storage.ErrInviteNotFound)
+		}
+		return models.Invite{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return invite, nil
+}
+
+// SavePasswordlessCode invalidates previous codes of the same email and app,
+// so only the latest sent code can be used.
+func (s *Storage) SavePasswordlessCode(ctx context.Context, code models.PasswordlessCode) (int64, error) {
+	const op = "storage.postgres.SavePasswordlessCode"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var pgErr *pgconn.PgError
+	var codeId int64
+	stmt := `with revoked as (
+		update passwordless_code set used_at=now()
+		where app_id=$1 and email=$2 and used_at is null
+	)
+	insert into passwordless_code(app_id, email, code_hash, expires_at)
+	values ($1, $2, $3, $4) returning code_id`
+	err := s.conn(ctx).QueryRow(ctx, stmt, code.AppId, code.Email, code.CodeHash, code.ExpiresAt).Scan(&codeId)
+	if err != nil {
+		if ok := errors.As(err, &pgErr); ok && pgErr.Code == pgerrcode.ForeignKeyViolation {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return codeId, nil
+}
+
+func (s *Storage) GetActivePasswordlessCode(ctx context.Context, appId int, email string) (models.PasswordlessCode, error) {
+	const op = "storage.postgres.GetActivePasswordlessCode"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var code models.PasswordlessCode
+	stmt := `select code_id, app_id, email, code_hash, attempts, expires_at from passwordless_code
+		where app_id=$1 and email=$2 and used_at is null and expires_at > now()
+		order by code_id desc limit 1`
+	err := s.conn(ctx).QueryRow(ctx, stmt, appId, email).Scan(
+		&code.Id,
+		&code.AppId,
+		&code.Email,
+		&code.CodeHash,
+		&code.Attempts,
+		&code.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.PasswordlessCode{}, fmt.Errorf("%s: %w", op, storage.ErrCodeNotFound)
+		}
+		return models.PasswordlessCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return code, nil
+}
+
+// IncrementPasswordlessAttempts spends an attempt of the code. The check
+// and the increment are a single statement, so concurrent guesses can't
+// spend more than maxAttempts.
+func (s *Storage) IncrementPasswordlessAttempts(ctx context.Context, codeId int64, maxAttempts int) error {
+	const op = "storage.postgres.IncrementPasswordlessAttempts"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `update passwordless_code set attempts=attempts+1 where code_id=$1 and attempts < $2`
+	tag, err := s.conn(ctx).Exec(ctx, stmt, codeId, maxAttempts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAttemptsExhausted)
+	}
+	return nil
+}
+
+func (s *Storage) MarkPasswordlessCodeUsed(ctx context.Context, codeId int64) error {
+	const op = "storage.postgres.MarkPasswordlessCodeUsed"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `update passwordless_code set used_at=now() where code_id=$1 and used_at is null`
+	tag, err := s.conn(ctx).Exec(ctx, stmt, codeId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrCodeNotFound)
+	}
+	return nil
+}
+
+func (s *Storage) SaveWebAuthnCredential(ctx context.Context, cred models.WebAuthnCredential) error {
+	const op = "storage.postgres.SaveWebAuthnCredential"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var pgErr *pgconn.PgError
+	stmt := `insert into webauthn_credential(
+		credential_id, user_id, public_key, attestation_type, aaguid,
+		sign_count, transports, backup_eligible, backup_state
+	) values ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err := s.conn(ctx).Exec(
+		ctx,
+		stmt,
+		cred.Id,
+		cred.UserId,
+		cred.PublicKey,
+		cred.AttestationType,
+		cred.AAGUID,
+		int64(cred.SignCount),
+		cred.Transports,
+		cred.BackupEligible,
+		cred.BackupState,
+	)
+	if err != nil {
+		if ok := errors.As(err, &pgErr); ok && pgErr.Code == pgerrcode.UniqueViolation {
+			return fmt.Errorf("%s: %w", op, storage.ErrCredentialExists)
+		}
+		if ok := errors.As(err, &pgErr); ok && pgErr.Code == pgerrcode.ForeignKeyViolation {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) ListWebAuthnCredentials(ctx context.Context, userId int64) ([]models.WebAuthnCredential, error) {
+	const op = "storage.postgres.ListWebAuthnCredentials"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `select credential_id, user_id, public_key, attestation_type, aaguid, sign_count, clone_warning,
+		transports, backup_eligible, backup_state, created_at, last_used_at
+		from webauthn_credential where user_id=$1 order by created_at`
+	rows, err := s.conn(ctx).Query(ctx, stmt, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+	var creds []models.WebAuthnCredential
+	for rows.Next() {
+		var cred models.WebAuthnCredential
+		var signCount int64
+		var lastUsedAt *time.Time
+		err := rows.Scan(
+			&cred.Id,
+			&cred.UserId,
+			&cred.PublicKey,
+			&cred.AttestationType,
+			&cred.AAGUID,
+			&signCount,
+			&cred.CloneWarning,
+			&cred.Transports,
+			&cred.BackupEligible,
+			&cred.BackupState,
+			&cred.CreatedAt,
+			&lastUsedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		cred.SignCount = uint32(signCount)
+		if lastUsedAt != nil {
+			cred.LastUsedAt = *lastUsedAt
+		}
+		creds = append(creds, cred)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return creds, nil
+}
+
+func (s *Storage) UpdateWebAuthnSignCount(ctx context.Context, credentialId []byte, signCount uint32, cloneWarning bool) error {
+	const op = "storage.postgres.UpdateWebAuthnSignCount"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `update webauthn_credential set sign_count=$2, clone_warning=$3, last_used_at=now() where credential_id=$1`
+	tag, err := s.conn(ctx).Exec(ctx, stmt, credentialId, int64(signCount), cloneWarning)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrCredentialNotFound)
+	}
+	return nil
+}
+
+func (s *Storage) DeleteWebAuthnCredential(ctx context.Context, userId int64, credentialId []byte) error {
+	const op = "storage.postgres.DeleteWebAuthnCredential"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `delete from webauthn_credential where user_id=$1 and credential_id=$2`
+	tag, err := s.conn(ctx).Exec(ctx, stmt, userId, credentialId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrCredentialNotFound)
+	}
+	return nil
+}
+
+func (s *Storage) SaveWebAuthnSession(ctx context.Context, sessionHash []byte, session models.WebAuthnSession) error {
+	const op = "storage.postgres.SaveWebAuthnSession"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var appId *int
+	if session.AppId != 0 {
+		appId = &session.AppId
+	}
+	stmt := `insert into webauthn_session(session_hash, user_id, app_id, ceremony, data, expires_at)
+		values ($1, $2, $3, $4, $5, $6)`
+	_, err := s.conn(ctx).Exec(ctx, stmt, sessionHash, session.UserId, appId, session.Ceremony, session.Data, session.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ConsumeWebAuthnSession deletes the session, so a ceremony can be finished only once.
+func (s *Storage) ConsumeWebAuthnSession(ctx context.Context, sessionHash []byte, ceremony string) (models.WebAuthnSession, error) {
+	const op = "storage.postgres.ConsumeWebAuthnSession"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var session models.WebAuthnSession
+	var appId *int
+	stmt := `delete from webauthn_session
+		where session_hash=$1 and ceremony=$2 and expires_at > now()
+		returning user_id, app_id, ceremony, data, expires_at`
+	err := s.conn(ctx).QueryRow(ctx, stmt, sessionHash, ceremony).Scan(
+		&session.UserId,
+		&appId,
+		&session.Ceremony,
+		&session.Data,
+		&session.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+		}
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if appId != nil {
+		session.AppId = *appId
+	}
+	return session, nil
+}
+
+func (s *Storage) SaveDeviceAuthorization(ctx context.Context, auth models.DeviceAuthorization) error {
+	const op = "storage.postgres.SaveDeviceAuthorization"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var pgErr *pgconn.PgError
+	stmt := `insert into device_authorization(device_code_hash, user_code, app_id, interval_seconds, expires_at)
+		values ($1, $2, $3, $4, $5)`
+	_, err := s.conn(ctx).Exec(
+		ctx,
+		stmt,
+		auth.DeviceCodeHash,
+		auth.UserCode,
+		auth.AppId,
+		int(auth.Interval/time.Second),
+		auth.ExpiresAt,
+	)
+	if err != nil {
+		if ok := errors.As(err, &pgErr); ok && pgErr.Code == pgerrcode.UniqueViolation {
+			return fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeExists)
+		}
+		if ok := errors.As(err, &pgErr); ok && pgErr.Code == pgerrcode.ForeignKeyViolation {
+			return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) GetDeviceAuthorization(ctx context.Context, deviceCodeHash []byte) (models.DeviceAuthorization, error) {
+	const op = "storage.postgres.GetDeviceAuthorization"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `select device_code_hash, user_code, app_id, coalesce(user_id, 0), status, interval_seconds,
+		last_polled_at, expires_at
+		from device_authorization where device_code_hash=$1`
+	auth, err := scanDeviceAuthorization(s.conn(ctx).QueryRow(ctx, stmt, deviceCodeHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.DeviceAuthorization{}, fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeNotFound)
+		}
+		return models.DeviceAuthorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return auth, nil
+}
+
+func (s *Storage) GetDeviceAuthorizationByUserCode(ctx context.Context, userCode string) (models.DeviceAuthorization, error) {
+	const op = "storage.postgres.GetDeviceAuthorizationByUserCode"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `select device_code_hash, user_code, app_id, coalesce(user_id, 0), status, interval_seconds,
+		last_polled_at, expires_at
+		from device_authorization where user_code=$1`
+	auth, err := scanDeviceAuthorization(s.conn(ctx).QueryRow(ctx, stmt, userCode))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.DeviceAuthorization{}, fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeNotFound)
+		}
+		return models.DeviceAuthorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return auth, nil
+}
+
+// ResolveDeviceAuthorization approves or denies a pending authorization.
+func (s *Storage) ResolveDeviceAuthorization(ctx context.Context, userCode string, userId int64, status string) error {
+	const op = "storage.postgres.ResolveDeviceAuthorization"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `update device_authorization set user_id=$2, status=$3
+		where user_code=$1 and status='pending' and expires_at > now()`
+	tag, err := s.conn(ctx).Exec(ctx, stmt, userCode, userId, status)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeNotFound)
+	}
+	return nil
+}
+
+func (s *Storage) UpdateDevicePoll(ctx context.Context, deviceCodeHash []byte, polledAt time.Time, interval time.Duration) error {
+	const op = "storage.postgres.UpdateDevicePoll"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `update device_authorization set last_polled_at=$2, interval_seconds=$3 where device_code_hash=$1`
+	tag, err := s.conn(ctx).Exec(ctx, stmt, deviceCodeHash, polledAt, int(interval/time.Second))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeNotFound)
+	}
+	return nil
+}
+
+// ConsumeDeviceAuthorization moves an approved authorization to consumed,
+// so tokens are issued for a device code only once.
+func (s *Storage) ConsumeDeviceAuthorization(ctx context.Context, deviceCodeHash []byte) error {
+	const op = "storage.postgres.ConsumeDeviceAuthorization"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `update device_authorization set status='consumed' where device_code_hash=$1 and status='approved'`
+	tag, err := s.conn(ctx).Exec(ctx, stmt, deviceCodeHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeNotFound)
+	}
+	return nil
+}
+
+func (s *Storage) SaveAuditEvent(ctx context.Context, event models.AuditEvent) (int64, error) {
+	const op = "storage.postgres.SaveAuditEvent"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var eventId int64
+	stmt := `insert into audit_event(org_id, actor_id, target_user_id, app_id, action, reason)
+		values ($1, $2, $3, $4, $5, $6) returning event_id`
+	err := s.conn(ctx).QueryRow(
+		ctx,
+		stmt,
+		event.OrgId,
+		event.ActorId,
+		event.TargetUserId,
+		event.AppId,
+		event.Action,
+		event.Reason,
+	).Scan(&eventId)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return eventId, nil
+}
+
+func scanDeviceAuthorization(row pgx.Row) (models.DeviceAuthorization, error) {
+	var auth models.DeviceAuthorization
+	var intervalSeconds int
+	var lastPolledAt *time.Time
+	err := row.Scan(
+		&auth.DeviceCodeHash,
+		&auth.UserCode,
+		&auth.AppId,
+		&auth.UserId,
+		&auth.Status,
+		&intervalSeconds,
+		&lastPolledAt,
+		&auth.ExpiresAt,
+	)
+	if err != nil {
+		return models.DeviceAuthorization{}, err
+	}
+	auth.Interval = time.Duration(intervalSeconds) * time.Second
+	if lastPolledAt != nil {
+		auth.LastPolledAt = *lastPolledAt
+	}
+	return auth, nil
 }
 
 func (s *Storage) truncateUsers(ctx context.Context) error {
-	stmt := `truncate "user"`
-	_, err := s.connection.Exec(ctx, stmt)
+	stmt := `truncate "user" cascade`
+	_, err := s.conn(ctx).Exec(ctx, stmt)
 	if err != nil {
 		return err
 	}
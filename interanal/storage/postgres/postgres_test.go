@@ -2,13 +2,16 @@ package postgres
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
-	"fmt"
 	"os"
 	"sso/interanal/config"
 	"sso/interanal/domain/models"
 	"sso/interanal/storage"
+	"sso/interanal/storage/storagetest"
+	"sso/lib/secretbox"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -16,36 +19,33 @@ import (
 
 func TestMain(m *testing.M) {
 	exitVal := m.Run()
-	path := "../../../config/db.yaml"
-	cfg := config.MustLoadDBConfig(path)
-	dbURL := fmt.Sprintf("postgres://%s:%s@localhost:%s/%s", cfg.User, cfg.Password, cfg.Port, cfg.DBName)
 	ctx := context.Background()
-	s := MustNewConnection(ctx, dbURL)
+	s := MustNewConnection(ctx, testDBURL(), Options{})
 	s.truncateUsers(ctx)
 	os.Exit(exitVal)
 }
 
+// testDBURL reads the database section of the local config, so
+// DB_* variables redirect the tests to another database.
+func testDBURL() string {
+	return config.MustLoadByPath("../../../config/local.yaml").Database.URL()
+}
+
 // TestSuccessfullyConnectToDb проверяет,
 // что подключение к базе данных происходит успешно.
 func TestSuccessfullyConnectToDb(t *testing.T) {
-	path := "../../../config/db.yaml"
-	cfg := config.MustLoadDBConfig(path)
-	dbURL := fmt.Sprintf("postgres://%s:%s@localhost:%s/%s", cfg.User, cfg.Password, cfg.Port, cfg.DBName)
 	ctx := context.Background()
 
-	MustNewConnection(ctx, dbURL)
+	MustNewConnection(ctx, testDBURL(), Options{})
 }
 
 // TestSaveUser проверяет, что пользователь
 // успешно сохраняется.
 func TestSaveUser(t *testing.T) {
-	path := "../../../config/db.yaml"
-	cfg := config.MustLoadDBConfig(path)
-	dbURL := fmt.Sprintf("postgres://%s:%s@localhost:%s/%s", cfg.User, cfg.Password, cfg.Port, cfg.DBName)
 	ctx := context.Background()
-	s := MustNewConnection(ctx, dbURL)
+	s := MustNewConnection(ctx, testDBURL(), Options{})
 
-	id, err := s.SaveUser(ctx, "test1@gmail.com", []byte("qwertyy"))
+	id, err := s.SaveUser(ctx, models.DefaultOrgId, "test1@gmail.com", []byte("qwertyy"))
 
 	require.NoError(t, err)
 	assert.Greater(t, int(id), 0)
@@ -54,17 +54,14 @@ func TestSaveUser(t *testing.T) {
 // TestCannotSaveUserWithDuplicateEmail проверяет,
 // что добавить юзера с уже существующим email в таблицу нельзя.
 func TestCannotSaveUserWithDuplicateEmail(t *testing.T) {
-	path := "../../../config/db.yaml"
-	cfg := config.MustLoadDBConfig(path)
-	dbURL := fmt.Sprintf("postgres://%s:%s@localhost:%s/%s", cfg.User, cfg.Password, cfg.Port, cfg.DBName)
 	ctx := context.Background()
-	s := MustNewConnection(ctx, dbURL)
+	s := MustNewConnection(ctx, testDBURL(), Options{})
 
-	id, err := s.SaveUser(ctx, "TestCannotSaveUserWithDuplicateEmail@gmail.com", []byte("qwertyy"))
+	id, err := s.SaveUser(ctx, models.DefaultOrgId, "TestCannotSaveUserWithDuplicateEmail@gmail.com", []byte("qwertyy"))
 	require.NoError(t, err)
 	assert.Greater(t, int(id), 0)
 
-	id, err = s.SaveUser(ctx, "TestCannotSaveUserWithDuplicateEmail@gmail.com", []byte("qwertyy"))
+	id, err = s.SaveUser(ctx, models.DefaultOrgId, "TestCannotSaveUserWithDuplicateEmail@gmail.com", []byte("qwertyy"))
 	assert.ErrorIs(t, errors.Unwrap(err), storage.ErrUserExists)
 	assert.Equal(t, 0, int(id))
 }
@@ -72,20 +69,18 @@ func TestCannotSaveUserWithDuplicateEmail(t *testing.T) {
 // TestGetUser проверяет, что
 // запрос на получения юзера успешно выполняется.
 func TestGetUser(t *testing.T) {
-	path := "../../../config/db.yaml"
-	cfg := config.MustLoadDBConfig(path)
-	dbURL := fmt.Sprintf("postgres://%s:%s@localhost:%s/%s", cfg.User, cfg.Password, cfg.Port, cfg.DBName)
 	ctx := context.Background()
-	s := MustNewConnection(ctx, dbURL)
+	s := MustNewConnection(ctx, testDBURL(), Options{})
 	email := "TestGetUser@gmail.com"
 	pass := []byte("qwe")
 
-	_, err := s.SaveUser(ctx, email, pass)
+	_, err := s.SaveUser(ctx, models.DefaultOrgId, email, pass)
 	require.NoError(t, err)
-	user, err := s.GetUser(ctx, email)
+	user, err := s.GetUser(ctx, models.DefaultOrgId, email)
 
 	require.NoError(t, err)
 	assert.Equal(t, user.Email, email)
+	assert.Equal(t, user.OrgId, models.DefaultOrgId)
 	assert.Equal(t, user.PaswordHash, pass)
 	assert.Greater(t, int(user.Id), 0)
 }
@@ -94,13 +89,10 @@ func TestGetUser(t *testing.T) {
 // что если юзера нет в базе данных, то возвращается пустая модель
 // юзера и ошибка ErrUserNotFound.
 func TestCannotGetUserBecouseItsNotExists(t *testing.T) {
-	path := "../../../config/db.yaml"
-	cfg := config.MustLoadDBConfig(path)
-	dbURL := fmt.Sprintf("postgres://%s:%s@localhost:%s/%s", cfg.User, cfg.Password, cfg.Port, cfg.DBName)
 	ctx := context.Background()
-	s := MustNewConnection(ctx, dbURL)
+	s := MustNewConnection(ctx, testDBURL(), Options{})
 
-	user, err := s.GetUser(ctx, "aboba")
+	user, err := s.GetUser(ctx, models.DefaultOrgId, "aboba")
 
 	assert.ErrorIs(t, errors.Unwrap(err), storage.ErrUserNotFound)
 	assert.Equal(t, user, models.User{})
@@ -109,15 +101,12 @@ func TestCannotGetUserBecouseItsNotExists(t *testing.T) {
 // TestIsAdmin проверяет, что если юзерн не является
 // админом, то метод IsAdmin возвращает false.
 func TestIsAdmin(t *testing.T) {
-	path := "../../../config/db.yaml"
-	cfg := config.MustLoadDBConfig(path)
-	dbURL := fmt.Sprintf("postgres://%s:%s@localhost:%s/%s", cfg.User, cfg.Password, cfg.Port, cfg.DBName)
 	ctx := context.Background()
-	s := MustNewConnection(ctx, dbURL)
-	user_id, err := s.SaveUser(ctx, "TestIsAdmin@gmail.com", []byte("qwe"))
+	s := MustNewConnection(ctx, testDBURL(), Options{})
+	user_id, err := s.SaveUser(ctx, models.DefaultOrgId, "TestIsAdmin@gmail.com", []byte("qwe"))
 	require.NoError(t, err)
 
-	isAdmin, err := s.IsAdmin(ctx, user_id)
+	isAdmin, err := s.IsAdmin(ctx, models.DefaultOrgId, user_id)
 	require.NoError(t, err)
 	assert.Equal(t, isAdmin, false)
 }
@@ -126,14 +115,367 @@ func TestIsAdmin(t *testing.T) {
 // если попытаться проверить админа у несуществующего пользователя,
 // то вернется ошибка ErrUserNotFound.
 func TestCannotCheckAdminBecouseUserNotExists(t *testing.T) {
-	path := "../../../config/db.yaml"
-	cfg := config.MustLoadDBConfig(path)
-	dbURL := fmt.Sprintf("postgres://%s:%s@localhost:%s/%s", cfg.User, cfg.Password, cfg.Port, cfg.DBName)
 	ctx := context.Background()
-	s := MustNewConnection(ctx, dbURL)
+	s := MustNewConnection(ctx, testDBURL(), Options{})
+
+	isAdmin, err := s.IsAdmin(ctx, models.DefaultOrgId, 2)
+
+	assert.ErrorIs(t, errors.Unwrap(err), storage.ErrUserNotFound)
+	assert.Equal(t, isAdmin, false)
+}
+
+// TestSameEmailInDifferentOrganizations проверяет, что
+// уникальность email ограничена организацией: один и тот же
+// email можно зарегистрировать в разных организациях.
+func TestSameEmailInDifferentOrganizations(t *testing.T) {
+	ctx := context.Background()
+	s := MustNewConnection(ctx, testDBURL(), Options{})
+	email := "TestSameEmailInDifferentOrganizations@gmail.com"
+	orgId, err := s.SaveOrganization(ctx, "TestSameEmailInDifferentOrganizations")
+	require.NoError(t, err)
+
+	defaultUserId, err := s.SaveUser(ctx, models.DefaultOrgId, email, []byte("qwe"))
+	require.NoError(t, err)
+	orgUserId, err := s.SaveUser(ctx, orgId, email, []byte("qwe"))
+	require.NoError(t, err)
+
+	assert.NotEqual(t, defaultUserId, orgUserId)
+}
+
+// TestCannotAccessUserFromAnotherOrganization проверяет, что
+// юзер одной организации не виден в другой: GetUser и IsAdmin
+// возвращают ErrUserNotFound.
+func TestCannotAccessUserFromAnotherOrganization(t *testing.T) {
+	ctx := context.Background()
+	s := MustNewConnection(ctx, testDBURL(), Options{})
+	email := "TestCannotAccessUserFromAnotherOrganization@gmail.com"
+	orgId, err := s.SaveOrganization(ctx, "TestCannotAccessUserFromAnotherOrganization")
+	require.NoError(t, err)
+	userId, err := s.SaveUser(ctx, orgId, email, []byte("qwe"))
+	require.NoError(t, err)
 
-	isAdmin, err := s.IsAdmin(ctx, 2)
+	user, err := s.GetUser(ctx, models.DefaultOrgId, email)
+	assert.ErrorIs(t, errors.Unwrap(err), storage.ErrUserNotFound)
+	assert.Equal(t, user, models.User{})
 
+	isAdmin, err := s.IsAdmin(ctx, models.DefaultOrgId, userId)
 	assert.ErrorIs(t, errors.Unwrap(err), storage.ErrUserNotFound)
 	assert.Equal(t, isAdmin, false)
 }
+
+// TestCannotSaveUserInNonExistentOrganization проверяет, что
+// при сохранении юзера в несуществующую организацию
+// возвращается ошибка ErrOrgNotFound.
+func TestCannotSaveUserInNonExistentOrganization(t *testing.T) {
+	ctx := context.Background()
+	s := MustNewConnection(ctx, testDBURL(), Options{})
+
+	id, err := s.SaveUser(ctx, 99999, "TestCannotSaveUserInNonExistentOrganization@gmail.com", []byte("qwe"))
+
+	assert.ErrorIs(t, errors.Unwrap(err), storage.ErrOrgNotFound)
+	assert.Equal(t, 0, int(id))
+}
+
+// TestNewUserIsOrganizationMember проверяет, что
+// сохраненный юзер становится участником своей организации
+// с ролью member.
+func TestNewUserIsOrganizationMember(t *testing.T) {
+	ctx := context.Background()
+	s := MustNewConnection(ctx, testDBURL(), Options{})
+	userId, err := s.SaveUser(ctx, models.DefaultOrgId, "TestNewUserIsOrganizationMember@gmail.com", []byte("qwe"))
+	require.NoError(t, err)
+
+	role, err := s.MemberRole(ctx, models.DefaultOrgId, userId)
+
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleMember, role)
+}
+
+// TestInviteCanBeConsumedOnlyOnce проверяет, что
+// приглашение принимается один раз, а повторная попытка
+// возвращает ошибку ErrInviteNotFound.
+func TestInviteCanBeConsumedOnlyOnce(t *testing.T) {
+	ctx := context.Background()
+	s := MustNewConnection(ctx, testDBURL(), Options{})
+	inviterId, err := s.SaveUser(ctx, models.DefaultOrgId, "TestInviteCanBeConsumedOnlyOnce@gmail.com", []byte("qwe"))
+	require.NoError(t, err)
+	tokenHash := []byte("TestInviteCanBeConsumedOnlyOnce")
+	invite := models.Invite{
+		OrgId:     models.DefaultOrgId,
+		Email:     "invited-TestInviteCanBeConsumedOnlyOnce@gmail.com",
+		Role:      models.RoleAdmin,
+		CreatedBy: inviterId,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	inviteId, err := s.SaveInvite(ctx, invite, tokenHash)
+	require.NoError(t, err)
+
+	consumed, err := s.ConsumeInvite(ctx, tokenHash)
+	require.NoError(t, err)
+	assert.Equal(t, inviteId, consumed.Id)
+	assert.Equal(t, invite.Email, consumed.Email)
+	assert.Equal(t, invite.Role, consumed.Role)
+	assert.False(t, consumed.AcceptedAt.IsZero())
+
+	_, err = s.ConsumeInvite(ctx, tokenHash)
+	assert.ErrorIs(t, errors.Unwrap(err), storage.ErrInviteNotFound)
+}
+
+// TestCannotConsumeExpiredInvite проверяет, что
+// просроченное приглашение принять нельзя.
+func TestCannotConsumeExpiredInvite(t *testing.T) {
+	ctx := context.Background()
+	s := MustNewConnection(ctx, testDBURL(), Options{})
+	inviterId, err := s.SaveUser(ctx, models.DefaultOrgId, "TestCannotConsumeExpiredInvite@gmail.com", []byte("qwe"))
+	require.NoError(t, err)
+	tokenHash := []byte("TestCannotConsumeExpiredInvite")
+	_, err = s.SaveInvite(ctx, models.Invite{
+		OrgId:     models.DefaultOrgId,
+		Email:     "invited-TestCannotConsumeExpiredInvite@gmail.com",
+		Role:      models.RoleMember,
+		CreatedBy: inviterId,
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}, tokenHash)
+	require.NoError(t, err)
+
+	_, err = s.ConsumeInvite(ctx, tokenHash)
+
+	assert.ErrorIs(t, errors.Unwrap(err), storage.ErrInviteNotFound)
+}
+
+// TestSetMemberRole проверяет, что роль участника
+// организации обновляется.
+func TestSetMemberRole(t *testing.T) {
+	ctx := context.Background()
+	s := MustNewConnection(ctx, testDBURL(), Options{})
+	userId, err := s.SaveUser(ctx, models.DefaultOrgId, "TestSetMemberRole@gmail.com", []byte("qwe"))
+	require.NoError(t, err)
+
+	err = s.SetMemberRole(ctx, models.DefaultOrgId, userId, models.RoleOwner)
+	require.NoError(t, err)
+
+	role, err := s.MemberRole(ctx, models.DefaultOrgId, userId)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleOwner, role)
+}
+
+// TestOnlyLatestPasswordlessCodeIsActive проверяет, что
+// новый одноразовый код отзывает ранее выданные.
+func TestOnlyLatestPasswordlessCodeIsActive(t *testing.T) {
+	ctx := context.Background()
+	s := MustNewConnection(ctx, testDBURL(), Options{})
+	email := "TestOnlyLatestPasswordlessCodeIsActive@gmail.com"
+	code := models.PasswordlessCode{AppId: 1, Email: email, CodeHash: []byte("first"), ExpiresAt: time.Now().Add(time.Minute)}
+	_, err := s.SavePasswordlessCode(ctx, code)
+	require.NoError(t, err)
+	code.CodeHash = []byte("second")
+	secondId, err := s.SavePasswordlessCode(ctx, code)
+	require.NoError(t, err)
+
+	active, err := s.GetActivePasswordlessCode(ctx, 1, email)
+
+	require.NoError(t, err)
+	assert.Equal(t, secondId, active.Id)
+	assert.Equal(t, []byte("second"), active.CodeHash)
+	assert.Equal(t, 0, active.Attempts)
+}
+
+// TestPasswordlessCodeCanBeUsedOnlyOnce проверяет, что
+// использованный код больше не активен.
+func TestPasswordlessCodeCanBeUsedOnlyOnce(t *testing.T) {
+	ctx := context.Background()
+	s := MustNewConnection(ctx, testDBURL(), Options{})
+	email := "TestPasswordlessCodeCanBeUsedOnlyOnce@gmail.com"
+	codeId, err := s.SavePasswordlessCode(ctx, models.PasswordlessCode{
+		AppId:     1,
+		Email:     email,
+		CodeHash:  []byte("code"),
+		ExpiresAt: time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.IncrementPasswordlessAttempts(ctx, codeId, 3))
+
+	require.NoError(t, s.MarkPasswordlessCodeUsed(ctx, codeId))
+	err = s.MarkPasswordlessCodeUsed(ctx, codeId)
+	assert.ErrorIs(t, errors.Unwrap(err), storage.ErrCodeNotFound)
+
+	_, err = s.GetActivePasswordlessCode(ctx, 1, email)
+	assert.ErrorIs(t, errors.Unwrap(err), storage.ErrCodeNotFound)
+}
+
+// TestWebAuthnCredentialLifecycle проверяет сохранение,
+// получение списка, обновление счетчика и удаление ключа.
+func TestWebAuthnCredentialLifecycle(t *testing.T) {
+	ctx := context.Background()
+	s := MustNewConnection(ctx, testDBURL(), Options{})
+	userId, err := s.SaveUser(ctx, models.DefaultOrgId, "TestWebAuthnCredentialLifecycle@gmail.com", []byte("qwe"))
+	require.NoError(t, err)
+	cred := models.WebAuthnCredential{
+		Id:              []byte("TestWebAuthnCredentialLifecycle"),
+		UserId:          userId,
+		PublicKey:       []byte("public-key"),
+		AttestationType: "none",
+		AAGUID:          make([]byte, 16),
+		Transports:      []string{"internal", "hybrid"},
+	}
+
+	require.NoError(t, s.SaveWebAuthnCredential(ctx, cred))
+	err = s.SaveWebAuthnCredential(ctx, cred)
+	assert.ErrorIs(t, errors.Unwrap(err), storage.ErrCredentialExists)
+
+	require.NoError(t, s.UpdateWebAuthnSignCount(ctx, cred.Id, 7, false))
+	creds, err := s.ListWebAuthnCredentials(ctx, userId)
+	require.NoError(t, err)
+	require.Len(t, creds, 1)
+	assert.Equal(t, uint32(7), creds[0].SignCount)
+	assert.Equal(t, cred.Transports, creds[0].Transports)
+
+	require.NoError(t, s.DeleteWebAuthnCredential(ctx, userId, cred.Id))
+	err = s.DeleteWebAuthnCredential(ctx, userId, cred.Id)
+	assert.ErrorIs(t, errors.Unwrap(err), storage.ErrCredentialNotFound)
+}
+
+// TestWebAuthnSessionCanBeConsumedOnlyOnce проверяет, что
+// сессия церемонии удаляется после использования.
+func TestWebAuthnSessionCanBeConsumedOnlyOnce(t *testing.T) {
+	ctx := context.Background()
+	s := MustNewConnection(ctx, testDBURL(), Options{})
+	userId, err := s.SaveUser(ctx, models.DefaultOrgId, "TestWebAuthnSessionCanBeConsumedOnlyOnce@gmail.com", []byte("qwe"))
+	require.NoError(t, err)
+	sessionHash := []byte("TestWebAuthnSessionCanBeConsumedOnlyOnce")
+	err = s.SaveWebAuthnSession(ctx, sessionHash, models.WebAuthnSession{
+		UserId:    userId,
+		AppId:     1,
+		Ceremony:  models.CeremonyLogin,
+		Data:      []byte(`{"challenge":"abc"}`),
+		ExpiresAt: time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+
+	_, err = s.ConsumeWebAuthnSession(ctx, sessionHash, models.CeremonyRegistration)
+	assert.ErrorIs(t, errors.Unwrap(err), storage.ErrSessionNotFound)
+	session, err := s.ConsumeWebAuthnSession(ctx, sessionHash, models.CeremonyLogin)
+	require.NoError(t, err)
+	assert.Equal(t, userId, session.UserId)
+	assert.Equal(t, 1, session.AppId)
+	_, err = s.ConsumeWebAuthnSession(ctx, sessionHash, models.CeremonyLogin)
+	assert.ErrorIs(t, errors.Unwrap(err), storage.ErrSessionNotFound)
+}
+
+// TestDeviceAuthorizationLifecycle проверяет, что
+// запрос устройства подтверждается один раз, а токен
+// по нему выдается тоже один раз.
+func TestDeviceAuthorizationLifecycle(t *testing.T) {
+	ctx := context.Background()
+	s := MustNewConnection(ctx, testDBURL(), Options{})
+	userId, err := s.SaveUser(ctx, models.DefaultOrgId, "TestDeviceAuthorizationLifecycle@gmail.com", []byte("qwe"))
+	require.NoError(t, err)
+	hash := []byte("TestDeviceAuthorizationLifecycle")
+	err = s.SaveDeviceAuthorization(ctx, models.DeviceAuthorization{
+		DeviceCodeHash: hash,
+		UserCode:       "BCDF-TDAL",
+		AppId:          1,
+		Status:         models.DeviceStatusPending,
+		Interval:       5 * time.Second,
+		ExpiresAt:      time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+
+	err = s.ConsumeDeviceAuthorization(ctx, hash)
+	assert.ErrorIs(t, errors.Unwrap(err), storage.ErrDeviceCodeNotFound)
+
+	require.NoError(t, s.ResolveDeviceAuthorization(ctx, "BCDF-TDAL", userId, models.DeviceStatusApproved))
+	err = s.ResolveDeviceAuthorization(ctx, "BCDF-TDAL", userId, models.DeviceStatusDenied)
+	assert.ErrorIs(t, errors.Unwrap(err), storage.ErrDeviceCodeNotFound)
+
+	polledAt := time.Now()
+	require.NoError(t, s.UpdateDevicePoll(ctx, hash, polledAt, 10*time.Second))
+	auth, err := s.GetDeviceAuthorization(ctx, hash)
+	require.NoError(t, err)
+	assert.Equal(t, models.DeviceStatusApproved, auth.Status)
+	assert.Equal(t, userId, auth.UserId)
+	assert.Equal(t, 10*time.Second, auth.Interval)
+	assert.WithinDuration(t, polledAt, auth.LastPolledAt, time.Millisecond)
+
+	require.NoError(t, s.ConsumeDeviceAuthorization(ctx, hash))
+	err = s.ConsumeDeviceAuthorization(ctx, hash)
+	assert.ErrorIs(t, errors.Unwrap(err), storage.ErrDeviceCodeNotFound)
+}
+
+// TestSaveAuditEvent проверяет, что событие аудита
+// сохраняется и получает идентификатор.
+func TestSaveAuditEvent(t *testing.T) {
+	ctx := context.Background()
+	s := MustNewConnection(ctx, testDBURL(), Options{})
+	actorId, err := s.SaveUser(ctx, models.DefaultOrgId, "TestSaveAuditEventActor@gmail.com", []byte("qwe"))
+	require.NoError(t, err)
+	targetId, err := s.SaveUser(ctx, models.DefaultOrgId, "TestSaveAuditEventTarget@gmail.com", []byte("qwe"))
+	require.NoError(t, err)
+
+	eventId, err := s.SaveAuditEvent(ctx, models.AuditEvent{
+		OrgId:        models.DefaultOrgId,
+		ActorId:      actorId,
+		TargetUserId: targetId,
+		AppId:        1,
+		Action:       models.AuditActionImpersonate,
+		Reason:       "support ticket",
+	})
+
+	require.NoError(t, err)
+	assert.NotZero(t, eventId)
+}
+
+// TestGetAppDecryptsSecret проверяет, что секрет приложения
+// хранится зашифрованным и расшифровывается при чтении.
+func TestGetAppDecryptsSecret(t *testing.T) {
+	ctx := context.Background()
+	secrets, err := secretbox.ParseKeyring([]string{"test:" + base64.StdEncoding.EncodeToString(make([]byte, secretbox.KeySize))})
+	require.NoError(t, err)
+	s := MustNewConnection(ctx, testDBURL(), Options{Secrets: secrets})
+	sealed, err := secrets.Encrypt("encrypted-secret")
+	require.NoError(t, err)
+	_, err = s.connection.Exec(ctx, `insert into app (app_id, org_id, name, secret) values (101, $1, 'encrypted', $2)`, models.DefaultOrgId, sealed)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		s.connection.Exec(ctx, `delete from app where app_id = 101`)
+	})
+
+	app, err := s.GetApp(ctx, 101)
+	require.NoError(t, err)
+	assert.Equal(t, "encrypted-secret", app.Secret)
+
+	_, err = MustNewConnection(ctx, testDBURL(), Options{}).GetApp(ctx, 101)
+	assert.Error(t, err)
+}
+
+// TestConformance проверяет postgres общим набором
+// тестов хранилища.
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, MustNewConnection(context.Background(), testDBURL(), Options{}))
+}
+
+// TestWithinTxRetriesSerializationFailures проверяет, что
+// конкурирующие транзакции повторяются, а не падают.
+func TestWithinTxRetriesSerializationFailures(t *testing.T) {
+	ctx := context.Background()
+	s := MustNewConnection(ctx, testDBURL(), Options{})
+	userId, err := s.SaveUser(ctx, models.DefaultOrgId, "TestWithinTxRetries@gmail.com", []byte("qwertyy"))
+	require.NoError(t, err)
+
+	roles := []string{models.RoleMember, models.RoleAdmin, models.RoleOwner}
+	errs := make(chan error, len(roles))
+	for _, role := range roles {
+		go func() {
+			errs <- s.WithinTx(ctx, func(ctx context.Context) error {
+				if _, err := s.MemberRole(ctx, models.DefaultOrgId, userId); err != nil {
+					return err
+				}
+				time.Sleep(10 * time.Millisecond)
+				return s.SetMemberRole(ctx, models.DefaultOrgId, userId, role)
+			})
+		}()
+	}
+	for range roles {
+		assert.NoError(t, <-errs)
+	}
+}
@@ -0,0 +1,157 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sso/interanal/storage"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// replica is a read replica. Reads skip it while its last health
+// check failed.
+type replica struct {
+	pool    *pgxpool.Pool
+	host    string
+	healthy bool
+}
+
+// replicaSet routes reads over the healthy replicas in turn.
+type replicaSet struct {
+	logger   *slog.Logger
+	mu       sync.Mutex
+	replicas []*replica
+	next     int
+	interval time.Duration
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// pick returns the next healthy replica or nil when there is none.
+func (rs *replicaSet) pick() *pgxpool.Pool {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	for range rs.replicas {
+		r := rs.replicas[rs.next]
+		rs.next = (rs.next + 1) % len(rs.replicas)
+		if r.healthy {
+			return r.pool
+		}
+	}
+	return nil
+}
+
+// watch pings every replica each interval until stop is called.
+func (rs *replicaSet) watch() {
+	ticker := time.NewTicker(rs.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rs.done:
+			return
+		case <-ticker.C:
+			rs.check()
+		}
+	}
+}
+
+func (rs *replicaSet) check() {
+	const op = "storage.postgres.replicaSet.check"
+	logger := rs.logger.With(slog.String("op", op))
+	for _, r := range rs.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), rs.interval)
+		err := r.pool.Ping(ctx)
+		cancel()
+		rs.mu.Lock()
+		if r.healthy != (err == nil) {
+			if err != nil {
+				logger.Warn("replica is down, reading from the primary", slog.String("host", r.host), slog.String("err", err.Error()))
+			} else {
+				logger.Info("replica is back", slog.String("host", r.host))
+			}
+		}
+		r.healthy = err == nil
+		rs.mu.Unlock()
+	}
+}
+
+func (rs *replicaSet) stop() {
+	rs.stopOnce.Do(func() {
+		close(rs.done)
+		for _, r := range rs.replicas {
+			r.pool.Close()
+		}
+	})
+}
+
+// recentWrites remembers what was written in the last window, so
+// reads of it go to the primary until the replicas catch up.
+type recentWrites struct {
+	mu      sync.Mutex
+	window  time.Duration
+	written map[string]time.Time
+}
+
+func newRecentWrites(window time.Duration) *recentWrites {
+	return &recentWrites{window: window, written: make(map[string]time.Time)}
+}
+
+func (w *recentWrites) mark(keys ...string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := time.Now()
+	for key, at := range w.written {
+		if now.Sub(at) >= w.window {
+			delete(w.written, key)
+		}
+	}
+	for _, key := range keys {
+		w.written[key] = now
+	}
+}
+
+func (w *recentWrites) has(key string) bool {
+	if w == nil {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	at, ok := w.written[key]
+	return ok && time.Since(at) < w.window
+}
+
+func userEmailKey(orgId int64, email string) string {
+	return fmt.Sprintf("user:%d:%s", orgId, email)
+}
+
+func userIdKey(orgId int64, userId int64) string {
+	return fmt.Sprintf("user:%d:#%d", orgId, userId)
+}
+
+func orgKey(orgId int64) string {
+	return fmt.Sprintf("org:%d", orgId)
+}
+
+// reader returns where a read-only query runs: the transaction of
+// ctx, or the primary when ctx asks for it or key was written
+// recently, or else a healthy replica. Without replicas it is the
+// primary.
+func (s *Storage) reader(ctx context.Context, key string) querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	if s.replicas == nil || storage.PrimaryReads(ctx) || s.recent.has(key) {
+		return s.connection
+	}
+	if pool := s.replicas.pick(); pool != nil {
+		return pool
+	}
+	return s.connection
+}
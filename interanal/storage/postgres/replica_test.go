@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"context"
+	"sso/interanal/storage"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReaderSkipsUnhealthyReplicas проверяет, что чтения идут
+// по здоровым репликам по очереди, а без них — в primary.
+func TestReaderSkipsUnhealthyReplicas(t *testing.T) {
+	primary, first, second := &pgxpool.Pool{}, &pgxpool.Pool{}, &pgxpool.Pool{}
+	s := &Storage{
+		connection: primary,
+		replicas: &replicaSet{replicas: []*replica{
+			{pool: first, healthy: true},
+			{pool: second, healthy: true},
+		}},
+	}
+	ctx := context.Background()
+
+	assert.Same(t, first, s.reader(ctx, ""))
+	assert.Same(t, second, s.reader(ctx, ""))
+
+	s.replicas.replicas[0].healthy = false
+	assert.Same(t, second, s.reader(ctx, ""))
+	assert.Same(t, second, s.reader(ctx, ""))
+
+	s.replicas.replicas[1].healthy = false
+	assert.Same(t, primary, s.reader(ctx, ""))
+}
+
+// TestReaderReadsOwnWrites проверяет, что недавно записанные
+// данные и запросы WithPrimaryReads читаются из primary.
+func TestReaderReadsOwnWrites(t *testing.T) {
+	primary, replicaPool := &pgxpool.Pool{}, &pgxpool.Pool{}
+	s := &Storage{
+		connection: primary,
+		replicas:   &replicaSet{replicas: []*replica{{pool: replicaPool, healthy: true}}},
+		recent:     newRecentWrites(time.Minute),
+	}
+	ctx := context.Background()
+	key := userEmailKey(1, "new@gmail.com")
+
+	assert.Same(t, replicaPool, s.reader(ctx, key))
+	s.recent.mark(key)
+	assert.Same(t, primary, s.reader(ctx, key))
+	assert.Same(t, replicaPool, s.reader(ctx, userEmailKey(1, "other@gmail.com")))
+	assert.Same(t, primary, s.reader(storage.WithPrimaryReads(ctx), ""))
+
+	s.recent.written[key] = time.Now().Add(-time.Minute)
+	assert.Same(t, replicaPool, s.reader(ctx, key))
+}
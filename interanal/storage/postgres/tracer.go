@@ -0,0 +1,32 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"sso/interanal/tracing"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("sso/interanal/storage/postgres")
+
+// queryTracer adds the statement and the query error to the span
+// that the Storage method started, so each method stays one span.
+type queryTracer struct{}
+
+func (queryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.statement", data.SQL),
+	)
+	return ctx
+}
+
+func (queryTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	if data.Err != nil && !errors.Is(data.Err, pgx.ErrNoRows) {
+		tracing.RecordError(trace.SpanFromContext(ctx), data.Err)
+	}
+}
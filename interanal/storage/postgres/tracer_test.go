@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestQueryTracer проверяет, что запрос и его ошибка
+// попадают в span метода Storage, а ErrNoRows - нет.
+func TestQueryTracer(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tracer := provider.Tracer("test")
+	qt := queryTracer{}
+
+	ctx, span := tracer.Start(context.Background(), "storage.postgres.GetUser")
+	ctx = qt.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "select 1"})
+	qt.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: pgx.ErrNoRows})
+	span.End()
+	ctx, span = tracer.Start(context.Background(), "storage.postgres.SaveUser")
+	qt.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("boom")})
+	span.End()
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, codes.Unset, spans[0].Status.Code)
+	assert.Contains(t, spans[0].Attributes, attribute.String("db.statement", "select 1"))
+	assert.Equal(t, codes.Error, spans[1].Status.Code)
+}
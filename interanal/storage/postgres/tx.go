@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// maxTxAttempts bounds the retries of a transaction that lost
+// a serialization conflict.
+const maxTxAttempts = 5
+
+// querier runs statements either on the pool or in a transaction.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+type txKey struct{}
+
+// conn returns the transaction of ctx started by WithinTx or the pool.
+func (s *Storage) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return s.connection
+}
+
+// WithinTx runs fn in a serializable transaction. Storage methods
+// called with the context passed to fn run in that transaction, and
+// a nested WithinTx joins it. The transaction commits when fn returns
+// nil and rolls back otherwise; the error of fn is returned as is.
+//
+// On a serialization failure or a deadlock the whole transaction is
+// retried, so fn must be safe to run again.
+func (s *Storage) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	const op = "storage.postgres.WithinTx"
+	if _, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return fn(ctx)
+	}
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = s.runTx(ctx, fn)
+		if !isRetryable(err) {
+			return err
+		}
+		if attempt < maxTxAttempts {
+			backoff := time.Duration(attempt) * 10 * time.Millisecond
+			select {
+			case <-time.After(backoff + rand.N(backoff)):
+			case <-ctx.Done():
+				return fmt.Errorf("%s: %w", op, ctx.Err())
+			}
+		}
+	}
+	return fmt.Errorf("%s: gave up after %d attempts: %w", op, maxTxAttempts, err)
+}
+
+func (s *Storage) runTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	const op = "storage.postgres.runTx"
+	tx, err := s.connection.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback(ctx)
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) &&
+		(pgErr.Code == pgerrcode.SerializationFailure || pgErr.Code == pgerrcode.DeadlockDetected)
+}
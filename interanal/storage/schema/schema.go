@@ -0,0 +1,61 @@
+// Package schema applies the embedded migrations on startup.
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+// ErrTooNew is returned when the database has migrations that the
+// binary doesn't know about, i.e. a newer release already ran.
+var ErrTooNew = errors.New("database schema is newer than the binary")
+
+// Up applies the pending migrations of src. It refuses to touch a dirty
+// database or one that is ahead of src. It returns the versions before
+// and after, zero meaning an empty database.
+func Up(m *migrate.Migrate, src source.Driver) (from uint, to uint, err error) {
+	const op = "schema.Up"
+	latest, err := Latest(src)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	from, dirty, err := m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if dirty {
+		return 0, 0, fmt.Errorf("%s: database version %d is dirty, fix it with cmd/migrator force", op, from)
+	}
+	if from > latest {
+		return 0, 0, fmt.Errorf("%s: %w: database is at %d, the binary knows up to %d", op, ErrTooNew, from, latest)
+	}
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return from, latest, nil
+}
+
+// Latest returns the highest version in src.
+func Latest(src source.Driver) (uint, error) {
+	version, err := src.First()
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"errors"
+	"sso/lib/secretbox"
+)
+
+// OpenSecret decrypts a stored app secret. Plaintext left from before
+// encryption was enabled is returned as is.
+func OpenSecret(secrets *secretbox.Keyring, stored string) (string, error) {
+	if !secretbox.IsEncrypted(stored) {
+		return stored, nil
+	}
+	if secrets == nil {
+		return "", errors.New("secret is encrypted but no keys are configured")
+	}
+	return secrets.Decrypt(stored)
+}
+
+// SealSecret encrypts an app secret for storing. Without keys it is
+// stored as plaintext, as OpenSecret expects.
+func SealSecret(secrets *secretbox.Keyring, plaintext string) (string, error) {
+	if secrets == nil {
+		return plaintext, nil
+	}
+	return secrets.Encrypt(plaintext)
+}
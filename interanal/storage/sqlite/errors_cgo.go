@@ -0,0 +1,18 @@
+//go:build cgo
+
+package sqlite
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// extendedCode returns the extended result code of a sqlite error or 0.
+func extendedCode(err error) int {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return int(sqliteErr.ExtendedCode)
+	}
+	return 0
+}
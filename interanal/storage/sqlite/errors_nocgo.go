@@ -0,0 +1,13 @@
+//go:build !cgo
+
+package sqlite
+
+import (
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Without cgo the driver is a stub that fails to open, so there are
+// no sqlite errors to inspect.
+func extendedCode(err error) int {
+	return 0
+}
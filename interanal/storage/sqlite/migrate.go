@@ -0,0 +1,38 @@
+package sqlite
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sso/interanal/storage/schema"
+	"sso/migrations"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// Migrate applies the embedded migrations to the database file at path
+// and records them in table, like cmd/migrator does. SQLite serializes
+// writers itself, so no extra lock is taken.
+func Migrate(path string, table string) (from uint, to uint, err error) {
+	const op = "storage.sqlite.Migrate"
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	src, err := iofs.New(migrations.FS, migrations.SQLiteDir)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", src, "sqlite3://"+path+"?x-migrations-table="+url.QueryEscape(table))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer m.Close()
+	from, to, err = schema.Up(m, src)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return from, to, nil
+}
@@ -0,0 +1,926 @@
+// Package sqlite is a storage for dev machines and small installs
+// that don't run postgres. Its schema lives in migrations/sqlite.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sso/interanal/domain/models"
+	"sso/interanal/storage"
+	"sso/lib/secretbox"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("sso/interanal/storage/sqlite")
+
+type Storage struct {
+	db      *sql.DB
+	secrets *secretbox.Keyring
+}
+
+type Options struct {
+	// Secrets encrypts app secrets at rest. Without it secrets are
+	// read and written as plaintext.
+	Secrets *secretbox.Keyring
+}
+
+// DSN returns the connection string for the database file at path,
+// with foreign keys on and a busy timeout instead of instant
+// "database is locked" errors. Transactions take the write lock
+// at BEGIN, so two of them can't deadlock upgrading their locks.
+func DSN(path string) string {
+	return fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate", path)
+}
+
+func MustNewConnection(ctx context.Context, path string, opts Options) *Storage {
+	const op = "storage.sqlite.MustNewConnection"
+	db, err := sql.Open("sqlite3", DSN(path))
+	if err != nil {
+		log.Fatalf("%s: cannot open db: %v", op, err)
+	}
+	// SQLite has a single writer, so one connection avoids lock errors
+	// between connections of the same process.
+	db.SetMaxOpenConns(1)
+	if err := db.PingContext(ctx); err != nil {
+		log.Fatalf("%s: db is unreachable: %v", op, err)
+	}
+	return &Storage{db: db, secrets: opts.Secrets}
+}
+
+func (s *Storage) Stop(ctx context.Context) error {
+	const op = "storage.sqlite.Stop"
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *Storage) SaveUser(ctx context.Context, orgId int64, email string, passHash []byte) (int64, error) {
+	const op = "storage.sqlite.SaveUser"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var userId int64
+	err := s.WithinTx(ctx, func(ctx context.Context) error {
+		stmt := `insert into "user"(org_id, email, pass_hash) values (?, ?, ?) returning user_id`
+		err := s.conn(ctx).QueryRowContext(ctx, stmt, orgId, email, passHash).Scan(&userId)
+		if err != nil {
+			if isConstraint(err, constraintUnique) {
+				return fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+			}
+			if isConstraint(err, constraintForeignKey) {
+				return fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+			}
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		stmt = `insert into membership(org_id, user_id, role) values (?, ?, ?)`
+		if _, err := s.conn(ctx).ExecContext(ctx, stmt, orgId, userId, models.RoleMember); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return userId, nil
+}
+
+func (s *Storage) GetUser(ctx context.Context, orgId int64, email string) (models.User, error) {
+	const op = "storage.sqlite.GetUser"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var user models.User
+	stmt := `select user_id, org_id, email, pass_hash from "user" where org_id=? and email=?`
+	err := s.conn(ctx).QueryRowContext(ctx, stmt, orgId, email).Scan(&user.Id, &user.OrgId, &user.Email, &user.PaswordHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return user, nil
+}
+
+func (s *Storage) GetUserById(ctx context.Context, orgId int64, userId int64) (models.User, error) {
+	const op = "storage.sqlite.GetUserById"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var user models.User
+	stmt := `select user_id, org_id, email, pass_hash from "user" where org_id=? and user_id=?`
+	err := s.conn(ctx).QueryRowContext(ctx, stmt, orgId, userId).Scan(&user.Id, &user.OrgId, &user.Email, &user.PaswordHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return user, nil
+}
+
+func (s *Storage) IsAdmin(ctx context.Context, orgId int64, userId int64) (bool, error) {
+	const op = "storage.sqlite.IsAdmin"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var isAdmin bool
+	stmt := `select is_admin from "user" where org_id=? and user_id=?`
+	err := s.conn(ctx).QueryRowContext(ctx, stmt, orgId, userId).Scan(&isAdmin)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return isAdmin, nil
+}
+
+func (s *Storage) GetApp(ctx context.Context, appId int) (models.App, error) {
+	const op = "storage.sqlite.GetApp"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var app models.App
+	stmt := `select app_id, org_id, name, secret, allow_auto_provision from app where app_id=?`
+	err := s.conn(ctx).QueryRowContext(ctx, stmt, appId).Scan(&app.Id, &app.OrgId, &app.Name, &app.Secret, &app.AllowAutoProvision)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+	app.Secret, err = storage.OpenSecret(s.secrets, app.Secret)
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: app %d: %w", op, app.Id, err)
+	}
+	return app, nil
+}
+
+// RotateAppSecrets encrypts plaintext app secrets and re-encrypts the
+// ones sealed with an old key under the primary key.
+func (s *Storage) RotateAppSecrets(ctx context.Context) (int, error) {
+	const op = "storage.sqlite.RotateAppSecrets"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	if s.secrets == nil {
+		return 0, nil
+	}
+	var rotated int
+	err := s.WithinTx(ctx, func(ctx context.Context) error {
+		rows, err := s.conn(ctx).QueryContext(ctx, `select app_id, secret from app`)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		stale := make(map[int]string)
+		for rows.Next() {
+			var appId int
+			var secret string
+			if err := rows.Scan(&appId, &secret); err != nil {
+				rows.Close()
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			if s.secrets.NeedsRotation(secret) {
+				stale[appId] = secret
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		for appId, secret := range stale {
+			plaintext, err := storage.OpenSecret(s.secrets, secret)
+			if err != nil {
+				return fmt.Errorf("%s: app %d: %w", op, appId, err)
+			}
+			sealed, err := s.secrets.Encrypt(plaintext)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+			if _, err := s.conn(ctx).ExecContext(ctx, `update app set secret=? where app_id=?`, sealed, appId); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+		rotated = len(stale)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return rotated, nil
+}
+
+// SaveApp creates an app. The secret is sealed like RotateAppSecrets does.
+func (s *Storage) SaveApp(ctx context.Context, app models.App) error {
+	const op = "storage.sqlite.SaveApp"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	secret, err := storage.SealSecret(s.secrets, app.Secret)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	stmt := `insert into app(app_id, org_id, name, secret, allow_auto_provision) values (?, ?, ?, ?, ?)`
+	_, err = s.conn(ctx).ExecContext(ctx, stmt, app.Id, app.OrgId, app.Name, secret, app.AllowAutoProvision)
+	if err != nil {
+		if isConstraint(err, constraintPrimaryKey) || isConstraint(err, constraintUnique) {
+			return fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+		}
+		if isConstraint(err, constraintForeignKey) {
+			return fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// UpdateApp changes everything but the secret of an app.
+func (s *Storage) UpdateApp(ctx context.Context, app models.App) error {
+	const op = "storage.sqlite.UpdateApp"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `update app set org_id=?, name=?, allow_auto_provision=? where app_id=?`
+	res, err := s.conn(ctx).ExecContext(ctx, stmt, app.OrgId, app.Name, app.AllowAutoProvision, app.Id)
+	if err != nil {
+		if isConstraint(err, constraintUnique) {
+			return fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+		}
+		if isConstraint(err, constraintForeignKey) {
+			return fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+		}
+	}
+	return affectedOne(op, res, err, storage.ErrAppNotFound)
+}
+
+// SetAppSecret replaces the secret of an app, sealed like SaveApp does.
+func (s *Storage) SetAppSecret(ctx context.Context, appId int, secret string) error {
+	const op = "storage.sqlite.SetAppSecret"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	sealed, err := storage.SealSecret(s.secrets, secret)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	res, err := s.conn(ctx).ExecContext(ctx, `update app set secret=? where app_id=?`, sealed, appId)
+	return affectedOne(op, res, err, storage.ErrAppNotFound)
+}
+
+// ListApps returns the apps of an organization without their secrets.
+func (s *Storage) ListApps(ctx context.Context, orgId int64) ([]models.App, error) {
+	const op = "storage.sqlite.ListApps"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `select app_id, org_id, name, allow_auto_provision from app where org_id=? order by app_id`
+	rows, err := s.conn(ctx).QueryContext(ctx, stmt, orgId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+	var apps []models.App
+	for rows.Next() {
+		var app models.App
+		if err := rows.Scan(&app.Id, &app.OrgId, &app.Name, &app.AllowAutoProvision); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		apps = append(apps, app)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return apps, nil
+}
+
+func (s *Storage) SaveOrganization(ctx context.Context, name string) (int64, error) {
+	const op = "storage.sqlite.SaveOrganization"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var orgId int64
+	stmt := `insert into organization(name) values (?) returning org_id`
+	err := s.conn(ctx).QueryRowContext(ctx, stmt, name).Scan(&orgId)
+	if err != nil {
+		if isConstraint(err, constraintUnique) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrOrgExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return orgId, nil
+}
+
+func (s *Storage) GetOrganization(ctx context.Context, orgId int64) (models.Organization, error) {
+	const op = "storage.sqlite.GetOrganization"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var org models.Organization
+	stmt := `select org_id, name from organization where org_id=?`
+	err := s.conn(ctx).QueryRowContext(ctx, stmt, orgId).Scan(&org.Id, &org.Name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Organization{}, fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+		}
+		return models.Organization{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return org, nil
+}
+
+func (s *Storage) MemberRole(ctx context.Context, orgId int64, userId int64) (string, error) {
+	const op = "storage.sqlite.MemberRole"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var role string
+	stmt := `select role from membership where org_id=? and user_id=?`
+	err := s.conn(ctx).QueryRowContext(ctx, stmt, orgId, userId).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return role, nil
+}
+
+func (s *Storage) SetMemberRole(ctx context.Context, orgId int64, userId int64, role string) error {
+	const op = "storage.sqlite.SetMemberRole"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `update membership set role=? where org_id=? and user_id=?`
+	res, err := s.conn(ctx).ExecContext(ctx, stmt, role, orgId, userId)
+	return affectedOne(op, res, err, storage.ErrUserNotFound)
+}
+
+func (s *Storage) ListMembers(ctx context.Context, orgId int64) ([]models.Member, error) {
+	const op = "storage.sqlite.ListMembers"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `select m.user_id, u.email, m.role from membership m
+		join "user" u on u.org_id = m.org_id and u.user_id = m.user_id
+		where m.org_id=? order by m.user_id`
+	rows, err := s.conn(ctx).QueryContext(ctx, stmt, orgId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+	var members []models.Member
+	for rows.Next() {
+		var member models.Member
+		if err := rows.Scan(&member.UserId, &member.Email, &member.Role); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return members, nil
+}
+
+// SetAdmin sets the legacy is_admin flag that IsAdmin reports.
+func (s *Storage) SetAdmin(ctx context.Context, orgId int64, userId int64, isAdmin bool) error {
+	const op = "storage.sqlite.SetAdmin"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `update "user" set is_admin=? where org_id=? and user_id=?`
+	res, err := s.conn(ctx).ExecContext(ctx, stmt, isAdmin, orgId, userId)
+	return affectedOne(op, res, err, storage.ErrUserNotFound)
+}
+
+// RevokeSessions rejects the tokens of a user issued up to revokedAt.
+func (s *Storage) RevokeSessions(ctx context.Context, orgId int64, userId int64, revokedAt time.Time) error {
+	const op = "storage.sqlite.RevokeSessions"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `update "user" set sessions_revoked_at=? where org_id=? and user_id=?`
+	res, err := s.conn(ctx).ExecContext(ctx, stmt, toMicros(revokedAt), orgId, userId)
+	return affectedOne(op, res, err, storage.ErrUserNotFound)
+}
+
+// SessionsRevokedAt returns the zero time for a user whose sessions
+// were never revoked.
+func (s *Storage) SessionsRevokedAt(ctx context.Context, orgId int64, userId int64) (time.Time, error) {
+	const op = "storage.sqlite.SessionsRevokedAt"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var revokedAt sql.NullInt64
+	stmt := `select sessions_revoked_at from "user" where org_id=? and user_id=?`
+	err := s.conn(ctx).QueryRowContext(ctx, stmt, orgId, userId).Scan(&revokedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !revokedAt.Valid {
+		return time.Time{}, nil
+	}
+	return fromMicros(revokedAt.Int64), nil
+}
+
+func (s *Storage) SaveInvite(ctx context.Context, invite models.Invite, tokenHash []byte) (int64, error) {
+	const op = "storage.sqlite.SaveInvite"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var inviteId int64
+	stmt := `insert into invite(org_id, email, role, token_hash, created_by, expires_at)
+		values (?, ?, ?, ?, ?, ?) returning invite_id`
+	err := s.conn(ctx).QueryRowContext(
+		ctx,
+		stmt,
+		invite.OrgId,
+		invite.Email,
+		invite.Role,
+		tokenHash,
+		invite.CreatedBy,
+		toMicros(invite.ExpiresAt),
+	).Scan(&inviteId)
+	if err != nil {
+		if isConstraint(err, constraintForeignKey) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrOrgNotFound)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return inviteId, nil
+}
+
+// GetActiveInvite returns an invite that is neither expired nor accepted.
+func (s *Storage) GetActiveInvite(ctx context.Context, tokenHash []byte) (models.Invite, error) {
+	const op = "storage.sqlite.GetActiveInvite"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var invite models.Invite
+	var expiresAt int64
+	stmt := `select invite_id, org_id, email, role, created_by, expires_at from invite
+		where token_hash=? and accepted_at is null and expires_at > ?`
+	err := s.conn(ctx).QueryRowContext(ctx, stmt, tokenHash, toMicros(time.Now())).Scan(
+		&invite.Id,
+		&invite.OrgId,
+		&invite.Email,
+		&invite.Role,
+		&invite.CreatedBy,
+		&expiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Invite{}, fmt.Errorf("%s: %w", op, storage.ErrInviteNotFound)
+		}
+		return models.Invite{}, fmt.Errorf("%s: %w", op, err)
+	}
+	invite.ExpiresAt = fromMicros(expiresAt)
+	return invite, nil
+}
+
+// ConsumeInvite marks an active invite as accepted, so a token can be used only once.
+func (s *Storage) ConsumeInvite(ctx context.Context, tokenHash []byte) (models.Invite, error) {
+	const op = "storage.sqlite.ConsumeInvite"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var invite models.Invite
+	var expiresAt, acceptedAt int64
+	now := toMicros(time.Now())
+	stmt := `update invite set accepted_at=?
+		where token_hash=? and accepted_at is null and expires_at > ?
+		returning invite_id, org_id, email, role, created_by, expires_at, accepted_at`
+	err := s.conn(ctx).QueryRowContext(ctx, stmt, now, tokenHash, now).Scan(
+		&invite.Id,
+		&invite.OrgId,
+		&invite.Email,
+		&invite.Role,
+		&invite.CreatedBy,
+		&expiresAt,
+		&acceptedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Invite{}, fmt.Errorf("%s: %w", op, storage.ErrInviteNotFound)
+		}
+		return models.Invite{}, fmt.Errorf("%s: %w", op, err)
+	}
+	invite.ExpiresAt = fromMicros(expiresAt)
+	invite.AcceptedAt = fromMicros(acceptedAt)
+	return invite, nil
+}
+
+// SavePasswordlessCode invalidates previous codes of the same email and app,
+// so only the latest sent code can be used.
+func (s *Storage) SavePasswordlessCode(ctx context.Context, code models.PasswordlessCode) (int64, error) {
+	const op = "storage.sqlite.SavePasswordlessCode"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var codeId int64
+	err := s.WithinTx(ctx, func(ctx context.Context) error {
+		stmt := `update passwordless_code set used_at=? where app_id=? and email=? and used_at is null`
+		if _, err := s.conn(ctx).ExecContext(ctx, stmt, toMicros(time.Now()), code.AppId, code.Email); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		stmt = `insert into passwordless_code(app_id, email, code_hash, expires_at) values (?, ?, ?, ?) returning code_id`
+		err := s.conn(ctx).QueryRowContext(ctx, stmt, code.AppId, code.Email, code.CodeHash, toMicros(code.ExpiresAt)).Scan(&codeId)
+		if err != nil {
+			if isConstraint(err, constraintForeignKey) {
+				return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+			}
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return codeId, nil
+}
+
+func (s *Storage) GetActivePasswordlessCode(ctx context.Context, appId int, email string) (models.PasswordlessCode, error) {
+	const op = "storage.sqlite.GetActivePasswordlessCode"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var code models.PasswordlessCode
+	var expiresAt int64
+	stmt := `select code_id, app_id, email, code_hash, attempts, expires_at from passwordless_code
+		where app_id=? and email=? and used_at is null and expires_at > ?
+		order by code_id desc limit 1`
+	err := s.conn(ctx).QueryRowContext(ctx, stmt, appId, email, toMicros(time.Now())).Scan(
+		&code.Id,
+		&code.AppId,
+		&code.Email,
+		&code.CodeHash,
+		&code.Attempts,
+		&expiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.PasswordlessCode{}, fmt.Errorf("%s: %w", op, storage.ErrCodeNotFound)
+		}
+		return models.PasswordlessCode{}, fmt.Errorf("%s: %w", op, err)
+	}
+	code.ExpiresAt = fromMicros(expiresAt)
+	return code, nil
+}
+
+// IncrementPasswordlessAttempts spends an attempt of the code. The check
+// and the increment are a single statement, so concurrent guesses can't
+// spend more than maxAttempts.
+func (s *Storage) IncrementPasswordlessAttempts(ctx context.Context, codeId int64, maxAttempts int) error {
+	const op = "storage.sqlite.IncrementPasswordlessAttempts"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `update passwordless_code set attempts=attempts+1 where code_id=? and attempts < ?`
+	res, err := s.conn(ctx).ExecContext(ctx, stmt, codeId, maxAttempts)
+	return affectedOne(op, res, err, storage.ErrAttemptsExhausted)
+}
+
+func (s *Storage) MarkPasswordlessCodeUsed(ctx context.Context, codeId int64) error {
+	const op = "storage.sqlite.MarkPasswordlessCodeUsed"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `update passwordless_code set used_at=? where code_id=? and used_at is null`
+	res, err := s.conn(ctx).ExecContext(ctx, stmt, toMicros(time.Now()), codeId)
+	return affectedOne(op, res, err, storage.ErrCodeNotFound)
+}
+
+func (s *Storage) SaveWebAuthnCredential(ctx context.Context, cred models.WebAuthnCredential) error {
+	const op = "storage.sqlite.SaveWebAuthnCredential"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	transports := cred.Transports
+	if transports == nil {
+		transports = []string{}
+	}
+	encodedTransports, err := json.Marshal(transports)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	stmt := `insert into webauthn_credential(
+		credential_id, user_id, public_key, attestation_type, aaguid,
+		sign_count, transports, backup_eligible, backup_state, created_at
+	) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = s.conn(ctx).ExecContext(
+		ctx,
+		stmt,
+		cred.Id,
+		cred.UserId,
+		cred.PublicKey,
+		cred.AttestationType,
+		cred.AAGUID,
+		int64(cred.SignCount),
+		string(encodedTransports),
+		cred.BackupEligible,
+		cred.BackupState,
+		toMicros(time.Now()),
+	)
+	if err != nil {
+		if isConstraint(err, constraintPrimaryKey) {
+			return fmt.Errorf("%s: %w", op, storage.ErrCredentialExists)
+		}
+		if isConstraint(err, constraintForeignKey) {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) ListWebAuthnCredentials(ctx context.Context, userId int64) ([]models.WebAuthnCredential, error) {
+	const op = "storage.sqlite.ListWebAuthnCredentials"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `select credential_id, user_id, public_key, attestation_type, aaguid, sign_count, clone_warning,
+		transports, backup_eligible, backup_state, created_at, last_used_at
+		from webauthn_credential where user_id=? order by created_at`
+	rows, err := s.conn(ctx).QueryContext(ctx, stmt, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+	var creds []models.WebAuthnCredential
+	for rows.Next() {
+		var cred models.WebAuthnCredential
+		var signCount, createdAt int64
+		var transports string
+		var lastUsedAt sql.NullInt64
+		err := rows.Scan(
+			&cred.Id,
+			&cred.UserId,
+			&cred.PublicKey,
+			&cred.AttestationType,
+			&cred.AAGUID,
+			&signCount,
+			&cred.CloneWarning,
+			&transports,
+			&cred.BackupEligible,
+			&cred.BackupState,
+			&createdAt,
+			&lastUsedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if err := json.Unmarshal([]byte(transports), &cred.Transports); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		cred.SignCount = uint32(signCount)
+		cred.CreatedAt = fromMicros(createdAt)
+		if lastUsedAt.Valid {
+			cred.LastUsedAt = fromMicros(lastUsedAt.Int64)
+		}
+		creds = append(creds, cred)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return creds, nil
+}
+
+func (s *Storage) UpdateWebAuthnSignCount(ctx context.Context, credentialId []byte, signCount uint32, cloneWarning bool) error {
+	const op = "storage.sqlite.UpdateWebAuthnSignCount"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `update webauthn_credential set sign_count=?, clone_warning=?, last_used_at=? where credential_id=?`
+	res, err := s.conn(ctx).ExecContext(ctx, stmt, int64(signCount), cloneWarning, toMicros(time.Now()), credentialId)
+	return affectedOne(op, res, err, storage.ErrCredentialNotFound)
+}
+
+func (s *Storage) DeleteWebAuthnCredential(ctx context.Context, userId int64, credentialId []byte) error {
+	const op = "storage.sqlite.DeleteWebAuthnCredential"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `delete from webauthn_credential where user_id=? and credential_id=?`
+	res, err := s.conn(ctx).ExecContext(ctx, stmt, userId, credentialId)
+	return affectedOne(op, res, err, storage.ErrCredentialNotFound)
+}
+
+func (s *Storage) SaveWebAuthnSession(ctx context.Context, sessionHash []byte, session models.WebAuthnSession) error {
+	const op = "storage.sqlite.SaveWebAuthnSession"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var appId *int
+	if session.AppId != 0 {
+		appId = &session.AppId
+	}
+	stmt := `insert into webauthn_session(session_hash, user_id, app_id, ceremony, data, expires_at)
+		values (?, ?, ?, ?, ?, ?)`
+	_, err := s.conn(ctx).ExecContext(
+		ctx,
+		stmt,
+		sessionHash,
+		session.UserId,
+		appId,
+		session.Ceremony,
+		string(session.Data),
+		toMicros(session.ExpiresAt),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ConsumeWebAuthnSession deletes the session, so a ceremony can be finished only once.
+func (s *Storage) ConsumeWebAuthnSession(ctx context.Context, sessionHash []byte, ceremony string) (models.WebAuthnSession, error) {
+	const op = "storage.sqlite.ConsumeWebAuthnSession"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var session models.WebAuthnSession
+	var appId sql.NullInt64
+	var data string
+	var expiresAt int64
+	stmt := `delete from webauthn_session
+		where session_hash=? and ceremony=? and expires_at > ?
+		returning user_id, app_id, ceremony, data, expires_at`
+	err := s.conn(ctx).QueryRowContext(ctx, stmt, sessionHash, ceremony, toMicros(time.Now())).Scan(
+		&session.UserId,
+		&appId,
+		&session.Ceremony,
+		&data,
+		&expiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+		}
+		return models.WebAuthnSession{}, fmt.Errorf("%s: %w", op, err)
+	}
+	session.AppId = int(appId.Int64)
+	session.Data = []byte(data)
+	session.ExpiresAt = fromMicros(expiresAt)
+	return session, nil
+}
+
+func (s *Storage) SaveDeviceAuthorization(ctx context.Context, auth models.DeviceAuthorization) error {
+	const op = "storage.sqlite.SaveDeviceAuthorization"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `insert into device_authorization(device_code_hash, user_code, app_id, interval_seconds, expires_at)
+		values (?, ?, ?, ?, ?)`
+	_, err := s.conn(ctx).ExecContext(
+		ctx,
+		stmt,
+		auth.DeviceCodeHash,
+		auth.UserCode,
+		auth.AppId,
+		int(auth.Interval/time.Second),
+		toMicros(auth.ExpiresAt),
+	)
+	if err != nil {
+		if isConstraint(err, constraintPrimaryKey) || isConstraint(err, constraintUnique) {
+			return fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeExists)
+		}
+		if isConstraint(err, constraintForeignKey) {
+			return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) GetDeviceAuthorization(ctx context.Context, deviceCodeHash []byte) (models.DeviceAuthorization, error) {
+	const op = "storage.sqlite.GetDeviceAuthorization"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `select device_code_hash, user_code, app_id, coalesce(user_id, 0), status, interval_seconds,
+		last_polled_at, expires_at
+		from device_authorization where device_code_hash=?`
+	auth, err := scanDeviceAuthorization(s.conn(ctx).QueryRowContext(ctx, stmt, deviceCodeHash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.DeviceAuthorization{}, fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeNotFound)
+		}
+		return models.DeviceAuthorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return auth, nil
+}
+
+func (s *Storage) GetDeviceAuthorizationByUserCode(ctx context.Context, userCode string) (models.DeviceAuthorization, error) {
+	const op = "storage.sqlite.GetDeviceAuthorizationByUserCode"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `select device_code_hash, user_code, app_id, coalesce(user_id, 0), status, interval_seconds,
+		last_polled_at, expires_at
+		from device_authorization where user_code=?`
+	auth, err := scanDeviceAuthorization(s.conn(ctx).QueryRowContext(ctx, stmt, userCode))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.DeviceAuthorization{}, fmt.Errorf("%s: %w", op, storage.ErrDeviceCodeNotFound)
+		}
+		return models.DeviceAuthorization{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return auth, nil
+}
+
+// ResolveDeviceAuthorization approves or denies a pending authorization.
+func (s *Storage) ResolveDeviceAuthorization(ctx context.Context, userCode string, userId int64, status string) error {
+	const op = "storage.sqlite.ResolveDeviceAuthorization"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `update device_authorization set user_id=?, status=?
+		where user_code=? and status='pending' and expires_at > ?`
+	res, err := s.conn(ctx).ExecContext(ctx, stmt, userId, status, userCode, toMicros(time.Now()))
+	return affectedOne(op, res, err, storage.ErrDeviceCodeNotFound)
+}
+
+func (s *Storage) UpdateDevicePoll(ctx context.Context, deviceCodeHash []byte, polledAt time.Time, interval time.Duration) error {
+	const op = "storage.sqlite.UpdateDevicePoll"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `update device_authorization set last_polled_at=?, interval_seconds=? where device_code_hash=?`
+	res, err := s.conn(ctx).ExecContext(ctx, stmt, toMicros(polledAt), int(interval/time.Second), deviceCodeHash)
+	return affectedOne(op, res, err, storage.ErrDeviceCodeNotFound)
+}
+
+// ConsumeDeviceAuthorization moves an approved authorization to consumed,
+// so tokens are issued for a device code only once.
+func (s *Storage) ConsumeDeviceAuthorization(ctx context.Context, deviceCodeHash []byte) error {
+	const op = "storage.sqlite.ConsumeDeviceAuthorization"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	stmt := `update device_authorization set status='consumed' where device_code_hash=? and status='approved'`
+	res, err := s.conn(ctx).ExecContext(ctx, stmt, deviceCodeHash)
+	return affectedOne(op, res, err, storage.ErrDeviceCodeNotFound)
+}
+
+func (s *Storage) SaveAuditEvent(ctx context.Context, event models.AuditEvent) (int64, error) {
+	const op = "storage.sqlite.SaveAuditEvent"
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var eventId int64
+	stmt := `insert into audit_event(org_id, actor_id, target_user_id, app_id, action, reason, created_at)
+		values (?, ?, ?, ?, ?, ?, ?) returning event_id`
+	err := s.conn(ctx).QueryRowContext(
+		ctx,
+		stmt,
+		event.OrgId,
+		event.ActorId,
+		event.TargetUserId,
+		event.AppId,
+		event.Action,
+		event.Reason,
+		toMicros(time.Now()),
+	).Scan(&eventId)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return eventId, nil
+}
+
+func scanDeviceAuthorization(row *sql.Row) (models.DeviceAuthorization, error) {
+	var auth models.DeviceAuthorization
+	var intervalSeconds int
+	var lastPolledAt sql.NullInt64
+	var expiresAt int64
+	err := row.Scan(
+		&auth.DeviceCodeHash,
+		&auth.UserCode,
+		&auth.AppId,
+		&auth.UserId,
+		&auth.Status,
+		&intervalSeconds,
+		&lastPolledAt,
+		&expiresAt,
+	)
+	if err != nil {
+		return models.DeviceAuthorization{}, err
+	}
+	auth.Interval = time.Duration(intervalSeconds) * time.Second
+	if lastPolledAt.Valid {
+		auth.LastPolledAt = fromMicros(lastPolledAt.Int64)
+	}
+	auth.ExpiresAt = fromMicros(expiresAt)
+	return auth, nil
+}
+
+// affectedOne turns an update that matched no rows into notFound.
+func affectedOne(op string, res sql.Result, err error, notFound error) error {
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, notFound)
+	}
+	return nil
+}
+
+// Extended result codes of constraint violations, see
+// https://www.sqlite.org/rescode.html.
+const (
+	constraintForeignKey = 787
+	constraintPrimaryKey = 1555
+	constraintUnique     = 2067
+)
+
+func isConstraint(err error, code int) bool {
+	return extendedCode(err) == code
+}
+
+// Timestamps are stored as unix microseconds, so they compare as
+// numbers and keep the precision of postgres.
+func toMicros(t time.Time) int64 {
+	return t.UnixMicro()
+}
+
+func fromMicros(v int64) time.Time {
+	return time.UnixMicro(v)
+}
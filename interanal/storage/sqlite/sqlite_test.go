@@ -0,0 +1,72 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sso/interanal/storage/schema"
+	"sso/interanal/storage/storagetest"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConformance проверяет sqlite общим набором
+// тестов хранилища.
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, newTestStorage(t))
+}
+
+// TestMigrationsDown проверяет, что миграции sqlite
+// откатываются до пустой базы.
+func TestMigrationsDown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sso.db")
+	m := newMigrate(t, path)
+	require.NoError(t, m.Up())
+
+	require.NoError(t, m.Down())
+}
+
+// TestMigrateRefusesNewerSchema проверяет, что встроенные миграции
+// повторно ничего не применяют и не трогают базу новее бинарника.
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sso.db")
+	from, latest, err := Migrate(path, "migrations")
+	require.NoError(t, err)
+	assert.Zero(t, from)
+
+	from, to, err := Migrate(path, "migrations")
+	require.NoError(t, err)
+	assert.Equal(t, latest, from)
+	assert.Equal(t, latest, to)
+
+	db, err := sql.Open("sqlite3", DSN(path))
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("update migrations set version = ?", latest+1)
+	require.NoError(t, err)
+	_, _, err = Migrate(path, "migrations")
+	assert.ErrorIs(t, err, schema.ErrTooNew)
+}
+
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "sso.db")
+	_, _, err := Migrate(path, "migrations")
+	require.NoError(t, err)
+	s := MustNewConnection(context.Background(), path, Options{})
+	t.Cleanup(func() { s.Stop(context.Background()) })
+	return s
+}
+
+func newMigrate(t *testing.T, path string) *migrate.Migrate {
+	t.Helper()
+	m, err := migrate.New("file://../../../migrations/sqlite", "sqlite3://"+path)
+	require.NoError(t, err)
+	t.Cleanup(func() { m.Close() })
+	return m
+}
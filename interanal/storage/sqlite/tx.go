@@ -0,0 +1,80 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// maxTxAttempts bounds the retries of a transaction that could not
+// get the database lock.
+const maxTxAttempts = 5
+
+// busy is the result code of a locked database, see
+// https://www.sqlite.org/rescode.html.
+const busy = 5
+
+// querier runs statements either on the database or in a transaction.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+// conn returns the transaction of ctx started by WithinTx or the database.
+// The pool has a single connection, so inside a transaction every
+// statement must go through it.
+func (s *Storage) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return s.db
+}
+
+// WithinTx runs fn in a transaction, see postgres.Storage.WithinTx.
+// SQLite transactions are serializable by design; the one conflict
+// to retry is a database locked by another process.
+func (s *Storage) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	const op = "storage.sqlite.WithinTx"
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+	ctx, span := tracer.Start(ctx, op)
+	defer span.End()
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = s.runTx(ctx, fn)
+		if extendedCode(err)&0xff != busy {
+			return err
+		}
+		if attempt < maxTxAttempts {
+			backoff := time.Duration(attempt) * 10 * time.Millisecond
+			select {
+			case <-time.After(backoff + rand.N(backoff)):
+			case <-ctx.Done():
+				return fmt.Errorf("%s: %w", op, ctx.Err())
+			}
+		}
+	}
+	return fmt.Errorf("%s: gave up after %d attempts: %w", op, maxTxAttempts, err)
+}
+
+func (s *Storage) runTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	const op = "storage.sqlite.runTx"
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer tx.Rollback()
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
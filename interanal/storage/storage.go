@@ -6,4 +6,20 @@ var (
 	ErrUserExists   = errors.New("user already exists")
 	ErrUserNotFound = errors.New("user not found")
 	ErrAppNotFound  = errors.New("app not found")
+	// ErrAppExists is returned when the id or the name of an app is taken.
+	ErrAppExists   = errors.New("app already exists")
+	ErrOrgExists   = errors.New("organization already exists")
+	ErrOrgNotFound = errors.New("organization not found")
+	// ErrInviteNotFound is also returned for invites that are expired or already accepted.
+	ErrInviteNotFound = errors.New("invite not found")
+	// ErrCodeNotFound is also returned for codes that are expired or already used.
+	ErrCodeNotFound = errors.New("code not found")
+	// ErrAttemptsExhausted is returned when a code has no attempts left.
+	ErrAttemptsExhausted  = errors.New("attempts exhausted")
+	ErrCredentialExists   = errors.New("credential already exists")
+	ErrCredentialNotFound = errors.New("credential not found")
+	// ErrSessionNotFound is also returned for sessions that are expired or already finished.
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrDeviceCodeExists   = errors.New("device code already exists")
+	ErrDeviceCodeNotFound = errors.New("device code not found")
 )
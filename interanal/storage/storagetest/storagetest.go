@@ -0,0 +1,614 @@
+// Package storagetest is a conformance suite that every storage
+// backend runs, so their behavior and errors can't drift apart.
+package storagetest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sso/interanal/domain/models"
+	"sso/interanal/storage"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Storage is implemented by every backend.
+type Storage interface {
+	SaveUser(ctx context.Context, orgId int64, email string, passHash []byte) (int64, error)
+	GetUser(ctx context.Context, orgId int64, email string) (models.User, error)
+	GetUserById(ctx context.Context, orgId int64, userId int64) (models.User, error)
+	IsAdmin(ctx context.Context, orgId int64, userId int64) (bool, error)
+	GetApp(ctx context.Context, appId int) (models.App, error)
+	SaveApp(ctx context.Context, app models.App) error
+	UpdateApp(ctx context.Context, app models.App) error
+	SetAppSecret(ctx context.Context, appId int, secret string) error
+	ListApps(ctx context.Context, orgId int64) ([]models.App, error)
+	SetAdmin(ctx context.Context, orgId int64, userId int64, isAdmin bool) error
+	SaveOrganization(ctx context.Context, name string) (int64, error)
+	GetOrganization(ctx context.Context, orgId int64) (models.Organization, error)
+	MemberRole(ctx context.Context, orgId int64, userId int64) (string, error)
+	SetMemberRole(ctx context.Context, orgId int64, userId int64, role string) error
+	ListMembers(ctx context.Context, orgId int64) ([]models.Member, error)
+	RevokeSessions(ctx context.Context, orgId int64, userId int64, revokedAt time.Time) error
+	SessionsRevokedAt(ctx context.Context, orgId int64, userId int64) (time.Time, error)
+	SaveInvite(ctx context.Context, invite models.Invite, tokenHash []byte) (int64, error)
+	GetActiveInvite(ctx context.Context, tokenHash []byte) (models.Invite, error)
+	ConsumeInvite(ctx context.Context, tokenHash []byte) (models.Invite, error)
+	SavePasswordlessCode(ctx context.Context, code models.PasswordlessCode) (int64, error)
+	GetActivePasswordlessCode(ctx context.Context, appId int, email string) (models.PasswordlessCode, error)
+	IncrementPasswordlessAttempts(ctx context.Context, codeId int64, maxAttempts int) error
+	MarkPasswordlessCodeUsed(ctx context.Context, codeId int64) error
+	SaveWebAuthnCredential(ctx context.Context, cred models.WebAuthnCredential) error
+	ListWebAuthnCredentials(ctx context.Context, userId int64) ([]models.WebAuthnCredential, error)
+	UpdateWebAuthnSignCount(ctx context.Context, credentialId []byte, signCount uint32, cloneWarning bool) error
+	DeleteWebAuthnCredential(ctx context.Context, userId int64, credentialId []byte) error
+	SaveWebAuthnSession(ctx context.Context, sessionHash []byte, session models.WebAuthnSession) error
+	ConsumeWebAuthnSession(ctx context.Context, sessionHash []byte, ceremony string) (models.WebAuthnSession, error)
+	SaveDeviceAuthorization(ctx context.Context, auth models.DeviceAuthorization) error
+	GetDeviceAuthorization(ctx context.Context, deviceCodeHash []byte) (models.DeviceAuthorization, error)
+	GetDeviceAuthorizationByUserCode(ctx context.Context, userCode string) (models.DeviceAuthorization, error)
+	ResolveDeviceAuthorization(ctx context.Context, userCode string, userId int64, status string) error
+	UpdateDevicePoll(ctx context.Context, deviceCodeHash []byte, polledAt time.Time, interval time.Duration) error
+	ConsumeDeviceAuthorization(ctx context.Context, deviceCodeHash []byte) error
+	SaveAuditEvent(ctx context.Context, event models.AuditEvent) (int64, error)
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Run checks s against the behavior of the postgres storage. The
+// data it creates is unique per run, so s may be a shared database
+// that already has the migrations applied.
+func Run(t *testing.T, s Storage) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s Storage)
+	}{
+		{"Users", testUsers},
+		{"Apps", testApps},
+		{"Organizations", testOrganizations},
+		{"Sessions", testSessions},
+		{"Invites", testInvites},
+		{"PasswordlessCodes", testPasswordlessCodes},
+		{"WebAuthnCredentials", testWebAuthnCredentials},
+		{"WebAuthnSessions", testWebAuthnSessions},
+		{"DeviceAuthorizations", testDeviceAuthorizations},
+		{"AuditEvents", testAuditEvents},
+		{"Transactions", testTransactions},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, s)
+		})
+	}
+}
+
+func testUsers(t *testing.T, s Storage) {
+	ctx := context.Background()
+	email := uniqueEmail()
+
+	id, err := s.SaveUser(ctx, models.DefaultOrgId, email, []byte("hash"))
+	require.NoError(t, err)
+	assert.Greater(t, id, int64(0))
+
+	_, err = s.SaveUser(ctx, models.DefaultOrgId, email, []byte("hash"))
+	assert.ErrorIs(t, err, storage.ErrUserExists)
+	_, err = s.SaveUser(ctx, -1, uniqueEmail(), []byte("hash"))
+	assert.ErrorIs(t, err, storage.ErrOrgNotFound)
+
+	user, err := s.GetUser(ctx, models.DefaultOrgId, email)
+	require.NoError(t, err)
+	assert.Equal(t, models.User{Id: id, OrgId: models.DefaultOrgId, Email: email, PaswordHash: []byte("hash")}, user)
+	byId, err := s.GetUserById(ctx, models.DefaultOrgId, id)
+	require.NoError(t, err)
+	assert.Equal(t, user, byId)
+
+	orgId, err := s.SaveOrganization(ctx, unique("org"))
+	require.NoError(t, err)
+	_, err = s.GetUser(ctx, orgId, email)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+	_, err = s.GetUserById(ctx, orgId, id)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	isAdmin, err := s.IsAdmin(ctx, models.DefaultOrgId, id)
+	require.NoError(t, err)
+	assert.False(t, isAdmin)
+	_, err = s.IsAdmin(ctx, orgId, id)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+	require.NoError(t, s.SetAdmin(ctx, models.DefaultOrgId, id, true))
+	isAdmin, err = s.IsAdmin(ctx, models.DefaultOrgId, id)
+	require.NoError(t, err)
+	assert.True(t, isAdmin)
+	assert.ErrorIs(t, s.SetAdmin(ctx, orgId, id, true), storage.ErrUserNotFound)
+
+	role, err := s.MemberRole(ctx, models.DefaultOrgId, id)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleMember, role)
+	require.NoError(t, s.SetMemberRole(ctx, models.DefaultOrgId, id, models.RoleAdmin))
+	role, err = s.MemberRole(ctx, models.DefaultOrgId, id)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleAdmin, role)
+	_, err = s.MemberRole(ctx, orgId, id)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+	assert.ErrorIs(t, s.SetMemberRole(ctx, orgId, id, models.RoleAdmin), storage.ErrUserNotFound)
+}
+
+func testApps(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	_, err := s.GetApp(ctx, 32000)
+	assert.ErrorIs(t, err, storage.ErrAppNotFound)
+
+	created := saveApp(t, s)
+	saved, err := s.GetApp(ctx, created.Id)
+	require.NoError(t, err)
+	assert.Equal(t, created, saved)
+	assert.ErrorIs(t, s.SaveApp(ctx, created), storage.ErrAppExists)
+	taken := created
+	taken.Id = 32000
+	assert.ErrorIs(t, s.SaveApp(ctx, taken), storage.ErrAppExists)
+	taken.Name = unique("app")
+	taken.Secret = unique("secret")
+	taken.OrgId = -1
+	assert.ErrorIs(t, s.SaveApp(ctx, taken), storage.ErrOrgNotFound)
+	// Sealed secrets differ even for equal plaintexts, so a unique
+	// secret can't be enforced and isn't.
+	twin := saveAppWithSecret(t, s, created.Secret)
+	saved, err = s.GetApp(ctx, twin.Id)
+	require.NoError(t, err)
+	assert.Equal(t, created.Secret, saved.Secret)
+
+	orgId, err := s.SaveOrganization(ctx, unique("org"))
+	require.NoError(t, err)
+	updated := models.App{Id: created.Id, OrgId: orgId, Name: unique("app"), Secret: "ignored", AllowAutoProvision: true}
+	require.NoError(t, s.UpdateApp(ctx, updated))
+	saved, err = s.GetApp(ctx, created.Id)
+	require.NoError(t, err)
+	updated.Secret = created.Secret
+	assert.Equal(t, updated, saved)
+	moved := updated
+
+	updated.Id = 32000
+	assert.ErrorIs(t, s.UpdateApp(ctx, updated), storage.ErrAppNotFound)
+	updated.Id = created.Id
+	updated.OrgId = -1
+	assert.ErrorIs(t, s.UpdateApp(ctx, updated), storage.ErrOrgNotFound)
+	twin.Name = updated.Name
+	assert.ErrorIs(t, s.UpdateApp(ctx, twin), storage.ErrAppExists)
+
+	require.NoError(t, s.SetAppSecret(ctx, created.Id, "rotated"))
+	saved, err = s.GetApp(ctx, created.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "rotated", saved.Secret)
+	assert.ErrorIs(t, s.SetAppSecret(ctx, 32000, "rotated"), storage.ErrAppNotFound)
+
+	// Only the updated app is in the new organization, and secrets
+	// are never listed.
+	apps, err := s.ListApps(ctx, orgId)
+	require.NoError(t, err)
+	moved.Secret = ""
+	assert.Equal(t, []models.App{moved}, apps)
+}
+
+func testOrganizations(t *testing.T, s Storage) {
+	ctx := context.Background()
+	name := unique("org")
+
+	id, err := s.SaveOrganization(ctx, name)
+	require.NoError(t, err)
+	_, err = s.SaveOrganization(ctx, name)
+	assert.ErrorIs(t, err, storage.ErrOrgExists)
+
+	org, err := s.GetOrganization(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, models.Organization{Id: id, Name: name}, org)
+	_, err = s.GetOrganization(ctx, -1)
+	assert.ErrorIs(t, err, storage.ErrOrgNotFound)
+
+	members, err := s.ListMembers(ctx, id)
+	require.NoError(t, err)
+	assert.Empty(t, members)
+	owner, err := s.SaveUser(ctx, id, "owner@gmail.com", []byte("hash"))
+	require.NoError(t, err)
+	require.NoError(t, s.SetMemberRole(ctx, id, owner, models.RoleOwner))
+	member, err := s.SaveUser(ctx, id, "member@gmail.com", []byte("hash"))
+	require.NoError(t, err)
+	members, err = s.ListMembers(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Member{
+		{UserId: owner, Email: "owner@gmail.com", Role: models.RoleOwner},
+		{UserId: member, Email: "member@gmail.com", Role: models.RoleMember},
+	}, members)
+}
+
+func testSessions(t *testing.T, s Storage) {
+	ctx := context.Background()
+	id := saveUser(t, s)
+
+	revokedAt, err := s.SessionsRevokedAt(ctx, models.DefaultOrgId, id)
+	require.NoError(t, err)
+	assert.True(t, revokedAt.IsZero())
+
+	now := time.Now().Truncate(time.Microsecond)
+	require.NoError(t, s.RevokeSessions(ctx, models.DefaultOrgId, id, now))
+	revokedAt, err = s.SessionsRevokedAt(ctx, models.DefaultOrgId, id)
+	require.NoError(t, err)
+	assert.True(t, now.Equal(revokedAt), "revoked at %s, want %s", revokedAt, now)
+
+	orgId, err := s.SaveOrganization(ctx, unique("org"))
+	require.NoError(t, err)
+	assert.ErrorIs(t, s.RevokeSessions(ctx, orgId, id, now), storage.ErrUserNotFound)
+	_, err = s.SessionsRevokedAt(ctx, orgId, id)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+}
+
+func testInvites(t *testing.T, s Storage) {
+	ctx := context.Background()
+	creator := saveUser(t, s)
+	invite := models.Invite{
+		OrgId:     models.DefaultOrgId,
+		Email:     uniqueEmail(),
+		Role:      models.RoleAdmin,
+		CreatedBy: creator,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	token := randomBytes(t)
+
+	id, err := s.SaveInvite(ctx, invite, token)
+	require.NoError(t, err)
+	invite.OrgId = -1
+	_, err = s.SaveInvite(ctx, invite, randomBytes(t))
+	assert.ErrorIs(t, err, storage.ErrOrgNotFound)
+
+	active, err := s.GetActiveInvite(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, id, active.Id)
+	assert.Equal(t, invite.Email, active.Email)
+	assert.True(t, active.AcceptedAt.IsZero())
+	accepted, err := s.ConsumeInvite(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, id, accepted.Id)
+	assert.Equal(t, models.DefaultOrgId, accepted.OrgId)
+	assert.Equal(t, models.RoleAdmin, accepted.Role)
+	assert.Equal(t, creator, accepted.CreatedBy)
+	assert.WithinDuration(t, time.Now(), accepted.AcceptedAt, time.Minute)
+	_, err = s.ConsumeInvite(ctx, token)
+	assert.ErrorIs(t, err, storage.ErrInviteNotFound)
+	_, err = s.GetActiveInvite(ctx, token)
+	assert.ErrorIs(t, err, storage.ErrInviteNotFound)
+
+	expired := randomBytes(t)
+	invite.OrgId = models.DefaultOrgId
+	invite.ExpiresAt = time.Now().Add(-time.Minute)
+	_, err = s.SaveInvite(ctx, invite, expired)
+	require.NoError(t, err)
+	_, err = s.ConsumeInvite(ctx, expired)
+	assert.ErrorIs(t, err, storage.ErrInviteNotFound)
+	_, err = s.GetActiveInvite(ctx, expired)
+	assert.ErrorIs(t, err, storage.ErrInviteNotFound)
+}
+
+func testPasswordlessCodes(t *testing.T, s Storage) {
+	ctx := context.Background()
+	appId := saveApp(t, s).Id
+	email := uniqueEmail()
+	code := models.PasswordlessCode{AppId: appId, Email: email, CodeHash: []byte("first"), ExpiresAt: time.Now().Add(time.Hour)}
+
+	_, err := s.GetActivePasswordlessCode(ctx, appId, email)
+	assert.ErrorIs(t, err, storage.ErrCodeNotFound)
+	first, err := s.SavePasswordlessCode(ctx, code)
+	require.NoError(t, err)
+	code.CodeHash = []byte("second")
+	second, err := s.SavePasswordlessCode(ctx, code)
+	require.NoError(t, err)
+	code.AppId = 32000
+	_, err = s.SavePasswordlessCode(ctx, code)
+	assert.ErrorIs(t, err, storage.ErrAppNotFound)
+
+	// A new code revokes the previous one.
+	assert.ErrorIs(t, s.MarkPasswordlessCodeUsed(ctx, first), storage.ErrCodeNotFound)
+	require.NoError(t, s.IncrementPasswordlessAttempts(ctx, second, 2))
+	active, err := s.GetActivePasswordlessCode(ctx, appId, email)
+	require.NoError(t, err)
+	assert.Equal(t, second, active.Id)
+	assert.Equal(t, []byte("second"), active.CodeHash)
+	assert.Equal(t, 1, active.Attempts)
+	require.NoError(t, s.IncrementPasswordlessAttempts(ctx, second, 2))
+	assert.ErrorIs(t, s.IncrementPasswordlessAttempts(ctx, second, 2), storage.ErrAttemptsExhausted)
+	active, err = s.GetActivePasswordlessCode(ctx, appId, email)
+	require.NoError(t, err)
+	assert.Equal(t, 2, active.Attempts)
+
+	require.NoError(t, s.MarkPasswordlessCodeUsed(ctx, second))
+	assert.ErrorIs(t, s.MarkPasswordlessCodeUsed(ctx, second), storage.ErrCodeNotFound)
+	_, err = s.GetActivePasswordlessCode(ctx, appId, email)
+	assert.ErrorIs(t, err, storage.ErrCodeNotFound)
+
+	expired := models.PasswordlessCode{AppId: appId, Email: email, CodeHash: []byte("old"), ExpiresAt: time.Now().Add(-time.Minute)}
+	_, err = s.SavePasswordlessCode(ctx, expired)
+	require.NoError(t, err)
+	_, err = s.GetActivePasswordlessCode(ctx, appId, email)
+	assert.ErrorIs(t, err, storage.ErrCodeNotFound)
+}
+
+func testWebAuthnCredentials(t *testing.T, s Storage) {
+	ctx := context.Background()
+	userId := saveUser(t, s)
+	cred := models.WebAuthnCredential{
+		Id:              randomBytes(t),
+		UserId:          userId,
+		PublicKey:       []byte("public key"),
+		AttestationType: "none",
+		AAGUID:          make([]byte, 16),
+		SignCount:       1,
+		Transports:      []string{"internal", "hybrid"},
+		BackupEligible:  true,
+	}
+
+	creds, err := s.ListWebAuthnCredentials(ctx, userId)
+	require.NoError(t, err)
+	assert.Empty(t, creds)
+	require.NoError(t, s.SaveWebAuthnCredential(ctx, cred))
+	assert.ErrorIs(t, s.SaveWebAuthnCredential(ctx, cred), storage.ErrCredentialExists)
+	other := cred
+	other.Id = randomBytes(t)
+	other.UserId = -1
+	assert.ErrorIs(t, s.SaveWebAuthnCredential(ctx, other), storage.ErrUserNotFound)
+
+	require.NoError(t, s.UpdateWebAuthnSignCount(ctx, cred.Id, 5, true))
+	assert.ErrorIs(t, s.UpdateWebAuthnSignCount(ctx, randomBytes(t), 5, true), storage.ErrCredentialNotFound)
+
+	creds, err = s.ListWebAuthnCredentials(ctx, userId)
+	require.NoError(t, err)
+	require.Len(t, creds, 1)
+	got := creds[0]
+	assert.Equal(t, cred.Id, got.Id)
+	assert.Equal(t, cred.PublicKey, got.PublicKey)
+	assert.Equal(t, cred.AAGUID, got.AAGUID)
+	assert.Equal(t, cred.Transports, got.Transports)
+	assert.Equal(t, uint32(5), got.SignCount)
+	assert.True(t, got.CloneWarning)
+	assert.True(t, got.BackupEligible)
+	assert.False(t, got.BackupState)
+	assert.WithinDuration(t, time.Now(), got.CreatedAt, time.Minute)
+	assert.WithinDuration(t, time.Now(), got.LastUsedAt, time.Minute)
+
+	assert.ErrorIs(t, s.DeleteWebAuthnCredential(ctx, userId+1, cred.Id), storage.ErrCredentialNotFound)
+	require.NoError(t, s.DeleteWebAuthnCredential(ctx, userId, cred.Id))
+	assert.ErrorIs(t, s.DeleteWebAuthnCredential(ctx, userId, cred.Id), storage.ErrCredentialNotFound)
+}
+
+func testWebAuthnSessions(t *testing.T, s Storage) {
+	ctx := context.Background()
+	appId := saveApp(t, s).Id
+	userId := saveUser(t, s)
+	hash := randomBytes(t)
+	session := models.WebAuthnSession{
+		UserId:    userId,
+		AppId:     appId,
+		Ceremony:  models.CeremonyLogin,
+		Data:      []byte(`{"challenge":"abc"}`),
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+
+	require.NoError(t, s.SaveWebAuthnSession(ctx, hash, session))
+	_, err := s.ConsumeWebAuthnSession(ctx, hash, models.CeremonyRegistration)
+	assert.ErrorIs(t, err, storage.ErrSessionNotFound)
+
+	got, err := s.ConsumeWebAuthnSession(ctx, hash, models.CeremonyLogin)
+	require.NoError(t, err)
+	assert.Equal(t, userId, got.UserId)
+	assert.Equal(t, appId, got.AppId)
+	assert.JSONEq(t, string(session.Data), string(got.Data))
+	assert.WithinDuration(t, session.ExpiresAt, got.ExpiresAt, time.Millisecond)
+	_, err = s.ConsumeWebAuthnSession(ctx, hash, models.CeremonyLogin)
+	assert.ErrorIs(t, err, storage.ErrSessionNotFound)
+
+	// Registration sessions are not bound to an app.
+	expired := randomBytes(t)
+	session.AppId = 0
+	session.Ceremony = models.CeremonyRegistration
+	session.ExpiresAt = time.Now().Add(-time.Minute)
+	require.NoError(t, s.SaveWebAuthnSession(ctx, expired, session))
+	_, err = s.ConsumeWebAuthnSession(ctx, expired, models.CeremonyRegistration)
+	assert.ErrorIs(t, err, storage.ErrSessionNotFound)
+}
+
+func testDeviceAuthorizations(t *testing.T, s Storage) {
+	ctx := context.Background()
+	appId := saveApp(t, s).Id
+	userId := saveUser(t, s)
+	auth := models.DeviceAuthorization{
+		DeviceCodeHash: randomBytes(t),
+		UserCode:       userCode(t),
+		AppId:          appId,
+		Interval:       5 * time.Second,
+		ExpiresAt:      time.Now().Add(time.Minute),
+	}
+
+	require.NoError(t, s.SaveDeviceAuthorization(ctx, auth))
+	assert.ErrorIs(t, s.SaveDeviceAuthorization(ctx, auth), storage.ErrDeviceCodeExists)
+	other := auth
+	other.DeviceCodeHash = randomBytes(t)
+	other.UserCode = userCode(t)
+	other.AppId = 32000
+	assert.ErrorIs(t, s.SaveDeviceAuthorization(ctx, other), storage.ErrAppNotFound)
+
+	got, err := s.GetDeviceAuthorization(ctx, auth.DeviceCodeHash)
+	require.NoError(t, err)
+	assert.Equal(t, auth.UserCode, got.UserCode)
+	assert.Equal(t, models.DeviceStatusPending, got.Status)
+	assert.Equal(t, 5*time.Second, got.Interval)
+	assert.Zero(t, got.UserId)
+	assert.True(t, got.LastPolledAt.IsZero())
+	_, err = s.GetDeviceAuthorization(ctx, randomBytes(t))
+	assert.ErrorIs(t, err, storage.ErrDeviceCodeNotFound)
+
+	polledAt := time.Now()
+	require.NoError(t, s.UpdateDevicePoll(ctx, auth.DeviceCodeHash, polledAt, 10*time.Second))
+	assert.ErrorIs(t, s.UpdateDevicePoll(ctx, randomBytes(t), polledAt, time.Second), storage.ErrDeviceCodeNotFound)
+	got, err = s.GetDeviceAuthorizationByUserCode(ctx, auth.UserCode)
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, got.Interval)
+	assert.WithinDuration(t, polledAt, got.LastPolledAt, time.Millisecond)
+	_, err = s.GetDeviceAuthorizationByUserCode(ctx, userCode(t))
+	assert.ErrorIs(t, err, storage.ErrDeviceCodeNotFound)
+
+	assert.ErrorIs(t, s.ConsumeDeviceAuthorization(ctx, auth.DeviceCodeHash), storage.ErrDeviceCodeNotFound)
+	require.NoError(t, s.ResolveDeviceAuthorization(ctx, auth.UserCode, userId, models.DeviceStatusApproved))
+	err = s.ResolveDeviceAuthorization(ctx, auth.UserCode, userId, models.DeviceStatusDenied)
+	assert.ErrorIs(t, err, storage.ErrDeviceCodeNotFound)
+	require.NoError(t, s.ConsumeDeviceAuthorization(ctx, auth.DeviceCodeHash))
+	assert.ErrorIs(t, s.ConsumeDeviceAuthorization(ctx, auth.DeviceCodeHash), storage.ErrDeviceCodeNotFound)
+	got, err = s.GetDeviceAuthorization(ctx, auth.DeviceCodeHash)
+	require.NoError(t, err)
+	assert.Equal(t, userId, got.UserId)
+	assert.Equal(t, models.DeviceStatusConsumed, got.Status)
+
+	expired := auth
+	expired.DeviceCodeHash = randomBytes(t)
+	expired.UserCode = userCode(t)
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	require.NoError(t, s.SaveDeviceAuthorization(ctx, expired))
+	err = s.ResolveDeviceAuthorization(ctx, expired.UserCode, userId, models.DeviceStatusApproved)
+	assert.ErrorIs(t, err, storage.ErrDeviceCodeNotFound)
+}
+
+func testAuditEvents(t *testing.T, s Storage) {
+	ctx := context.Background()
+	appId := saveApp(t, s).Id
+	event := models.AuditEvent{
+		OrgId:        models.DefaultOrgId,
+		ActorId:      1,
+		TargetUserId: 2,
+		AppId:        appId,
+		Action:       models.AuditActionImpersonate,
+		Reason:       "conformance",
+	}
+
+	first, err := s.SaveAuditEvent(ctx, event)
+	require.NoError(t, err)
+	second, err := s.SaveAuditEvent(ctx, event)
+	require.NoError(t, err)
+	assert.Greater(t, second, first)
+
+	event.OrgId = -1
+	_, err = s.SaveAuditEvent(ctx, event)
+	assert.Error(t, err)
+}
+
+func testTransactions(t *testing.T, s Storage) {
+	ctx := context.Background()
+	errRollback := errors.New("rollback")
+
+	committed := uniqueEmail()
+	err := s.WithinTx(ctx, func(ctx context.Context) error {
+		userId, err := s.SaveUser(ctx, models.DefaultOrgId, committed, []byte("hash"))
+		if err != nil {
+			return err
+		}
+		return s.SetMemberRole(ctx, models.DefaultOrgId, userId, models.RoleOwner)
+	})
+	require.NoError(t, err)
+	user, err := s.GetUser(ctx, models.DefaultOrgId, committed)
+	require.NoError(t, err)
+	role, err := s.MemberRole(ctx, models.DefaultOrgId, user.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleOwner, role)
+
+	rolledBack := uniqueEmail()
+	err = s.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := s.SaveUser(ctx, models.DefaultOrgId, rolledBack, []byte("hash")); err != nil {
+			return err
+		}
+		if _, err := s.GetUser(ctx, models.DefaultOrgId, rolledBack); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	assert.ErrorIs(t, err, errRollback)
+	_, err = s.GetUser(ctx, models.DefaultOrgId, rolledBack)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	// A nested transaction joins the outer one and rolls back with it.
+	nested := uniqueEmail()
+	err = s.WithinTx(ctx, func(ctx context.Context) error {
+		err := s.WithinTx(ctx, func(ctx context.Context) error {
+			_, err := s.SaveUser(ctx, models.DefaultOrgId, nested, []byte("hash"))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return errRollback
+	})
+	assert.ErrorIs(t, err, errRollback)
+	_, err = s.GetUser(ctx, models.DefaultOrgId, nested)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	// A storage error inside the transaction keeps its type.
+	err = s.WithinTx(ctx, func(ctx context.Context) error {
+		_, err := s.SaveUser(ctx, models.DefaultOrgId, committed, []byte("hash"))
+		return err
+	})
+	assert.ErrorIs(t, err, storage.ErrUserExists)
+}
+
+func saveUser(t *testing.T, s Storage) int64 {
+	t.Helper()
+	id, err := s.SaveUser(context.Background(), models.DefaultOrgId, uniqueEmail(), []byte("hash"))
+	require.NoError(t, err)
+	return id
+}
+
+// saveApp creates an app with a random id, as apps are never deleted
+// from a shared database.
+func saveApp(t *testing.T, s Storage) models.App {
+	t.Helper()
+	return saveAppWithSecret(t, s, unique("secret"))
+}
+
+func saveAppWithSecret(t *testing.T, s Storage, secret string) models.App {
+	t.Helper()
+	for {
+		n, err := rand.Int(rand.Reader, big.NewInt(30000))
+		require.NoError(t, err)
+		app := models.App{Id: 1000 + int(n.Int64()), OrgId: models.DefaultOrgId, Name: unique("app"), Secret: secret}
+		err = s.SaveApp(context.Background(), app)
+		if errors.Is(err, storage.ErrAppExists) {
+			continue
+		}
+		require.NoError(t, err)
+		return app
+	}
+}
+
+func unique(prefix string) string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return prefix + "-" + hex.EncodeToString(b)
+}
+
+func uniqueEmail() string {
+	return unique("conformance") + "@gmail.com"
+}
+
+// userCode returns a random code in the XXXX-XXXX form of the device flow.
+func userCode(t *testing.T) string {
+	t.Helper()
+	code := strings.ToUpper(hex.EncodeToString(randomBytes(t)[:4]))
+	return fmt.Sprintf("%s-%s", code[:4], code[4:])
+}
+
+func randomBytes(t *testing.T) []byte {
+	t.Helper()
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	require.NoError(t, err)
+	return b
+}
@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// TestServerContinuesIncomingTrace проверяет, что span
+// сервера продолжает trace, пришедший от клиента.
+func TestServerContinuesIncomingTrace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(ServerOption())
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "client")
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	parent.End()
+
+	var serverSpan *tracetest.SpanStub
+	for _, span := range exporter.GetSpans() {
+		if span.Name == "grpc.health.v1.Health/Check" && span.SpanKind.String() == "server" {
+			serverSpan = &span
+		}
+	}
+	require.NotNil(t, serverSpan)
+	assert.Equal(t, parent.SpanContext().TraceID(), serverSpan.SpanContext.TraceID())
+	assert.True(t, serverSpan.Parent.IsRemote())
+}
+
+// TestNewRejectsUnknownExporter проверяет, что опечатка
+// в конфиге не отключает трассировку молча.
+func TestNewRejectsUnknownExporter(t *testing.T) {
+	_, err := New(context.Background(), Options{Exporter: "jaeger"})
+
+	assert.Error(t, err)
+}
+
+// TestNoneExporterDoesNotSample проверяет, что с
+// экспортером none span не записываются.
+func TestNoneExporterDoesNotSample(t *testing.T) {
+	provider, err := New(context.Background(), Options{Exporter: ExporterNone, SampleRatio: 1})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+
+	_, span := provider.Tracer("test").Start(context.Background(), "span")
+	defer span.End()
+
+	assert.False(t, span.SpanContext().IsSampled())
+}
@@ -1,19 +1,25 @@
 package jwt
 
 import (
+	"errors"
+	"fmt"
 	"sso/interanal/domain/models"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
 func NewToken(user models.User, app models.App, ttl time.Duration) (string, error) {
+	now := time.Now()
 	token := jwt.New(jwt.SigningMethodHS256)
 	claims := token.Claims.(jwt.MapClaims)
 	claims["uid"] = user.Id
 	claims["email"] = user.Email
-	claims["exp"] = time.Now().Add(ttl).Unix()
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
 	claims["app_id"] = app.Id
+	claims["org_id"] = app.OrgId
 
 	signedToken, err := token.SignedString([]byte(app.Secret))
 	if err != nil {
@@ -21,3 +27,91 @@ func NewToken(user models.User, app models.App, ttl time.Duration) (string, erro
 	}
 	return signedToken, nil
 }
+
+// NewImpersonationToken issues a token for user that carries the acting
+// admin in the "act" claim, as defined in RFC 8693, section 4.1.
+func NewImpersonationToken(user models.User, actor models.User, app models.App, ttl time.Duration) (string, error) {
+	now := time.Now()
+	token := jwt.New(jwt.SigningMethodHS256)
+	claims := token.Claims.(jwt.MapClaims)
+	claims["uid"] = user.Id
+	claims["email"] = user.Email
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+	claims["app_id"] = app.Id
+	claims["org_id"] = app.OrgId
+	claims["act"] = map[string]any{
+		"sub":   strconv.FormatInt(actor.Id, 10),
+		"email": actor.Email,
+	}
+
+	signedToken, err := token.SignedString([]byte(app.Secret))
+	if err != nil {
+		return "", err
+	}
+	return signedToken, nil
+}
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims are what Verify reads from a token of NewToken.
+type Claims struct {
+	UserId int64
+	Email  string
+	AppId  int
+	OrgId  int64
+	// IssuedAt is the iat claim, zero for tokens issued before it was
+	// added.
+	IssuedAt time.Time
+	// Impersonated is set for tokens of NewImpersonationToken.
+	Impersonated bool
+}
+
+// AppId returns the app_id claim without verifying the token, so the
+// caller can look up the secret that Verify needs.
+func AppId(token string) (int, error) {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	appId, ok := claims["app_id"].(float64)
+	if !ok {
+		return 0, fmt.Errorf("%w: no app_id claim", ErrInvalidToken)
+	}
+	return int(appId), nil
+}
+
+// Verify checks the signature of token with the app secret and its
+// expiry, and returns its claims.
+func Verify(token string, secret string) (Claims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	).ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+		return []byte(secret), nil
+	})
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	userId, okUser := claims["uid"].(float64)
+	appId, okApp := claims["app_id"].(float64)
+	orgId, okOrg := claims["org_id"].(float64)
+	if !okUser || !okApp || !okOrg {
+		return Claims{}, fmt.Errorf("%w: missing claims", ErrInvalidToken)
+	}
+	email, _ := claims["email"].(string)
+	var issuedAt time.Time
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		issuedAt = iat.Time
+	}
+	_, impersonated := claims["act"]
+	return Claims{
+		UserId:       int64(userId),
+		Email:        email,
+		AppId:        int(appId),
+		OrgId:        int64(orgId),
+		IssuedAt:     issuedAt,
+		Impersonated: impersonated,
+	}, nil
+}
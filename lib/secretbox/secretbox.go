@@ -0,0 +1,147 @@
+// Package secretbox encrypts values stored at rest with AES-256-GCM
+// under a set of master keys that can be rotated.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix marks encrypted values, so plaintext left from before
+// encryption was enabled is still recognized.
+const prefix = "enc:v1:"
+
+const KeySize = 32
+
+var (
+	ErrMalformed  = errors.New("malformed encrypted value")
+	ErrUnknownKey = errors.New("unknown key")
+)
+
+type Key struct {
+	Id    string
+	Value []byte
+}
+
+// ParseKey parses a key in the "id:base64" form used in the config.
+func ParseKey(s string) (Key, error) {
+	id, encoded, ok := strings.Cut(s, ":")
+	if !ok || id == "" {
+		return Key{}, errors.New(`key must have the form "id:base64"`)
+	}
+	value, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return Key{}, fmt.Errorf("key %q: %w", id, err)
+	}
+	if len(value) != KeySize {
+		return Key{}, fmt.Errorf("key %q: must be %d bytes, got %d", id, KeySize, len(value))
+	}
+	return Key{Id: id, Value: value}, nil
+}
+
+// Keyring encrypts with its first key and decrypts with any of them.
+type Keyring struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+}
+
+func NewKeyring(keys ...Key) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one key is required")
+	}
+	k := &Keyring{primary: keys[0].Id, aeads: make(map[string]cipher.AEAD, len(keys))}
+	for _, key := range keys {
+		if _, ok := k.aeads[key.Id]; ok {
+			return nil, fmt.Errorf("duplicate key %q", key.Id)
+		}
+		block, err := aes.NewCipher(key.Value)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", key.Id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", key.Id, err)
+		}
+		k.aeads[key.Id] = aead
+	}
+	return k, nil
+}
+
+// ParseKeyring builds a keyring from keys in the "id:base64" form.
+func ParseKeyring(specs []string) (*Keyring, error) {
+	keys := make([]Key, 0, len(specs))
+	for _, spec := range specs {
+		key, err := ParseKey(spec)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewKeyring(keys...)
+}
+
+// Encrypt returns "enc:v1:<key id>:<base64 nonce and ciphertext>".
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	aead := k.aeads[k.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	// The key id is authenticated, so a value can't be moved under another key.
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(k.primary))
+	return prefix + k.primary + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt with any key of the ring.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	id, sealed, err := split(value)
+	if err != nil {
+		return "", err
+	}
+	aead, ok := k.aeads[id]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", ErrMalformed
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation reports whether value is plaintext or encrypted
+// with a key other than the primary one.
+func (k *Keyring) NeedsRotation(value string) bool {
+	id, _, err := split(value)
+	return err != nil || id != k.primary
+}
+
+// IsEncrypted reports whether value was produced by Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+func split(value string) (string, []byte, error) {
+	rest, ok := strings.CutPrefix(value, prefix)
+	if !ok {
+		return "", nil, ErrMalformed
+	}
+	id, encoded, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", nil, ErrMalformed
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, ErrMalformed
+	}
+	return id, sealed, nil
+}
@@ -0,0 +1,91 @@
+package secretbox
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEncryptDecrypt проверяет, что зашифрованное
+// значение расшифровывается и не содержит открытый текст.
+func TestEncryptDecrypt(t *testing.T) {
+	k := newKeyring(t, key("k1", 1))
+
+	value, err := k.Encrypt("test-secret")
+	require.NoError(t, err)
+
+	assert.True(t, IsEncrypted(value))
+	assert.NotContains(t, value, "test-secret")
+	plaintext, err := k.Decrypt(value)
+	require.NoError(t, err)
+	assert.Equal(t, "test-secret", plaintext)
+}
+
+// TestRotation проверяет, что после добавления нового ключа
+// старые значения читаются и помечаются для перешифрования.
+func TestRotation(t *testing.T) {
+	old := newKeyring(t, key("k1", 1))
+	value, err := old.Encrypt("test-secret")
+	require.NoError(t, err)
+
+	rotated := newKeyring(t, key("k2", 2), key("k1", 1))
+
+	assert.True(t, rotated.NeedsRotation(value))
+	assert.True(t, rotated.NeedsRotation("plaintext"))
+	plaintext, err := rotated.Decrypt(value)
+	require.NoError(t, err)
+	assert.Equal(t, "test-secret", plaintext)
+
+	value, err = rotated.Encrypt(plaintext)
+	require.NoError(t, err)
+	assert.False(t, rotated.NeedsRotation(value))
+	_, err = old.Decrypt(value)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+// TestDecryptRejectsTampering проверяет, что измененное
+// значение или подмена ключа не расшифровываются.
+func TestDecryptRejectsTampering(t *testing.T) {
+	k := newKeyring(t, key("k1", 1), key("k2", 2))
+	value, err := k.Encrypt("test-secret")
+	require.NoError(t, err)
+
+	i := len(value) - 10
+	flipped := byte('A')
+	if value[i] == 'A' {
+		flipped = 'B'
+	}
+	_, err = k.Decrypt(value[:i] + string(flipped) + value[i+1:])
+	assert.ErrorIs(t, err, ErrMalformed)
+	_, err = k.Decrypt("enc:v1:k2:" + value[len("enc:v1:k1:"):])
+	assert.ErrorIs(t, err, ErrMalformed)
+	_, err = k.Decrypt("test-secret")
+	assert.ErrorIs(t, err, ErrMalformed)
+}
+
+// TestParseKey проверяет разбор ключа из конфига.
+func TestParseKey(t *testing.T) {
+	parsed, err := ParseKey(key("k1", 1))
+	require.NoError(t, err)
+	assert.Equal(t, "k1", parsed.Id)
+	assert.Len(t, parsed.Value, KeySize)
+
+	_, err = ParseKey("k1")
+	assert.Error(t, err)
+	_, err = ParseKey("k1:" + base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.Error(t, err)
+}
+
+func key(id string, fill byte) string {
+	return id + ":" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{fill}, KeySize))
+}
+
+func newKeyring(t *testing.T, specs ...string) *Keyring {
+	t.Helper()
+	k, err := ParseKeyring(specs)
+	require.NoError(t, err)
+	return k
+}
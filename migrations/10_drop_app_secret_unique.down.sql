@@ -0,0 +1 @@
+alter table app add constraint app_secret_key unique (secret);
@@ -0,0 +1,3 @@
+-- Sealed secrets have a random nonce, so equal secrets never collide
+-- and the constraint checks nothing.
+alter table app drop constraint if exists app_secret_key;
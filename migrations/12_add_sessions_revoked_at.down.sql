@@ -0,0 +1,2 @@
+alter table "user"
+    drop column if exists sessions_revoked_at;
@@ -0,0 +1,15 @@
+alter table app
+    drop column org_id;
+
+drop table if exists membership;
+
+alter table "user"
+    drop constraint if exists user_org_id_user_id_key;
+alter table "user"
+    drop constraint if exists user_org_id_email_key;
+alter table "user"
+    drop column org_id;
+alter table "user"
+    add constraint user_email_key unique (email);
+
+drop table if exists organization;
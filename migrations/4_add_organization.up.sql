@@ -0,0 +1,39 @@
+create table if not exists organization (
+    org_id bigint generated by default as identity primary key,
+    name varchar(100) not null unique
+);
+
+insert into organization (org_id, name)
+values
+(1, 'default')
+on conflict do nothing;
+
+select setval(pg_get_serial_sequence('organization', 'org_id'), (select max(org_id) from organization));
+
+alter table "user"
+    add column org_id bigint not null default 1 references organization (org_id) on delete cascade;
+alter table "user"
+    alter column org_id drop default;
+alter table "user"
+    drop constraint if exists user_email_key;
+alter table "user"
+    add constraint user_org_id_email_key unique (org_id, email);
+alter table "user"
+    add constraint user_org_id_user_id_key unique (org_id, user_id);
+
+create table if not exists membership (
+    org_id bigint not null,
+    user_id bigint not null,
+    role varchar(20) not null default 'member' check (role in ('owner', 'admin', 'member')),
+    primary key (org_id, user_id),
+    foreign key (org_id, user_id) references "user" (org_id, user_id) on delete cascade
+);
+
+insert into membership (org_id, user_id, role)
+select org_id, user_id, 'member' from "user"
+on conflict do nothing;
+
+alter table app
+    add column org_id bigint not null default 1 references organization (org_id) on delete cascade;
+alter table app
+    alter column org_id drop default;
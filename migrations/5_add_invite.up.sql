@@ -0,0 +1,12 @@
+create table if not exists invite (
+    invite_id bigint generated always as identity primary key,
+    org_id bigint not null references organization (org_id) on delete cascade,
+    email varchar(320) not null,
+    role varchar(20) not null default 'member' check (role in ('owner', 'admin', 'member')),
+    token_hash bytea not null unique,
+    created_by bigint not null references "user" (user_id) on delete cascade,
+    expires_at timestamptz not null,
+    accepted_at timestamptz
+);
+
+create index if not exists invite_org_id_email_idx on invite (org_id, email);
@@ -0,0 +1,14 @@
+alter table app
+    add column allow_auto_provision boolean not null default false;
+
+create table if not exists passwordless_code (
+    code_id bigint generated always as identity primary key,
+    app_id smallint not null references app (app_id) on delete cascade,
+    email varchar(320) not null,
+    code_hash bytea not null,
+    attempts int not null default 0,
+    expires_at timestamptz not null,
+    used_at timestamptz
+);
+
+create index if not exists passwordless_code_app_id_email_idx on passwordless_code (app_id, email);
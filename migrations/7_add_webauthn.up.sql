@@ -0,0 +1,25 @@
+create table if not exists webauthn_credential (
+    credential_id bytea primary key,
+    user_id bigint not null references "user" (user_id) on delete cascade,
+    public_key bytea not null,
+    attestation_type varchar(32) not null,
+    aaguid bytea not null,
+    sign_count bigint not null default 0,
+    clone_warning boolean not null default false,
+    transports text[] not null default '{}',
+    backup_eligible boolean not null default false,
+    backup_state boolean not null default false,
+    created_at timestamptz not null default now(),
+    last_used_at timestamptz
+);
+
+create index if not exists webauthn_credential_user_id_idx on webauthn_credential (user_id);
+
+create table if not exists webauthn_session (
+    session_hash bytea primary key,
+    user_id bigint not null references "user" (user_id) on delete cascade,
+    app_id smallint references app (app_id) on delete cascade,
+    ceremony varchar(16) not null check (ceremony in ('registration', 'login')),
+    data jsonb not null,
+    expires_at timestamptz not null
+);
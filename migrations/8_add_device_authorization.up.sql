@@ -0,0 +1,10 @@
+create table if not exists device_authorization (
+    device_code_hash bytea primary key,
+    user_code varchar(9) not null unique,
+    app_id smallint not null references app (app_id) on delete cascade,
+    user_id bigint references "user" (user_id) on delete cascade,
+    status varchar(16) not null default 'pending' check (status in ('pending', 'approved', 'denied', 'consumed')),
+    interval_seconds int not null,
+    last_polled_at timestamptz,
+    expires_at timestamptz not null
+);
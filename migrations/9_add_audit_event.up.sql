@@ -0,0 +1,12 @@
+create table if not exists audit_event (
+    event_id bigint generated always as identity primary key,
+    org_id bigint not null references organization (org_id) on delete cascade,
+    actor_id bigint not null,
+    target_user_id bigint,
+    app_id smallint,
+    action varchar(64) not null,
+    reason text not null default '',
+    created_at timestamptz not null default now()
+);
+
+create index if not exists audit_event_org_id_created_at_idx on audit_event (org_id, created_at);
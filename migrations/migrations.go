@@ -0,0 +1,16 @@
+// Package migrations embeds the SQL migrations, so the service can
+// apply them on startup without the files next to the binary.
+package migrations
+
+import "embed"
+
+// FS holds the postgres migrations in its root and the sqlite ones
+// in SQLiteDir.
+//
+//go:embed *.sql sqlite/*.sql
+var FS embed.FS
+
+const (
+	PostgresDir = "."
+	SQLiteDir   = "sqlite"
+)
@@ -0,0 +1,11 @@
+create table app_old (
+    app_id integer primary key,
+    org_id integer not null references organization (org_id) on delete cascade,
+    name text not null unique,
+    secret text not null unique,
+    allow_auto_provision boolean not null default false
+);
+insert into app_old (app_id, org_id, name, secret, allow_auto_provision)
+select app_id, org_id, name, secret, allow_auto_provision from app;
+drop table app;
+alter table app_old rename to app;
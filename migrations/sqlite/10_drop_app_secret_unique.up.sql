@@ -0,0 +1,14 @@
+-- Sealed secrets have a random nonce, so equal secrets never collide
+-- and the constraint checks nothing. SQLite can't drop it in place,
+-- so the table is rebuilt.
+create table app_new (
+    app_id integer primary key,
+    org_id integer not null references organization (org_id) on delete cascade,
+    name text not null unique,
+    secret text not null,
+    allow_auto_provision boolean not null default false
+);
+insert into app_new (app_id, org_id, name, secret, allow_auto_provision)
+select app_id, org_id, name, secret, allow_auto_provision from app;
+drop table app;
+alter table app_new rename to app;
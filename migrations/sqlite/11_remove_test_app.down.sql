@@ -0,0 +1,4 @@
+insert into app (app_id, org_id, name, secret)
+values
+(1, 1, 'test', 'test-secret')
+on conflict do nothing;
@@ -0,0 +1,2 @@
+alter table "user"
+    drop column sessions_revoked_at;
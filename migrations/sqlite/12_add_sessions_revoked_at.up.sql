@@ -0,0 +1,3 @@
+-- Tokens of a user issued up to sessions_revoked_at are rejected.
+alter table "user"
+    add column sessions_revoked_at integer;
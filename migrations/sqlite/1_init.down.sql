@@ -0,0 +1,2 @@
+drop table if exists app;
+drop table if exists "user";
@@ -0,0 +1,12 @@
+-- Timestamps are unix microseconds, the precision of postgres timestamptz.
+create table if not exists "user" (
+    user_id integer primary key autoincrement,
+    email text not null unique,
+    pass_hash blob not null
+);
+
+create table if not exists app (
+    app_id integer primary key,
+    name text not null unique,
+    secret text not null unique
+);
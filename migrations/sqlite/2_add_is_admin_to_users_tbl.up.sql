@@ -0,0 +1,2 @@
+alter table "user"
+    add column is_admin boolean not null default false;
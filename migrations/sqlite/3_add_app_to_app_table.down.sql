@@ -0,0 +1 @@
+delete from app where app_id = 1;
\ No newline at end of file
@@ -0,0 +1,24 @@
+create table app_old (
+    app_id integer primary key,
+    name text not null unique,
+    secret text not null unique
+);
+insert into app_old (app_id, name, secret)
+select app_id, name, secret from app;
+drop table app;
+alter table app_old rename to app;
+
+drop table if exists membership;
+
+create table user_old (
+    user_id integer primary key autoincrement,
+    email text not null unique,
+    pass_hash blob not null,
+    is_admin boolean not null default false
+);
+insert into user_old (user_id, email, pass_hash, is_admin)
+select user_id, email, pass_hash, is_admin from "user";
+drop table "user";
+alter table user_old rename to "user";
+
+drop table if exists organization;
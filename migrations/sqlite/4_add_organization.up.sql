@@ -0,0 +1,46 @@
+create table if not exists organization (
+    org_id integer primary key autoincrement,
+    name text not null unique
+);
+
+insert into organization (org_id, name)
+values
+(1, 'default')
+on conflict do nothing;
+
+-- SQLite can't change constraints in place, so the tables are rebuilt.
+create table user_new (
+    user_id integer primary key autoincrement,
+    org_id integer not null references organization (org_id) on delete cascade,
+    email text not null,
+    pass_hash blob not null,
+    is_admin boolean not null default false,
+    unique (org_id, email),
+    unique (org_id, user_id)
+);
+insert into user_new (user_id, org_id, email, pass_hash, is_admin)
+select user_id, 1, email, pass_hash, is_admin from "user";
+drop table "user";
+alter table user_new rename to "user";
+
+create table if not exists membership (
+    org_id integer not null,
+    user_id integer not null,
+    role text not null default 'member' check (role in ('owner', 'admin', 'member')),
+    primary key (org_id, user_id),
+    foreign key (org_id, user_id) references "user" (org_id, user_id) on delete cascade
+);
+
+insert into membership (org_id, user_id, role)
+select org_id, user_id, 'member' from "user";
+
+create table app_new (
+    app_id integer primary key,
+    org_id integer not null references organization (org_id) on delete cascade,
+    name text not null unique,
+    secret text not null unique
+);
+insert into app_new (app_id, org_id, name, secret)
+select app_id, 1, name, secret from app;
+drop table app;
+alter table app_new rename to app;
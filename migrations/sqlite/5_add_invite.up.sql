@@ -0,0 +1,12 @@
+create table if not exists invite (
+    invite_id integer primary key autoincrement,
+    org_id integer not null references organization (org_id) on delete cascade,
+    email text not null,
+    role text not null default 'member' check (role in ('owner', 'admin', 'member')),
+    token_hash blob not null unique,
+    created_by integer not null references "user" (user_id) on delete cascade,
+    expires_at integer not null,
+    accepted_at integer
+);
+
+create index if not exists invite_org_id_email_idx on invite (org_id, email);
@@ -0,0 +1,4 @@
+drop table if exists passwordless_code;
+
+alter table app
+    drop column allow_auto_provision;
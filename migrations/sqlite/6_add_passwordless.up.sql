@@ -0,0 +1,14 @@
+alter table app
+    add column allow_auto_provision boolean not null default false;
+
+create table if not exists passwordless_code (
+    code_id integer primary key autoincrement,
+    app_id integer not null references app (app_id) on delete cascade,
+    email text not null,
+    code_hash blob not null,
+    attempts integer not null default 0,
+    expires_at integer not null,
+    used_at integer
+);
+
+create index if not exists passwordless_code_app_id_email_idx on passwordless_code (app_id, email);
@@ -0,0 +1,2 @@
+drop table if exists webauthn_session;
+drop table if exists webauthn_credential;
@@ -0,0 +1,26 @@
+create table if not exists webauthn_credential (
+    credential_id blob primary key,
+    user_id integer not null references "user" (user_id) on delete cascade,
+    public_key blob not null,
+    attestation_type text not null,
+    aaguid blob not null,
+    sign_count integer not null default 0,
+    clone_warning boolean not null default false,
+    -- JSON array, SQLite has no array type.
+    transports text not null default '[]',
+    backup_eligible boolean not null default false,
+    backup_state boolean not null default false,
+    created_at integer not null,
+    last_used_at integer
+);
+
+create index if not exists webauthn_credential_user_id_idx on webauthn_credential (user_id);
+
+create table if not exists webauthn_session (
+    session_hash blob primary key,
+    user_id integer not null references "user" (user_id) on delete cascade,
+    app_id integer references app (app_id) on delete cascade,
+    ceremony text not null check (ceremony in ('registration', 'login')),
+    data text not null,
+    expires_at integer not null
+);
@@ -0,0 +1,10 @@
+create table if not exists device_authorization (
+    device_code_hash blob primary key,
+    user_code text not null unique,
+    app_id integer not null references app (app_id) on delete cascade,
+    user_id integer references "user" (user_id) on delete cascade,
+    status text not null default 'pending' check (status in ('pending', 'approved', 'denied', 'consumed')),
+    interval_seconds integer not null,
+    last_polled_at integer,
+    expires_at integer not null
+);
@@ -0,0 +1,12 @@
+create table if not exists audit_event (
+    event_id integer primary key autoincrement,
+    org_id integer not null references organization (org_id) on delete cascade,
+    actor_id integer not null,
+    target_user_id integer,
+    app_id integer,
+    action text not null,
+    reason text not null default '',
+    created_at integer not null
+);
+
+create index if not exists audit_event_org_id_created_at_idx on audit_event (org_id, created_at);
@@ -18,6 +18,7 @@ import (
 const (
 	emptyAppId     = 0
 	appId          = 1
+	defaultOrgId   = 1
 	appSecret      = "test-secret"
 	passDefaultLen = 10
 )
@@ -53,6 +54,7 @@ func TestSuccessLogin(t *testing.T) {
 	assert.Equal(t, resRegister.GetUserId(), int64(claims["uid"].(float64)))
 	assert.Equal(t, email, claims["email"].(string))
 	assert.Equal(t, appId, int(claims["app_id"].(float64)))
+	assert.EqualValues(t, defaultOrgId, int64(claims["org_id"].(float64)))
 	const deltaSeconds = 1
 
 	assert.InDelta(t, loginTime.Add(st.Cfg.TokenTTL).Unix(), claims["exp"].(float64), deltaSeconds)
@@ -4,16 +4,22 @@ import (
 	"context"
 	"net"
 	"sso/interanal/config"
+	authgrpc "sso/interanal/grpc/auth"
 	"strconv"
 	"testing"
 
 	ssov1 "github.com/sariya23/sso_proto/gen/sso"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 )
 
 const (
 	grpcHost = "localhost"
+	// appId is the test app of config/seed.dev.yaml, see make
+	// bootstrap_dev. Register and IsAdmin take the organization
+	// from it.
+	appId = "1"
 )
 
 type Suite struct {
@@ -27,7 +33,8 @@ func New(t *testing.T) (context.Context, *Suite) {
 	t.Parallel()
 
 	cfg := config.MustLoadByPath("../config/local.yaml")
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.GRPC.Timeout)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), authgrpc.AppIdMetadataKey, appId)
+	ctx, cancel := context.WithTimeout(ctx, cfg.GRPC.Timeout)
 	t.Cleanup(func() {
 		t.Helper()
 		cancel()